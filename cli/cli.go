@@ -0,0 +1,228 @@
+// Package cli 实现命令行子命令：通过服务端API提交与管理转换任务的客户端，以及无需启动服务端的镜像同步
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 退出码
+const (
+	ExitOK            = 0
+	ExitError         = 1 // 请求失败等一般错误
+	ExitUsage         = 2 // 命令或参数错误
+	ExitTaskFailed    = 3 // 任务执行失败
+	ExitTaskCancelled = 4 // 任务被取消或因服务关闭中断
+)
+
+// command 子命令，subs非空时为命令组
+type command struct {
+	name    string
+	args    string // 位置参数说明
+	summary string
+	run     func(a *app, path string, args []string) error
+	subs    []command
+}
+
+// commands 返回全部子命令
+func commands() []command {
+	return []command{
+		{name: "login", summary: "验证Token并保存服务端地址与凭据", run: runLogin},
+		{name: "transfer", args: "<源镜像>", summary: "创建转换任务，--wait 时等待任务结束", run: runTransfer},
+		{name: "promote", args: "<镜像> <标签>...", summary: "在同一仓库中为已有镜像添加标签，--wait 时等待任务结束", run: runPromote},
+		{name: "tasks", summary: "查看与管理转换任务", subs: []command{
+			{name: "list", summary: "列出执行中、排队中与最近完成的任务", run: runTasksList},
+			{name: "get", args: "<任务ID>", summary: "查看任务详情", run: runTasksGet},
+			{name: "cancel", args: "<任务ID>", summary: "取消任务", run: runTasksCancel},
+			{name: "logs", args: "<任务ID>", summary: "输出任务执行过程，--follow 时持续输出直到任务结束", run: runTasksLogs},
+		}},
+		{name: "registry", summary: "查看与测试目标仓库配置", subs: []command{
+			{name: "list", summary: "列出仓库配置", run: runRegistryList},
+			{name: "test", args: "<配置名称或ID>", summary: "测试仓库连接与推送权限", run: runRegistryTest},
+		}},
+		{name: "history", summary: "转换历史", subs: []command{
+			{name: "export", summary: "按条件导出历史记录", run: runHistoryExport},
+		}},
+		{name: "sync", args: "-f <同步文件>", summary: "不启动服务端，按同步文件直接转换镜像", run: runSync},
+	}
+}
+
+// IsCommand 判断参数是否为客户端子命令，用于区分服务端启动参数
+func IsCommand(name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run 执行子命令并返回退出码
+func Run(args []string) int {
+	a := &app{in: os.Stdin, out: os.Stdout, errOut: os.Stderr}
+	if len(args) == 0 || args[0] == "help" {
+		a.printUsage("", commands())
+		return ExitOK
+	}
+	return a.exitCode(a.dispatch("", commands(), args))
+}
+
+// app 命令执行环境
+type app struct {
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+}
+
+// dispatch 查找并执行子命令
+func (a *app) dispatch(parent string, cmds []command, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		a.printUsage(parent, cmds)
+		if len(args) == 0 {
+			return &exitError{code: ExitUsage}
+		}
+		return &exitError{code: ExitOK}
+	}
+
+	for _, cmd := range cmds {
+		if cmd.name != args[0] {
+			continue
+		}
+		path := strings.TrimSpace(parent + " " + cmd.name)
+		if len(cmd.subs) > 0 {
+			return a.dispatch(path, cmd.subs, args[1:])
+		}
+		return cmd.run(a, path, args[1:])
+	}
+
+	a.printUsage(parent, cmds)
+	return usageErrorf("未知命令: %s", strings.TrimSpace(parent+" "+args[0]))
+}
+
+// usageWidth 命令列表中命令一列的宽度
+const usageWidth = 28
+
+// printUsage 输出命令列表
+func (a *app) printUsage(parent string, cmds []command) {
+	prefix := strings.TrimSpace("docker-helper " + parent)
+	fmt.Fprintf(a.errOut, "用法: %s <命令> [参数]\n\n命令:\n", prefix)
+	for _, cmd := range cmds {
+		usage := cmd.name
+		if len(cmd.subs) > 0 {
+			usage += " <子命令>"
+		} else if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(a.errOut, "  %s%s%s\n", usage, strings.Repeat(" ", max(usageWidth-displayWidth(usage), 1)), cmd.summary)
+	}
+	fmt.Fprintf(a.errOut, "\n使用 \"%s <命令> -h\" 查看命令参数。", prefix)
+	if parent == "" {
+		fmt.Fprint(a.errOut, "不带子命令运行时启动服务端。")
+	}
+	fmt.Fprintln(a.errOut)
+}
+
+// exitError 携带退出码的错误，message为空时不再输出
+type exitError struct {
+	code    int
+	message string
+}
+
+func (e *exitError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitError{code: ExitUsage, message: fmt.Sprintf(format, args...)}
+}
+
+// exitCode 输出错误信息并返回对应的退出码
+func (a *app) exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var ee *exitError
+	if errors.As(err, &ee) {
+		if ee.message != "" {
+			fmt.Fprintf(a.errOut, "错误: %s\n", ee.message)
+		}
+		return ee.code
+	}
+
+	fmt.Fprintf(a.errOut, "错误: %v\n", err)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 401 {
+		fmt.Fprintln(a.errOut, "请执行 docker-helper login 重新登录，或通过 --token 指定Token")
+	}
+	return ExitError
+}
+
+// options 各命令共用的参数
+type options struct {
+	server   string
+	token    string
+	insecure bool
+	output   string
+}
+
+// newFlagSet 创建访问服务端的子命令参数集并注册共用参数，withOutput表示命令支持 -o 选择输出格式
+func (a *app) newFlagSet(path, args, summary string, withOutput bool) (*flag.FlagSet, *options) {
+	fs, o := a.localFlagSet(path, args, summary, withOutput)
+	fs.StringVar(&o.server, "server", "", "服务端地址，如 https://helper.example.com（环境变量 DOCKER_HELPER_SERVER）")
+	fs.StringVar(&o.token, "token", "", "访问Token（环境变量 DOCKER_HELPER_TOKEN）")
+	fs.BoolVar(&o.insecure, "insecure", false, "跳过HTTPS证书校验")
+	return fs, o
+}
+
+// localFlagSet 创建不访问服务端的子命令参数集
+func (a *app) localFlagSet(path, args, summary string, withOutput bool) (*flag.FlagSet, *options) {
+	o := &options{output: outputTable}
+	fs := flag.NewFlagSet("docker-helper "+path, flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(a.errOut, "用法: docker-helper %s [参数]\n\n%s\n\n参数:\n", strings.TrimSpace(path+" "+args), summary)
+		fs.PrintDefaults()
+	}
+	if withOutput {
+		fs.StringVar(&o.output, "o", outputTable, "输出格式: table/json")
+	}
+	return fs, o
+}
+
+// parse 解析参数，允许参数与位置参数交替出现，返回位置参数
+func parse(fs *flag.FlagSet, o *options, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, &exitError{code: ExitOK}
+			}
+			return nil, &exitError{code: ExitUsage}
+		}
+		rest := fs.Args()
+		// "--" 之后全部视为位置参数
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		args = rest
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if o.output != outputTable && o.output != outputJSON {
+		return nil, usageErrorf("输出格式必须是 table 或 json，当前为 %q", o.output)
+	}
+	return positional, nil
+}
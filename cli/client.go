@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"docker-helper/models"
+)
+
+// defaultServer 未指定服务端地址时使用的地址
+const defaultServer = "http://localhost:8080"
+
+// requestTimeout 单个API请求的超时时间，导出等流式下载不受限制
+const requestTimeout = 2 * time.Minute
+
+// APIError 服务端返回的错误响应
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("服务端返回 %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return e.Message
+}
+
+// Client 服务端API客户端
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient 创建客户端，server可以包含服务端配置的URL前缀
+func NewClient(server, token string, insecure bool) (*Client, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("服务端地址 %q 无效，应为 http(s)://host[:port][/前缀]", server)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Client{
+		baseURL: strings.TrimRight(server, "/"),
+		token:   token,
+		http:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// newRequest 创建带认证信息的请求
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("编码请求失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// Do 调用JSON接口，成功时将响应中的data解码到out
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求服务端失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		models.Response
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if resp.StatusCode >= 400 {
+			return &APIError{StatusCode: resp.StatusCode}
+		}
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if resp.StatusCode >= 400 || !result.Success {
+		return &APIError{StatusCode: resp.StatusCode, Message: result.Message}
+	}
+
+	if out != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("解析响应数据失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// Download 下载接口返回的文件内容并写入w
+func (c *Client) Download(ctx context.Context, path string, query url.Values, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求服务端失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result models.Response
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return &APIError{StatusCode: resp.StatusCode, Message: result.Message}
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("下载失败: %v", err)
+	}
+	return nil
+}
+
+// credentials 登录后保存在本地的服务端地址与Token
+type credentials struct {
+	Server   string `json:"server"`
+	Token    string `json:"token"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// credentialsPath 凭据文件路径，可通过 DOCKER_HELPER_CLI_CONFIG 环境变量指定
+func credentialsPath() (string, error) {
+	if path := os.Getenv("DOCKER_HELPER_CLI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户配置目录失败: %v", err)
+	}
+	return filepath.Join(dir, "docker-helper", "cli.json"), nil
+}
+
+// loadCredentials 读取已保存的凭据，文件不存在时返回空凭据
+func loadCredentials() (*credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &credentials{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据文件失败: %v", err)
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("解析凭据文件 %s 失败: %v", path, err)
+	}
+	return &creds, nil
+}
+
+// saveCredentials 保存凭据，文件仅当前用户可读写
+func saveCredentials(creds *credentials) (string, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("编码凭据失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("创建凭据目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("写入凭据文件失败: %v", err)
+	}
+	// WriteFile不会修改已存在文件的权限
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", fmt.Errorf("设置凭据文件权限失败: %v", err)
+	}
+	return path, nil
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// connect 按 参数 > 环境变量 > 已保存凭据 的顺序确定服务端地址与Token并创建客户端
+func (a *app) connect(o *options) (*Client, error) {
+	saved, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	server := firstNonEmpty(o.server, os.Getenv("DOCKER_HELPER_SERVER"), saved.Server, defaultServer)
+	token := firstNonEmpty(o.token, os.Getenv("DOCKER_HELPER_TOKEN"))
+	if token == "" && server == saved.Server {
+		token = saved.Token
+	}
+	if token == "" {
+		return nil, fmt.Errorf("未登录，请先执行 docker-helper login，或通过 --token / DOCKER_HELPER_TOKEN 指定Token")
+	}
+
+	insecure := o.insecure || (saved.Insecure && server == saved.Server)
+	return NewClient(server, token, insecure)
+}
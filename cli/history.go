@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"docker-helper/utils"
+)
+
+// runHistoryExport 按条件导出历史记录到文件或标准输出
+func runHistoryExport(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "", "按条件导出历史记录，未指定 --file 时输出到标准输出", false)
+	format := fs.String("format", utils.ExportCSV, "导出格式: csv/jsonl/xlsx")
+	file := fs.String("file", "", "保存到文件")
+	status := fs.String("status", "", "按状态筛选，多个以逗号分隔")
+	from := fs.String("from", "", "开始时间（RFC3339 或 2006-01-02）")
+	to := fs.String("to", "", "结束时间（RFC3339 或 2006-01-02）")
+	search := fs.String("search", "", "按源镜像或目标镜像关键字筛选")
+	source := fs.String("source", "", "按源镜像筛选")
+	target := fs.String("target", "", "按目标镜像筛选")
+	targetHost := fs.String("target-host", "", "按目标仓库地址筛选")
+	registry := fs.String("registry", "", "按仓库配置名称或ID筛选")
+	user := fs.String("user", "", "按创建者筛选")
+	sort := fs.String("sort", "", "排序字段: created_at/completed_at/duration")
+	order := fs.String("order", "", "排序方向: asc/desc")
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageErrorf("history export 不接受位置参数")
+	}
+	if *file == "" && *format == utils.ExportXLSX {
+		return usageErrorf("xlsx 格式需要通过 --file 指定输出文件")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	query := url.Values{"format": {*format}}
+	for key, value := range map[string]string{
+		"status": *status, "from": *from, "to": *to, "search": *search,
+		"source": *source, "target": *target, "target_host": *targetHost,
+		"user": *user, "sort": *sort, "order": *order,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *registry != "" {
+		cfg, err := findRegistryConfig(ctx, client, *registry)
+		if err != nil {
+			return err
+		}
+		query.Set("config_id", cfg.ID)
+	}
+
+	if *file == "" {
+		return client.Download(ctx, "/api/history/export", query, a.out)
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	if err := client.Download(ctx, "/api/history/export", query, f); err != nil {
+		f.Close()
+		os.Remove(*file)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	fmt.Fprintf(a.errOut, "历史记录已导出到 %s\n", *file)
+	return nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"docker-helper/models"
+)
+
+// runLogin 验证Token并保存凭据，未指定Token时从标准输入读取
+func runLogin(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "", "验证Token并将服务端地址与Token保存到本地，之后的命令无需再指定", false)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageErrorf("login 不接受位置参数，请使用 --token 指定Token")
+	}
+
+	saved, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	server := firstNonEmpty(o.server, os.Getenv("DOCKER_HELPER_SERVER"), saved.Server, defaultServer)
+
+	token := firstNonEmpty(o.token, os.Getenv("DOCKER_HELPER_TOKEN"))
+	if token == "" {
+		fmt.Fprint(a.errOut, "Token: ")
+		line, err := bufio.NewReader(a.in).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("读取Token失败: %v", err)
+		}
+		token = strings.TrimSpace(line)
+	}
+	if token == "" {
+		return usageErrorf("Token不能为空")
+	}
+
+	client, err := NewClient(server, token, o.insecure)
+	if err != nil {
+		return err
+	}
+	if err := client.Do(context.Background(), http.MethodPost, "/api/auth/login", nil, models.LoginRequest{Token: token}, nil); err != nil {
+		return err
+	}
+
+	file, err := saveCredentials(&credentials{Server: server, Token: token, Insecure: o.insecure})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "登录成功: %s\n凭据已保存到 %s\n", server, file)
+	return nil
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printJSON 以缩进格式输出JSON
+func (a *app) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(a.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable 对齐输出表格
+func (a *app) printTable(headers []string, rows [][]string) error {
+	widths := make([]int, len(headers))
+	for _, row := range append([][]string{headers}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range append([][]string{headers}, rows...) {
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)+2))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(a.out, b.String())
+	return err
+}
+
+// printFields 以“名称: 值”的形式输出单条记录
+func (a *app) printFields(fields [][2]string) error {
+	width := 0
+	for _, field := range fields {
+		width = max(width, displayWidth(field[0]))
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%s:%s%s\n", field[0], strings.Repeat(" ", width-displayWidth(field[0])+2), field[1])
+	}
+	_, err := io.WriteString(a.out, b.String())
+	return err
+}
+
+// displayWidth 字符串在终端中的显示宽度，中日韩文字与全角符号占两列
+//
+// text/tabwriter按字符数对齐，无法处理中文表头，因此表格自行计算宽度。
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case r >= 0x1100 && r <= 0x115F, // 谚文字母
+			r >= 0x2E80 && r <= 0xA4CF, // 中日韩部首、符号、文字
+			r >= 0xAC00 && r <= 0xD7A3, // 谚文音节
+			r >= 0xF900 && r <= 0xFAFF, // 兼容汉字
+			r >= 0xFE30 && r <= 0xFE4F, // 兼容标点
+			r >= 0xFF00 && r <= 0xFF60, // 全角字符
+			r >= 0xFFE0 && r <= 0xFFE6:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// formatTime 格式化为本地时间，nil时返回“-”
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// valueOrDash 空值显示为“-”
+func valueOrDash(s *string) string {
+	if s == nil || *s == "" {
+		return "-"
+	}
+	return *s
+}
+
+// yesNo 布尔值显示为是/否
+func yesNo(b bool) string {
+	if b {
+		return "是"
+	}
+	return "否"
+}
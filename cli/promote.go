@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"docker-helper/models"
+)
+
+// runPromote 创建晋级任务，在同一仓库中为已有镜像添加标签
+func runPromote(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<镜像> <标签>...",
+		"在同一仓库中为已有镜像添加标签，只写入清单，不传输镜像层。--wait 时等待任务结束，任务失败时退出码为3，被取消或中断时为4", true)
+	to := fs.String("to", "", "镜像所在的仓库配置名称或ID，默认使用默认配置")
+	wait := fs.Bool("wait", false, "等待任务结束")
+	interval := fs.Duration("interval", 2*time.Second, "--wait 时查询任务状态的间隔")
+	timeout := fs.Duration("timeout", 0, "--wait 时最长等待时间，0表示不限制")
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 {
+		return usageErrorf("需要指定镜像与至少一个标签")
+	}
+	if *interval <= 0 {
+		return usageErrorf("--interval 必须大于0")
+	}
+	image, tags := positional[0], positional[1:]
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	cfg, err := findRegistryConfig(ctx, client, *to)
+	if err != nil {
+		return err
+	}
+
+	var created models.TaskCreateResponse
+	req := models.PromoteRequest{ConfigID: cfg.ID, Image: image, Tags: tags}
+	if err := client.Do(ctx, http.MethodPost, "/api/tasks/promote", nil, req, &created); err != nil {
+		return err
+	}
+
+	if !*wait {
+		if o.output == outputJSON {
+			return a.printJSON(created)
+		}
+		fmt.Fprintf(a.out, "任务已创建: %s\n%s -> %s\n", created.TaskID, image, strings.Join(tags, ", "))
+		return nil
+	}
+
+	fmt.Fprintf(a.errOut, "任务已创建: %s，%s -> %s\n", created.TaskID, image, strings.Join(tags, ", "))
+	waitCtx, cancel := waitContext(*timeout)
+	defer cancel()
+	task, err := a.follow(waitCtx, client, created.TaskID, *interval, a.errOut, outputTable)
+	if err != nil {
+		return err
+	}
+	if err := a.printTask(task, o.output); err != nil {
+		return err
+	}
+	return taskResult(task)
+}
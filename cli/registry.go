@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"docker-helper/models"
+)
+
+// listRegistryConfigs 获取全部仓库配置
+func listRegistryConfigs(ctx context.Context, client *Client) ([]models.RegistryConfigResponse, error) {
+	var configs []models.RegistryConfigResponse
+	if err := client.Do(ctx, http.MethodGet, "/api/registry/configs", nil, nil, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// findRegistryConfig 按ID或名称查找仓库配置，ref为空时返回默认配置
+func findRegistryConfig(ctx context.Context, client *Client, ref string) (*models.RegistryConfigResponse, error) {
+	configs, err := listRegistryConfigs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" {
+		for i := range configs {
+			if configs[i].IsDefault {
+				return &configs[i], nil
+			}
+		}
+		return nil, fmt.Errorf("没有默认仓库配置，请通过 --to 指定配置名称或ID")
+	}
+
+	for i := range configs {
+		if configs[i].ID == ref {
+			return &configs[i], nil
+		}
+	}
+	var found *models.RegistryConfigResponse
+	for i := range configs {
+		if configs[i].Name != ref {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("存在多个名为 %q 的仓库配置，请使用配置ID", ref)
+		}
+		found = &configs[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("仓库配置 %q 不存在", ref)
+	}
+	return found, nil
+}
+
+// runRegistryList 列出仓库配置
+func runRegistryList(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "", "列出仓库配置，默认配置以 * 标记", true)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageErrorf("registry list 不接受位置参数")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	configs, err := listRegistryConfigs(context.Background(), client)
+	if err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		return a.printJSON(configs)
+	}
+	rows := make([][]string, 0, len(configs))
+	for _, cfg := range configs {
+		name := cfg.Name
+		if cfg.IsDefault {
+			name += " *"
+		}
+		rows = append(rows, []string{cfg.ID, name, cfg.RegistryURL, cfg.Username, cfg.Status, formatTime(cfg.LastTestTime)})
+	}
+	return a.printTable([]string{"ID", "名称", "仓库地址", "用户名", "状态", "最近测试"}, rows)
+}
+
+// runRegistryTest 测试仓库配置，连接失败时返回非零退出码
+func runRegistryTest(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<配置名称或ID>", "测试仓库连接与推送权限，连接失败时退出码为1", true)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("需要指定一个仓库配置名称或ID")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cfg, err := findRegistryConfig(ctx, client, positional[0])
+	if err != nil {
+		return err
+	}
+
+	var result models.TestConnectionResponse
+	if err := client.Do(ctx, http.MethodPost, "/api/registry/configs/"+cfg.ID+"/test", nil, nil, &result); err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		if err := a.printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fields := [][2]string{
+			{"仓库", fmt.Sprintf("%s (%s)", cfg.Name, cfg.RegistryURL)},
+			{"连接", yesNo(result.Success)},
+			{"可推送", yesNo(result.CanPush)},
+			{"耗时", fmt.Sprintf("%dms", result.ResponseTime)},
+		}
+		if result.Error != "" {
+			fields = append(fields, [2]string{"错误", result.Error})
+		}
+		if err := a.printFields(fields); err != nil {
+			return err
+		}
+	}
+
+	if !result.Success {
+		return &exitError{code: ExitError}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/utils"
+)
+
+// syncResult 单个镜像的同步结果
+type syncResult struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	TargetImage string `json:"target_image"`
+	Status      string `json:"status"`   // completed/failed/cancelled
+	Duration    int    `json:"duration"` // 秒
+	Error       string `json:"error,omitempty"`
+}
+
+// syncSummary 同步汇总
+type syncSummary struct {
+	Total     int          `json:"total"`
+	Completed int          `json:"completed"`
+	Failed    int          `json:"failed"`
+	Cancelled int          `json:"cancelled"`
+	Results   []syncResult `json:"results"`
+}
+
+// runSync 不启动服务端，按同步文件直接转换镜像
+func runSync(a *app, path string, args []string) error {
+	fs, o := a.localFlagSet(path, "-f <同步文件>",
+		"不启动Web服务与数据库，按同步文件直接拉取、标记并推送镜像。日志输出到标准错误，结束后输出汇总；有镜像失败时退出码为3，被中断时为4", true)
+	file := fs.String("f", "", "同步文件路径（YAML），- 表示从标准输入读取")
+	concurrency := fs.Int("concurrency", 0, "同时执行的转换数，覆盖同步文件中的 concurrency")
+	dryRun := fs.Bool("dry-run", false, "只输出同步计划，不执行转换")
+	logLevel := fs.String("log-level", "info", "日志级别: debug/info/warn/error")
+	logFormat := fs.String("log-format", "text", "日志格式: text/json")
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 || *file == "" {
+		return usageErrorf("需要通过 -f 指定同步文件")
+	}
+	if *concurrency < 0 {
+		return usageErrorf("--concurrency 不能为负数")
+	}
+
+	sf, err := loadSyncFile(*file, a.in)
+	if err != nil {
+		return &exitError{code: ExitUsage, message: err.Error()}
+	}
+	if *concurrency > 0 {
+		sf.Concurrency = *concurrency
+	}
+	jobs, err := sf.jobs()
+	if err != nil {
+		return &exitError{code: ExitUsage, message: err.Error()}
+	}
+
+	if *dryRun {
+		return a.printSyncPlan(jobs, o.output)
+	}
+
+	if err := utils.ConfigureLoggingOutput(a.errOut, *logLevel, *logFormat); err != nil {
+		return usageErrorf("%v", err)
+	}
+
+	// 源镜像策略与服务端一致，从 CONFIG_FILE 指定的配置文件与 POLICY_* 环境变量读取
+	if _, err := config.Init(nil); err != nil {
+		return &exitError{code: ExitUsage, message: fmt.Sprintf("加载配置失败: %v", err)}
+	}
+
+	imageService, err := services.NewImageService()
+	if err != nil {
+		return fmt.Errorf("连接Docker失败: %v", err)
+	}
+	defer imageService.Close()
+
+	// 收到中断信号后不再开始新的转换，并取消执行中的转换
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	summary := runSyncJobs(ctx, imageService, jobs, sf.Concurrency, sf.Timeout)
+	if err := a.printSyncSummary(summary, o.output); err != nil {
+		return err
+	}
+
+	switch {
+	case summary.Failed > 0:
+		return &exitError{code: ExitTaskFailed, message: fmt.Sprintf("%d 个镜像同步失败", summary.Failed)}
+	case summary.Cancelled > 0:
+		return &exitError{code: ExitTaskCancelled, message: fmt.Sprintf("同步被中断，%d 个镜像未完成", summary.Cancelled)}
+	}
+	return nil
+}
+
+// runSyncJobs 以有限并发执行全部转换，结果顺序与jobs一致
+//
+// 转换结束后会删除本地的源镜像，因此同一源镜像的多个目标按顺序执行，并发只发生在不同源镜像之间。
+func runSyncJobs(ctx context.Context, imageService *services.ImageService, jobs []syncJob, concurrency int, timeout time.Duration) *syncSummary {
+	logger := utils.NewLogger("sync")
+	logger.Infof("开始同步 %d 个镜像，并发数: %d", len(jobs), concurrency)
+
+	results := make([]syncResult, len(jobs))
+	var sources []string
+	groups := make(map[string][]int) // 源镜像 -> jobs下标
+	for i, job := range jobs {
+		results[i] = syncResult{Source: job.Source, Target: job.Target, TargetImage: job.TargetImage, Status: models.TaskStatusCancelled}
+		if _, exists := groups[job.Source]; !exists {
+			sources = append(sources, job.Source)
+		}
+		groups[job.Source] = append(groups[job.Source], i)
+	}
+
+	run := func(i int) {
+		job := jobs[i]
+		jobCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		start := time.Now()
+		err := services.CheckSourcePolicy(jobCtx, job.Source, []services.PushTarget{{Image: job.TargetImage, Username: job.username, Password: job.password}})
+		if err == nil {
+			_, _, err = imageService.TransformImage(jobCtx, job.Source, job.TargetImage, job.username, job.password)
+		}
+		result := &results[i]
+		result.Duration = int(time.Since(start).Seconds())
+
+		switch {
+		case err == nil:
+			result.Status = models.TaskStatusCompleted
+			logger.Infof("同步完成: %s -> %s", job.Source, job.TargetImage)
+		case ctx.Err() != nil:
+			result.Error = "同步被中断"
+			logger.Warnf("同步被中断: %s -> %s", job.Source, job.TargetImage)
+		default:
+			result.Status = models.TaskStatusFailed
+			result.Error = err.Error()
+			if jobCtx.Err() == context.DeadlineExceeded {
+				result.Error = fmt.Sprintf("超过 %v 未完成: %v", timeout, err)
+			}
+			logger.Errorf("同步失败: %s -> %s: %s", job.Source, job.TargetImage, result.Error)
+		}
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			for _, i := range indexes {
+				if ctx.Err() != nil {
+					return
+				}
+				run(i)
+			}
+		}(groups[source])
+	}
+	wg.Wait()
+
+	summary := &syncSummary{Total: len(results), Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case models.TaskStatusCompleted:
+			summary.Completed++
+		case models.TaskStatusFailed:
+			summary.Failed++
+		default:
+			summary.Cancelled++
+		}
+	}
+	logger.Infof("同步结束: 成功 %d，失败 %d，未完成 %d", summary.Completed, summary.Failed, summary.Cancelled)
+	return summary
+}
+
+// printSyncPlan 输出同步计划
+func (a *app) printSyncPlan(jobs []syncJob, output string) error {
+	if output == outputJSON {
+		return a.printJSON(jobs)
+	}
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		rows = append(rows, []string{job.Source, job.Target, job.TargetImage})
+	}
+	return a.printTable([]string{"源镜像", "目标仓库", "目标镜像"}, rows)
+}
+
+// printSyncSummary 输出同步汇总
+func (a *app) printSyncSummary(summary *syncSummary, output string) error {
+	if output == outputJSON {
+		return a.printJSON(summary)
+	}
+
+	rows := make([][]string, 0, len(summary.Results))
+	for _, result := range summary.Results {
+		message := result.Error
+		if message == "" {
+			message = "-"
+		}
+		rows = append(rows, []string{result.Source, result.TargetImage, result.Status, fmt.Sprintf("%ds", result.Duration), message})
+	}
+	if err := a.printTable([]string{"源镜像", "目标镜像", "结果", "耗时", "错误"}, rows); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(a.out, "\n共 %d 个，成功 %d，失败 %d，未完成 %d\n", summary.Total, summary.Completed, summary.Failed, summary.Cancelled)
+	return err
+}
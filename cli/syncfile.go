@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSyncConcurrency 同步文件未指定并发数时同时执行的转换数
+const defaultSyncConcurrency = 2
+
+// syncFile 同步文件（images.yaml）
+type syncFile struct {
+	Concurrency int           `yaml:"concurrency"` // 同时执行的转换数
+	Timeout     time.Duration `yaml:"timeout"`     // 单个镜像的超时时间，默认与服务端 task_timeout 相同
+	Targets     []syncTarget  `yaml:"targets"`
+	Images      []syncImage   `yaml:"images"`
+}
+
+// syncTarget 目标仓库，凭据从环境变量或文件读取，不写入同步文件
+type syncTarget struct {
+	Name         string  `yaml:"name"`
+	Registry     string  `yaml:"registry"`
+	Namespace    *string `yaml:"namespace"` // 未设置时与服务端 target_namespace 默认值相同，空字符串表示不加命名空间
+	Naming       string  `yaml:"naming"`    // keep（默认）/flatten
+	Username     string  `yaml:"username"`
+	UsernameEnv  string  `yaml:"username_env"`
+	PasswordEnv  string  `yaml:"password_env"`
+	PasswordFile string  `yaml:"password_file"`
+}
+
+// syncImage 需要同步的源镜像
+type syncImage struct {
+	Source  string   `yaml:"source"`
+	Targets []string `yaml:"targets"` // 目标仓库名称，为空时同步到全部目标
+	Path    string   `yaml:"path"`    // 目标仓库中的路径与标签（如 base/nginx:1.25），设置后不使用命名规则
+}
+
+// syncJob 一次镜像转换
+type syncJob struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"` // 目标仓库名称
+	TargetImage string `json:"target_image"`
+	username    string
+	password    string
+}
+
+// loadSyncFile 读取并校验同步文件，path为“-”时从in读取
+func loadSyncFile(path string, in io.Reader) (*syncFile, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(in)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取同步文件失败: %v", err)
+	}
+
+	sf := &syncFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(sf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("解析同步文件 %s 失败: %v", path, err)
+	}
+
+	if sf.Concurrency == 0 {
+		sf.Concurrency = defaultSyncConcurrency
+	}
+	if sf.Timeout == 0 {
+		sf.Timeout = config.Defaults().TaskTimeout
+	}
+	for i := range sf.Targets {
+		if sf.Targets[i].Naming == "" {
+			sf.Targets[i].Naming = utils.NamingKeep
+		}
+	}
+
+	if err := sf.validate(); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// validate 校验同步文件，返回包含全部问题的错误
+func (sf *syncFile) validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(sf.Concurrency > 0, "concurrency 必须大于0")
+	check(sf.Timeout > 0, "timeout 必须大于0")
+	check(len(sf.Targets) > 0, "至少需要一个目标仓库（targets）")
+	check(len(sf.Images) > 0, "至少需要一个源镜像（images）")
+
+	names := make(map[string]bool)
+	for i, target := range sf.Targets {
+		check(target.Name != "", "targets[%d] 缺少 name", i)
+		check(!names[target.Name], "目标仓库名称 %q 重复", target.Name)
+		names[target.Name] = true
+		check(target.Registry != "", "目标仓库 %q 缺少 registry", target.Name)
+		check(target.Naming == utils.NamingKeep || target.Naming == utils.NamingFlatten,
+			"目标仓库 %q 的 naming 必须是 keep/flatten，当前为 %q", target.Name, target.Naming)
+		check(target.Username == "" || target.UsernameEnv == "", "目标仓库 %q 的 username 与 username_env 只能设置一个", target.Name)
+		check(target.PasswordEnv == "" || target.PasswordFile == "", "目标仓库 %q 的 password_env 与 password_file 只能设置一个", target.Name)
+	}
+
+	for i, image := range sf.Images {
+		if err := utils.ValidateImageName(image.Source); err != nil {
+			check(false, "images[%d] 源镜像 %q 无效: %v", i, image.Source, err)
+		}
+		for _, name := range image.Targets {
+			check(names[name], "images[%d] 引用了不存在的目标仓库 %q", i, name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("同步文件无效:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// credentials 读取目标仓库凭据
+func (t *syncTarget) credentials() (username, password string, err error) {
+	username = t.Username
+	if t.UsernameEnv != "" {
+		if username = os.Getenv(t.UsernameEnv); username == "" {
+			return "", "", fmt.Errorf("目标仓库 %q 的用户名环境变量 %s 未设置", t.Name, t.UsernameEnv)
+		}
+	}
+
+	switch {
+	case t.PasswordEnv != "":
+		if password = os.Getenv(t.PasswordEnv); password == "" {
+			return "", "", fmt.Errorf("目标仓库 %q 的密码环境变量 %s 未设置", t.Name, t.PasswordEnv)
+		}
+	case t.PasswordFile != "":
+		data, err := os.ReadFile(t.PasswordFile)
+		if err != nil {
+			return "", "", fmt.Errorf("读取目标仓库 %q 的密码文件失败: %v", t.Name, err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	return username, password, nil
+}
+
+// targetImage 按命名规则生成目标镜像名称
+func (t *syncTarget) targetImage(image syncImage) string {
+	namespace := config.Defaults().TargetNamespace
+	if t.Namespace != nil {
+		namespace = *t.Namespace
+	}
+	return utils.TargetImageName(image.Source, t.Registry, namespace, t.Naming, image.Path)
+}
+
+// jobs 展开为源镜像与目标仓库的组合，并读取凭据
+func (sf *syncFile) jobs() ([]syncJob, error) {
+	targets := make(map[string]*syncTarget, len(sf.Targets))
+	for i := range sf.Targets {
+		targets[sf.Targets[i].Name] = &sf.Targets[i]
+	}
+
+	var jobs []syncJob
+	seen := make(map[string]string) // 目标镜像 -> 源镜像
+	for _, image := range sf.Images {
+		names := image.Targets
+		if len(names) == 0 {
+			for _, target := range sf.Targets {
+				names = append(names, target.Name)
+			}
+		}
+
+		for _, name := range names {
+			target := targets[name]
+			username, password, err := target.credentials()
+			if err != nil {
+				return nil, err
+			}
+
+			job := syncJob{
+				Source:      image.Source,
+				Target:      name,
+				TargetImage: target.targetImage(image),
+				username:    username,
+				password:    password,
+			}
+			if previous, exists := seen[job.TargetImage]; exists {
+				return nil, fmt.Errorf("源镜像 %s 与 %s 的目标镜像相同: %s", previous, job.Source, job.TargetImage)
+			}
+			seen[job.TargetImage] = job.Source
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"docker-helper/models"
+)
+
+// maxPollFailures 等待任务时允许连续查询失败的次数，用于容忍服务端短暂重启
+const maxPollFailures = 5
+
+// isFinished 任务是否已结束
+func isFinished(status string) bool {
+	switch status {
+	case models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled, models.TaskStatusInterrupted:
+		return true
+	}
+	return false
+}
+
+// taskResult 将任务结果转换为退出码，未结束或成功时返回nil
+func taskResult(task *models.TaskStatusResponse) error {
+	switch task.Status {
+	case models.TaskStatusFailed:
+		message := "任务执行失败"
+		if task.ErrorMsg != nil && *task.ErrorMsg != "" {
+			message += ": " + *task.ErrorMsg
+		}
+		return &exitError{code: ExitTaskFailed, message: message}
+	case models.TaskStatusCancelled:
+		return &exitError{code: ExitTaskCancelled, message: "任务已取消"}
+	case models.TaskStatusInterrupted:
+		return &exitError{code: ExitTaskCancelled, message: "任务因服务关闭被中断"}
+	}
+	return nil
+}
+
+// getTask 查询任务
+func getTask(ctx context.Context, client *Client, id string) (*models.TaskStatusResponse, error) {
+	var task models.TaskStatusResponse
+	if err := client.Do(ctx, http.MethodGet, "/api/tasks/"+url.PathEscape(id), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// logEntry 任务执行过程中的一条记录
+type logEntry struct {
+	Time     time.Time `json:"time"`
+	Status   string    `json:"status"`
+	Progress int       `json:"progress"`
+	Step     int       `json:"step"`
+	Message  string    `json:"message"`
+}
+
+// taskLog 根据任务状态的变化生成执行记录
+//
+// 服务端只保存任务的当前状态，执行记录由客户端对比相邻两次查询结果得到。
+type taskLog struct {
+	created, started, finished bool
+	last                       logEntry
+}
+
+// observe 返回相对上次查询新增的记录
+func (l *taskLog) observe(task *models.TaskStatusResponse) []logEntry {
+	var entries []logEntry
+	if !l.created {
+		l.created = true
+		entries = append(entries, logEntry{Time: task.CreatedAt, Status: models.TaskStatusPending, Message: "任务已创建"})
+	}
+	if task.StartedAt != nil && !l.started {
+		l.started = true
+		entries = append(entries, logEntry{Time: *task.StartedAt, Status: models.TaskStatusRunning, Message: "开始执行"})
+	}
+
+	if task.Status == models.TaskStatusRunning {
+		message := models.TaskStepMessages[task.CurrentStep]
+		if task.StepMessage != nil && *task.StepMessage != "" {
+			message = *task.StepMessage
+		}
+		entry := logEntry{Time: time.Now(), Status: task.Status, Progress: task.Progress, Step: task.CurrentStep, Message: message}
+		if entry.Progress != l.last.Progress || entry.Step != l.last.Step || entry.Message != l.last.Message {
+			l.last = entry
+			entries = append(entries, entry)
+		}
+	}
+
+	if isFinished(task.Status) && !l.finished {
+		l.finished = true
+		entry := logEntry{Time: time.Now(), Status: task.Status, Progress: task.Progress, Step: task.CurrentStep}
+		if task.CompletedAt != nil {
+			entry.Time = *task.CompletedAt
+		}
+		switch task.Status {
+		case models.TaskStatusCompleted:
+			entry.Message = "转换完成: " + task.TargetImage
+		case models.TaskStatusFailed:
+			entry.Message = "执行失败"
+		case models.TaskStatusCancelled:
+			entry.Message = "任务已取消"
+		case models.TaskStatusInterrupted:
+			entry.Message = "服务关闭，任务被中断"
+		}
+		if task.ErrorMsg != nil && *task.ErrorMsg != "" {
+			entry.Message += ": " + *task.ErrorMsg
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// writeLog 输出执行记录，json格式时每行一条记录
+func (a *app) writeLog(w io.Writer, output string, entries []logEntry) {
+	for _, entry := range entries {
+		if output == outputJSON {
+			_ = json.NewEncoder(w).Encode(entry)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %-11s %3d%%  %s\n", entry.Time.Local().Format("15:04:05"), entry.Status, entry.Progress, entry.Message)
+	}
+}
+
+// follow 定期查询任务并输出执行记录，直到任务结束或超时
+func (a *app) follow(ctx context.Context, client *Client, id string, interval time.Duration, w io.Writer, output string) (*models.TaskStatusResponse, error) {
+	var log taskLog
+	failures := 0
+	for {
+		task, err := getTask(ctx, client, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("等待任务 %s 超时", id)
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				return nil, err
+			}
+			failures++
+			if failures >= maxPollFailures {
+				return nil, err
+			}
+			fmt.Fprintf(a.errOut, "查询任务失败，稍后重试: %v\n", err)
+		} else {
+			failures = 0
+			a.writeLog(w, output, log.observe(task))
+			if isFinished(task.Status) {
+				return task, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待任务 %s 超时", id)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// waitContext 按 --timeout 创建等待任务使用的上下文，0表示不限制
+func waitContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runTasksList 列出任务
+func runTasksList(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "", "列出执行中、排队中与最近完成的任务", true)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageErrorf("tasks list 不接受位置参数")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	var list models.TaskListResponse
+	if err := client.Do(context.Background(), http.MethodGet, "/api/tasks", nil, nil, &list); err != nil {
+		return err
+	}
+
+	if o.output == outputJSON {
+		return a.printJSON(list)
+	}
+	var tasks []*models.Task
+	if list.Current != nil {
+		tasks = append(tasks, list.Current)
+	}
+	tasks = append(tasks, list.Queue...)
+	tasks = append(tasks, list.Recent...)
+
+	rows := make([][]string, 0, len(tasks))
+	for _, task := range tasks {
+		rows = append(rows, []string{
+			task.ID, task.Status, fmt.Sprintf("%d%%", task.Progress),
+			task.SourceImage, task.TargetImage, formatTime(&task.CreatedAt),
+		})
+	}
+	return a.printTable([]string{"ID", "状态", "进度", "源镜像", "目标镜像", "创建时间"}, rows)
+}
+
+// printTask 输出任务详情
+func (a *app) printTask(task *models.TaskStatusResponse, output string) error {
+	if output == outputJSON {
+		return a.printJSON(task)
+	}
+
+	fields := [][2]string{
+		{"ID", task.ID},
+		{"状态", task.Status},
+		{"进度", fmt.Sprintf("%d%%", task.Progress)},
+		{"当前步骤", valueOrDash(task.StepMessage)},
+		{"源镜像", task.SourceImage},
+		{"目标镜像", task.TargetImage},
+		{"目标仓库", task.TargetHost},
+		{"创建时间", formatTime(&task.CreatedAt)},
+		{"开始时间", formatTime(task.StartedAt)},
+		{"完成时间", formatTime(task.CompletedAt)},
+	}
+	if task.Duration > 0 {
+		fields = append(fields, [2]string{"耗时", fmt.Sprintf("%ds", task.Duration)})
+	}
+	if task.EstimatedTimeRemaining != nil {
+		fields = append(fields, [2]string{"预计剩余", fmt.Sprintf("%ds", *task.EstimatedTimeRemaining)})
+	}
+	if task.ErrorMsg != nil && *task.ErrorMsg != "" {
+		fields = append(fields, [2]string{"错误", *task.ErrorMsg})
+	}
+	if err := a.printFields(fields); err != nil || len(task.Targets) == 0 {
+		return err
+	}
+
+	// 多目标任务输出各目标的结果
+	rows := make([][]string, 0, len(task.Targets))
+	for _, target := range task.Targets {
+		message := target.ErrorMsg
+		if message == "" {
+			message = "-"
+		}
+		rows = append(rows, []string{target.Name, target.TargetImage, target.Status, fmt.Sprintf("%ds", target.Duration), message})
+	}
+	if _, err := fmt.Fprintln(a.out); err != nil {
+		return err
+	}
+	return a.printTable([]string{"仓库配置", "目标镜像", "结果", "耗时", "错误"}, rows)
+}
+
+// runTasksGet 查看任务详情，退出码反映已结束任务的结果
+func runTasksGet(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<任务ID>", "查看任务详情，任务失败时退出码为3，被取消或中断时为4", true)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("需要指定一个任务ID")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	task, err := getTask(context.Background(), client, positional[0])
+	if err != nil {
+		return err
+	}
+	if err := a.printTask(task, o.output); err != nil {
+		return err
+	}
+	return taskResult(task)
+}
+
+// runTasksCancel 取消任务
+func runTasksCancel(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<任务ID>", "取消排队中或执行中的任务", false)
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("需要指定一个任务ID")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	if err := client.Do(context.Background(), http.MethodDelete, "/api/tasks/"+url.PathEscape(positional[0]), nil, nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "任务已取消: %s\n", positional[0])
+	return nil
+}
+
+// runTasksLogs 输出任务执行过程
+func runTasksLogs(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<任务ID>", "输出任务执行过程，--follow 时持续输出直到任务结束，退出码反映任务结果", true)
+	followFlag := fs.Bool("follow", false, "持续输出直到任务结束")
+	fs.BoolVar(followFlag, "f", false, "同 --follow")
+	interval := fs.Duration("interval", 2*time.Second, "--follow 时查询任务状态的间隔")
+	timeout := fs.Duration("timeout", 0, "--follow 时最长等待时间，0表示不限制")
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("需要指定一个任务ID")
+	}
+	if *interval <= 0 {
+		return usageErrorf("--interval 必须大于0")
+	}
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+
+	if !*followFlag {
+		task, err := getTask(context.Background(), client, positional[0])
+		if err != nil {
+			return err
+		}
+		var log taskLog
+		a.writeLog(a.out, o.output, log.observe(task))
+		return nil
+	}
+
+	ctx, cancel := waitContext(*timeout)
+	defer cancel()
+	task, err := a.follow(ctx, client, positional[0], *interval, a.out, o.output)
+	if err != nil {
+		return err
+	}
+	return taskResult(task)
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"docker-helper/models"
+)
+
+// runTransfer 创建转换任务，--wait 时等待任务结束并以任务结果作为退出码
+func runTransfer(a *app, path string, args []string) error {
+	fs, o := a.newFlagSet(path, "<源镜像>",
+		"创建转换任务。--wait 时等待任务结束，执行过程输出到标准错误，任务失败时退出码为3，被取消或中断时为4", true)
+	to := fs.String("to", "", "目标仓库配置名称或ID，默认使用默认配置")
+	target := fs.String("target", "", "目标镜像完整名称，默认按源镜像与服务端 target_namespace 生成")
+	referrers := fs.String("referrers", "", "推送后复制的附属制品类型，多个以逗号分隔: signature/sbom/attestation/artifactType，none 表示不复制，默认使用服务端 referrer_types")
+	wait := fs.Bool("wait", false, "等待任务结束")
+	interval := fs.Duration("interval", 2*time.Second, "--wait 时查询任务状态的间隔")
+	timeout := fs.Duration("timeout", 0, "--wait 时最长等待时间，0表示不限制")
+	positional, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf("需要指定一个源镜像")
+	}
+	if *interval <= 0 {
+		return usageErrorf("--interval 必须大于0")
+	}
+	source := positional[0]
+
+	client, err := a.connect(o)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	cfg, err := findRegistryConfig(ctx, client, *to)
+	if err != nil {
+		return err
+	}
+
+	targetImage := *target
+	if targetImage == "" {
+		var built struct {
+			TargetImage string `json:"target_image"`
+		}
+		body := map[string]string{"source_image": source, "target_host": cfg.RegistryURL}
+		if err := client.Do(ctx, http.MethodPost, "/api/image/build-target", nil, body, &built); err != nil {
+			return err
+		}
+		targetImage = built.TargetImage
+	}
+
+	var created models.TaskCreateResponse
+	req := models.TransformRequest{SourceImage: source, TargetImage: targetImage, ConfigID: cfg.ID}
+	if *referrers != "" {
+		for _, t := range strings.Split(*referrers, ",") {
+			req.ReferrerTypes = append(req.ReferrerTypes, strings.TrimSpace(t))
+		}
+	}
+	if err := client.Do(ctx, http.MethodPost, "/api/tasks", nil, req, &created); err != nil {
+		return err
+	}
+
+	if !*wait {
+		if o.output == outputJSON {
+			return a.printJSON(created)
+		}
+		fmt.Fprintf(a.out, "任务已创建: %s\n%s -> %s\n", created.TaskID, source, targetImage)
+		return nil
+	}
+
+	fmt.Fprintf(a.errOut, "任务已创建: %s，%s -> %s\n", created.TaskID, source, targetImage)
+	waitCtx, cancel := waitContext(*timeout)
+	defer cancel()
+	task, err := a.follow(waitCtx, client, created.TaskID, *interval, a.errOut, outputTable)
+	if err != nil {
+		return err
+	}
+	if err := a.printTask(task, o.output); err != nil {
+		return err
+	}
+	return taskResult(task)
+}
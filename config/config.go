@@ -1,30 +1,185 @@
+// Package config 加载服务配置：默认值 < 配置文件 < 环境变量 < 命令行参数
 package config
 
 import (
-	"os"
+	"net"
+	"time"
 )
 
+// Config 服务配置
+//
+// 字段标签说明：
+//   - yaml: 配置文件中的键
+//   - env: 覆盖该项的环境变量
+//   - flag: 覆盖该项的命令行参数（仅部分常用项）
+//   - reload: 为true时可通过SIGHUP热加载，其余项修改后需要重启
 type Config struct {
-	Port         string
-	GinMode      string
-	LogLevel     string
-	DBPath       string
-	DefaultToken string
+	// ConfigFile 本次加载使用的配置文件路径，由 -config 参数或 CONFIG_FILE 环境变量指定
+	ConfigFile string `yaml:"-"`
+
+	Port         string `yaml:"port" env:"PORT" flag:"port"`
+	GinMode      string `yaml:"gin_mode" env:"GIN_MODE" flag:"gin-mode"`
+	LogLevel     string `yaml:"log_level" env:"LOG_LEVEL" flag:"log-level" reload:"true"`
+	LogFormat    string `yaml:"log_format" env:"LOG_FORMAT" flag:"log-format" reload:"true"` // json 或 text
+	DBPath       string `yaml:"db_path" env:"DB_PATH" flag:"db-path"`
+	DefaultToken string `yaml:"default_token" env:"DEFAULT_TOKEN"`
+
+	// HTTP服务
+	BasePath         string   `yaml:"base_path" env:"BASE_PATH" flag:"base-path"`             // URL前缀，如 /docker-helper，为空表示部署在根路径
+	TLSCertFile      string   `yaml:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file"` // TLS证书文件，与私钥同时设置时启用HTTPS
+	TLSKeyFile       string   `yaml:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key-file"`    // TLS私钥文件
+	HTTPRedirectPort string   `yaml:"http_redirect_port" env:"HTTP_REDIRECT_PORT"`            // 启用HTTPS时在该端口监听HTTP并跳转到HTTPS，为空表示不监听
+	TrustedProxies   []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`                  // 可信反向代理的IP或CIDR，仅信任其转发的客户端IP
+
+	// 存储后端
+	DBDriver    string `yaml:"db_driver" env:"DB_DRIVER" flag:"db-driver"`          // sqlite 或 postgres
+	DatabaseURL string `yaml:"database_url" env:"DATABASE_URL" flag:"database-url"` // PostgreSQL连接串，DBDriver为postgres时必填
+	InstanceID  string `yaml:"instance_id" env:"INSTANCE_ID"`                       // 实例标识，多个副本共享数据库时用于区分各自的任务，为空时使用主机名
+
+	// 任务执行
+	TaskTimeout     time.Duration `yaml:"task_timeout" env:"TASK_TIMEOUT" reload:"true"`         // 单个任务的最长执行时间（不含排队）
+	TaskConcurrency int           `yaml:"task_concurrency" env:"TASK_CONCURRENCY" reload:"true"` // 同时执行的任务数，0表示不限
+	TargetNamespace string        `yaml:"target_namespace" env:"TARGET_NAMESPACE" reload:"true"` // 自动生成目标镜像名时使用的命名空间
+	PushConcurrency int           `yaml:"push_concurrency" env:"PUSH_CONCURRENCY" reload:"true"` // 多目标任务中同时推送的目标数，1表示逐个推送
+
+	// 源镜像策略，创建转换、导出、导入与晋级任务时检查，不符合时拒绝创建
+	PolicyAllowedSources []string      `yaml:"policy_allowed_sources" env:"POLICY_ALLOWED_SOURCES" reload:"true"`     // 允许的源镜像仓库与路径，如 docker.io/library、ghcr.io/myorg，为空表示不限
+	PolicyDeniedSources  []string      `yaml:"policy_denied_sources" env:"POLICY_DENIED_SOURCES" reload:"true"`       // 禁止的源镜像仓库与路径，优先于允许列表
+	PolicyBannedTags     []string      `yaml:"policy_banned_tags" env:"POLICY_BANNED_TAGS" reload:"true"`             // 禁止使用的源镜像标签，如 latest，带摘要的源镜像不检查
+	PolicyDigestTargets  []string      `yaml:"policy_digest_targets" env:"POLICY_DIGEST_TARGETS" reload:"true"`       // 推送到这些仓库与路径时源镜像必须带摘要
+	PolicyMaxImageSizeMB int           `yaml:"policy_max_image_size_mb" env:"POLICY_MAX_IMAGE_SIZE_MB" reload:"true"` // 源镜像压缩后大小上限（MB），0表示不限
+	PolicyMaxImageAge    time.Duration `yaml:"policy_max_image_age" env:"POLICY_MAX_IMAGE_AGE" reload:"true"`         // 源镜像构建时间距今的上限，0表示不限
+
+	// 附属制品
+	ReferrerTypes []string `yaml:"referrer_types" env:"REFERRER_TYPES" reload:"true"` // 推送后复制到目标的附属制品类型: signature/sbom/attestation 或完整的artifactType，为空或 none 表示不复制
+
+	// 离线包
+	BundleDir       string        `yaml:"bundle_dir" env:"BUNDLE_DIR"`                           // 离线包文件存放目录
+	BundleRetention time.Duration `yaml:"bundle_retention" env:"BUNDLE_RETENTION" reload:"true"` // 离线包与上传文件的保留时间，0表示不自动删除
+
+	// 本地镜像缓存
+	ImageCache      bool          `yaml:"image_cache" env:"IMAGE_CACHE" reload:"true"`               // 任务结束后保留拉取的源镜像供后续任务复用，关闭时清理已缓存的镜像
+	ImageCacheTTL   time.Duration `yaml:"image_cache_ttl" env:"IMAGE_CACHE_TTL" reload:"true"`       // 缓存镜像未被使用超过该时间后清理，0表示不按时间清理
+	ImageCacheMaxMB int           `yaml:"image_cache_max_mb" env:"IMAGE_CACHE_MAX_MB" reload:"true"` // 缓存镜像大小之和的上限（MB），超出时先清理最久未使用的镜像，0表示不限
+
+	// 优雅关闭
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period" env:"SHUTDOWN_GRACE_PERIOD" reload:"true"` // 关闭时等待执行中任务完成的最长时间，超时后中断
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" reload:"true"`           // 任务结束后等待进行中的HTTP请求完成的最长时间
+
+	// 仓库连接测试
+	RegistryTimeout      time.Duration `yaml:"registry_timeout" env:"REGISTRY_TIMEOUT" reload:"true"`             // 连接与认证请求超时
+	RegistryProbeTimeout time.Duration `yaml:"registry_probe_timeout" env:"REGISTRY_PROBE_TIMEOUT" reload:"true"` // 权限与仓库类型探测请求超时
+	InsecureRegistries   []string      `yaml:"insecure_registries" env:"INSECURE_REGISTRIES" reload:"true"`       // HTTPS不可用时允许改用HTTP的仓库地址（host或host:port），HTTP请求不发送认证信息
+
+	// 登录会话
+	CookieMaxAge time.Duration `yaml:"cookie_max_age" env:"COOKIE_MAX_AGE" reload:"true"` // 登录Cookie有效期
+
+	// 历史记录保留策略
+	HistoryRetentionDays    int           `yaml:"history_retention_days" env:"HISTORY_RETENTION_DAYS"`           // 已结束任务保留天数，0表示不按时间清理
+	HistoryMaxRowsPerStatus int           `yaml:"history_max_rows_per_status" env:"HISTORY_MAX_ROWS_PER_STATUS"` // 每种状态最多保留的记录数，0表示不限
+	HistoryPruneInterval    time.Duration `yaml:"history_prune_interval" env:"HISTORY_PRUNE_INTERVAL"`           // 后台清理间隔
+	HistoryArchiveDir       string        `yaml:"history_archive_dir" env:"HISTORY_ARCHIVE_DIR"`                 // 删除前归档目录，为空表示不归档
+
+	// 登录保护
+	LoginRateLimit     int           `yaml:"login_rate_limit" env:"LOGIN_RATE_LIMIT"`         // 每个IP每分钟允许的登录尝试次数，0表示不限
+	LoginMaxFailures   int           `yaml:"login_max_failures" env:"LOGIN_MAX_FAILURES"`     // 单个IP连续失败多少次后锁定，0表示不锁定
+	AccountMaxFailures int           `yaml:"account_max_failures" env:"ACCOUNT_MAX_FAILURES"` // 账户连续登录失败超过多少次后逐次延迟登录响应（不锁定），0表示不延迟
+	AccountMaxDelay    time.Duration `yaml:"account_max_delay" env:"ACCOUNT_MAX_DELAY"`       // 账户登录延迟的上限
+	LoginLockout       time.Duration `yaml:"login_lockout" env:"LOGIN_LOCKOUT"`               // 锁定时长
+
+	// 监控
+	MetricsToken string `yaml:"metrics_token" env:"METRICS_TOKEN"` // /metrics 访问令牌，为空表示不校验
+
+	// 链路追踪
+	TracingExporter    string  `yaml:"tracing_exporter" env:"TRACING_EXPORTER"`         // none/otlp/stdout/file
+	TracingEndpoint    string  `yaml:"tracing_endpoint" env:"TRACING_ENDPOINT"`         // OTLP HTTP端点，为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+	TracingFile        string  `yaml:"tracing_file" env:"TRACING_FILE"`                 // file导出器写入的文件
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO"` // 采样比例
+
+	// 就绪检查
+	HealthDiskPath      string        `yaml:"health_disk_path" env:"HEALTH_DISK_PATH"`               // 检查可用空间的路径，为空时使用Docker数据目录
+	HealthMinFreeDiskMB int           `yaml:"health_min_free_disk_mb" env:"HEALTH_MIN_FREE_DISK_MB"` // 最低可用空间（MB）
+	HealthCacheTTL      time.Duration `yaml:"health_cache_ttl" env:"HEALTH_CACHE_TTL"`               // 检查结果缓存时间
+
+	// 任务创建限流（按API Key计数）
+	TaskRateLimit  int           `yaml:"task_rate_limit" env:"TASK_RATE_LIMIT"`   // 每个窗口允许创建的任务数，0表示不限
+	TaskRateWindow time.Duration `yaml:"task_rate_window" env:"TASK_RATE_WINDOW"` // 限流窗口
 }
 
-func Load() *Config {
-	return &Config{
-		Port:         getEnv("PORT", "8080"),
-		GinMode:      getEnv("GIN_MODE", "debug"),
-		LogLevel:     getEnv("LOG_LEVEL", "info"),
-		DBPath:       getEnv("DB_PATH", "./data/transform.db"),
-		DefaultToken: getEnv("DEFAULT_TOKEN", "docker-helper"),
+// TLSEnabled 是否启用HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// IsTrustedProxy ip是否属于 trusted_proxies 中的某个IP或CIDR
+func (c *Config) IsTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(addr) {
+			return true
+		}
 	}
+	return false
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Defaults 返回默认配置
+func Defaults() *Config {
+	return &Config{
+		Port:         "8080",
+		GinMode:      "debug",
+		LogLevel:     "info",
+		LogFormat:    "json",
+		DBPath:       "./data/transform.db",
+		DefaultToken: "docker-helper",
+
+		TrustedProxies: []string{"127.0.0.1", "::1"},
+
+		DBDriver: "sqlite",
+
+		TaskTimeout:     10 * time.Minute,
+		TaskConcurrency: 0,
+		TargetNamespace: "transform",
+		PushConcurrency: 3,
+
+		ReferrerTypes: []string{"signature", "sbom", "attestation"},
+
+		BundleDir:       "./data/bundles",
+		BundleRetention: 72 * time.Hour,
+
+		ImageCacheTTL:   24 * time.Hour,
+		ImageCacheMaxMB: 10240,
+
+		ShutdownGracePeriod: 5 * time.Minute,
+		ShutdownTimeout:     15 * time.Second,
+
+		RegistryTimeout:      30 * time.Second,
+		RegistryProbeTimeout: 15 * time.Second,
+
+		CookieMaxAge: 24 * time.Hour,
+
+		HistoryPruneInterval: time.Hour,
+
+		LoginRateLimit:     10,
+		LoginMaxFailures:   5,
+		AccountMaxFailures: 5,
+		AccountMaxDelay:    10 * time.Second,
+		LoginLockout:       15 * time.Minute,
+
+		TracingExporter:    "none",
+		TracingFile:        "./data/traces.jsonl",
+		TracingSampleRatio: 1.0,
+
+		HealthMinFreeDiskMB: 1024,
+		HealthCacheTTL:      5 * time.Second,
+
+		TaskRateLimit:  30,
+		TaskRateWindow: time.Minute,
 	}
-	return defaultValue
 }
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load 依次应用默认值、配置文件、环境变量与命令行参数，并校验结果
+//
+// 配置文件由 -config 参数或 CONFIG_FILE 环境变量指定，未指定时只使用默认值与覆盖项。
+func Load(args []string) (*Config, error) {
+	cfg := Defaults()
+
+	fs, flags := newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.ConfigFile = *flags.configFile
+	if cfg.ConfigFile == "" {
+		cfg.ConfigFile = os.Getenv("CONFIG_FILE")
+	}
+	if cfg.ConfigFile != "" {
+		if err := loadFile(cfg, cfg.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyFlags(cfg, flags.values); err != nil {
+		return nil, err
+	}
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// normalize 统一可以有多种写法的配置项
+func (c *Config) normalize() {
+	c.BasePath = strings.TrimRight(c.BasePath, "/")
+	if c.BasePath != "" && !strings.HasPrefix(c.BasePath, "/") {
+		c.BasePath = "/" + c.BasePath
+	}
+}
+
+// parsedFlags 命令行中出现的参数
+type parsedFlags struct {
+	configFile *string
+	values     map[string]string // flag名 -> 值，只包含显式传入的参数
+}
+
+// newFlagSet 根据Config的flag标签注册命令行参数
+func newFlagSet() (*flag.FlagSet, *parsedFlags) {
+	fs := flag.NewFlagSet("docker-helper", flag.ContinueOnError)
+	flags := &parsedFlags{values: make(map[string]string)}
+	flags.configFile = fs.String("config", "", "配置文件路径（YAML），也可通过 CONFIG_FILE 环境变量指定")
+
+	eachField(func(field reflect.StructField, _ reflect.Value) {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			return
+		}
+		usage := fmt.Sprintf("覆盖配置项 %s（环境变量 %s）", field.Tag.Get("yaml"), field.Tag.Get("env"))
+		fs.Func(name, usage, func(value string) error {
+			flags.values[name] = value
+			return nil
+		})
+	}, reflect.ValueOf(Defaults()).Elem())
+
+	return fs, flags
+}
+
+// Usage 输出命令行参数说明
+func Usage(w io.Writer) {
+	fs, _ := newFlagSet()
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// loadFile 读取YAML配置文件，未知的键视为错误
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// applyEnv 使用环境变量覆盖配置，值无法解析时返回错误
+func applyEnv(cfg *Config) error {
+	var errs []string
+	eachField(func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("env")
+		if key == "" {
+			return
+		}
+		raw := os.Getenv(key)
+		if raw == "" {
+			return
+		}
+		if err := setValue(value, raw); err != nil {
+			errs = append(errs, fmt.Sprintf("环境变量 %s=%q 无效: %v", key, raw, err))
+		}
+	}, reflect.ValueOf(cfg).Elem())
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyFlags 使用命令行参数覆盖配置
+func applyFlags(cfg *Config, values map[string]string) error {
+	var errs []string
+	eachField(func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("flag")
+		raw, ok := values[name]
+		if name == "" || !ok {
+			return
+		}
+		if err := setValue(value, raw); err != nil {
+			errs = append(errs, fmt.Sprintf("参数 -%s=%q 无效: %v", name, raw, err))
+		}
+	}, reflect.ValueOf(cfg).Elem())
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// eachField 遍历Config的导出字段
+func eachField(fn func(field reflect.StructField, value reflect.Value), v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fn(t.Field(i), v.Field(i))
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue 按字段类型解析字符串
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("应为时长，如 30s、10m")
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("应为整数")
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("应为数字")
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		// 逗号分隔的列表
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("应为 true 或 false")
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("不支持的配置类型 %s", v.Type())
+	}
+	return nil
+}
+
+// namespacePattern 镜像仓库路径：小写字母数字，以 . _ - 分隔，多级以 / 分隔
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+
+// basePathPattern URL前缀：以/开头的一级或多级路径，不以/结尾
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// Validate 校验配置，返回包含全部问题的错误
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(value string, options ...string) bool {
+		for _, option := range options {
+			if value == option {
+				return true
+			}
+		}
+		return false
+	}
+
+	validPort := func(value string) bool {
+		port, err := strconv.Atoi(value)
+		return err == nil && port > 0 && port < 65536
+	}
+
+	check(validPort(c.Port), "port 必须是 1-65535 之间的端口号，当前为 %q", c.Port)
+	check(oneOf(c.GinMode, "debug", "release", "test"), "gin_mode 必须是 debug/release/test，当前为 %q", c.GinMode)
+	check(oneOf(strings.ToLower(c.LogLevel), "debug", "info", "warn", "error"), "log_level 必须是 debug/info/warn/error，当前为 %q", c.LogLevel)
+	check(oneOf(strings.ToLower(c.LogFormat), "json", "text"), "log_format 必须是 json/text，当前为 %q", c.LogFormat)
+
+	check(c.BasePath == "" || basePathPattern.MatchString(c.BasePath),
+		"base_path %q 不是合法的URL前缀（如 /docker-helper）", c.BasePath)
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""), "tls_cert_file 与 tls_key_file 必须同时设置")
+	if c.HTTPRedirectPort != "" {
+		check(c.TLSEnabled(), "http_redirect_port 需要同时启用HTTPS（tls_cert_file 与 tls_key_file）")
+		check(validPort(c.HTTPRedirectPort), "http_redirect_port 必须是 1-65535 之间的端口号，当前为 %q", c.HTTPRedirectPort)
+		check(c.HTTPRedirectPort != c.Port, "http_redirect_port 不能与 port 相同")
+	}
+	for _, proxy := range c.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "trusted_proxies 中的 %q 不是合法的IP或CIDR", proxy)
+	}
+
+	switch c.DBDriver {
+	case "sqlite":
+		check(c.DBPath != "", "db_driver 为 sqlite 时必须设置 db_path")
+	case "postgres":
+		check(c.DatabaseURL != "", "db_driver 为 postgres 时必须设置 database_url")
+	default:
+		check(false, "db_driver 必须是 sqlite/postgres，当前为 %q", c.DBDriver)
+	}
+
+	check(c.TaskTimeout > 0, "task_timeout 必须大于0")
+	check(c.TaskConcurrency >= 0, "task_concurrency 不能为负数")
+	check(c.TargetNamespace == "" || namespacePattern.MatchString(c.TargetNamespace),
+		"target_namespace %q 不是合法的镜像仓库路径（小写字母、数字、. _ - /）", c.TargetNamespace)
+	check(c.PushConcurrency > 0, "push_concurrency 必须大于0")
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"policy_allowed_sources", c.PolicyAllowedSources},
+		{"policy_denied_sources", c.PolicyDeniedSources},
+		{"policy_digest_targets", c.PolicyDigestTargets},
+	} {
+		for _, pattern := range list.patterns {
+			_, err := path.Match(pattern, "")
+			check(err == nil && pattern != "" && !strings.HasPrefix(pattern, "/") && !strings.HasSuffix(pattern, "/"),
+				"%s 中的 %q 不是合法的仓库路径模式（如 docker.io/library、*.example.com/team）", list.key, pattern)
+		}
+	}
+	check(c.PolicyMaxImageSizeMB >= 0, "policy_max_image_size_mb 不能为负数")
+	check(c.PolicyMaxImageAge >= 0, "policy_max_image_age 不能为负数")
+	referrerErr := ValidateReferrerTypes(c.ReferrerTypes)
+	check(referrerErr == nil, "referrer_types: %v", referrerErr)
+	check(c.BundleDir != "", "bundle_dir 不能为空")
+	check(c.BundleRetention >= 0, "bundle_retention 不能为负数")
+	check(c.ImageCacheTTL >= 0, "image_cache_ttl 不能为负数")
+	check(c.ImageCacheMaxMB >= 0, "image_cache_max_mb 不能为负数")
+	check(c.ShutdownGracePeriod >= 0, "shutdown_grace_period 不能为负数")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout 必须大于0")
+	check(c.RegistryTimeout > 0, "registry_timeout 必须大于0")
+	check(c.RegistryProbeTimeout > 0, "registry_probe_timeout 必须大于0")
+	for _, host := range c.InsecureRegistries {
+		check(host != "" && !strings.Contains(host, "/"), "insecure_registries 中的 %q 不是合法的仓库地址（如 reg.local:5000）", host)
+	}
+	check(c.CookieMaxAge > 0, "cookie_max_age 必须大于0")
+
+	check(c.HistoryRetentionDays >= 0, "history_retention_days 不能为负数")
+	check(c.HistoryMaxRowsPerStatus >= 0, "history_max_rows_per_status 不能为负数")
+	check(c.HistoryPruneInterval > 0, "history_prune_interval 必须大于0")
+
+	check(c.LoginRateLimit >= 0, "login_rate_limit 不能为负数")
+	check(c.LoginMaxFailures >= 0, "login_max_failures 不能为负数")
+	check(c.AccountMaxFailures >= 0, "account_max_failures 不能为负数")
+	check(c.AccountMaxDelay >= 0, "account_max_delay 不能为负数")
+	check(c.LoginLockout > 0, "login_lockout 必须大于0")
+
+	check(oneOf(c.TracingExporter, "none", "otlp", "stdout", "file"), "tracing_exporter 必须是 none/otlp/stdout/file，当前为 %q", c.TracingExporter)
+	check(c.TracingExporter != "file" || c.TracingFile != "", "tracing_exporter 为 file 时必须设置 tracing_file")
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "tracing_sample_ratio 必须在 0 到 1 之间")
+
+	check(c.HealthMinFreeDiskMB >= 0, "health_min_free_disk_mb 不能为负数")
+	check(c.HealthCacheTTL >= 0, "health_cache_ttl 不能为负数")
+
+	check(c.TaskRateLimit >= 0, "task_rate_limit 不能为负数")
+	check(c.TaskRateWindow > 0, "task_rate_window 必须大于0")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置无效:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// ValidateReferrerTypes 检查附属制品类型列表，每项为 signature、sbom、attestation、完整的artifactType（如 application/spdx+json），
+// 或单独使用 none 表示不复制
+func ValidateReferrerTypes(types []string) error {
+	for _, t := range types {
+		switch {
+		case t == "none":
+			if len(types) > 1 {
+				return errors.New("none 不能与其他类型同时使用")
+			}
+		case t == "signature", t == "sbom", t == "attestation":
+		case strings.Count(t, "/") == 1 && !strings.ContainsAny(t, " ,") && !strings.HasPrefix(t, "/") && !strings.HasSuffix(t, "/"):
+		default:
+			return fmt.Errorf("%q 不是 signature/sbom/attestation/none 或合法的artifactType", t)
+		}
+	}
+	return nil
+}
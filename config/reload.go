@@ -0,0 +1,97 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	loadArgs  []string
+	listeners []func(old, new *Config)
+)
+
+// Init 加载配置并设为当前配置，args会在Reload时重新使用
+func Init(args []string) (*Config, error) {
+	cfg, err := Load(args)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	loadArgs = args
+	mu.Unlock()
+
+	current.Store(cfg)
+	return cfg, nil
+}
+
+// Current 返回当前生效的配置，未初始化时返回默认配置
+//
+// 可热加载的配置项应在每次使用时通过Current读取，而不是在启动时保存副本。
+func Current() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return Defaults()
+}
+
+// OnReload 注册热加载回调，在新配置生效后按注册顺序调用
+func OnReload(fn func(old, new *Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// ReloadResult 热加载结果，字段名为配置文件中的键
+type ReloadResult struct {
+	Applied []string // 已生效的变更
+	Ignored []string // 需要重启才能生效、本次被忽略的变更
+}
+
+// Reload 重新读取配置文件、环境变量与启动参数
+//
+// 新配置校验失败时保持当前配置不变。只有标记为reload的配置项会生效，
+// 其余配置项保留当前值，并在结果中列出。
+func Reload() (*ReloadResult, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	next, err := Load(loadArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	old := Current()
+	result := &ReloadResult{}
+
+	oldValue := reflect.ValueOf(old).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	eachField(func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("yaml")
+		if name == "" || name == "-" {
+			return
+		}
+		previous := oldValue.FieldByIndex(field.Index)
+		if reflect.DeepEqual(previous.Interface(), value.Interface()) {
+			return
+		}
+
+		if field.Tag.Get("reload") == "true" {
+			result.Applied = append(result.Applied, name)
+		} else {
+			result.Ignored = append(result.Ignored, name)
+			value.Set(previous)
+		}
+	}, nextValue)
+
+	current.Store(next)
+	for _, fn := range listeners {
+		fn(old, next)
+	}
+
+	return result, nil
+}
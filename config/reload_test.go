@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeConfigFile 写入配置文件内容
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadKeepsNonReloadableFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "port: \"8080\"\ndb_path: /data/a.db\nlog_level: info\ntask_concurrency: 2\n")
+	t.Cleanup(func() { current.Store(nil) })
+
+	if _, err := Init([]string{"-config", path}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	var notified *Config
+	OnReload(func(old, new *Config) { notified = new })
+
+	writeConfigFile(t, path, "port: \"9090\"\ndb_path: /data/b.db\nlog_level: debug\ntask_concurrency: 4\n")
+	result, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	cfg := Current()
+	if cfg.Port != "8080" || cfg.DBPath != "/data/a.db" {
+		t.Errorf("不可热加载的配置项 port=%s db_path=%s, want 保持 8080 与 /data/a.db", cfg.Port, cfg.DBPath)
+	}
+	if cfg.LogLevel != "debug" || cfg.TaskConcurrency != 4 {
+		t.Errorf("可热加载的配置项 log_level=%s task_concurrency=%d, want debug 与 4", cfg.LogLevel, cfg.TaskConcurrency)
+	}
+	slices.Sort(result.Applied)
+	slices.Sort(result.Ignored)
+	if !slices.Equal(result.Applied, []string{"log_level", "task_concurrency"}) || !slices.Equal(result.Ignored, []string{"db_path", "port"}) {
+		t.Errorf("Reload() = %+v, want 生效 [log_level task_concurrency]，忽略 [db_path port]", result)
+	}
+	if notified != cfg {
+		t.Error("热加载回调未收到新配置")
+	}
+
+	// 新配置无效时保持当前配置
+	writeConfigFile(t, path, "log_level: verbose\n")
+	if _, err := Reload(); err == nil {
+		t.Fatal("Reload(无效配置) = nil, want error")
+	}
+	if Current() != cfg {
+		t.Error("无效配置替换了当前配置")
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Open 连接数据库并应用迁移，driver为sqlite时dsn为数据库文件路径
+func Open(driver, dsn string) (*sql.DB, error) {
+	switch driver {
+	case DriverSQLite:
+		// 确保数据目录存在
+		if err := ensureDir(filepath.Dir(dsn)); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %v", err)
+		}
+	case DriverPostgres:
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+
+	// 连接数据库
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	// 测试连接
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	// 运行迁移
+	if err = Migrate(db, driver); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %v", err)
+	}
+
+	return db, nil
+}
+
+func ensureDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0755)
+	}
+	return nil
+}
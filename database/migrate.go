@@ -0,0 +1,242 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docker-helper/config"
+	"docker-helper/utils"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFS embed.FS
+
+var migrateLogger = utils.NewLogger("database")
+
+// migrationLockID PostgreSQL迁移咨询锁的键
+const migrationLockID = 7426_2025
+
+// Migration 一个版本化的数据库迁移
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string
+}
+
+// createMigrationsTable 迁移记录表，记录已应用的版本
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// LoadMigrations 读取指定驱动的嵌入迁移文件，文件名格式为 NNNN_name.sql，按版本号排序
+// 各驱动的迁移目录保持相同的版本编号，保证版本检查在不同数据库上含义一致
+func LoadMigrations(driver string) ([]Migration, error) {
+	files, err := fs.Glob(migrationFS, "migrations/"+driver+"/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations found for driver %s", driver)
+	}
+
+	seen := make(map[int]string)
+	var migrations []Migration
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", file)
+		}
+		if existing, dup := seen[version]; dup {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, existing, file)
+		}
+		seen[version] = file
+
+		content, err := migrationFS.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", file, err)
+		}
+
+		sum := sha256.Sum256(content)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(content),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestVersion 当前程序支持的最高迁移版本
+func LatestVersion(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate 按顺序在事务中应用尚未执行的迁移
+func Migrate(db *sql.DB, driver string) error {
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	// 拒绝在更新版本程序创建的数据库上运行，避免旧代码破坏新结构
+	latest := LatestVersion(migrations)
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database schema version %d is newer than supported version %d, please upgrade docker-helper", version, latest)
+		}
+	}
+
+	for _, m := range migrations {
+		if checksum, ok := applied[m.Version]; ok {
+			if checksum != m.Checksum {
+				migrateLogger.Errorf("迁移 %04d_%s 在应用后被修改，已忽略变更", m.Version, m.Name)
+			}
+			continue
+		}
+
+		if err := applyMigration(db, driver, m); err != nil {
+			return err
+		}
+		migrateLogger.Infof("已应用数据库迁移: %04d_%s", m.Version, m.Name)
+	}
+
+	// 新建的数据库使用配置的默认Token作为admin账户的Token，已有数据库不受影响
+	if len(applied) == 0 {
+		if err := seedAdminToken(db, driver, config.Current().DefaultToken); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedAdminToken 将种子迁移创建的admin账户Token替换为token，token为空时保留种子数据
+func seedAdminToken(db *sql.DB, driver, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	query := "UPDATE users SET token = ? WHERE username = 'admin'"
+	if driver == DriverPostgres {
+		query = "UPDATE users SET token = $1 WHERE username = 'admin'"
+	}
+	if _, err := db.Exec(query, token); err != nil {
+		return fmt.Errorf("failed to seed admin token: %v", err)
+	}
+	return nil
+}
+
+// appliedMigrations 查询已应用的迁移版本及其校验和
+func appliedMigrations(db *sql.DB) (map[int]string, error) {
+	rows, err := db.Query("SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %v", err)
+		}
+		applied[version] = checksum
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigration 在单个事务中执行迁移并记录版本
+func applyMigration(db *sql.DB, driver string, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %v", m.Version, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// 多副本共享PostgreSQL时，使用事务级咨询锁串行化迁移，并在锁内复查是否已被其他实例应用
+	if driver == DriverPostgres {
+		if _, err = tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to lock migration %d: %v", m.Version, err)
+		}
+
+		var exists int
+		err = tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = $1", m.Version).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %d: %v", m.Version, err)
+		}
+		if exists > 0 {
+			return tx.Commit()
+		}
+	}
+
+	if _, err = tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+
+	record := "INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)"
+	if driver == DriverPostgres {
+		record = "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)"
+	}
+	_, err = tx.Exec(record, m.Version, m.Name, m.Checksum)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %v", m.Version, err)
+	}
+
+	return nil
+}
+
+// SchemaVersion 返回数据库当前的迁移版本
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
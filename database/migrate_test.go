@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docker-helper/config"
+)
+
+// setDefaultToken 设置 DEFAULT_TOKEN 并重新加载配置，测试结束后恢复
+func setDefaultToken(t *testing.T, token string) {
+	t.Helper()
+	t.Cleanup(func() {
+		if _, err := config.Init(nil); err != nil {
+			t.Errorf("恢复配置失败: %v", err)
+		}
+	})
+	t.Setenv("DEFAULT_TOKEN", token)
+	if _, err := config.Init(nil); err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+}
+
+// adminToken 查询admin账户的Token
+func adminToken(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var token string
+	if err := db.QueryRow("SELECT token FROM users WHERE username = 'admin'").Scan(&token); err != nil {
+		t.Fatalf("查询admin账户失败: %v", err)
+	}
+	return token
+}
+
+func TestMigrateSeedsDefaultToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.db")
+
+	setDefaultToken(t, "first-token")
+	db, err := Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if token := adminToken(t, db); token != "first-token" {
+		t.Fatalf("新数据库admin Token = %q, want first-token", token)
+	}
+	db.Close()
+
+	// 已有数据库不再使用配置的默认Token
+	setDefaultToken(t, "second-token")
+	db, err = Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatalf("重新打开: %v", err)
+	}
+	defer db.Close()
+	if token := adminToken(t, db); token != "first-token" {
+		t.Fatalf("重启后admin Token = %q, want first-token", token)
+	}
+}
+
+// openTestDB 在临时目录中创建并迁移SQLite数据库
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	migrations, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	latest := LatestVersion(migrations)
+
+	// 模拟更新版本的程序应用过的迁移
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, 'future', 'x')", latest+1); err != nil {
+		t.Fatal(err)
+	}
+	err = Migrate(db, DriverSQLite)
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("Migrate() = %v, want 数据库版本过新的错误", err)
+	}
+}
+
+func TestMigrateAppliesSeedOnce(t *testing.T) {
+	db := openTestDB(t)
+
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除种子数据后再次迁移，种子迁移不会重新执行
+	if _, err := db.Exec("DELETE FROM config WHERE key = 'app_version'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db, DriverSQLite); err != nil {
+		t.Fatalf("再次迁移: %v", err)
+	}
+
+	var seeded, again int
+	if err := db.QueryRow("SELECT COUNT(*) FROM config WHERE key IN ('app_version', 'token')").Scan(&seeded); err != nil {
+		t.Fatal(err)
+	}
+	if seeded != 0 {
+		t.Errorf("再次迁移后种子配置 = %d 行, want 0", seeded)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&again); err != nil {
+		t.Fatal(err)
+	}
+	if again != applied {
+		t.Errorf("再次迁移后迁移记录 = %d, want %d", again, applied)
+	}
+}
@@ -0,0 +1,30 @@
+-- 审计事件表（只追加）
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL,              -- 操作者
+    source_ip TEXT,                   -- 来源IP
+    action TEXT NOT NULL,             -- 操作名称，如 registry.update
+    resource TEXT,                    -- 目标资源，如 registry_config:<id>
+    before_summary TEXT,              -- 变更前摘要（已脱敏）
+    after_summary TEXT,               -- 变更后摘要（已脱敏）
+    result TEXT NOT NULL,             -- success, failure
+    status_code INTEGER DEFAULT 0,    -- HTTP状态码
+    message TEXT,                     -- 响应消息
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+
+-- 禁止修改和删除审计事件
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
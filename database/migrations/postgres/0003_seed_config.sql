@@ -0,0 +1,5 @@
+-- 插入初始化数据（仅在首次迁移时写入，已存在的配置不会被覆盖）
+INSERT INTO config (key, value) VALUES
+('token', 'docker-helper'),
+('app_version', '1.0.0')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,13 @@
+-- 访问账户表，原 config 表中的 token 迁移为 admin 账户
+CREATE TABLE IF NOT EXISTS users (
+    username TEXT PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users (username, token)
+SELECT 'admin', value FROM config WHERE key = 'token'
+ON CONFLICT (username) DO NOTHING;
+
+DELETE FROM config WHERE key = 'token';
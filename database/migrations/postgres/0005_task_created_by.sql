@@ -0,0 +1,5 @@
+-- 记录任务创建者，并为历史查询常用的列建立索引
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS created_by TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_status_created_at ON tasks (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_target_host ON tasks (target_host);
@@ -0,0 +1,8 @@
+-- 任务类型：transform 为镜像转换，bundle 为导出离线包
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'transform';
+
+-- 离线包任务的镜像列表（JSON数组）、打包格式与生成的文件信息
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS images TEXT;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS bundle_format TEXT;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS artifact_size BIGINT;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS artifact_sha256 TEXT;
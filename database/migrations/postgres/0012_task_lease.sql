@@ -0,0 +1,5 @@
+-- 任务所属的服务实例与租约，多副本共享数据库时启动只中断本实例或租约已过期的遗留任务
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS instance_id TEXT;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_instance_id ON tasks (instance_id);
@@ -0,0 +1,5 @@
+-- 镜像缓存记录按服务实例区分：多副本共享数据库时各副本只清理自己本地Docker中的镜像
+-- 升级前的记录所属实例为空，由下次启动的实例认领
+ALTER TABLE image_cache ADD COLUMN IF NOT EXISTS instance_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE image_cache DROP CONSTRAINT IF EXISTS image_cache_pkey;
+ALTER TABLE image_cache ADD PRIMARY KEY (instance_id, image);
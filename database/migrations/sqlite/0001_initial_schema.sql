@@ -38,8 +38,3 @@ CREATE TABLE IF NOT EXISTS tasks (
     started_at DATETIME,              -- 开始执行时间
     completed_at DATETIME             -- 完成时间
 );
-
--- 插入初始化数据
-INSERT OR REPLACE INTO config (key, value) VALUES 
-('token', 'docker-helper'),
-('app_version', '1.0.0'); 
\ No newline at end of file
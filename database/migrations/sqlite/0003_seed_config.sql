@@ -0,0 +1,4 @@
+-- 插入初始化数据（仅在首次迁移时写入，已存在的配置不会被覆盖）
+INSERT OR IGNORE INTO config (key, value) VALUES 
+('token', 'docker-helper'),
+('app_version', '1.0.0');
@@ -0,0 +1,7 @@
+-- 就绪检查写入测试使用的单行表
+CREATE TABLE IF NOT EXISTS health_check (
+    id INTEGER PRIMARY KEY,
+    checked_at DATETIME
+);
+
+INSERT OR IGNORE INTO health_check (id, checked_at) VALUES (1, CURRENT_TIMESTAMP);
@@ -0,0 +1,11 @@
+-- 本地保留的源镜像缓存，按最近使用时间与磁盘预算清理
+CREATE TABLE IF NOT EXISTS image_cache (
+    image TEXT PRIMARY KEY,           -- 标准化后的镜像名
+    image_id TEXT NOT NULL,           -- 最近一次拉取得到的镜像ID
+    size INTEGER NOT NULL DEFAULT 0,  -- 镜像大小（字节）
+    hits INTEGER NOT NULL DEFAULT 0,  -- 被后续任务复用的次数
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_cache_last_used_at ON image_cache (last_used_at);
@@ -0,0 +1,2 @@
+-- 多目标转换任务各目标的推送结果（JSON数组）
+ALTER TABLE tasks ADD COLUMN targets TEXT;
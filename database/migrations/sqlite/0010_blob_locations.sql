@@ -0,0 +1,12 @@
+-- 跨仓库挂载节省的上传字节数
+ALTER TABLE tasks ADD COLUMN mounted_bytes INTEGER NOT NULL DEFAULT 0;
+
+-- 目标仓库中已知存在的镜像层，推送前从其他仓库路径跨仓库挂载
+CREATE TABLE IF NOT EXISTS blob_locations (
+    registry TEXT NOT NULL,            -- 仓库地址
+    repository TEXT NOT NULL,          -- 仓库内路径
+    digest TEXT NOT NULL,              -- 镜像层摘要
+    size INTEGER NOT NULL DEFAULT 0,   -- 镜像层大小（字节）
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (registry, digest, repository)
+);
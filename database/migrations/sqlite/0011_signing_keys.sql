@@ -0,0 +1,11 @@
+-- 仓库配置的签名验证公钥，配置了公钥的仓库只接收签名可被其中一个公钥验证的镜像
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id TEXT PRIMARY KEY,
+    config_id TEXT NOT NULL,           -- 仓库配置ID，删除配置时一并删除
+    name TEXT NOT NULL,
+    public_key TEXT NOT NULL,          -- PEM格式公钥
+    algorithm TEXT NOT NULL,           -- ecdsa-p256/ecdsa-p384/rsa/ed25519
+    fingerprint TEXT NOT NULL,         -- 公钥DER编码的SHA-256
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (config_id, fingerprint)
+);
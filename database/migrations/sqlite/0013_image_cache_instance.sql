@@ -0,0 +1,20 @@
+-- 镜像缓存记录按服务实例区分：多副本共享数据库时各副本只清理自己本地Docker中的镜像
+-- 升级前的记录所属实例为空，由下次启动的实例认领
+CREATE TABLE IF NOT EXISTS image_cache_new (
+    instance_id TEXT NOT NULL DEFAULT '', -- 拉取并保留该镜像的服务实例
+    image TEXT NOT NULL,              -- 标准化后的镜像名
+    image_id TEXT NOT NULL,           -- 最近一次拉取得到的镜像ID
+    size INTEGER NOT NULL DEFAULT 0,  -- 镜像大小（字节）
+    hits INTEGER NOT NULL DEFAULT 0,  -- 被后续任务复用的次数
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (instance_id, image)
+);
+
+INSERT INTO image_cache_new (instance_id, image, image_id, size, hits, created_at, last_used_at)
+SELECT '', image, image_id, size, hits, created_at, last_used_at FROM image_cache;
+
+DROP TABLE image_cache;
+ALTER TABLE image_cache_new RENAME TO image_cache;
+
+CREATE INDEX IF NOT EXISTS idx_image_cache_last_used_at ON image_cache (last_used_at);
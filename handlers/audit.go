@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService *services.AuditService
+	logger       *utils.Logger
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		logger:       utils.NewLogger("audit"),
+	}
+}
+
+// GetAuditEvents 查询审计事件
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.auditService.List(query)
+	if err != nil {
+		h.logger.WithContext(c).Errorf("查询审计事件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "查询审计事件失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "获取审计事件成功",
+		Data:    result,
+	})
+}
+
+// ExportAuditEvents 以JSON Lines格式导出审计事件
+func (h *AuditHandler) ExportAuditEvents(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.jsonl", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	count, err := h.auditService.ExportJSONLines(query, c.Writer)
+	if err != nil {
+		// 响应头已发送，只能记录错误
+		h.logger.WithContext(c).Errorf("导出审计事件失败: 已导出 %d 条, 错误: %v", count, err)
+		return
+	}
+
+	h.logger.WithContext(c).Infof("导出审计事件完成: %d 条", count)
+}
+
+// parseAuditQuery 解析审计查询参数
+func parseAuditQuery(c *gin.Context) (*models.AuditQuery, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	from, err := utils.ParseTimeParam(c.Query("from"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := utils.ParseTimeParam(c.Query("to"), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuditQuery{
+		Actor:    c.Query("actor"),
+		Action:   c.Query("action"),
+		Resource: c.Query("resource"),
+		Result:   c.Query("result"),
+		SourceIP: c.Query("source_ip"),
+		From:     from,
+		To:       to,
+		Limit:    limit,
+		Offset:   offset,
+	}, nil
+}
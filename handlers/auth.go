@@ -1,19 +1,25 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"net/http"
 
-	"docker-helper/database"
+	"docker-helper/config"
 	"docker-helper/models"
+	"docker-helper/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	users storage.UserRepository
+}
 
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+func NewAuthHandler(store storage.Store) *AuthHandler {
+	return &AuthHandler{
+		users: store.Users(),
+	}
 }
 
 // Login 用户登录
@@ -27,15 +33,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 从数据库获取当前Token
-	var storedToken string
-	query := "SELECT value FROM config WHERE key = 'token'"
-	err := database.DB.QueryRow(query).Scan(&storedToken)
+	// 根据Token查找用户
+	_, err := h.users.GetByToken(context.Background(), req.Token)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusInternalServerError, models.Response{
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, models.Response{
 				Success: false,
-				Message: "系统配置错误，请联系管理员",
+				Message: "Token验证失败，请检查输入",
 			})
 			return
 		}
@@ -46,17 +50,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 验证Token
-	if req.Token != storedToken {
-		c.JSON(http.StatusUnauthorized, models.Response{
-			Success: false,
-			Message: "Token验证失败，请检查输入",
-		})
-		return
-	}
-
 	// 登录成功，设置会话（简单实现，实际应用中可以使用JWT）
-	c.SetCookie("auth_token", req.Token, 3600*24, "/", "", false, true)
+	setAuthCookie(c, req.Token, int(config.Current().CookieMaxAge.Seconds()))
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -67,7 +62,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // Logout 用户退出
 func (h *AuthHandler) Logout(c *gin.Context) {
 	// 清除Cookie
-	c.SetCookie("auth_token", "", -1, "/", "", false, true)
+	setAuthCookie(c, "", -1)
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -116,10 +111,15 @@ func (h *AuthHandler) ChangeToken(c *gin.Context) {
 	}
 
 	// 验证旧Token（这一步其实AuthMiddleware已经做过了，但为了安全再次验证）
-	var storedToken string
-	query := "SELECT value FROM config WHERE key = 'token'"
-	err := database.DB.QueryRow(query).Scan(&storedToken)
+	user, err := h.users.GetByToken(context.Background(), oldToken)
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, models.Response{
+				Success: false,
+				Message: "当前Token验证失败",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "获取当前Token失败: " + err.Error(),
@@ -127,18 +127,8 @@ func (h *AuthHandler) ChangeToken(c *gin.Context) {
 		return
 	}
 
-	if oldToken != storedToken {
-		c.JSON(http.StatusUnauthorized, models.Response{
-			Success: false,
-			Message: "当前Token验证失败",
-		})
-		return
-	}
-
 	// 更新Token
-	updateQuery := "UPDATE config SET value = ?, updated_at = CURRENT_TIMESTAMP WHERE key = 'token'"
-	_, err = database.DB.Exec(updateQuery, req.NewToken)
-	if err != nil {
+	if err := h.users.UpdateToken(context.Background(), user.Username, req.NewToken); err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "更新Token失败: " + err.Error(),
@@ -151,3 +141,15 @@ func (h *AuthHandler) ChangeToken(c *gin.Context) {
 		Message: "Token修改成功",
 	})
 }
+
+// setAuthCookie 设置登录Cookie，路径限定在URL前缀下，HTTPS访问时设置Secure
+//
+// X-Forwarded-Proto 只在请求来自 trusted_proxies 中的反向代理时采信，避免客户端伪造该头。
+func setAuthCookie(c *gin.Context, value string, maxAge int) {
+	cfg := config.Current()
+	secure := c.Request.TLS != nil
+	if !secure && cfg.IsTrustedProxy(c.RemoteIP()) {
+		secure = c.GetHeader("X-Forwarded-Proto") == "https"
+	}
+	c.SetCookie("auth_token", value, maxAge, cfg.BasePath+"/", "", secure, true)
+}
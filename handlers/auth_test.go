@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"docker-helper/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetAuthCookieSecure(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,127.0.0.1")
+	t.Cleanup(func() { config.Init(nil) })
+	if _, err := config.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		tls        bool
+		want       bool
+	}{
+		{"direct https", "198.51.100.7:40000", "", true, true},
+		{"direct http", "198.51.100.7:40000", "", false, false},
+		{"forged header", "198.51.100.7:40000", "https", false, false},
+		{"trusted proxy https", "10.1.2.3:40000", "https", false, true},
+		{"trusted proxy http", "127.0.0.1:40000", "http", false, false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/auth/login", nil)
+		c.Request.RemoteAddr = tt.remoteAddr
+		if tt.proto != "" {
+			c.Request.Header.Set("X-Forwarded-Proto", tt.proto)
+		}
+		if tt.tls {
+			c.Request.TLS = &tls.ConnectionState{}
+		}
+
+		setAuthCookie(c, "token", 60)
+		cookie := w.Header().Get("Set-Cookie")
+		if secure := strings.Contains(cookie, "; Secure"); secure != tt.want {
+			t.Errorf("%s: Set-Cookie = %q, want Secure=%v", tt.name, cookie, tt.want)
+		}
+	}
+}
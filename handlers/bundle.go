@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"docker-helper/middlewares"
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BundleHandler 离线包上传处理器
+type BundleHandler struct {
+	bundles *services.BundleService
+	logger  *utils.Logger
+}
+
+// NewBundleHandler 创建离线包上传处理器
+func NewBundleHandler(bundles *services.BundleService) *BundleHandler {
+	return &BundleHandler{
+		bundles: bundles,
+		logger:  utils.NewLogger("bundle"),
+	}
+}
+
+// CreateUpload 创建上传会话
+func (h *BundleHandler) CreateUpload(c *gin.Context) {
+	var req models.BundleUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	upload, err := h.bundles.CreateUpload(&req, c.GetString(middlewares.ActorContextKey))
+	if err != nil {
+		h.logger.WithContext(c).Errorf("创建上传会话失败: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "上传会话已创建",
+		Data:    upload,
+	})
+}
+
+// GetUpload 获取上传会话，received 为续传的起始位置；校验完成后包含镜像列表
+func (h *BundleHandler) GetUpload(c *gin.Context) {
+	upload, err := h.bundles.GetUpload(c.Param("id"))
+	if err != nil {
+		h.uploadError(c, nil, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "获取上传会话成功",
+		Data:    upload,
+	})
+}
+
+// UploadChunk 接收一个分片，请求头 Content-Range 指定分片位置，如 bytes 0-1048575/5242880
+func (h *BundleHandler) UploadChunk(c *gin.Context) {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(c.GetHeader("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || start < 0 || end < start {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求头 Content-Range 无效，格式为 bytes <起始>-<结束>/<总大小>",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	upload, err := h.bundles.GetUpload(id)
+	if err != nil {
+		h.uploadError(c, nil, err)
+		return
+	}
+	if total != upload.Size {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: fmt.Sprintf("Content-Range 中的总大小 %d 与上传会话的大小 %d 不一致", total, upload.Size),
+		})
+		return
+	}
+
+	upload, err = h.bundles.WriteChunk(id, start, end-start+1, c.Request.Body)
+	if err != nil {
+		h.logger.WithContext(c).Warnf("接收分片失败: %s, 位置: %d-%d, 错误: %v", id, start, end, err)
+		h.uploadError(c, upload, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "分片已接收",
+		Data:    upload,
+	})
+}
+
+// CompleteUpload 结束上传，校验文件与包内摘要并列出其中的镜像
+func (h *BundleHandler) CompleteUpload(c *gin.Context) {
+	id := c.Param("id")
+	upload, err := h.bundles.CompleteUpload(c.Request.Context(), id)
+	if err != nil {
+		h.logger.WithContext(c).Errorf("离线包校验失败: %s, 错误: %v", id, err)
+		h.uploadError(c, upload, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "离线包校验通过",
+		Data:    upload,
+	})
+}
+
+// DeleteUpload 删除上传会话与已上传的文件
+func (h *BundleHandler) DeleteUpload(c *gin.Context) {
+	if err := h.bundles.DeleteUpload(c.Param("id")); err != nil {
+		h.uploadError(c, nil, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "上传会话已删除",
+	})
+}
+
+// uploadError 返回上传错误，upload非空时附带当前状态以便客户端续传
+func (h *BundleHandler) uploadError(c *gin.Context, upload *models.BundleUpload, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrUploadOffset), errors.Is(err, services.ErrUploadIncomplete):
+		status = http.StatusConflict
+	case upload == nil:
+		status = http.StatusInternalServerError
+	}
+
+	response := models.Response{
+		Success: false,
+		Message: err.Error(),
+	}
+	if upload != nil {
+		response.Data = upload
+	}
+	c.JSON(status, response)
+}
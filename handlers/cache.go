@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheHandler 本地镜像缓存处理器
+type CacheHandler struct {
+	cache  *services.ImageCache
+	logger *utils.Logger
+}
+
+// NewCacheHandler 创建本地镜像缓存处理器
+func NewCacheHandler(cache *services.ImageCache) *CacheHandler {
+	return &CacheHandler{
+		cache:  cache,
+		logger: utils.NewLogger("cache"),
+	}
+}
+
+// GetCache 获取缓存策略与当前缓存的镜像
+func (h *CacheHandler) GetCache(c *gin.Context) {
+	status, err := h.cache.Status(c.Request.Context())
+	if err != nil {
+		h.logger.WithContext(c).Errorf("获取镜像缓存失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "获取镜像缓存成功",
+		Data:    status,
+	})
+}
+
+// PurgeCache 清理缓存镜像，可通过多个 image 查询参数指定，未指定时清理全部
+func (h *CacheHandler) PurgeCache(c *gin.Context) {
+	images := c.QueryArray("image")
+
+	result, err := h.cache.Purge(c.Request.Context(), images)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrNotCached) {
+			status = http.StatusNotFound
+		}
+		h.logger.WithContext(c).Errorf("清理镜像缓存失败: %v", err)
+		c.JSON(status, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("镜像缓存已清理: 删除 %d 个，跳过 %d 个", len(result.Removed), len(result.Skipped))
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "镜像缓存已清理",
+		Data:    result,
+	})
+}
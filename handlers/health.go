@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"docker-helper/models"
+	"docker-helper/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler 存活与就绪检查处理器
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+// NewHealthHandler 创建健康检查处理器
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Live 存活检查，只要进程能够处理请求即返回ok，不检查外部依赖
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"status":         models.HealthStatusOK,
+		"message":        "Docker镜像转换服务运行正常",
+		"uptime_seconds": int64(h.healthService.Uptime().Seconds()),
+	})
+}
+
+// Ready 就绪检查，任一检查失败时返回503；refresh=true 时忽略缓存
+func (h *HealthHandler) Ready(c *gin.Context) {
+	report := h.healthService.Readiness(c.Request.Context(), c.Query("refresh") == "true")
+
+	status := http.StatusOK
+	if report.Status != models.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -1,152 +1,201 @@
 package handlers
 
 import (
-	"docker-helper/utils"
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
-	"docker-helper/database"
 	"docker-helper/models"
-
-	"database/sql"
+	"docker-helper/services"
+	"docker-helper/storage"
+	"docker-helper/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type HistoryHandler struct {
-	logger *utils.Logger
+	tasks          storage.TaskRepository
+	historyService *services.HistoryService
+	logger         *utils.Logger
 }
 
-func NewHistoryHandler() *HistoryHandler {
-	logger := utils.NewLogger("info")
+func NewHistoryHandler(store storage.Store, historyService *services.HistoryService) *HistoryHandler {
+	logger := utils.NewLogger("history")
 	return &HistoryHandler{
-		logger: logger,
+		tasks:          store.Tasks(),
+		historyService: historyService,
+		logger:         logger,
 	}
 }
 
-// GetHistory 获取转换历史记录（从tasks表中查询已完成的任务）
+// GetHistory 获取转换历史记录（从tasks表中查询已完成的任务），支持过滤、排序和游标分页
 func (h *HistoryHandler) GetHistory(c *gin.Context) {
-	// 获取分页参数
-	limitStr := c.DefaultQuery("limit", "10")
-	offsetStr := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 || limit > 100 {
-		limit = 10
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "查询参数无效: " + err.Error(),
+		})
+		return
 	}
 
-	// 从tasks表查询已完成的任务作为历史记录
-	query := `
-		SELECT id, source_image, target_image, target_host, status, error_msg, duration, created_at
-		FROM tasks
-		WHERE status IN ('completed', 'failed', 'cancelled')
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`
-
-	// 使用DEBUG级别记录SQL查询
-	h.logger.DebugSQL(query, limit, offset)
-
-	rows, err := database.DB.Query(query, limit, offset)
+	total, err := h.tasks.CountHistory(context.Background(), query)
 	if err != nil {
-		h.logger.Errorf("查询历史记录失败: %v", err)
+		h.logger.WithContext(c).Errorf("统计历史记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "查询历史记录失败: " + err.Error(),
 		})
 		return
 	}
-	defer rows.Close()
-
-	var history []models.HistoryItem
-	for rows.Next() {
-		var item models.HistoryItem
-		var taskID string
-		err := rows.Scan(
-			&taskID,
-			&item.SourceImage,
-			&item.TargetImage,
-			&item.TargetHost,
-			&item.Status,
-			&item.ErrorMsg,
-			&item.Duration,
-			&item.CreatedAt,
-		)
-		if err != nil {
-			h.logger.Errorf("解析历史记录失败: %v", err)
-			c.JSON(http.StatusInternalServerError, models.Response{
-				Success: false,
-				Message: "解析历史记录失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 将UUID转换为整型ID（为了兼容前端）
-		item.ID = len(history) + 1 + offset
-
-		// 状态映射：completed -> success（保持前端兼容）
-		if item.Status == "completed" {
-			item.Status = "success"
-		}
-
-		history = append(history, item)
-	}
 
-	if err = rows.Err(); err != nil {
-		h.logger.Errorf("读取历史记录失败: %v", err)
+	// 多取一条用于判断是否还有下一页
+	limit := query.Limit
+	query.Limit = limit + 1
+	tasks, err := h.tasks.ListHistory(context.Background(), query)
+	if err != nil {
+		h.logger.WithContext(c).Errorf("查询历史记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
-			Message: "读取历史记录失败: " + err.Error(),
+			Message: "查询历史记录失败: " + err.Error(),
 		})
 		return
 	}
 
+	response := models.HistoryListResponse{
+		Items:  make([]models.HistoryItem, 0, len(tasks)),
+		Total:  total,
+		Limit:  limit,
+		Offset: query.Offset,
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		response.HasMore = true
+		response.NextCursor = models.NewHistoryCursor(tasks[len(tasks)-1], query.Sort, query.Desc).Encode()
+	}
+
+	for _, task := range tasks {
+		response.Items = append(response.Items, toHistoryItem(task))
+	}
+
 	// 成功时使用轮询感知的日志
 	requestPath := c.Request.URL.Path
-	h.logger.InfoPolling(requestPath, "获取历史记录成功，返回 %d 条记录", len(history))
+	h.logger.WithContext(c).InfoPolling(requestPath, "获取历史记录成功，返回 %d 条记录", len(response.Items))
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
 		Message: "获取历史记录成功",
-		Data:    history,
+		Data:    response,
 	})
 }
 
-// GetHistoryStats 获取历史统计信息（从tasks表统计已完成任务）
-func (h *HistoryHandler) GetHistoryStats(c *gin.Context) {
-	query := `
-		SELECT 
-			COALESCE(COUNT(*), 0) as total,
-			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success_count,
-			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed_count,
-			COALESCE(AVG(CASE WHEN status = 'completed' THEN duration ELSE NULL END), 0) as avg_duration
-		FROM tasks
-		WHERE status IN ('completed', 'failed', 'cancelled')
-	`
-
-	var stats struct {
-		Total        int     `json:"total"`
-		SuccessCount int     `json:"success_count"`
-		FailedCount  int     `json:"failed_count"`
-		AvgDuration  float64 `json:"avg_duration"`
+// toHistoryItem 将任务转换为历史记录项
+func toHistoryItem(task *models.Task) models.HistoryItem {
+	duration := task.Duration
+	item := models.HistoryItem{
+		ID:          task.ID,
+		SourceImage: task.SourceImage,
+		TargetImage: task.TargetImage,
+		TargetHost:  task.TargetHost,
+		ConfigID:    task.ConfigID,
+		CreatedBy:   task.CreatedBy,
+		Status:      task.Status,
+		ErrorMsg:    task.ErrorMsg,
+		Duration:    &duration,
+		CreatedAt:   task.CreatedAt,
+		CompletedAt: task.CompletedAt,
+	}
+
+	// 状态映射：completed -> success（保持前端兼容）
+	if item.Status == models.TaskStatusCompleted {
+		item.Status = "success"
+	}
+
+	return item
+}
+
+// parseHistoryQuery 解析历史记录的过滤、排序与分页参数
+func parseHistoryQuery(c *gin.Context) (*models.HistoryQuery, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := &models.HistoryQuery{
+		Search:     c.Query("search"),
+		Source:     c.Query("source"),
+		Target:     c.Query("target"),
+		TargetHost: c.Query("target_host"),
+		ConfigID:   c.Query("config_id"),
+		CreatedBy:  c.Query("user"),
+		Sort:       c.DefaultQuery("sort", models.HistorySortCreatedAt),
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	// 状态支持逗号分隔的多个值，success 等同于 completed
+	if status := c.Query("status"); status != "" {
+		for _, value := range strings.Split(status, ",") {
+			switch value = strings.TrimSpace(value); value {
+			case "success", models.TaskStatusCompleted:
+				query.Statuses = append(query.Statuses, models.TaskStatusCompleted)
+			case models.TaskStatusFailed, models.TaskStatusCancelled, models.TaskStatusInterrupted:
+				query.Statuses = append(query.Statuses, value)
+			default:
+				return nil, fmt.Errorf("不支持的状态: %s", value)
+			}
+		}
+	}
+
+	switch query.Sort {
+	case models.HistorySortCreatedAt, models.HistorySortCompletedAt, models.HistorySortDuration:
+	default:
+		return nil, fmt.Errorf("不支持的排序字段: %s", query.Sort)
+	}
+
+	switch order := c.DefaultQuery("order", "desc"); order {
+	case "desc":
+		query.Desc = true
+	case "asc":
+	default:
+		return nil, fmt.Errorf("不支持的排序方向: %s", order)
+	}
+
+	if query.From, err = utils.ParseTimeParam(c.Query("from"), false); err != nil {
+		return nil, err
+	}
+	if query.To, err = utils.ParseTimeParam(c.Query("to"), true); err != nil {
+		return nil, err
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := models.DecodeHistoryCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != query.Sort || after.Desc != query.Desc {
+			return nil, fmt.Errorf("游标与当前排序参数不一致")
+		}
+		query.After = after
+		query.Offset = 0
 	}
 
-	// 使用DEBUG级别记录SQL查询，避免轮询时的日志噪音
-	h.logger.DebugSQL(query)
+	return query, nil
+}
 
-	err := database.DB.QueryRow(query).Scan(
-		&stats.Total,
-		&stats.SuccessCount,
-		&stats.FailedCount,
-		&stats.AvgDuration,
-	)
+// GetHistoryStats 获取历史统计信息（从tasks表统计已完成任务）
+func (h *HistoryHandler) GetHistoryStats(c *gin.Context) {
+	stats, err := h.tasks.HistoryStats(context.Background())
 	if err != nil {
-		h.logger.Errorf("获取统计信息失败: %v", err)
+		h.logger.WithContext(c).Errorf("获取统计信息失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "获取统计信息失败: " + err.Error(),
@@ -156,7 +205,7 @@ func (h *HistoryHandler) GetHistoryStats(c *gin.Context) {
 
 	// 成功时使用轮询感知的日志，简化输出
 	requestPath := c.Request.URL.Path
-	h.logger.InfoPolling(requestPath, "统计查询成功: total=%d, success=%d, failed=%d",
+	h.logger.WithContext(c).InfoPolling(requestPath, "统计查询成功: total=%d, success=%d, failed=%d",
 		stats.Total, stats.SuccessCount, stats.FailedCount)
 
 	c.JSON(http.StatusOK, models.Response{
@@ -168,11 +217,9 @@ func (h *HistoryHandler) GetHistoryStats(c *gin.Context) {
 
 // ClearHistory 清空历史记录（删除tasks表中已完成的任务）
 func (h *HistoryHandler) ClearHistory(c *gin.Context) {
-	query := "DELETE FROM tasks WHERE status IN ('completed', 'failed', 'cancelled')"
-
-	result, err := database.DB.Exec(query)
+	result, err := h.historyService.Delete(context.Background(), &models.HistoryQuery{}, "clear")
 	if err != nil {
-		h.logger.Errorf("清空历史记录失败: %v", err)
+		h.logger.WithContext(c).Errorf("清空历史记录失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "清空历史记录失败: " + err.Error(),
@@ -180,64 +227,72 @@ func (h *HistoryHandler) ClearHistory(c *gin.Context) {
 		return
 	}
 
-	// 获取删除的记录数
-	rowsAffected, _ := result.RowsAffected()
-	h.logger.Infof("已清空 %d 条历史记录", rowsAffected)
+	h.logger.WithContext(c).Infof("已清空 %d 条历史记录", result.Deleted)
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
 		Message: "历史记录已清空",
+		Data:    result,
+	})
+}
+
+// DeleteHistoryRecords 按过滤条件删除历史记录，过滤参数与 GetHistory 相同
+func (h *HistoryHandler) DeleteHistoryRecords(c *gin.Context) {
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "查询参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	// 必须至少指定一个过滤条件，清空全部请使用 DELETE /api/history
+	if !query.HasFilters() {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请至少指定一个过滤条件",
+		})
+		return
+	}
+
+	result, err := h.historyService.Delete(context.Background(), query, "delete")
+	if err != nil {
+		h.logger.WithContext(c).Errorf("删除历史记录失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("已按条件删除 %d 条历史记录", result.Deleted)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: fmt.Sprintf("已删除 %d 条历史记录", result.Deleted),
+		Data:    result,
+	})
+}
+
+// GetRetention 获取历史记录保留策略及最近一次清理结果
+func (h *HistoryHandler) GetRetention(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "获取保留策略成功",
+		Data:    h.historyService.Status(),
 	})
 }
 
 // GetDetailedStats 获取详细统计信息
 func (h *HistoryHandler) GetDetailedStats(c *gin.Context) {
-	// 1. 按日期统计任务数量
-	dateStatsQuery := `
-		SELECT 
-			DATE(created_at) as date,
-			COUNT(*) as total,
-			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success,
-			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed,
-			AVG(CASE WHEN status = 'completed' THEN duration ELSE NULL END) as avg_duration
-		FROM tasks
-		WHERE status IN ('completed', 'failed', 'cancelled')
-		AND created_at >= DATE('now', '-30 days')
-		GROUP BY DATE(created_at)
-		ORDER BY date DESC
-		LIMIT 30
-	`
-
-	// 2. 按仓库统计
-	registryStatsQuery := `
-		SELECT 
-			target_host,
-			COUNT(*) as total,
-			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success,
-			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed
-		FROM tasks
-		WHERE status IN ('completed', 'failed', 'cancelled')
-		GROUP BY target_host
-		ORDER BY total DESC
-		LIMIT 10
-	`
+	ctx := context.Background()
 
-	// 3. 失败原因统计
-	failureStatsQuery := `
-		SELECT 
-			error_msg,
-			COUNT(*) as count
-		FROM tasks
-		WHERE status = 'failed' AND error_msg IS NOT NULL
-		GROUP BY error_msg
-		ORDER BY count DESC
-		LIMIT 10
-	`
-
-	// 执行查询
-	dateStats, err := h.queryDateStats(dateStatsQuery)
+	// 1. 按日期统计最近30天的任务数量
+	since := time.Now().AddDate(0, 0, -30)
+	dateStats, err := h.tasks.DateStats(ctx, &models.HistoryQuery{From: &since}, 30)
 	if err != nil {
-		h.logger.Errorf("查询日期统计失败: %v", err)
+		h.logger.WithContext(c).Errorf("查询日期统计失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "查询日期统计失败: " + err.Error(),
@@ -245,9 +300,10 @@ func (h *HistoryHandler) GetDetailedStats(c *gin.Context) {
 		return
 	}
 
-	registryStats, err := h.queryRegistryStats(registryStatsQuery)
+	// 2. 按仓库统计
+	registryStats, err := h.tasks.RegistryStats(ctx, &models.HistoryQuery{}, 10)
 	if err != nil {
-		h.logger.Errorf("查询仓库统计失败: %v", err)
+		h.logger.WithContext(c).Errorf("查询仓库统计失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "查询仓库统计失败: " + err.Error(),
@@ -255,9 +311,10 @@ func (h *HistoryHandler) GetDetailedStats(c *gin.Context) {
 		return
 	}
 
-	failureStats, err := h.queryFailureStats(failureStatsQuery)
+	// 3. 失败原因统计
+	failureStats, err := h.tasks.FailureStats(ctx, &models.HistoryQuery{}, 10)
 	if err != nil {
-		h.logger.Errorf("查询失败统计失败: %v", err)
+		h.logger.WithContext(c).Errorf("查询失败统计失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "查询失败统计失败: " + err.Error(),
@@ -265,106 +322,66 @@ func (h *HistoryHandler) GetDetailedStats(c *gin.Context) {
 		return
 	}
 
-	// 构建响应数据
-	detailedStats := map[string]interface{}{
-		"date_stats":     dateStats,
-		"registry_stats": registryStats,
-		"failure_stats":  failureStats,
-	}
-
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
 		Message: "获取详细统计信息成功",
-		Data:    detailedStats,
+		Data: models.DetailedStats{
+			DateStats:     dateStats,
+			RegistryStats: registryStats,
+			FailureStats:  failureStats,
+		},
 	})
 }
 
-// queryDateStats 查询日期统计
-func (h *HistoryHandler) queryDateStats(query string) ([]map[string]interface{}, error) {
-	rows, err := database.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var stats []map[string]interface{}
-	for rows.Next() {
-		var date string
-		var total, success, failed int
-		var avgDuration sql.NullFloat64
-
-		err := rows.Scan(&date, &total, &success, &failed, &avgDuration)
-		if err != nil {
-			return nil, err
-		}
-
-		stat := map[string]interface{}{
-			"date":         date,
-			"total":        total,
-			"success":      success,
-			"failed":       failed,
-			"avg_duration": avgDuration.Float64,
-		}
-		stats = append(stats, stat)
-	}
+// ExportHistory 按过滤条件流式导出历史记录，format 可选 csv/jsonl/xlsx
+func (h *HistoryHandler) ExportHistory(c *gin.Context) {
+	h.export(c, "history", func(query *models.HistoryQuery, writer utils.TableWriter) (int, error) {
+		return h.historyService.ExportHistory(context.Background(), query, writer)
+	})
+}
 
-	return stats, nil
+// ExportDetailedStats 按过滤条件导出日期、仓库和失败原因统计，format 可选 csv/jsonl/xlsx
+func (h *HistoryHandler) ExportDetailedStats(c *gin.Context) {
+	h.export(c, "history-stats", func(query *models.HistoryQuery, writer utils.TableWriter) (int, error) {
+		return h.historyService.ExportDetailedStats(context.Background(), query, writer)
+	})
 }
 
-// queryRegistryStats 查询仓库统计
-func (h *HistoryHandler) queryRegistryStats(query string) ([]map[string]interface{}, error) {
-	rows, err := database.DB.Query(query)
+// export 解析过滤条件与导出格式，设置下载响应头后调用write写出数据
+func (h *HistoryHandler) export(c *gin.Context, name string, write func(*models.HistoryQuery, utils.TableWriter) (int, error)) {
+	query, err := parseHistoryQuery(c)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var stats []map[string]interface{}
-	for rows.Next() {
-		var registry string
-		var total, success, failed int
-
-		err := rows.Scan(&registry, &total, &success, &failed)
-		if err != nil {
-			return nil, err
-		}
-
-		stat := map[string]interface{}{
-			"registry": registry,
-			"total":    total,
-			"success":  success,
-			"failed":   failed,
-		}
-		stats = append(stats, stat)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "查询参数无效: " + err.Error(),
+		})
+		return
 	}
 
-	return stats, nil
-}
-
-// queryFailureStats 查询失败统计
-func (h *HistoryHandler) queryFailureStats(query string) ([]map[string]interface{}, error) {
-	rows, err := database.DB.Query(query)
+	format := c.DefaultQuery("format", utils.ExportCSV)
+	writer, err := utils.NewTableWriter(format, c.Writer)
 	if err != nil {
-		return nil, err
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
 	}
-	defer rows.Close()
-
-	var stats []map[string]interface{}
-	for rows.Next() {
-		var errorMsg string
-		var count int
 
-		err := rows.Scan(&errorMsg, &count)
-		if err != nil {
-			return nil, err
-		}
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", utils.ExportContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
 
-		stat := map[string]interface{}{
-			"error_msg": errorMsg,
-			"count":     count,
-		}
-		stats = append(stats, stat)
+	count, err := write(query, writer)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// 响应头已发送，只能记录错误
+		h.logger.WithContext(c).Errorf("导出%s失败: 已导出 %d 行, 错误: %v", name, count, err)
+		return
 	}
 
-	return stats, nil
+	h.logger.WithContext(c).Infof("导出%s完成: %d 行, 格式=%s", name, count, format)
 }
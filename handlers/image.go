@@ -4,6 +4,7 @@ import (
 	"docker-helper/utils"
 	"net/http"
 
+	"docker-helper/config"
 	"docker-helper/models"
 	"docker-helper/services"
 
@@ -21,7 +22,7 @@ func NewImageHandler() (*ImageHandler, error) {
 		return nil, err
 	}
 
-	logger := utils.NewLogger("info")
+	logger := utils.NewLogger("image")
 
 	return &ImageHandler{
 		imageService: imageService,
@@ -33,7 +34,7 @@ func NewImageHandler() (*ImageHandler, error) {
 func (h *ImageHandler) ParseImage(c *gin.Context) {
 	var req models.ImageParseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Errorf("镜像解析请求参数无效: %v", err)
+		h.logger.WithContext(c).Errorf("镜像解析请求参数无效: %v", err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "请求参数无效: " + err.Error(),
@@ -41,12 +42,12 @@ func (h *ImageHandler) ParseImage(c *gin.Context) {
 		return
 	}
 
-	h.logger.Infof("收到镜像解析请求: %s", req.Image)
+	h.logger.WithContext(c).Infof("收到镜像解析请求: %s", req.Image)
 
 	// 解析镜像名称
 	imageInfo, err := h.imageService.ParseImage(req.Image)
 	if err != nil {
-		h.logger.Errorf("镜像名称解析失败: %s, 错误: %v", req.Image, err)
+		h.logger.WithContext(c).Errorf("镜像名称解析失败: %s, 错误: %v", req.Image, err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "镜像名称解析失败: " + err.Error(),
@@ -61,9 +62,11 @@ func (h *ImageHandler) ParseImage(c *gin.Context) {
 		Namespace:   imageInfo["namespace"],
 		Repository:  imageInfo["repository"],
 		Tag:         imageInfo["tag"],
+
+		TargetNamespace: config.Current().TargetNamespace,
 	}
 
-	h.logger.Infof("镜像解析成功: %s -> %s", req.Image, imageInfo["parsed_image"])
+	h.logger.WithContext(c).Infof("镜像解析成功: %s -> %s", req.Image, imageInfo["parsed_image"])
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -80,7 +83,7 @@ func (h *ImageHandler) BuildTargetImage(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Errorf("目标镜像构建请求参数无效: %v", err)
+		h.logger.WithContext(c).Errorf("目标镜像构建请求参数无效: %v", err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "请求参数无效: " + err.Error(),
@@ -88,12 +91,12 @@ func (h *ImageHandler) BuildTargetImage(c *gin.Context) {
 		return
 	}
 
-	h.logger.Infof("收到目标镜像构建请求: %s -> %s", req.SourceImage, req.TargetHost)
+	h.logger.WithContext(c).Infof("收到目标镜像构建请求: %s -> %s", req.SourceImage, req.TargetHost)
 
 	// 解析源镜像
 	imageInfo, err := h.imageService.ParseImage(req.SourceImage)
 	if err != nil {
-		h.logger.Errorf("源镜像名称解析失败: %s, 错误: %v", req.SourceImage, err)
+		h.logger.WithContext(c).Errorf("源镜像名称解析失败: %s, 错误: %v", req.SourceImage, err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "源镜像名称解析失败: " + err.Error(),
@@ -101,26 +104,10 @@ func (h *ImageHandler) BuildTargetImage(c *gin.Context) {
 		return
 	}
 
-	// 构建目标镜像名称 (使用utils包中的函数)
-	// 这里为了简化，直接在handler中构建
-	registry := imageInfo["registry"]
-	namespace := imageInfo["namespace"]
-	repository := imageInfo["repository"]
-	tag := imageInfo["tag"]
-
-	var targetImage string
-	if registry == "docker.io" && namespace == "library" {
-		// nginx:latest -> harbor.com/transform/nginx:latest
-		targetImage = req.TargetHost + "/transform/" + repository + ":" + tag
-	} else if registry == "docker.io" {
-		// user/nginx:latest -> harbor.com/transform/user/nginx:latest
-		targetImage = req.TargetHost + "/transform/" + namespace + "/" + repository + ":" + tag
-	} else {
-		// gcr.io/google/nginx:latest -> harbor.com/transform/gcr.io/google/nginx:latest
-		targetImage = req.TargetHost + "/transform/" + registry + "/" + namespace + "/" + repository + ":" + tag
-	}
+	// 目标镜像：仓库地址 + 可配置的命名空间（target_namespace，默认transform）+ 源镜像路径
+	targetImage := utils.BuildTargetImageName(req.SourceImage, req.TargetHost, config.Current().TargetNamespace)
 
-	h.logger.Infof("目标镜像构建成功: %s -> %s", req.SourceImage, targetImage)
+	h.logger.WithContext(c).Infof("目标镜像构建成功: %s -> %s", req.SourceImage, targetImage)
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetSigningKeys 获取仓库配置的签名验证公钥
+func (h *RegistryHandler) GetSigningKeys(c *gin.Context) {
+	configID := c.Param("id")
+	if _, err := h.configs.Get(context.Background(), configID); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			Success: false,
+			Message: "仓库配置不存在",
+		})
+		return
+	}
+
+	keys, err := h.keys.List(context.Background(), configID)
+	if err != nil {
+		h.logger.WithContext(c).Errorf("查询签名验证公钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "查询签名验证公钥失败: " + err.Error(),
+		})
+		return
+	}
+	if keys == nil {
+		keys = []*models.SigningKey{}
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "获取签名验证公钥成功",
+		Data:    keys,
+	})
+}
+
+// CreateSigningKey 为仓库配置添加签名验证公钥，添加后推送到该仓库的镜像需要通过签名验证
+func (h *RegistryHandler) CreateSigningKey(c *gin.Context) {
+	configID := c.Param("id")
+
+	var req models.CreateSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithContext(c).Errorf("添加签名验证公钥请求参数解析失败: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.configs.Get(context.Background(), configID); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			Success: false,
+			Message: "仓库配置不存在",
+		})
+		return
+	}
+
+	_, algorithm, fingerprint, err := services.ParsePublicKey(req.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "公钥无效: " + err.Error(),
+		})
+		return
+	}
+
+	keys, err := h.keys.List(context.Background(), configID)
+	if err != nil {
+		h.logger.WithContext(c).Errorf("查询签名验证公钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "查询签名验证公钥失败: " + err.Error(),
+		})
+		return
+	}
+	for _, key := range keys {
+		if key.Fingerprint == fingerprint {
+			c.JSON(http.StatusConflict, models.Response{
+				Success: false,
+				Message: "该公钥已添加: " + key.Name,
+			})
+			return
+		}
+	}
+
+	key := &models.SigningKey{
+		ID:          uuid.New().String(),
+		ConfigID:    configID,
+		Name:        req.Name,
+		PublicKey:   req.PublicKey,
+		Algorithm:   algorithm,
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Now(),
+	}
+	if err := h.keys.Create(context.Background(), key); err != nil {
+		h.logger.WithContext(c).Errorf("添加签名验证公钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "添加签名验证公钥失败: " + err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("已添加签名验证公钥: 仓库配置=%s, 名称=%s, 指纹=%s", configID, req.Name, fingerprint)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "签名验证公钥添加成功",
+		Data:    key,
+	})
+}
+
+// DeleteSigningKey 删除仓库配置的签名验证公钥，删除最后一个公钥后不再验证签名
+func (h *RegistryHandler) DeleteSigningKey(c *gin.Context) {
+	configID, keyID := c.Param("id"), c.Param("key_id")
+
+	if err := h.keys.Delete(context.Background(), configID, keyID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Success: false,
+				Message: "签名验证公钥不存在",
+			})
+			return
+		}
+
+		h.logger.WithContext(c).Errorf("删除签名验证公钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "删除签名验证公钥失败: " + err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("已删除签名验证公钥: 仓库配置=%s, 公钥=%s", configID, keyID)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "签名验证公钥删除成功",
+	})
+}
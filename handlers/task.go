@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"docker-helper/middlewares"
 	"docker-helper/models"
 	"docker-helper/services"
+	"docker-helper/storage"
 	"docker-helper/utils"
 
 	"github.com/gin-gonic/gin"
@@ -15,25 +19,20 @@ type TaskHandler struct {
 	logger      *utils.Logger
 }
 
-func NewTaskHandler() (*TaskHandler, error) {
-	taskService, err := services.NewTaskService()
-	if err != nil {
-		return nil, err
-	}
-
-	logger := utils.NewLogger("info")
+func NewTaskHandler(taskService *services.TaskService) *TaskHandler {
+	logger := utils.NewLogger("task")
 
 	return &TaskHandler{
 		taskService: taskService,
 		logger:      logger,
-	}, nil
+	}
 }
 
 // CreateTask 创建新任务 (异步执行)
 func (h *TaskHandler) CreateTask(c *gin.Context) {
 	var req models.TransformRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Errorf("任务创建请求参数解析失败: %v", err)
+		h.logger.WithContext(c).Errorf("任务创建请求参数解析失败: %v", err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "请求参数无效: " + err.Error(),
@@ -41,20 +40,123 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	h.logger.Infof("收到任务创建请求: 源镜像=%s, 目标镜像=%s", req.SourceImage, req.TargetImage)
+	h.logger.WithContext(c).Infof("收到任务创建请求: 源镜像=%s, 目标镜像=%s, 目标仓库数=%d", req.SourceImage, req.TargetImage, len(req.Targets))
 
 	// 创建任务
-	response, err := h.taskService.CreateTask(&req)
+	response, err := h.taskService.CreateTask(c.Request.Context(), &req, c.GetString(middlewares.ActorContextKey))
+	if err != nil {
+		h.logger.WithContext(c).Errorf("创建任务失败: %v", err)
+		auditPolicyRejection(c, err)
+		c.JSON(createTaskErrorStatus(err), models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("任务创建成功: %s", response.TaskID)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: response.Message,
+		Data:    response,
+	})
+}
+
+// CreateBundleTask 创建离线包导出任务 (异步执行)
+func (h *TaskHandler) CreateBundleTask(c *gin.Context) {
+	var req models.BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithContext(c).Errorf("离线包任务请求参数解析失败: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("收到离线包任务创建请求: 镜像=%v, 格式=%s", req.Images, req.Format)
+
+	response, err := h.taskService.CreateBundleTask(c.Request.Context(), &req, c.GetString(middlewares.ActorContextKey))
+	if err != nil {
+		h.logger.WithContext(c).Errorf("创建离线包任务失败: %v", err)
+		auditPolicyRejection(c, err)
+		c.JSON(createTaskErrorStatus(err), models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("离线包任务创建成功: %s", response.TaskID)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: response.Message,
+		Data:    response,
+	})
+}
+
+// CreateImportTask 创建离线包导入任务 (异步执行)
+func (h *TaskHandler) CreateImportTask(c *gin.Context) {
+	var req models.ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithContext(c).Errorf("导入任务请求参数解析失败: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("收到导入任务创建请求: 上传=%s, 仓库配置=%s", req.UploadID, req.ConfigID)
+
+	response, err := h.taskService.CreateImportTask(c.Request.Context(), &req, c.GetString(middlewares.ActorContextKey))
 	if err != nil {
-		h.logger.Errorf("创建任务失败: %v", err)
+		h.logger.WithContext(c).Errorf("创建导入任务失败: %v", err)
+		auditPolicyRejection(c, err)
+		c.JSON(createTaskErrorStatus(err), models.Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("导入任务创建成功: %s", response.TaskID)
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: response.Message,
+		Data:    response,
+	})
+}
+
+// CreatePromoteTask 创建镜像晋级任务 (异步执行)，在同一仓库中为已有镜像添加标签
+func (h *TaskHandler) CreatePromoteTask(c *gin.Context) {
+	var req models.PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithContext(c).Errorf("晋级任务请求参数解析失败: %v", err)
 		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithContext(c).Infof("收到晋级任务创建请求: 镜像=%s, 标签=%v, 仓库配置=%s", req.Image, req.Tags, req.ConfigID)
+
+	response, err := h.taskService.CreatePromoteTask(c.Request.Context(), &req, c.GetString(middlewares.ActorContextKey))
+	if err != nil {
+		h.logger.WithContext(c).Errorf("创建晋级任务失败: %v", err)
+		auditPolicyRejection(c, err)
+		c.JSON(createTaskErrorStatus(err), models.Response{
 			Success: false,
 			Message: err.Error(),
 		})
 		return
 	}
 
-	h.logger.Infof("任务创建成功: %s", response.TaskID)
+	h.logger.WithContext(c).Infof("晋级任务创建成功: %s", response.TaskID)
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -63,6 +165,49 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 	})
 }
 
+// DownloadBundle 下载离线包任务生成的文件，支持断点续传（Range请求）
+func (h *TaskHandler) DownloadBundle(c *gin.Context) {
+	taskID := c.Param("id")
+
+	task, file, err := h.taskService.OpenBundle(taskID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "读取离线包失败: " + err.Error()
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			status, message = http.StatusNotFound, "任务不存在"
+		case errors.Is(err, services.ErrBundleNotReady):
+			status, message = http.StatusConflict, err.Error()
+		case errors.Is(err, services.ErrBundleNotFound):
+			status, message = http.StatusGone, err.Error()
+		}
+		h.logger.WithContext(c).Errorf("下载离线包失败: %s, 错误: %v", taskID, err)
+		c.JSON(status, models.Response{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.logger.WithContext(c).Errorf("读取离线包失败: %s, 错误: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Message: "读取离线包失败: " + err.Error(),
+		})
+		return
+	}
+
+	if task.ArtifactSHA256 != nil {
+		c.Header("X-Checksum-Sha256", *task.ArtifactSHA256)
+	}
+	c.Header("Content-Type", "application/x-tar")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, h.taskService.BundleFileName(taskID)))
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), file)
+}
+
 // GetTask 获取单个任务状态
 func (h *TaskHandler) GetTask(c *gin.Context) {
 	taskID := c.Param("id")
@@ -76,7 +221,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 
 	task, err := h.taskService.GetTask(taskID)
 	if err != nil {
-		h.logger.Errorf("获取任务失败: %s, 错误: %v", taskID, err)
+		h.logger.WithContext(c).Errorf("获取任务失败: %s, 错误: %v", taskID, err)
 		c.JSON(http.StatusNotFound, models.Response{
 			Success: false,
 			Message: err.Error(),
@@ -96,7 +241,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 func (h *TaskHandler) GetTaskList(c *gin.Context) {
 	taskList, err := h.taskService.GetTaskList()
 	if err != nil {
-		h.logger.Errorf("获取任务列表失败: %v", err)
+		h.logger.WithContext(c).Errorf("获取任务列表失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "获取任务列表失败: " + err.Error(),
@@ -116,7 +261,7 @@ func (h *TaskHandler) GetTaskList(c *gin.Context) {
 func (h *TaskHandler) GetTaskStats(c *gin.Context) {
 	stats, err := h.taskService.GetTaskStats()
 	if err != nil {
-		h.logger.Errorf("获取任务统计失败: %v", err)
+		h.logger.WithContext(c).Errorf("获取任务统计失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Success: false,
 			Message: "获取任务统计失败: " + err.Error(),
@@ -145,7 +290,7 @@ func (h *TaskHandler) CancelTask(c *gin.Context) {
 
 	err := h.taskService.CancelTask(taskID)
 	if err != nil {
-		h.logger.Errorf("取消任务失败: %s, 错误: %v", taskID, err)
+		h.logger.WithContext(c).Errorf("取消任务失败: %s, 错误: %v", taskID, err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: err.Error(),
@@ -153,7 +298,7 @@ func (h *TaskHandler) CancelTask(c *gin.Context) {
 		return
 	}
 
-	h.logger.Infof("任务取消成功: %s", taskID)
+	h.logger.WithContext(c).Infof("任务取消成功: %s", taskID)
 
 	c.JSON(http.StatusOK, models.Response{
 		Success: true,
@@ -161,10 +306,24 @@ func (h *TaskHandler) CancelTask(c *gin.Context) {
 	})
 }
 
-// Close 关闭处理器
-func (h *TaskHandler) Close() error {
-	if h.taskService != nil {
-		return h.taskService.Close()
+// createTaskErrorStatus 创建任务失败时的状态码，服务关闭中返回503以便客户端重试
+func createTaskErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPolicyRejected), errors.Is(err, services.ErrSignatureRequired):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUploadNotReady):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// auditPolicyRejection 任务被源镜像策略拒绝时以单独的审计动作记录，便于按动作查询
+func auditPolicyRejection(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrPolicyRejected) {
+		c.Set(middlewares.AuditActionContextKey, "task.policy_reject")
 	}
-	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"docker-helper/utils"
 	"net/http"
 
+	"docker-helper/middlewares"
 	"docker-helper/models"
 
 	"github.com/gin-gonic/gin"
@@ -15,25 +16,20 @@ type TransformHandler struct {
 	logger      *utils.Logger
 }
 
-func NewTransformHandler() (*TransformHandler, error) {
-	taskService, err := services.NewTaskService()
-	if err != nil {
-		return nil, err
-	}
-
-	logger := utils.NewLogger("info")
+func NewTransformHandler(taskService *services.TaskService) *TransformHandler {
+	logger := utils.NewLogger("transform")
 
 	return &TransformHandler{
 		taskService: taskService,
 		logger:      logger,
-	}, nil
+	}
 }
 
 // StartTransform 开始镜像转换 (异步执行，立即返回任务ID)
 func (h *TransformHandler) StartTransform(c *gin.Context) {
 	var req models.TransformRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Errorf("转换请求参数解析失败: %v", err)
+		h.logger.WithContext(c).Errorf("转换请求参数解析失败: %v", err)
 		c.JSON(http.StatusBadRequest, models.Response{
 			Success: false,
 			Message: "请求参数无效: " + err.Error(),
@@ -43,26 +39,30 @@ func (h *TransformHandler) StartTransform(c *gin.Context) {
 
 	// 记录请求开始
 	clientIP := c.ClientIP()
-	h.logger.Infof("======== 收到镜像转换请求（异步） ========")
-	h.logger.Infof("客户端IP: %s", clientIP)
-	h.logger.Infof("源镜像: %s", req.SourceImage)
-	h.logger.Infof("目标镜像: %s", req.TargetImage)
+	h.logger.WithContext(c).Infof("======== 收到镜像转换请求（异步） ========")
+	h.logger.WithContext(c).Infof("客户端IP: %s", clientIP)
+	h.logger.WithContext(c).Infof("源镜像: %s", req.SourceImage)
+	h.logger.WithContext(c).Infof("目标镜像: %s", req.TargetImage)
+	if len(req.Targets) > 0 {
+		h.logger.WithContext(c).Infof("目标仓库数: %d", len(req.Targets))
+	}
 	if req.ConfigID != "" {
-		h.logger.Infof("使用配置ID: %s", req.ConfigID)
+		h.logger.WithContext(c).Infof("使用配置ID: %s", req.ConfigID)
 	}
 
 	// 使用任务服务创建异步任务
-	response, err := h.taskService.CreateTask(&req)
+	response, err := h.taskService.CreateTask(c.Request.Context(), &req, c.GetString(middlewares.ActorContextKey))
 	if err != nil {
-		h.logger.Errorf("创建转换任务失败: %v", err)
-		c.JSON(http.StatusBadRequest, models.Response{
+		h.logger.WithContext(c).Errorf("创建转换任务失败: %v", err)
+		auditPolicyRejection(c, err)
+		c.JSON(createTaskErrorStatus(err), models.Response{
 			Success: false,
 			Message: "创建转换任务失败: " + err.Error(),
 		})
 		return
 	}
 
-	h.logger.Infof("======== 转换任务创建成功: %s ========", response.TaskID)
+	h.logger.WithContext(c).Infof("======== 转换任务创建成功: %s ========", response.TaskID)
 
 	// 立即返回任务信息，不等待执行完成
 	c.JSON(http.StatusOK, models.Response{
@@ -75,11 +75,3 @@ func (h *TransformHandler) StartTransform(c *gin.Context) {
 		},
 	})
 }
-
-// Close 关闭服务
-func (h *TransformHandler) Close() error {
-	if h.taskService != nil {
-		return h.taskService.Close()
-	}
-	return nil
-}
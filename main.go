@@ -1,21 +1,36 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
+	"docker-helper/cli"
 	"docker-helper/config"
 	"docker-helper/database"
 	"docker-helper/handlers"
+	"docker-helper/metrics"
 	"docker-helper/middlewares"
+	"docker-helper/models"
+	"docker-helper/server"
+	"docker-helper/services"
+	"docker-helper/storage"
+	"docker-helper/storage/postgres"
+	"docker-helper/storage/sqlite"
+	"docker-helper/tracing"
 	"docker-helper/utils"
 
 	"github.com/gin-gonic/gin"
@@ -165,7 +180,14 @@ func setupStaticAssets(r *gin.Engine, logger *utils.Logger) {
 	}
 }
 
-// serveIndexHTML 提供React应用的index.html文件
+var (
+	// indexHeadPattern index.html的<head>标签
+	indexHeadPattern = regexp.MustCompile(`(?i)<head[^>]*>`)
+	// indexAssetPattern index.html中以/开头的资源引用（不含 // 开头的外部地址）
+	indexAssetPattern = regexp.MustCompile(`((?:src|href)=")/([^/"])`)
+)
+
+// serveIndexHTML 提供React应用的index.html文件，并注入URL前缀
 func serveIndexHTML(c *gin.Context) {
 	distFS, err := fs.Sub(webAssets, "web/dist")
 	if err != nil {
@@ -189,24 +211,120 @@ func serveIndexHTML(c *gin.Context) {
 		return
 	}
 
+	// 资源引用改为带前缀的地址，并注入<base>与前端使用的前缀
+	basePath := config.Current().BasePath
+	if basePath != "" {
+		content = indexAssetPattern.ReplaceAll(content, []byte("${1}"+basePath+"/${2}"))
+	}
+	inject := fmt.Sprintf(`<base href="%s/"><script>window.__BASE_PATH__=%q</script>`, html.EscapeString(basePath), basePath)
+	if loc := indexHeadPattern.FindIndex(content); loc != nil {
+		content = slices.Concat(content[:loc[1]], []byte(inject), content[loc[1]:])
+	} else {
+		content = append([]byte(inject), content...)
+	}
+
 	c.Data(http.StatusOK, "text/html; charset=utf-8", content)
 }
 
+// openStore 根据配置打开存储后端
+func openStore(cfg *config.Config) (storage.Store, error) {
+	switch cfg.DBDriver {
+	case database.DriverSQLite:
+		return sqlite.New(cfg.DBPath)
+	case database.DriverPostgres:
+		if cfg.DatabaseURL == "" {
+			return nil, fmt.Errorf("DB_DRIVER=postgres 时必须设置 DATABASE_URL")
+		}
+		return postgres.New(cfg.DatabaseURL)
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.DBDriver)
+	}
+}
+
+// reloadConfig 重新加载配置，校验失败时保持当前配置
+func reloadConfig(logger *utils.Logger) {
+	result, err := config.Reload()
+	if err != nil {
+		logger.Errorf("配置热加载失败，继续使用当前配置: %v", err)
+		return
+	}
+	if len(result.Applied) == 0 && len(result.Ignored) == 0 {
+		logger.Info("配置热加载完成，无变更")
+		return
+	}
+	if len(result.Applied) > 0 {
+		logger.Infof("配置热加载完成，已生效: %s", strings.Join(result.Applied, ", "))
+	}
+	if len(result.Ignored) > 0 {
+		logger.Warnf("以下配置项需要重启才能生效: %s", strings.Join(result.Ignored, ", "))
+	}
+}
+
 func main() {
+	// 带子命令时作为命令行客户端运行，通过API访问服务端
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	// 加载配置
-	cfg := config.Load()
+	cfg, err := config.Init(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(2)
+	}
 
 	// 初始化日志
-	logger := utils.NewLogger(cfg.LogLevel)
+	if err := utils.ConfigureLogging(cfg.LogLevel, cfg.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "日志配置无效: %v\n", err)
+		os.Exit(1)
+	}
+	logger := utils.NewLogger("main")
+	if cfg.ConfigFile != "" {
+		logger.Infof("已加载配置文件: %s", cfg.ConfigFile)
+	}
+
+	// 配置热加载后同步日志设置
+	config.OnReload(func(old, new *config.Config) {
+		if old.LogLevel == new.LogLevel && old.LogFormat == new.LogFormat {
+			return
+		}
+		if err := utils.ConfigureLogging(new.LogLevel, new.LogFormat); err != nil {
+			logger.Errorf("应用日志配置失败: %v", err)
+		}
+	})
+
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		File:        cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logger.Errorf("链路追踪初始化失败: %v", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("关闭链路追踪失败: %v", err)
+		}
+	}()
+	logger.Infof("链路追踪导出器: %s", cfg.TracingExporter)
 	logger.Info("启动Docker镜像转换服务...")
 
 	// 初始化数据库
-	if err := database.InitDB(cfg.DBPath); err != nil {
+	store, err := openStore(cfg)
+	if err != nil {
 		logger.Errorf("数据库初始化失败: %v", err)
 		os.Exit(1)
 	}
-	defer database.Close()
-	logger.Info("数据库初始化成功")
+	defer store.Close()
+	logger.Infof("数据库初始化成功 (driver=%s)", cfg.DBDriver)
 
 	// 设置Gin模式
 	gin.SetMode(cfg.GinMode)
@@ -214,43 +332,74 @@ func main() {
 	// 创建Gin路由器
 	r := gin.New()
 
-	// 添加中间件
-	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// 过滤轮询请求的日志，减少日志噪音
-		if utils.IsPollingRequest(param.Path, param.Method) {
-			return ""
-		}
+	// 仅信任配置的反向代理转发的客户端IP（X-Forwarded-For/X-Real-IP）
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Errorf("可信代理配置无效: %v", err)
+		os.Exit(1)
+	}
 
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
-		)
-	}))
+	// 日志通过请求上下文携带请求ID，需要gin.Context回退到Request.Context()
+	r.ContextWithFallback = true
+
+	// 添加中间件
+	r.Use(middlewares.RequestIDMiddleware())
+	r.Use(middlewares.TracingMiddleware())
+	r.Use(middlewares.AccessLogMiddleware())
 	r.Use(gin.Recovery())
+	r.Use(middlewares.MetricsMiddleware())
 	r.Use(middlewares.CORSMiddleware())
 
 	// 创建处理器
 	logger.Info("初始化API处理器...")
 
-	authHandler := handlers.NewAuthHandler()
+	authHandler := handlers.NewAuthHandler(store)
 	logger.Info("认证处理器初始化完成")
 
-	historyHandler := handlers.NewHistoryHandler()
+	historyService := services.NewHistoryService(store.Tasks(), models.RetentionPolicy{
+		RetentionDays:    cfg.HistoryRetentionDays,
+		MaxRowsPerStatus: cfg.HistoryMaxRowsPerStatus,
+		Interval:         cfg.HistoryPruneInterval,
+		ArchiveDir:       cfg.HistoryArchiveDir,
+	})
+	historyService.Start()
+	defer historyService.Stop()
+
+	historyHandler := handlers.NewHistoryHandler(store, historyService)
 	logger.Info("历史记录处理器初始化完成")
 
-	transformHandler, err := handlers.NewTransformHandler()
+	bundleService, err := services.NewBundleService(cfg.BundleDir)
 	if err != nil {
-		logger.Errorf("创建转换处理器失败: %v", err)
+		logger.Errorf("创建离线包服务失败: %v", err)
 		os.Exit(1)
 	}
-	defer transformHandler.Close()
+	bundleService.Start()
+	defer bundleService.Stop()
+
+	dockerService, err := services.NewDockerService()
+	if err != nil {
+		logger.Errorf("创建Docker服务失败: %v", err)
+		os.Exit(1)
+	}
+	defer dockerService.Close()
+
+	imageCache := services.NewImageCache(store.ImageCache(), dockerService)
+	imageCache.Start()
+	defer imageCache.Stop()
+
+	// 任务服务由转换与任务处理器共享，保证并发限制与关闭流程覆盖全部任务
+	taskService, err := services.NewTaskService(store, bundleService, imageCache)
+	if err != nil {
+		logger.Errorf("创建任务服务失败: %v", err)
+		os.Exit(1)
+	}
+	defer taskService.Close()
+	if count, err := taskService.RecoverUnfinished(context.Background()); err != nil {
+		logger.Errorf("%v", err)
+	} else if count > 0 {
+		logger.Warnf("已将上次运行遗留的%d个未完成任务标记为已中断", count)
+	}
+
+	transformHandler := handlers.NewTransformHandler(taskService)
 	logger.Info("转换处理器初始化完成")
 
 	imageHandler, err := handlers.NewImageHandler()
@@ -261,37 +410,54 @@ func main() {
 	defer imageHandler.Close()
 	logger.Info("镜像处理器初始化完成")
 
-	registryHandler := handlers.NewRegistryHandler()
+	registryHandler := handlers.NewRegistryHandler(store)
 	logger.Info("仓库配置处理器初始化完成")
 
-	taskHandler, err := handlers.NewTaskHandler()
-	if err != nil {
-		logger.Errorf("创建任务处理器失败: %v", err)
-		os.Exit(1)
-	}
-	defer taskHandler.Close()
+	auditService := services.NewAuditService(store.Audit())
+	auditHandler := handlers.NewAuditHandler(auditService)
+	logger.Info("审计日志处理器初始化完成")
+
+	taskHandler := handlers.NewTaskHandler(taskService)
 	logger.Info("任务处理器初始化完成")
 
+	bundleHandler := handlers.NewBundleHandler(bundleService)
+	logger.Info("离线包处理器初始化完成")
+
+	cacheHandler := handlers.NewCacheHandler(imageCache)
+	logger.Info("镜像缓存处理器初始化完成")
+
+	healthService := services.NewHealthService(store, dockerService, taskService, services.HealthOptions{
+		DiskPath:     cfg.HealthDiskPath,
+		MinFreeBytes: uint64(cfg.HealthMinFreeDiskMB) << 20,
+		CacheTTL:     cfg.HealthCacheTTL,
+	})
+	healthHandler := handlers.NewHealthHandler(healthService)
+	logger.Info("健康检查处理器初始化完成")
+
 	// 注册API路由
 	logger.Info("注册API路由...")
 
 	// API路由组
+	loginProtection := middlewares.NewLoginProtection(cfg)
+	taskRateLimit := middlewares.RateLimitMiddleware(utils.NewRateLimiter(cfg.TaskRateLimit, cfg.TaskRateWindow))
+
 	api := r.Group("/api")
+	api.Use(middlewares.AuditMiddleware(auditService, store), loginProtection.Middleware())
 	{
 		// 认证相关路由
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/logout", authHandler.Logout)
-			auth.POST("/change-token", middlewares.AuthMiddleware(), authHandler.ChangeToken)
+			auth.POST("/change-token", middlewares.AuthMiddleware(store.Users()), authHandler.ChangeToken)
 		}
 
 		// 需要认证的路由
 		authenticated := api.Group("")
-		authenticated.Use(middlewares.AuthMiddleware())
+		authenticated.Use(middlewares.AuthMiddleware(store.Users()))
 		{
 			// 镜像转换相关
-			authenticated.POST("/transform/start", transformHandler.StartTransform)
+			authenticated.POST("/transform/start", taskRateLimit, transformHandler.StartTransform)
 
 			// 镜像解析相关
 			authenticated.POST("/image/parse", imageHandler.ParseImage)
@@ -301,7 +467,11 @@ func main() {
 			authenticated.GET("/history", historyHandler.GetHistory)
 			authenticated.GET("/history/stats", historyHandler.GetHistoryStats)
 			authenticated.GET("/history/detailed-stats", historyHandler.GetDetailedStats)
+			authenticated.GET("/history/export", historyHandler.ExportHistory)
+			authenticated.GET("/history/detailed-stats/export", historyHandler.ExportDetailedStats)
 			authenticated.DELETE("/history", historyHandler.ClearHistory)
+			authenticated.DELETE("/history/records", historyHandler.DeleteHistoryRecords)
+			authenticated.GET("/history/retention", historyHandler.GetRetention)
 
 			// 仓库配置管理相关
 			authenticated.GET("/registry/configs", registryHandler.GetConfigs)
@@ -310,13 +480,35 @@ func main() {
 			authenticated.DELETE("/registry/configs/:id", registryHandler.DeleteConfig)
 			authenticated.POST("/registry/test", registryHandler.TestConnection)
 			authenticated.POST("/registry/configs/:id/test", registryHandler.TestConfigConnection)
+			authenticated.GET("/registry/configs/:id/keys", registryHandler.GetSigningKeys)
+			authenticated.POST("/registry/configs/:id/keys", registryHandler.CreateSigningKey)
+			authenticated.DELETE("/registry/configs/:id/keys/:key_id", registryHandler.DeleteSigningKey)
 
 			// 任务管理相关（异步任务）
 			authenticated.GET("/tasks", taskHandler.GetTaskList)
-			authenticated.POST("/tasks", taskHandler.CreateTask)
+			authenticated.POST("/tasks", taskRateLimit, taskHandler.CreateTask)
+			authenticated.POST("/tasks/bundle", taskRateLimit, taskHandler.CreateBundleTask)
+			authenticated.POST("/tasks/import", taskRateLimit, taskHandler.CreateImportTask)
+			authenticated.POST("/tasks/promote", taskRateLimit, taskHandler.CreatePromoteTask)
 			authenticated.GET("/tasks/:id", taskHandler.GetTask)
 			authenticated.DELETE("/tasks/:id", taskHandler.CancelTask)
+			authenticated.GET("/tasks/:id/bundle", taskHandler.DownloadBundle)
 			authenticated.GET("/tasks/stats", taskHandler.GetTaskStats)
+
+			// 离线包上传相关（分片上传，支持续传）
+			authenticated.POST("/bundles/uploads", bundleHandler.CreateUpload)
+			authenticated.GET("/bundles/uploads/:id", bundleHandler.GetUpload)
+			authenticated.PUT("/bundles/uploads/:id", bundleHandler.UploadChunk)
+			authenticated.POST("/bundles/uploads/:id/complete", bundleHandler.CompleteUpload)
+			authenticated.DELETE("/bundles/uploads/:id", bundleHandler.DeleteUpload)
+
+			// 本地镜像缓存相关
+			authenticated.GET("/cache/images", cacheHandler.GetCache)
+			authenticated.DELETE("/cache/images", cacheHandler.PurgeCache)
+
+			// 审计日志相关
+			authenticated.GET("/audit", auditHandler.GetAuditEvents)
+			authenticated.GET("/audit/export", auditHandler.ExportAuditEvents)
 		}
 	}
 
@@ -341,42 +533,109 @@ func main() {
 		serveIndexHTML(c)
 	})
 
-	// 健康检查端点
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"status":  "ok",
-			"message": "Docker镜像转换服务运行正常",
-		})
-	})
+	// 健康检查端点：/health 与 /health/live 为存活检查，/health/ready 为就绪检查
+	r.GET("/health", healthHandler.Live)
+	r.GET("/health/live", healthHandler.Live)
+	r.GET("/health/ready", healthHandler.Ready)
 
-	// 启动服务器
-	port := cfg.Port
-	logger.Infof("服务器启动在端口: %s", port)
-	logger.Infof("健康检查: http://localhost:%s/health", port)
-	logger.Infof("前端界面: http://localhost:%s", port)
+	// Prometheus指标端点
+	metrics.RegisterTaskCollector(store.Tasks())
+	r.GET("/metrics", middlewares.MetricsAuthMiddleware(cfg.MetricsToken), gin.WrapH(metrics.Handler()))
 
-	// 创建服务器
+	// 创建服务器，URL前缀在进入路由前去掉
+	port := cfg.Port
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: r,
+		Handler: server.StripBasePath(tracing.Handler(r, "/metrics", "/health", "/health/live", "/health/ready"), cfg.BasePath),
+	}
+
+	scheme := "http"
+	var certs *server.CertReloader
+	if cfg.TLSEnabled() {
+		scheme = "https"
+		certs, err = server.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			logger.Errorf("TLS证书加载失败: %v", err)
+			os.Exit(1)
+		}
+		srv.TLSConfig = certs.TLSConfig()
 	}
 
+	logger.Infof("服务器启动在端口: %s (%s)", port, scheme)
+	logger.Infof("健康检查: %s://localhost:%s%s/health", scheme, port, cfg.BasePath)
+	logger.Infof("前端界面: %s://localhost:%s%s/", scheme, port, cfg.BasePath)
+
 	// 在协程中启动服务器
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if certs != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Errorf("服务器启动失败: %v", err)
 			os.Exit(1)
 		}
 	}()
 
-	// 等待中断信号
+	// HTTP跳转HTTPS
+	var redirectSrv *http.Server
+	if certs != nil && cfg.HTTPRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:    ":" + cfg.HTTPRedirectPort,
+			Handler: server.RedirectToHTTPS(port),
+		}
+		logger.Infof("HTTP跳转服务启动在端口: %s", cfg.HTTPRedirectPort)
+		go func() {
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Errorf("HTTP跳转服务启动失败: %v", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// 等待中断信号，SIGHUP触发配置热加载
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range quit {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reloadConfig(logger)
+		if certs != nil {
+			if reloaded, err := certs.Reload(); err != nil {
+				logger.Errorf("TLS证书重新加载失败，继续使用当前证书: %v", err)
+			} else if reloaded {
+				logger.Infof("TLS证书已重新加载: %s", cfg.TLSCertFile)
+			}
+		}
+	}
 	logger.Info("正在关闭服务器...")
 
-	// 优雅关闭
-	if err := srv.Shutdown(nil); err != nil {
+	// 关闭期间再次收到中断信号时不再等待任务完成
+	forceCtx, force := context.WithCancel(context.Background())
+	defer force()
+	go func() {
+		for sig := range quit {
+			if sig != syscall.SIGHUP {
+				force()
+				return
+			}
+		}
+	}()
+
+	// 阶段1-3：停止接收新任务，等待执行中的任务完成，超时后中断剩余任务
+	// 期间HTTP服务继续运行，客户端可以查询任务状态，就绪检查返回503
+	taskService.Drain(forceCtx, config.Current().ShutdownGracePeriod)
+
+	// 阶段4：关闭HTTP服务，等待进行中的请求完成
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Current().ShutdownTimeout)
+	defer cancel()
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Errorf("服务器关闭失败: %v", err)
 	}
 
@@ -0,0 +1,127 @@
+// Package metrics 定义服务暴露给Prometheus的指标
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "docker_helper"
+
+// Registry 服务使用的指标注册表（不使用全局默认注册表，避免引入无关指标）
+var Registry = prometheus.NewRegistry()
+
+var (
+	// TaskStepDuration 镜像转换各步骤耗时
+	TaskStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_step_duration_seconds",
+		Help:      "Duration of image transfer steps (pull, tag, push, signature, referrers, save, load).",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1200},
+	}, []string{"step"})
+
+	// RegistryBytes 按仓库统计的传输字节数
+	RegistryBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registry_bytes_transferred_total",
+		Help:      "Bytes of image layers transferred, by registry and direction (pull/push).",
+	}, []string{"registry", "direction"})
+
+	// RegistryTests 仓库连接测试结果
+	RegistryTests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registry_tests_total",
+		Help:      "Registry connection test results.",
+	}, []string{"registry", "result"})
+
+	// HTTPRequestDuration 按路由统计的HTTP请求耗时
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// ImageCacheLookups 任务拉取源镜像时的本地缓存命中情况
+	ImageCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_cache_lookups_total",
+		Help:      "Source image pulls served from the local image cache (hit) or not (miss).",
+	}, []string{"result"})
+
+	// ImageCacheBytes 本地缓存镜像大小之和
+	ImageCacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "image_cache_bytes",
+		Help:      "Total size of images kept in the local image cache.",
+	})
+
+	// BlobMounts 推送前跨仓库挂载镜像层的结果
+	BlobMounts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blob_mounts_total",
+		Help:      "Cross-repository blob mount attempts before push, by result (mounted/rejected/error).",
+	}, []string{"result"})
+
+	// BlobMountBytes 跨仓库挂载而无需上传的镜像层大小
+	BlobMountBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blob_mount_bytes_total",
+		Help:      "Bytes of layers mounted from another repository instead of uploaded.",
+	})
+
+	// PolicyRejections 被源镜像策略拒绝的任务
+	PolicyRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "policy_rejections_total",
+		Help:      "Tasks rejected by the source image policy, by rule.",
+	}, []string{"rule"})
+
+	// SignatureVerifications 推送前验证源镜像签名的结果
+	SignatureVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signature_verifications_total",
+		Help:      "Source image signature verifications before push, by result (verified/unsigned/invalid).",
+	}, []string{"result"})
+
+	// ReferrersCopied 推送后复制到目标的附属制品
+	ReferrersCopied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "referrers_copied_total",
+		Help:      "Referrer artifacts copied next to pushed images, by kind (signature/sbom/attestation/other) and result (copied/exists/failed).",
+	}, []string{"kind", "result"})
+
+	// DBErrors 数据库操作错误（不含记录不存在）
+	DBErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Database errors by operation (exec, query).",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		TaskStepDuration,
+		RegistryBytes,
+		RegistryTests,
+		HTTPRequestDuration,
+		ImageCacheLookups,
+		ImageCacheBytes,
+		BlobMounts,
+		BlobMountBytes,
+		PolicyRejections,
+		SignatureVerifications,
+		ReferrersCopied,
+		DBErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler 返回Prometheus文本格式的指标处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// taskStatuses 需要输出的任务状态，没有任务时也输出0
+var taskStatuses = []string{"pending", "running", "completed", "failed", "cancelled", "interrupted"}
+
+// TaskCounter 按状态统计任务数量，由存储层实现
+type TaskCounter interface {
+	CountByStatus(ctx context.Context) (map[string]int, error)
+}
+
+// taskCollector 在每次抓取时从数据库读取任务数量
+type taskCollector struct {
+	counter TaskCounter
+
+	tasks   *prometheus.Desc
+	queue   *prometheus.Desc
+	running *prometheus.Desc
+}
+
+// RegisterTaskCollector 注册任务数量指标
+func RegisterTaskCollector(counter TaskCounter) {
+	Registry.MustRegister(&taskCollector{
+		counter: counter,
+		tasks: prometheus.NewDesc(namespace+"_tasks",
+			"Number of tasks by status.", []string{"status"}, nil),
+		queue: prometheus.NewDesc(namespace+"_task_queue_depth",
+			"Number of tasks waiting to be executed.", nil, nil),
+		running: prometheus.NewDesc(namespace+"_tasks_running",
+			"Number of tasks currently running.", nil, nil),
+	})
+}
+
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tasks
+	ch <- c.queue
+	ch <- c.running
+}
+
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.counter.CountByStatus(ctx)
+	if err != nil {
+		// 数据库错误已计入 db_errors_total，本次不输出任务指标
+		return
+	}
+
+	for _, status := range taskStatuses {
+		ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(counts[status]), status)
+	}
+	ch <- prometheus.MustNewConstMetric(c.queue, prometheus.GaugeValue, float64(counts["pending"]))
+	ch <- prometheus.MustNewConstMetric(c.running, prometheus.GaugeValue, float64(counts["running"]))
+}
@@ -0,0 +1,224 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"docker-helper/models"
+	"docker-helper/services"
+	"docker-helper/storage"
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActorContextKey 认证通过后写入gin上下文的操作者标识
+const ActorContextKey = "actor"
+
+// AuditActionContextKey 处理器写入gin上下文以替换审计动作名，如任务被源镜像策略拒绝时
+const AuditActionContextKey = "audit_action"
+
+// AnonymousActor 未认证请求的操作者标识
+const AnonymousActor = "anonymous"
+
+// maxAuditBodySize 审计记录中请求/响应体的最大采集长度
+const maxAuditBodySize = 64 * 1024
+
+// auditRoute 路由对应的审计动作与资源类型
+type auditRoute struct {
+	Action       string
+	ResourceType string
+	// Before 加载变更前状态摘要（可选）
+	Before func(c *gin.Context, store storage.Store) interface{}
+}
+
+// auditRoutes 按 "METHOD 路由模板" 索引的审计定义，未列出的路由使用方法和路径作为动作名
+var auditRoutes = map[string]auditRoute{
+	"POST /api/auth/login":                          {Action: "auth.login", ResourceType: "session"},
+	"POST /api/auth/logout":                         {Action: "auth.logout", ResourceType: "session"},
+	"POST /api/auth/change-token":                   {Action: "auth.change_token", ResourceType: "token", Before: redactedTokenSummary},
+	"POST /api/transform/start":                     {Action: "transform.start", ResourceType: "task"},
+	"POST /api/image/parse":                         {Action: "image.parse", ResourceType: "image"},
+	"POST /api/image/build-target":                  {Action: "image.build_target", ResourceType: "image"},
+	"DELETE /api/history":                           {Action: "history.clear", ResourceType: "history", Before: historySummary},
+	"DELETE /api/history/records":                   {Action: "history.delete", ResourceType: "history"},
+	"POST /api/registry/configs":                    {Action: "registry.create", ResourceType: "registry_config"},
+	"PUT /api/registry/configs/:id":                 {Action: "registry.update", ResourceType: "registry_config", Before: registryConfigSummary},
+	"DELETE /api/registry/configs/:id":              {Action: "registry.delete", ResourceType: "registry_config", Before: registryConfigSummary},
+	"POST /api/registry/test":                       {Action: "registry.test", ResourceType: "registry"},
+	"POST /api/registry/configs/:id/test":           {Action: "registry.test_config", ResourceType: "registry_config", Before: registryConfigSummary},
+	"POST /api/registry/configs/:id/keys":           {Action: "registry.key_add", ResourceType: "registry_config"},
+	"DELETE /api/registry/configs/:id/keys/:key_id": {Action: "registry.key_delete", ResourceType: "registry_config"},
+	"POST /api/tasks":                               {Action: "task.create", ResourceType: "task"},
+	"POST /api/tasks/bundle":                        {Action: "task.create_bundle", ResourceType: "task"},
+	"POST /api/tasks/import":                        {Action: "task.create_import", ResourceType: "task"},
+	"POST /api/tasks/promote":                       {Action: "task.create_promote", ResourceType: "task"},
+	"DELETE /api/tasks/:id":                         {Action: "task.cancel", ResourceType: "task", Before: taskSummary},
+	"POST /api/bundles/uploads":                     {Action: "bundle.upload_create", ResourceType: "bundle_upload"},
+	"PUT /api/bundles/uploads/:id":                  {Action: "bundle.upload_chunk", ResourceType: "bundle_upload"},
+	"POST /api/bundles/uploads/:id/complete":        {Action: "bundle.upload_complete", ResourceType: "bundle_upload"},
+	"DELETE /api/bundles/uploads/:id":               {Action: "bundle.upload_delete", ResourceType: "bundle_upload"},
+	"DELETE /api/cache/images":                      {Action: "cache.purge", ResourceType: "image_cache"},
+}
+
+// auditResponseWriter 在写出响应的同时保留一份副本用于审计
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.body.Len() < maxAuditBodySize {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// AuditMiddleware 审计中间件，为所有非GET的API请求追加审计事件
+func AuditMiddleware(auditService *services.AuditService, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		// 读取请求体并放回，供后续处理器继续使用
+		var requestBody []byte
+		if c.Request.Body != nil {
+			requestBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(requestBody), c.Request.Body))
+		}
+
+		route, known := auditRoutes[method+" "+c.FullPath()]
+		if !known {
+			route = auditRoute{Action: method + " " + c.Request.URL.Path}
+		}
+
+		var before interface{}
+		if route.Before != nil {
+			before = route.Before(c, store)
+		}
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		c.Next()
+
+		if action := c.GetString(AuditActionContextKey); action != "" {
+			route.Action = action
+		}
+
+		// 解析统一响应结构，提取结果与返回数据
+		var response struct {
+			Success bool            `json:"success"`
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data"`
+		}
+		_ = json.Unmarshal(writer.body.Bytes(), &response)
+
+		statusCode := writer.Status()
+		result := models.AuditResultFailure
+		if statusCode < http.StatusBadRequest && (response.Success || writer.body.Len() == 0) {
+			result = models.AuditResultSuccess
+		}
+
+		event := &models.AuditEvent{
+			Actor:      c.GetString(ActorContextKey),
+			SourceIP:   c.ClientIP(),
+			Action:     route.Action,
+			Resource:   auditResource(c, route.ResourceType, response.Data),
+			Result:     result,
+			StatusCode: statusCode,
+			Message:    response.Message,
+		}
+		if event.Actor == "" {
+			event.Actor = AnonymousActor
+		}
+
+		if summary := utils.MarshalRedacted(before); summary != "" {
+			event.Before = &summary
+		}
+
+		// 变更后摘要优先使用响应数据，其次使用请求体
+		after := utils.RedactJSON(response.Data)
+		if after == "" || after == "null" {
+			after = utils.RedactJSON(requestBody)
+		}
+		if after != "" {
+			event.After = &after
+		}
+
+		// 审计写入失败不影响请求结果，错误已在服务内记录
+		_ = auditService.Record(event)
+	}
+}
+
+// auditResource 生成目标资源标识，优先使用路由参数，其次使用响应中的ID
+func auditResource(c *gin.Context, resourceType string, data json.RawMessage) string {
+	id := c.Param("id")
+	if id == "" && len(data) > 0 {
+		var ids struct {
+			ID     string `json:"id"`
+			TaskID string `json:"task_id"`
+		}
+		if err := json.Unmarshal(data, &ids); err == nil {
+			id = ids.ID
+			if id == "" {
+				id = ids.TaskID
+			}
+		}
+	}
+
+	if resourceType == "" {
+		return strings.TrimPrefix(c.Request.URL.Path, "/api/")
+	}
+	if id == "" {
+		return resourceType
+	}
+	return resourceType + ":" + id
+}
+
+// registryConfigSummary 加载仓库配置的变更前状态（不含密码）
+func registryConfigSummary(c *gin.Context, store storage.Store) interface{} {
+	config, err := store.RegistryConfigs().Get(context.Background(), c.Param("id"))
+	if err != nil {
+		return nil
+	}
+
+	return config.ToResponse()
+}
+
+// taskSummary 加载任务的变更前状态
+func taskSummary(c *gin.Context, store storage.Store) interface{} {
+	task, err := store.Tasks().Get(context.Background(), c.Param("id"))
+	if err != nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"id":           task.ID,
+		"source_image": task.SourceImage,
+		"target_image": task.TargetImage,
+		"status":       task.Status,
+	}
+}
+
+// historySummary 统计清理前的历史记录数量
+func historySummary(c *gin.Context, store storage.Store) interface{} {
+	count, err := store.Tasks().CountHistory(context.Background(), &models.HistoryQuery{})
+	if err != nil {
+		return nil
+	}
+
+	return map[string]interface{}{"history_rows": count}
+}
+
+// redactedTokenSummary Token变更只记录发生了变更，不记录内容
+func redactedTokenSummary(c *gin.Context, store storage.Store) interface{} {
+	return map[string]interface{}{"token": utils.RedactedValue}
+}
@@ -1,20 +1,24 @@
 package middlewares
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"net/http"
 
-	"docker-helper/database"
 	"docker-helper/models"
+	"docker-helper/storage"
 	"docker-helper/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-var authLogger = utils.NewLogger("info")
+var authLogger = utils.NewLogger("auth")
+
+// AdminActor 默认管理账户的操作者标识
+const AdminActor = models.DefaultUsername
 
 // AuthMiddleware 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(users storage.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 跳过登录接口的认证
 		if c.Request.URL.Path == "/api/auth/login" {
@@ -26,28 +30,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		requestPath := c.Request.URL.Path
 
 		// 使用轮询感知的日志记录
-		authLogger.InfoPolling(requestPath, "认证检查: IP=%s, Path=%s", clientIP, requestPath)
-
-		// 从Header获取Token
-		authHeader := c.GetHeader("Authorization")
-		var token string
-
-		if authHeader != "" {
-			// 检查是否是Bearer格式
-			if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-				token = authHeader[7:] // 提取Bearer后面的token
-			} else {
-				token = authHeader // 向后兼容，直接使用原始值
-			}
-		}
-
-		// 如果Header中没有token，尝试从Cookie获取
-		if token == "" {
-			token, _ = c.Cookie("auth_token")
-		}
+		authLogger.WithContext(c).InfoPolling(requestPath, "认证检查: IP=%s, Path=%s", clientIP, requestPath)
 
+		token := extractToken(c)
 		if token == "" {
-			authLogger.Errorf("认证失败: 缺少Token, IP=%s, Path=%s", clientIP, requestPath)
+			authLogger.WithContext(c).Errorf("认证失败: 缺少Token, IP=%s, Path=%s", clientIP, requestPath)
 			c.JSON(http.StatusUnauthorized, models.Response{
 				Success: false,
 				Message: "未登录，请先登录",
@@ -57,27 +44,19 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 验证Token
-		var storedToken string
-		query := "SELECT value FROM config WHERE key = 'token'"
-		err := database.DB.QueryRow(query).Scan(&storedToken)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusInternalServerError, models.Response{
-					Success: false,
-					Message: "系统配置错误",
-				})
-			} else {
-				c.JSON(http.StatusInternalServerError, models.Response{
-					Success: false,
-					Message: "验证失败",
-				})
-			}
+		user, err := users.GetByToken(context.Background(), token)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			authLogger.WithContext(c).Errorf("认证失败: 查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Success: false,
+				Message: "验证失败",
+			})
 			c.Abort()
 			return
 		}
 
-		if token != storedToken {
-			authLogger.Errorf("认证失败: Token无效, IP=%s, Path=%s, Token前6位=%s",
+		if user == nil {
+			authLogger.WithContext(c).Errorf("认证失败: Token无效, IP=%s, Path=%s, Token前6位=%s",
 				clientIP, requestPath, token[:min(6, len(token))])
 			c.JSON(http.StatusUnauthorized, models.Response{
 				Success: false,
@@ -88,11 +67,35 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 验证通过，使用轮询感知的日志记录
-		authLogger.InfoPolling(requestPath, "认证成功: IP=%s, Path=%s", clientIP, requestPath)
+		authLogger.WithContext(c).InfoPolling(requestPath, "认证成功: IP=%s, Path=%s", clientIP, requestPath)
+		c.Set(ActorContextKey, user.Username)
 		c.Next()
 	}
 }
 
+// extractToken 从Authorization头或Cookie中提取Token
+func extractToken(c *gin.Context) string {
+	// 从Header获取Token
+	authHeader := c.GetHeader("Authorization")
+	var token string
+
+	if authHeader != "" {
+		// 检查是否是Bearer格式
+		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+			token = authHeader[7:] // 提取Bearer后面的token
+		} else {
+			token = authHeader // 向后兼容，直接使用原始值
+		}
+	}
+
+	// 如果Header中没有token，尝试从Cookie获取
+	if token == "" {
+		token, _ = c.Cookie("auth_token")
+	}
+
+	return token
+}
+
 // min 返回两个整数中的较小值
 func min(a, b int) int {
 	if a < b {
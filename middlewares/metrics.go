@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-helper/metrics"
+	"docker-helper/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware 按路由模板记录HTTP请求耗时
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// 使用路由模板而不是实际路径，避免标签基数随ID增长
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuthMiddleware 校验/metrics的访问令牌，token为空时不校验
+func MetricsAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, models.Response{
+				Success: false,
+				Message: "指标访问令牌无效",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,139 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginPath 登录接口路径
+const loginPath = "/api/auth/login"
+
+// LoginProtection 登录暴力破解防护：按IP限制尝试频率并锁定连续失败，按账户逐次延迟连续失败后的登录
+type LoginProtection struct {
+	attempts        *utils.RateLimiter
+	ipGuard         *utils.LoginGuard
+	accountThrottle *utils.LoginThrottle
+}
+
+// NewLoginProtection 根据配置创建登录保护
+func NewLoginProtection(cfg *config.Config) *LoginProtection {
+	return &LoginProtection{
+		attempts:        utils.NewRateLimiter(cfg.LoginRateLimit, time.Minute),
+		ipGuard:         utils.NewLoginGuard(cfg.LoginMaxFailures, cfg.LoginLockout),
+		accountThrottle: utils.NewLoginThrottle(cfg.AccountMaxFailures, cfg.AccountMaxDelay),
+	}
+}
+
+// Middleware 登录保护中间件，挂载在API路由组上
+// 登录失败和携带无效Token的请求都会计入失败次数，避免绕过登录接口直接猜测Token；
+// IP锁定期间拒绝该IP的所有认证尝试（登录或携带Token的请求）且不校验凭据，避免响应暴露凭据是否正确
+func (lp *LoginProtection) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIP := c.ClientIP()
+		ipKey := "ip:" + clientIP
+		isLogin := c.Request.URL.Path == loginPath
+		isAttempt := isLogin || extractToken(c) != ""
+
+		if isLogin {
+			result := lp.attempts.Allow(clientIP)
+			if !result.Allowed {
+				setRateLimitHeaders(c, result)
+				abortTooManyRequests(c, result.RetryAfter(), "登录尝试过于频繁，请稍后重试")
+				return
+			}
+		}
+
+		if isAttempt {
+			if wait := lp.ipGuard.LockedFor(ipKey); wait > 0 {
+				authLogger.WithContext(c).Errorf("认证请求被拒绝: IP已锁定, IP=%s, Path=%s, 剩余=%v", clientIP, c.Request.URL.Path, wait.Round(time.Second))
+				abortTooManyRequests(c, wait, "登录失败次数过多，请稍后重试")
+				return
+			}
+		}
+
+		if isLogin {
+			// 账户连续失败后逐次延迟登录，抵御分布式猜测，不锁定账户以免管理员被拒之门外
+			if delay := lp.accountThrottle.Delay(AdminActor); delay > 0 {
+				authLogger.WithContext(c).Warnf("账户连续登录失败，延迟 %v 后处理登录: IP=%s", delay, clientIP)
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-c.Request.Context().Done():
+					timer.Stop()
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		c.Next()
+
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized && isAttempt:
+			if lp.ipGuard.RecordFailure(ipKey) {
+				authLogger.WithContext(c).Errorf("IP连续认证失败，已锁定: IP=%s", clientIP)
+			}
+			if isLogin {
+				lp.accountThrottle.RecordFailure(AdminActor)
+			}
+		case isLogin && status == http.StatusOK:
+			lp.ipGuard.RecordSuccess(ipKey)
+			lp.accountThrottle.RecordSuccess(AdminActor)
+		}
+	}
+}
+
+// RateLimitMiddleware 按API Key限流，未携带Token时按IP计数
+func RateLimitMiddleware(limiter *utils.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if token := extractToken(c); token != "" {
+			sum := sha256.Sum256([]byte(token))
+			key = "key:" + hex.EncodeToString(sum[:8])
+		}
+
+		result := limiter.Allow(key)
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			authLogger.WithContext(c).Errorf("请求被限流: IP=%s, Path=%s", c.ClientIP(), c.Request.URL.Path)
+			abortTooManyRequests(c, result.RetryAfter(), "请求过于频繁，请稍后重试")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders 写入标准限流响应头
+func setRateLimitHeaders(c *gin.Context, result utils.RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+}
+
+// abortTooManyRequests 返回429并设置Retry-After（秒）
+func abortTooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, models.Response{
+		Success: false,
+		Message: fmt.Sprintf("%s（%d秒后可重试）", message, seconds),
+	})
+	c.Abort()
+}
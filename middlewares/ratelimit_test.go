@@ -0,0 +1,155 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testUsers 内存中的用户，Token -> 用户名
+type testUsers map[string]string
+
+func (u testUsers) Get(ctx context.Context, username string) (*models.User, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (u testUsers) GetByToken(ctx context.Context, token string) (*models.User, error) {
+	if username, ok := u[token]; ok {
+		return &models.User{Username: username}, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (u testUsers) UpdateToken(ctx context.Context, username, token string) error {
+	return nil
+}
+
+// newProtectedRouter 挂载登录保护的路由：登录接口按请求体中的Token校验，/api/tasks 需要认证
+func newProtectedRouter(cfg *config.Config, users testUsers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api")
+	api.Use(NewLoginProtection(cfg).Middleware())
+	api.POST("/auth/login", func(c *gin.Context) {
+		var req models.LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{Success: false, Message: err.Error()})
+			return
+		}
+		if _, ok := users[req.Token]; !ok {
+			c.JSON(http.StatusUnauthorized, models.Response{Success: false})
+			return
+		}
+		c.JSON(http.StatusOK, models.Response{Success: true})
+	})
+	api.GET("/tasks", AuthMiddleware(users), func(c *gin.Context) {
+		c.JSON(http.StatusOK, models.Response{Success: true})
+	})
+	return r
+}
+
+// send 以remoteIP发送请求
+func send(r *gin.Engine, method, path, remoteIP, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = remoteIP + ":40000"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// request 以remoteIP发送请求，返回状态码
+func request(r *gin.Engine, method, path, remoteIP, token, body string) int {
+	return send(r, method, path, remoteIP, token, body).Code
+}
+
+func TestLoginProtectionIPLock(t *testing.T) {
+	cfg := &config.Config{LoginRateLimit: 100, LoginMaxFailures: 3, LoginLockout: time.Minute}
+	r := newProtectedRouter(cfg, testUsers{"valid-token": AdminActor})
+	const ip = "192.0.2.10"
+
+	for i := 0; i < 3; i++ {
+		if code := request(r, http.MethodGet, "/api/tasks", ip, "guess", ""); code != http.StatusUnauthorized {
+			t.Fatalf("第%d次无效Token = %d, want 401", i+1, code)
+		}
+	}
+
+	// 锁定期间不校验凭据，正确的Token与错误的Token得到相同的响应
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"invalid token", http.MethodGet, "/api/tasks", "guess", "", http.StatusTooManyRequests},
+		{"valid token", http.MethodGet, "/api/tasks", "valid-token", "", http.StatusTooManyRequests},
+		{"no token", http.MethodGet, "/api/tasks", "", "", http.StatusUnauthorized},
+		{"invalid login", http.MethodPost, "/api/auth/login", "", `{"token":"guess"}`, http.StatusTooManyRequests},
+		{"valid login", http.MethodPost, "/api/auth/login", "", `{"token":"valid-token"}`, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		if code := request(r, tt.method, tt.path, ip, tt.token, tt.body); code != tt.want {
+			t.Errorf("%s: 锁定期间 = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	// 其他IP不受影响
+	if code := request(r, http.MethodGet, "/api/tasks", "192.0.2.11", "valid-token", ""); code != http.StatusOK {
+		t.Errorf("其他IP = %d, want 200", code)
+	}
+}
+
+func TestLoginProtectionRateLimitDuringLock(t *testing.T) {
+	cfg := &config.Config{LoginRateLimit: 3, LoginMaxFailures: 2, LoginLockout: time.Minute}
+	r := newProtectedRouter(cfg, testUsers{"valid-token": AdminActor})
+	const ip = "192.0.2.30"
+
+	for i := 0; i < 2; i++ {
+		request(r, http.MethodPost, "/api/auth/login", ip, "", `{"token":"guess"}`)
+	}
+	// 锁定期间的尝试同样消耗限流配额
+	if w := send(r, http.MethodPost, "/api/auth/login", ip, "", `{"token":"guess"}`); w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Remaining") != "" {
+		t.Fatalf("锁定期间第1次尝试 = %d, want 锁定的429", w.Code)
+	}
+	w := send(r, http.MethodPost, "/api/auth/login", ip, "", `{"token":"valid-token"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("超出限流 = %d (X-RateLimit-Remaining=%q), want 限流的429", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+}
+
+func TestLoginProtectionAccountThrottle(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	cfg := &config.Config{LoginRateLimit: 100, AccountMaxFailures: 2, AccountMaxDelay: delay, LoginLockout: time.Minute}
+	r := newProtectedRouter(cfg, testUsers{"valid-token": AdminActor})
+
+	// 多个IP的失败累计到同一账户
+	for i := 0; i < 3; i++ {
+		request(r, http.MethodPost, "/api/auth/login", fmt.Sprintf("198.51.100.%d", i), "", `{"token":"guess"}`)
+	}
+
+	// 超过阈值后延迟处理，但不锁定：正确的Token仍可登录
+	start := time.Now()
+	if code := request(r, http.MethodPost, "/api/auth/login", "192.0.2.20", "", `{"token":"valid-token"}`); code != http.StatusOK {
+		t.Fatalf("账户连续失败后有效登录 = %d, want 200", code)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("登录耗时 %v, want 至少延迟 %v", elapsed, delay)
+	}
+}
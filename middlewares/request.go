@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"time"
+
+	"docker-helper/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID的请求/响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDContextKey 请求ID在gin.Context中的键
+const RequestIDContextKey = "request_id"
+
+// maxRequestIDLength 客户端传入请求ID的最大长度，超出或包含非法字符时重新生成
+const maxRequestIDLength = 128
+
+var accessLogger = utils.NewLogger("http")
+
+// RequestIDMiddleware 为每个请求分配请求ID
+//
+// 沿用客户端或上游代理传入的 X-Request-ID，否则生成新的ID；
+// 请求ID写入响应头，并放入请求上下文，供日志与下游调用使用。
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(RequestIDContextKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(utils.ContextWithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
+
+// validRequestID 只接受长度合理的可打印ASCII字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware 结构化访问日志，轮询请求降为DEBUG级别以减少噪音
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logger := accessLogger.WithContext(c).Slog()
+		args := []interface{}{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if actor := c.GetString(ActorContextKey); actor != "" {
+			args = append(args, "actor", actor)
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			args = append(args, "error", errs)
+		}
+
+		if utils.IsPollingRequest(c.Request.URL.RequestURI(), c.Request.Method) {
+			logger.Debug("HTTP请求", args...)
+		} else {
+			logger.Info("HTTP请求", args...)
+		}
+	}
+}
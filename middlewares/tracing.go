@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware 以路由模板命名HTTP请求的Span，并附加请求ID与操作者
+//
+// Span本身由外层的 tracing.Handler 创建，这里只在路由匹配后补充信息。
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		span := trace.SpanFromContext(c.Request.Context())
+		if !span.IsRecording() {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		span.SetName(c.Request.Method + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			attribute.String("request_id", c.GetString(RequestIDContextKey)),
+		)
+		if actor := c.GetString(ActorContextKey); actor != "" {
+			span.SetAttributes(attribute.String("actor", actor))
+		}
+	}
+}
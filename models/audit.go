@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 审计结果枚举
+const (
+	AuditResultSuccess = "success" // 操作成功
+	AuditResultFailure = "failure" // 操作失败
+)
+
+// AuditEvent 审计事件（只追加，不允许修改或删除）
+type AuditEvent struct {
+	ID         int64     `json:"id" db:"id"`
+	Actor      string    `json:"actor" db:"actor"`
+	SourceIP   string    `json:"source_ip" db:"source_ip"`
+	Action     string    `json:"action" db:"action"`
+	Resource   string    `json:"resource" db:"resource"`
+	Before     *string   `json:"before,omitempty" db:"before_summary"` // 变更前摘要（已脱敏）
+	After      *string   `json:"after,omitempty" db:"after_summary"`   // 变更后摘要（已脱敏）
+	Result     string    `json:"result" db:"result"`
+	StatusCode int       `json:"status_code" db:"status_code"`
+	Message    string    `json:"message" db:"message"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditQuery 审计事件查询条件
+type AuditQuery struct {
+	Actor    string
+	Action   string
+	Resource string
+	Result   string
+	SourceIP string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// AuditListResponse 审计事件列表响应
+type AuditListResponse struct {
+	Items  []*AuditEvent `json:"items"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
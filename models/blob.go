@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// BlobLocation 目标仓库中已知存在某个镜像层的仓库路径，推送时可从该路径跨仓库挂载
+type BlobLocation struct {
+	Registry   string    `json:"registry"`   // 仓库地址
+	Repository string    `json:"repository"` // 仓库内路径，如 project/nginx
+	Digest     string    `json:"digest"`
+	Size       int64     `json:"size"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// 离线包上传状态
+const (
+	BundleUploadUploading = "uploading" // 接收分片中
+	BundleUploadReady     = "ready"     // 已校验，可以导入
+)
+
+// BundleUpload 离线包上传会话
+type BundleUpload struct {
+	ID        string            `json:"id"`
+	FileName  string            `json:"file_name,omitempty"`
+	Size      int64             `json:"size"`               // 文件总大小（字节）
+	Received  int64             `json:"received"`           // 已接收的字节数，续传时从该位置继续
+	SHA256    string            `json:"sha256,omitempty"`   // 客户端提供的文件SHA-256，完成时校验
+	Status    string            `json:"status"`             // uploading/ready
+	Format    string            `json:"format,omitempty"`   // 校验后识别的格式: oci/docker
+	Images    []BundleImageInfo `json:"images,omitempty"`   // 校验后列出的镜像
+	Verified  []string          `json:"verified,omitempty"` // 已通过的校验项
+	CreatedBy string            `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// BundleImageInfo 离线包中的镜像
+type BundleImageInfo struct {
+	Name   string `json:"name,omitempty"` // 包中记录的镜像名，未命名时为空
+	ID     string `json:"id"`             // 镜像ID（配置摘要）
+	Layers int    `json:"layers"`
+	Size   int64  `json:"size"` // 配置与各层大小之和（字节）
+}
+
+// BundleUploadRequest 创建上传会话请求
+type BundleUploadRequest struct {
+	FileName string `json:"file_name,omitempty"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	SHA256   string `json:"sha256,omitempty"` // 可选，导出任务的 artifact_sha256 或 sha256sum 的结果
+}
+
+// ImportRequest 导入离线包并推送到仓库的请求
+type ImportRequest struct {
+	UploadID string          `json:"upload_id" binding:"required"`
+	ConfigID string          `json:"config_id,omitempty"` // 目标仓库配置，为空时使用默认配置
+	Images   []ImportMapping `json:"images,omitempty"`    // 为空时推送包中全部已命名的镜像
+}
+
+// ImportMapping 包中镜像与目标镜像的对应关系
+type ImportMapping struct {
+	Source string `json:"source" binding:"required"` // 包中的镜像名或镜像ID
+	Target string `json:"target,omitempty"`          // 目标仓库中的路径与标签（如 base/nginx:1.25），为空时按 target_namespace 生成
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// 健康检查状态
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFail    = "fail"
+	HealthStatusSkipped = "skipped" // 检查不适用于当前部署，不影响就绪状态
+)
+
+// HealthCheckResult 单项检查结果
+type HealthCheckResult struct {
+	Name      string                 `json:"name"`
+	Status    string                 `json:"status"`
+	LatencyMs float64                `json:"latency_ms"`
+	Message   string                 `json:"message,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthReport 就绪检查报告
+type HealthReport struct {
+	Status    string              `json:"status"`
+	Checks    []HealthCheckResult `json:"checks"`
+	CheckedAt time.Time           `json:"checked_at"`
+	Cached    bool                `json:"cached"`
+}
+
+// 任务服务生命周期阶段
+const (
+	WorkerPhaseAccepting    = "accepting"    // 正常接收任务
+	WorkerPhaseDraining     = "draining"     // 停止接收新任务，等待执行中的任务完成
+	WorkerPhaseInterrupting = "interrupting" // 等待超时，正在中断剩余任务
+	WorkerPhaseStopped      = "stopped"      // 已停止
+)
+
+// WorkerStats 任务执行状态
+type WorkerStats struct {
+	Running       int        `json:"running"`                  // 正在执行的任务数
+	Queued        int        `json:"queued"`                   // 等待执行名额的任务数
+	Accepting     bool       `json:"accepting"`                // 是否仍在接收新任务
+	Phase         string     `json:"phase"`                    // 生命周期阶段
+	DrainDeadline *time.Time `json:"drain_deadline,omitempty"` // 关闭过程中，执行中任务的最晚完成时间
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// HistoryStats 历史统计信息
+type HistoryStats struct {
+	Total        int     `json:"total"`
+	SuccessCount int     `json:"success_count"`
+	FailedCount  int     `json:"failed_count"`
+	AvgDuration  float64 `json:"avg_duration"`
+}
+
+// DateStat 按日期统计
+type DateStat struct {
+	Date        string  `json:"date"`
+	Total       int     `json:"total"`
+	Success     int     `json:"success"`
+	Failed      int     `json:"failed"`
+	AvgDuration float64 `json:"avg_duration"`
+}
+
+// RegistryStat 按目标仓库统计
+type RegistryStat struct {
+	Registry string `json:"registry"`
+	Total    int    `json:"total"`
+	Success  int    `json:"success"`
+	Failed   int    `json:"failed"`
+}
+
+// FailureStat 失败原因统计
+type FailureStat struct {
+	ErrorMsg string `json:"error_msg"`
+	Count    int    `json:"count"`
+}
+
+// DetailedStats 详细统计信息
+type DetailedStats struct {
+	DateStats     []DateStat     `json:"date_stats"`
+	RegistryStats []RegistryStat `json:"registry_stats"`
+	FailureStats  []FailureStat  `json:"failure_stats"`
+}
+
+// 历史记录排序字段
+const (
+	HistorySortCreatedAt   = "created_at"
+	HistorySortCompletedAt = "completed_at"
+	HistorySortDuration    = "duration"
+)
+
+// HistoryQuery 历史记录查询条件
+type HistoryQuery struct {
+	Statuses   []string   // 任务状态（completed/failed/cancelled/interrupted），为空表示全部
+	Search     string     // 源镜像或目标镜像包含的关键字
+	Source     string     // 源镜像包含的关键字
+	Target     string     // 目标镜像包含的关键字
+	TargetHost string     // 目标仓库主机
+	ConfigID   string     // 仓库配置ID
+	CreatedBy  string     // 创建者
+	From       *time.Time // 创建时间下限
+	To         *time.Time // 创建时间上限
+	// FinishedBefore 结束时间（无结束时间时取创建时间）早于该时间，用于保留策略
+	FinishedBefore *time.Time
+	Sort           string // 排序字段，默认 created_at
+	Desc           bool   // 是否倒序
+	After          *HistoryCursor
+	Limit          int
+	Offset         int // 未使用游标时的偏移量
+}
+
+// HasFilters 是否设置了任一过滤条件（不含排序与分页）
+func (q *HistoryQuery) HasFilters() bool {
+	return len(q.Statuses) > 0 || q.Search != "" || q.Source != "" || q.Target != "" ||
+		q.TargetHost != "" || q.ConfigID != "" || q.CreatedBy != "" ||
+		q.From != nil || q.To != nil || q.FinishedBefore != nil
+}
+
+// HistoryCursor 游标分页位置，记录上一页最后一条记录的排序值和ID
+type HistoryCursor struct {
+	Sort     string     `json:"s"`
+	Desc     bool       `json:"d"`
+	Time     *time.Time `json:"t,omitempty"`
+	Duration int        `json:"n,omitempty"`
+	ID       string     `json:"id"`
+}
+
+// NewHistoryCursor 根据排序字段生成指向task之后的游标
+func NewHistoryCursor(task *Task, sort string, desc bool) *HistoryCursor {
+	cursor := &HistoryCursor{Sort: sort, Desc: desc, ID: task.ID}
+	switch sort {
+	case HistorySortDuration:
+		cursor.Duration = task.Duration
+	case HistorySortCompletedAt:
+		cursor.Time = &task.CreatedAt
+		if task.CompletedAt != nil {
+			cursor.Time = task.CompletedAt
+		}
+	default:
+		cursor.Time = &task.CreatedAt
+	}
+	return cursor
+}
+
+// Encode 将游标编码为URL安全的字符串
+func (c *HistoryCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeHistoryCursor 解析游标字符串
+func DecodeHistoryCursor(value string) (*HistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("无效的游标: %v", err)
+	}
+
+	var cursor HistoryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("无效的游标")
+	}
+	if cursor.Sort != HistorySortDuration && cursor.Time == nil {
+		return nil, fmt.Errorf("无效的游标")
+	}
+	return &cursor, nil
+}
+
+// HistoryListResponse 历史记录分页响应
+type HistoryListResponse struct {
+	Items      []HistoryItem `json:"items"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset,omitempty"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+}
+
+// RetentionPolicy 历史记录保留策略
+type RetentionPolicy struct {
+	RetentionDays    int           `json:"retention_days"`        // 已结束任务保留天数，0表示不按时间清理
+	MaxRowsPerStatus int           `json:"max_rows_per_status"`   // 每种状态最多保留的记录数，0表示不限
+	Interval         time.Duration `json:"-"`                     // 后台清理间隔
+	ArchiveDir       string        `json:"archive_dir,omitempty"` // 删除前归档目录，为空表示不归档
+}
+
+// Enabled 是否启用了任一保留规则
+func (p RetentionPolicy) Enabled() bool {
+	return p.RetentionDays > 0 || p.MaxRowsPerStatus > 0
+}
+
+// HistoryDeleteResult 历史记录删除结果
+type HistoryDeleteResult struct {
+	Deleted int64  `json:"deleted"`
+	Archive string `json:"archive,omitempty"` // 归档文件路径
+}
+
+// PruneResult 一次保留策略清理的结果
+type PruneResult struct {
+	HistoryDeleteResult
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RetentionStatus 保留策略及最近一次清理结果
+type RetentionStatus struct {
+	RetentionPolicy
+	Enabled   bool         `json:"enabled"`
+	Interval  string       `json:"interval"`
+	LastPrune *PruneResult `json:"last_prune,omitempty"`
+}
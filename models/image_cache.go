@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// CachedImage 保留在本地的源镜像，后续任务拉取同一镜像时只下载变化的层
+type CachedImage struct {
+	Image      string    `json:"image"`    // 标准化后的源镜像名，与任务拉取时使用的名称相同
+	ImageID    string    `json:"image_id"` // 最近一次拉取得到的镜像ID
+	Size       int64     `json:"size"`     // 镜像大小（字节），与其他镜像共用的层重复计入
+	Hits       int       `json:"hits"`     // 被后续任务复用的次数
+	InUse      bool      `json:"in_use"`   // 正被执行中的任务使用，清理时跳过
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// ImageCacheStatus 镜像缓存策略与当前缓存的镜像
+type ImageCacheStatus struct {
+	Enabled   bool           `json:"enabled"`
+	TTL       string         `json:"ttl"`        // 未被使用超过该时间的镜像被清理，0s表示不按时间清理
+	MaxSize   int64          `json:"max_size"`   // 磁盘预算（字节），超出时先清理最久未使用的镜像，0表示不限
+	TotalSize int64          `json:"total_size"` // 缓存镜像大小之和（字节）
+	Count     int            `json:"count"`
+	Images    []*CachedImage `json:"images"` // 按最近使用时间倒序
+}
+
+// ImageCachePurgeResult 清理镜像缓存的结果
+type ImageCachePurgeResult struct {
+	Removed []string `json:"removed"`
+	Skipped []string `json:"skipped,omitempty"` // 正在使用或删除失败，仍保留在缓存中
+	Freed   int64    `json:"freed"`             // 释放的镜像大小之和（字节）
+}
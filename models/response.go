@@ -26,6 +26,20 @@ type TransformRequest struct {
 	TargetHost     string `json:"target_host,omitempty"`
 	TargetUsername string `json:"target_username,omitempty"`
 	TargetPassword string `json:"target_password,omitempty"`
+
+	// 方式3: 推送到多个已保存的仓库配置，只拉取一次源镜像；设置后忽略以上目标字段
+	Targets []TransformTarget `json:"targets,omitempty" binding:"omitempty,dive"`
+
+	// 推送后复制到目标的附属制品类型（signature/sbom/attestation 或artifactType，none 表示不复制），为空时使用 referrer_types 配置
+	ReferrerTypes []string `json:"referrer_types,omitempty"`
+}
+
+// 多目标转换中的一个目标
+type TransformTarget struct {
+	ConfigID  string  `json:"config_id" binding:"required"`
+	Naming    string  `json:"naming,omitempty"`    // keep（默认）: 保留源镜像的仓库与命名空间路径；flatten: 只保留镜像名与标签
+	Namespace *string `json:"namespace,omitempty"` // 命名空间，未设置时使用 target_namespace，空字符串表示不加命名空间
+	Path      string  `json:"path,omitempty"`      // 目标仓库中的路径与标签（如 base/nginx:1.25），设置后不使用命名规则
 }
 
 // 镜像转换响应
@@ -47,16 +61,21 @@ type ImageParseResponse struct {
 	Namespace   string `json:"namespace"`
 	Repository  string `json:"repository"`
 	Tag         string `json:"tag"`
+
+	TargetNamespace string `json:"target_namespace"` // 生成目标镜像名使用的命名空间，为空表示不加命名空间
 }
 
 // 历史记录项
 type HistoryItem struct {
-	ID          int       `json:"id"`
-	SourceImage string    `json:"source_image"`
-	TargetImage string    `json:"target_image"`
-	TargetHost  string    `json:"target_host"`
-	Status      string    `json:"status"`
-	ErrorMsg    *string   `json:"error_msg,omitempty"`
-	Duration    *int      `json:"duration,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string     `json:"id"`
+	SourceImage string     `json:"source_image"`
+	TargetImage string     `json:"target_image"`
+	TargetHost  string     `json:"target_host"`
+	ConfigID    *string    `json:"config_id,omitempty"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	Status      string     `json:"status"`
+	ErrorMsg    *string    `json:"error_msg,omitempty"`
+	Duration    *int       `json:"duration,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
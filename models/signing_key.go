@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// SigningKey 仓库配置的签名验证公钥
+//
+// 仓库配置了公钥时，推送到该仓库前需要源镜像带有可被其中一个公钥验证的cosign签名。
+type SigningKey struct {
+	ID          string    `json:"id" db:"id"`
+	ConfigID    string    `json:"config_id" db:"config_id"`
+	Name        string    `json:"name" db:"name"`
+	PublicKey   string    `json:"public_key" db:"public_key"`   // PEM格式
+	Algorithm   string    `json:"algorithm" db:"algorithm"`     // ecdsa-p256/ecdsa-p384/rsa/ed25519
+	Fingerprint string    `json:"fingerprint" db:"fingerprint"` // 公钥DER编码的SHA-256
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateSigningKeyRequest 添加签名验证公钥请求
+type CreateSigningKeyRequest struct {
+	Name      string `json:"name" binding:"required"`
+	PublicKey string `json:"public_key" binding:"required"` // PEM格式，如 cosign.pub 的内容
+}
@@ -4,11 +4,26 @@ import "time"
 
 // 任务状态枚举
 const (
-	TaskStatusPending   = "pending"   // 等待执行
-	TaskStatusRunning   = "running"   // 正在执行
-	TaskStatusCompleted = "completed" // 执行成功
-	TaskStatusFailed    = "failed"    // 执行失败
-	TaskStatusCancelled = "cancelled" // 已取消
+	TaskStatusPending     = "pending"     // 等待执行
+	TaskStatusRunning     = "running"     // 正在执行
+	TaskStatusCompleted   = "completed"   // 执行成功
+	TaskStatusFailed      = "failed"      // 执行失败
+	TaskStatusCancelled   = "cancelled"   // 已取消
+	TaskStatusInterrupted = "interrupted" // 服务关闭时被中断
+)
+
+// 任务类型
+const (
+	TaskTypeTransform = "transform" // 镜像转换
+	TaskTypeBundle    = "bundle"    // 导出离线包
+	TaskTypeImport    = "import"    // 导入离线包并推送
+	TaskTypePromote   = "promote"   // 仓库内为已有镜像添加标签
+)
+
+// 离线包格式
+const (
+	BundleFormatOCI    = "oci"    // OCI镜像布局（oci-layout、index.json、blobs/）
+	BundleFormatDocker = "docker" // docker save 格式，可直接 docker load
 )
 
 // 转换步骤枚举
@@ -39,6 +54,7 @@ type Task struct {
 	TargetHost     string     `json:"target_host" db:"target_host"`
 	TargetUsername string     `json:"target_username" db:"target_username"`
 	ConfigID       *string    `json:"config_id,omitempty" db:"config_id"`
+	CreatedBy      *string    `json:"created_by,omitempty" db:"created_by"`
 	Status         string     `json:"status" db:"status"`
 	Progress       int        `json:"progress" db:"progress"`
 	CurrentStep    int        `json:"current_step" db:"current_step"`
@@ -48,6 +64,47 @@ type Task struct {
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	StartedAt      *time.Time `json:"started_at,omitempty" db:"started_at"`
 	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+
+	// 离线包任务
+	Type           string   `json:"type" db:"type"`                                 // 任务类型: transform/bundle/import/promote
+	Images         []string `json:"images,omitempty" db:"images"`                   // 打包的镜像列表
+	BundleFormat   *string  `json:"bundle_format,omitempty" db:"bundle_format"`     // 打包格式
+	ArtifactSize   *int64   `json:"artifact_size,omitempty" db:"artifact_size"`     // 离线包大小（字节）
+	ArtifactSHA256 *string  `json:"artifact_sha256,omitempty" db:"artifact_sha256"` // 离线包文件的SHA-256
+
+	// 多目标转换任务
+	Targets []TaskTarget `json:"targets,omitempty" db:"targets"` // 各目标的推送结果
+
+	// 跨仓库挂载
+	MountedBytes int64 `json:"mounted_bytes" db:"mounted_bytes"` // 通过跨仓库挂载而无需上传的镜像层大小（字节）
+
+	// 多副本
+	InstanceID     *string    `json:"instance_id,omitempty" db:"instance_id"`           // 创建并执行任务的服务实例
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" db:"lease_expires_at"` // 实例租约到期时间，实例存活期间定期续期
+}
+
+// TaskTarget 多目标转换任务中一个目标的推送结果
+type TaskTarget struct {
+	ConfigID    string `json:"config_id"`
+	Name        string `json:"name"` // 仓库配置名称
+	TargetHost  string `json:"target_host"`
+	TargetImage string `json:"target_image"`
+	Status      string `json:"status"` // pending, running, completed, failed, cancelled
+	ErrorMsg    string `json:"error_msg,omitempty"`
+	Duration    int    `json:"duration"` // 标记与推送耗时（秒）
+}
+
+// 离线包导出请求
+type BundleRequest struct {
+	Images []string `json:"images" binding:"required,min=1"`
+	Format string   `json:"format,omitempty"` // oci（默认）或 docker
+}
+
+// 镜像晋级请求：在同一仓库中为已有镜像添加标签，只写入清单，不传输镜像层
+type PromoteRequest struct {
+	ConfigID string   `json:"config_id,omitempty"`                         // 仓库配置，为空时使用默认配置
+	Image    string   `json:"image" binding:"required"`                    // 仓库中的镜像，如 project/app:rc-123，可带仓库地址或使用 @sha256: 摘要
+	Tags     []string `json:"tags" binding:"required,min=1,dive,required"` // 要添加的标签
 }
 
 // 任务创建请求（复用现有的TransformRequest）
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// 默认管理账户
+const DefaultUsername = "admin"
+
+// User 访问账户，使用Token认证
+type User struct {
+	Username  string    `json:"username" db:"username"`
+	Token     string    `json:"-" db:"token"` // 不返回给前端
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,42 @@
+// Package server 提供HTTP服务层的通用处理：URL前缀、TLS证书与HTTPS跳转
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StripBasePath 去掉请求路径中的URL前缀后交给h处理，使路由无需感知部署路径
+//
+// 访问前缀本身（如 /docker-helper）时跳转到带斜杠的地址，前缀之外的路径返回404。
+// 转发时设置 X-Forwarded-Prefix，gin生成的跳转地址会自动带上前缀。
+func StripBasePath(h http.Handler, basePath string) http.Handler {
+	if basePath == "" || basePath == "/" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		rest, ok := strings.CutPrefix(r.URL.Path, basePath+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + rest
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = "/" + strings.TrimPrefix(r.URL.RawPath, basePath+"/")
+		}
+		r2.Header.Set("X-Forwarded-Prefix", basePath)
+		h.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"docker-helper/utils"
+)
+
+// certCheckInterval 检查证书文件是否变化的最小间隔
+const certCheckInterval = 30 * time.Second
+
+var tlsLogger = utils.NewLogger("tls")
+
+// CertReloader 从文件加载TLS证书，文件更新后自动重新加载，无需重启服务
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // 证书与私钥文件中较新的修改时间
+	lastCheck time.Time
+}
+
+// NewCertReloader 加载证书，文件不存在或证书无效时返回错误
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := cr.reload(true); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// GetCertificate 用于tls.Config.GetCertificate，定期检查文件变化
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if time.Since(cr.lastCheck) >= certCheckInterval {
+		if reloaded, err := cr.reloadLocked(false); err != nil {
+			tlsLogger.Errorf("%v，继续使用当前证书", err)
+		} else if reloaded {
+			tlsLogger.Infof("TLS证书已重新加载: %s", cr.certFile)
+		}
+	}
+	return cr.cert, nil
+}
+
+// Reload 立即检查证书文件，有变化时重新加载，返回是否加载了新证书
+//
+// 新证书无效时继续使用当前证书，并返回错误。
+func (cr *CertReloader) Reload() (bool, error) {
+	return cr.reload(false)
+}
+
+// TLSConfig 返回使用该证书的服务端TLS配置
+func (cr *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+func (cr *CertReloader) reload(force bool) (bool, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.reloadLocked(force)
+}
+
+// reloadLocked 文件修改时间晚于当前证书时重新加载，force为true时总是加载
+func (cr *CertReloader) reloadLocked(force bool) (bool, error) {
+	cr.lastCheck = time.Now()
+
+	modTime, err := latestModTime(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("检查证书文件失败: %v", err)
+	}
+	if !force && !modTime.After(cr.modTime) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("加载TLS证书失败: %v", err)
+	}
+
+	cr.cert = &cert
+	cr.modTime = modTime
+	return true, nil
+}
+
+// latestModTime 返回多个文件中最新的修改时间
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
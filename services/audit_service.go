@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// AuditService 审计日志服务
+type AuditService struct {
+	repo   storage.AuditRepository
+	logger *utils.Logger
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService(repo storage.AuditRepository) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		logger: utils.NewLogger("audit"),
+	}
+}
+
+// Record 追加一条审计事件
+func (as *AuditService) Record(event *models.AuditEvent) error {
+	if err := as.repo.Append(context.Background(), event); err != nil {
+		as.logger.Errorf("写入审计事件失败: action=%s, 错误: %v", event.Action, err)
+		return fmt.Errorf("写入审计事件失败: %v", err)
+	}
+
+	return nil
+}
+
+// List 分页查询审计事件
+func (as *AuditService) List(q *models.AuditQuery) (*models.AuditListResponse, error) {
+	items, total, err := as.repo.List(context.Background(), q)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计事件失败: %v", err)
+	}
+
+	return &models.AuditListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+	}, nil
+}
+
+// ExportJSONLines 以JSON Lines格式逐行导出审计事件（忽略分页参数）
+func (as *AuditService) ExportJSONLines(q *models.AuditQuery, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+
+	err := as.repo.Each(context.Background(), q, func(event *models.AuditEvent) error {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("写出审计事件失败: %v", err)
+		}
+		count++
+		return nil
+	})
+
+	return count, err
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"docker-helper/metrics"
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// BlobMounter 推送前将目标仓库中已有的镜像层跨仓库挂载到目标路径，推送时这些层无需再上传
+//
+// 源镜像与目标在同一仓库时从源镜像路径挂载；其他情况下从此前推送记录的、包含该层的路径挂载。
+// 挂载失败不影响推送，未挂载的层仍由推送上传。
+type BlobMounter struct {
+	locations storage.BlobLocationRepository
+	docker    *DockerService
+	logger    *utils.Logger
+}
+
+// NewBlobMounter 创建跨仓库挂载服务，docker用于确定多架构源镜像拉取到本地的平台
+func NewBlobMounter(locations storage.BlobLocationRepository, docker *DockerService) *BlobMounter {
+	return &BlobMounter{
+		locations: locations,
+		docker:    docker,
+		logger:    utils.NewLogger("blob-mount"),
+	}
+}
+
+// mount 将源镜像的镜像层挂载到目标镜像路径，返回源镜像的镜像层与挂载节省的上传字节数
+//
+// 返回的镜像层在推送成功后交给record记录，供之后推送到同一仓库的任务挂载。
+func (m *BlobMounter) mount(ctx context.Context, source, target, username, password string) ([]*models.BlobLocation, int64) {
+	if m == nil {
+		return nil, 0
+	}
+	logger := m.logger.WithContext(ctx)
+
+	sourceHost, sourceRepository, sourceReference := splitImageReference(source)
+	targetHost, targetRepository, _ := splitImageReference(target)
+	targetClient := NewRegistryClient(targetHost, username, password)
+	sameRegistry := NewRegistryClient(sourceHost, "", "").Host() == targetClient.Host()
+
+	// 同一仓库时源镜像可能需要目标仓库的凭据才能读取
+	sourceClient := NewRegistryClient(sourceHost, "", "")
+	if sameRegistry {
+		sourceClient = targetClient
+	}
+
+	layers, err := m.imageBlobs(ctx, sourceClient, sourceRepository, sourceReference, source)
+	if err != nil {
+		logger.Warnf("读取源镜像清单失败，跳过跨仓库挂载: %s, 错误: %v", source, err)
+		return nil, 0
+	}
+
+	if sameRegistry && sourceRepository != targetRepository {
+		sourceLocations := make([]*models.BlobLocation, 0, len(layers))
+		for _, layer := range layers {
+			sourceLocations = append(sourceLocations, &models.BlobLocation{
+				Registry: targetClient.Host(), Repository: sourceRepository, Digest: layer.Digest, Size: layer.Size,
+			})
+		}
+		if err := m.locations.Record(ctx, sourceLocations); err != nil {
+			logger.Errorf("记录镜像层位置失败: %v", err)
+		}
+	}
+
+	var saved int64
+	blobs := make([]*models.BlobLocation, 0, len(layers))
+	for _, layer := range layers {
+		blobs = append(blobs, &models.BlobLocation{
+			Registry: targetClient.Host(), Repository: targetRepository, Digest: layer.Digest, Size: layer.Size,
+		})
+
+		exists, err := targetClient.BlobExists(ctx, targetRepository, layer.Digest)
+		if err != nil {
+			logger.Warnf("查询目标镜像层失败，跳过跨仓库挂载: %s, 错误: %v", target, err)
+			return blobs, saved
+		}
+		if exists {
+			continue
+		}
+		if m.mountBlob(ctx, targetClient, targetRepository, layer) {
+			saved += layer.Size
+		}
+	}
+
+	if saved > 0 {
+		logger.Infof("跨仓库挂载完成: %s, 节省上传 %d 字节", target, saved)
+	}
+	return blobs, saved
+}
+
+// mountBlob 依次尝试从已知包含该层的路径挂载，仓库拒绝挂载的路径不再记录
+func (m *BlobMounter) mountBlob(ctx context.Context, client *RegistryClient, repository string, layer Descriptor) bool {
+	logger := m.logger.WithContext(ctx)
+
+	candidates, err := m.locations.Locate(ctx, client.Host(), layer.Digest)
+	if err != nil {
+		logger.Errorf("查询镜像层位置失败: %v", err)
+		return false
+	}
+	for _, candidate := range candidates {
+		if candidate.Repository == repository {
+			continue
+		}
+
+		mounted, err := client.MountBlob(ctx, repository, layer.Digest, candidate.Repository)
+		switch {
+		case err != nil:
+			metrics.BlobMounts.WithLabelValues("error").Inc()
+			logger.Warnf("挂载镜像层失败: %s from %s, 错误: %v", layer.Digest, candidate.Repository, err)
+			// 单个来源失败时继续尝试其他来源，请求已取消时不再尝试
+			if ctx.Err() != nil {
+				return false
+			}
+			continue
+		case mounted:
+			metrics.BlobMounts.WithLabelValues("mounted").Inc()
+			metrics.BlobMountBytes.Add(float64(layer.Size))
+			logger.Infof("已挂载镜像层: %s from %s 到 %s", layer.Digest, candidate.Repository, repository)
+			return true
+		}
+
+		metrics.BlobMounts.WithLabelValues("rejected").Inc()
+		if err := m.locations.Forget(ctx, client.Host(), candidate.Repository, layer.Digest); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			logger.Errorf("删除镜像层位置失败: %v", err)
+		}
+	}
+	return false
+}
+
+// imageBlobs 读取源镜像的配置与镜像层，多架构镜像取与本地拉取的镜像平台一致的子清单
+func (m *BlobMounter) imageBlobs(ctx context.Context, client *RegistryClient, repository, reference, image string) ([]Descriptor, error) {
+	manifest, err := client.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.IsIndex() {
+		index, err := manifest.Content()
+		if err != nil {
+			return nil, err
+		}
+		os, architecture, variant := "linux", "amd64", ""
+		if m.docker != nil {
+			if o, a, v, err := m.docker.ImagePlatform(ctx, utils.NormalizeImageName(image)); err == nil {
+				os, architecture, variant = o, a, v
+			}
+		}
+
+		var child *Descriptor
+		for i, candidate := range index.Manifests {
+			platform := candidate.Platform
+			if platform == nil || platform.OS != os || platform.Architecture != architecture {
+				continue
+			}
+			if variant == "" || platform.Variant == variant {
+				child = &index.Manifests[i]
+				break
+			}
+		}
+		if child == nil {
+			return nil, errors.New("多架构镜像中没有与本地镜像平台一致的子清单")
+		}
+		if manifest, err = client.GetManifest(ctx, repository, child.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := manifest.Content()
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]Descriptor, 0, len(content.Layers)+1)
+	if content.Config.Digest != "" {
+		blobs = append(blobs, content.Config)
+	}
+	return append(blobs, content.Layers...), nil
+}
+
+// record 推送成功后记录目标路径包含的镜像层
+func (m *BlobMounter) record(ctx context.Context, blobs []*models.BlobLocation) {
+	if m == nil || len(blobs) == 0 {
+		return
+	}
+	if err := m.locations.Record(ctx, blobs); err != nil {
+		m.logger.WithContext(ctx).Errorf("记录镜像层位置失败: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"docker-helper/models"
+	"docker-helper/storage/sqlite"
+)
+
+func TestMountBlobTriesNextCandidateOnError(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.failMounts = "a-broken/app"
+	layer := registry.putBlob("b-good/app", []byte("shared layer"))
+
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "blobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	client := NewRegistryClient(registry.host(), "", "")
+	ctx := context.Background()
+	// 记录时间相同时按路径排序，出错的路径排在前面
+	if err := store.BlobLocations().Record(ctx, []*models.BlobLocation{
+		{Registry: client.Host(), Repository: "a-broken/app", Digest: layer.Digest, Size: layer.Size},
+		{Registry: client.Host(), Repository: "b-good/app", Digest: layer.Digest, Size: layer.Size},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mounter := NewBlobMounter(store.BlobLocations(), nil)
+	if !mounter.mountBlob(ctx, client, "target/app", layer) {
+		t.Fatalf("mountBlob() = false, want 从其他路径挂载成功, 请求: %v", registry.requests)
+	}
+	if !registry.hasBlob("target/app", layer.Digest) {
+		t.Fatal("目标路径中没有挂载的镜像层")
+	}
+
+	// 先尝试出错的路径，再从下一个路径挂载
+	if mounts := len(registry.requests); mounts != 2 {
+		t.Errorf("挂载请求 = %d 个, want 2, 请求: %v", mounts, registry.requests)
+	}
+}
@@ -0,0 +1,724 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/utils"
+
+	"github.com/google/uuid"
+)
+
+// 离线包上传错误
+var (
+	ErrUploadNotFound   = errors.New("上传会话不存在或已过期删除")
+	ErrUploadOffset     = errors.New("分片起始位置与已接收的字节数不一致")
+	ErrUploadTooLarge   = errors.New("分片超出文件大小")
+	ErrUploadIncomplete = errors.New("文件尚未上传完整")
+	ErrUploadNotReady   = errors.New("离线包尚未完成上传与校验")
+)
+
+// maxBundleMetadataSize 离线包中 manifest.json、index.json 等元数据文件的最大大小
+const maxBundleMetadataSize = 16 << 20
+
+// 离线包导入时完成的校验项
+const (
+	verifiedFileSHA256 = "file_sha256"  // 整个文件与上传时提供的SHA-256一致
+	verifiedSHA256SUMS = "sha256sums"   // 包内 SHA256SUMS 列出的文件全部一致
+	verifiedBlobDigest = "blob_digests" // OCI blobs 的内容与文件名中的摘要一致
+)
+
+// 可以作为镜像清单导入的OCI描述符类型
+var importableManifestTypes = map[string]bool{
+	mediaTypeOCIManifest: true,
+	"application/vnd.docker.distribution.manifest.v2+json": true,
+}
+
+// uploadState 上传会话的持久化状态
+type uploadState struct {
+	models.BundleUpload
+	// LoadManifest OCI格式导入时补充给 docker load 的 manifest.json
+	LoadManifest []savedManifestEntry `json:"load_manifest,omitempty"`
+}
+
+// uploadLocks 按上传会话串行化分片写入与状态更新
+type uploadLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (l *uploadLocks) lock(id string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[id] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// uploadDir 上传文件存放目录，服务重启后仍可续传
+func (bs *BundleService) uploadDir() string {
+	return filepath.Join(bs.dir, "uploads")
+}
+
+func (bs *BundleService) uploadPath(id, ext string) string {
+	return filepath.Join(bs.uploadDir(), id+ext)
+}
+
+// CreateUpload 创建上传会话
+func (bs *BundleService) CreateUpload(req *models.BundleUploadRequest, createdBy string) (*models.BundleUpload, error) {
+	if req.SHA256 != "" {
+		if _, err := hex.DecodeString(req.SHA256); err != nil || len(req.SHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("sha256 必须是64位十六进制字符串")
+		}
+	}
+
+	now := time.Now().UTC()
+	state := &uploadState{BundleUpload: models.BundleUpload{
+		ID:        uuid.New().String(),
+		Size:      req.Size,
+		SHA256:    strings.ToLower(req.SHA256),
+		Status:    models.BundleUploadUploading,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+	if req.FileName != "" {
+		state.FileName = filepath.Base(req.FileName)
+	}
+
+	if err := os.MkdirAll(bs.uploadDir(), 0755); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %v", err)
+	}
+	file, err := os.Create(bs.uploadPath(state.ID, ".tar"))
+	if err != nil {
+		return nil, fmt.Errorf("创建上传文件失败: %v", err)
+	}
+	file.Close()
+	if err := bs.saveUpload(state); err != nil {
+		os.Remove(bs.uploadPath(state.ID, ".tar"))
+		return nil, err
+	}
+
+	bs.logger.Infof("离线包上传会话已创建: %s, 大小: %d字节", state.ID, state.Size)
+	return &state.BundleUpload, nil
+}
+
+// GetUpload 返回上传会话
+func (bs *BundleService) GetUpload(id string) (*models.BundleUpload, error) {
+	state, err := bs.loadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	return &state.BundleUpload, nil
+}
+
+// WriteChunk 从offset开始写入length字节，offset必须等于已接收的字节数
+//
+// 连接中断时已写入的部分仍然有效，客户端通过 GetUpload 取得 received 后从该位置续传。
+func (bs *BundleService) WriteChunk(id string, offset, length int64, r io.Reader) (*models.BundleUpload, error) {
+	unlock := bs.uploads.lock(id)
+	defer unlock()
+
+	state, err := bs.loadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status != models.BundleUploadUploading {
+		return &state.BundleUpload, fmt.Errorf("文件已上传完成")
+	}
+	if offset != state.Received {
+		return &state.BundleUpload, ErrUploadOffset
+	}
+	if offset+length > state.Size {
+		return &state.BundleUpload, ErrUploadTooLarge
+	}
+
+	file, err := os.OpenFile(bs.uploadPath(id, ".tar"), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开上传文件失败: %v", err)
+	}
+	defer file.Close()
+
+	// 丢弃上次中断时写入但未记录的内容
+	if err := file.Truncate(offset); err != nil {
+		return nil, fmt.Errorf("写入上传文件失败: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("写入上传文件失败: %v", err)
+	}
+
+	written, copyErr := io.Copy(file, io.LimitReader(r, length))
+	state.Received += written
+	state.UpdatedAt = time.Now().UTC()
+	if err := bs.saveUpload(state); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return &state.BundleUpload, fmt.Errorf("接收分片失败: %v", copyErr)
+	}
+	if written < length {
+		return &state.BundleUpload, fmt.Errorf("分片不完整: 期望%d字节，收到%d字节", length, written)
+	}
+	return &state.BundleUpload, nil
+}
+
+// CompleteUpload 校验已上传完整的离线包并列出其中的镜像，已校验过时直接返回
+func (bs *BundleService) CompleteUpload(ctx context.Context, id string) (*models.BundleUpload, error) {
+	unlock := bs.uploads.lock(id)
+	defer unlock()
+
+	state, err := bs.loadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status == models.BundleUploadReady {
+		return &state.BundleUpload, nil
+	}
+	if state.Received != state.Size {
+		return &state.BundleUpload, ErrUploadIncomplete
+	}
+
+	contents, err := inspectBundle(ctx, bs.uploadPath(id, ".tar"), state.SHA256)
+	if err != nil {
+		return &state.BundleUpload, fmt.Errorf("离线包校验失败: %v", err)
+	}
+
+	state.Status = models.BundleUploadReady
+	state.Format = contents.format
+	state.Images = contents.images
+	state.Verified = contents.verified
+	state.LoadManifest = contents.loadManifest
+	state.UpdatedAt = time.Now().UTC()
+	if err := bs.saveUpload(state); err != nil {
+		return nil, err
+	}
+
+	bs.logger.Infof("离线包校验通过: %s, 格式: %s, 镜像数: %d, 校验项: %v", id, state.Format, len(state.Images), state.Verified)
+	return &state.BundleUpload, nil
+}
+
+// DeleteUpload 删除上传会话与文件
+func (bs *BundleService) DeleteUpload(id string) error {
+	unlock := bs.uploads.lock(id)
+	defer unlock()
+
+	if _, err := bs.loadUpload(id); err != nil {
+		return err
+	}
+	bs.removeUpload(id)
+	return nil
+}
+
+// removeUpload 删除上传会话的状态与文件
+func (bs *BundleService) removeUpload(id string) {
+	os.Remove(bs.uploadPath(id, ".tar"))
+	os.Remove(bs.uploadPath(id, ".json"))
+}
+
+// OpenImport 打开已校验的离线包，返回可直接交给 docker load 的流
+//
+// OCI格式的离线包在原有内容后补充 manifest.json，docker load 按其中的路径读取配置与层。
+func (bs *BundleService) OpenImport(id string) (*models.BundleUpload, io.ReadCloser, error) {
+	state, err := bs.loadUpload(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.Status != models.BundleUploadReady {
+		return nil, nil, ErrUploadNotReady
+	}
+
+	file, err := os.Open(bs.uploadPath(id, ".tar"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开离线包失败: %v", err)
+	}
+	if state.Format != models.BundleFormatOCI {
+		return &state.BundleUpload, file, nil
+	}
+
+	manifest, err := json.Marshal(state.LoadManifest)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer file.Close()
+		tw := tar.NewWriter(pw)
+		tr := tar.NewReader(bufio.NewReader(file))
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "manifest.json", Size: int64(len(manifest)), Mode: 0644, ModTime: time.Now()})
+		if err == nil {
+			_, err = tw.Write(manifest)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return &state.BundleUpload, pr, nil
+}
+
+// cleanupUploads 删除超过保留时间未更新的上传会话
+func (bs *BundleService) cleanupUploads(retention time.Duration) int {
+	entries, err := os.ReadDir(bs.uploadDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			bs.logger.Errorf("读取上传目录失败: %v", err)
+		}
+		return 0
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		unlock := bs.uploads.lock(id)
+		bs.removeUpload(id)
+		unlock()
+		removed++
+	}
+	return removed
+}
+
+// loadUpload 读取上传会话状态
+func (bs *BundleService) loadUpload(id string) (*uploadState, error) {
+	// 会话ID用作文件名，只接受UUID
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+	data, err := os.ReadFile(bs.uploadPath(id, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取上传状态失败: %v", err)
+	}
+	state := &uploadState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析上传状态失败: %v", err)
+	}
+	return state, nil
+}
+
+// saveUpload 写入上传会话状态，先写临时文件再改名
+func (bs *BundleService) saveUpload(state *uploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := bs.uploadPath("."+state.ID, ".json")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("保存上传状态失败: %v", err)
+	}
+	if err := os.Rename(tmp, bs.uploadPath(state.ID, ".json")); err != nil {
+		return fmt.Errorf("保存上传状态失败: %v", err)
+	}
+	return nil
+}
+
+// bundleContents 校验离线包得到的内容
+type bundleContents struct {
+	format       string
+	images       []models.BundleImageInfo
+	verified     []string
+	loadManifest []savedManifestEntry
+}
+
+// inspectBundle 校验离线包并列出镜像
+//
+// 依次校验：整个文件的SHA-256（expectedSHA256非空时）、包内 SHA256SUMS（存在时）、OCI blobs 的摘要，
+// 并确认每个镜像的配置与层都在包中。包含 manifest.json 时按 docker save 格式处理，否则按OCI镜像布局处理。
+func inspectBundle(ctx context.Context, path, expectedSHA256 string) (*bundleContents, error) {
+	index := newTarIndex()
+	metadata, fileSHA256, err := scanBundle(ctx, path, &index, func(name string) bool {
+		return name == "manifest.json" || name == "index.json" || name == "oci-layout" || name == bundleChecksumFile
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &bundleContents{}
+	if expectedSHA256 != "" {
+		if fileSHA256 != expectedSHA256 {
+			return nil, fmt.Errorf("文件SHA-256不一致: 期望 %s，实际 %s", expectedSHA256, fileSHA256)
+		}
+		contents.verified = append(contents.verified, verifiedFileSHA256)
+	}
+
+	if sums, ok := metadata[bundleChecksumFile]; ok {
+		if err := verifyChecksums(&index, sums); err != nil {
+			return nil, err
+		}
+		contents.verified = append(contents.verified, verifiedSHA256SUMS)
+	}
+
+	switch {
+	case metadata["manifest.json"] != nil:
+		contents.format = models.BundleFormatDocker
+		err = contents.describeDocker(&index, metadata["manifest.json"])
+	case metadata["oci-layout"] != nil && metadata["index.json"] != nil:
+		contents.format = models.BundleFormatOCI
+		if err := verifyBlobDigests(&index); err != nil {
+			return nil, err
+		}
+		contents.verified = append(contents.verified, verifiedBlobDigest)
+		err = contents.describeOCI(ctx, path, &index, metadata["index.json"])
+	default:
+		return nil, fmt.Errorf("无法识别的格式：既不是OCI镜像布局（oci-layout、index.json），也不是 docker save 格式（manifest.json）")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(contents.images) == 0 {
+		return nil, fmt.Errorf("离线包中没有镜像")
+	}
+	return contents, nil
+}
+
+// scanBundle 读取整个离线包，记录各文件摘要，返回keep选中的小文件内容与整个文件的SHA-256
+func scanBundle(ctx context.Context, path string, index *tarIndex, keep func(name string) bool) (map[string][]byte, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开离线包失败: %v", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	r := io.TeeReader(bufio.NewReader(file), hasher)
+
+	kept := make(map[string][]byte)
+	err = walkTar(ctx, r, index, func(header *tar.Header, name string, body io.Reader) error {
+		if body == nil || !keep(name) {
+			return nil
+		}
+		if header.Size > maxBundleMetadataSize {
+			return fmt.Errorf("%s 过大（%d字节）", name, header.Size)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		kept[name] = data
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		return nil, "", fmt.Errorf("读取离线包失败: %v", err)
+	}
+	// tar结束标记之后可能还有填充数据，同样计入文件摘要
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, "", fmt.Errorf("读取离线包失败: %v", err)
+	}
+	return kept, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// verifyChecksums 按 SHA256SUMS（sha256sum 输出格式）校验包内文件
+func verifyChecksums(index *tarIndex, sums []byte) error {
+	for i, line := range strings.Split(string(sums), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		digest, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("%s 第%d行格式错误", bundleChecksumFile, i+1)
+		}
+		// 二进制模式的文件名以“*”开头
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+
+		_, file, err := index.resolve(name)
+		if err != nil {
+			return fmt.Errorf("%s 中列出的文件不存在: %s", bundleChecksumFile, name)
+		}
+		if file.digest != strings.ToLower(digest) {
+			return fmt.Errorf("文件 %s 的SHA-256与 %s 不一致", name, bundleChecksumFile)
+		}
+	}
+	return nil
+}
+
+// verifyBlobDigests 校验 blobs/sha256 下各文件的内容与文件名一致
+func verifyBlobDigests(index *tarIndex) error {
+	for name, file := range index.files {
+		digest, ok := strings.CutPrefix(name, "blobs/sha256/")
+		if !ok {
+			continue
+		}
+		if file.digest != digest {
+			return fmt.Errorf("blob %s 的内容与摘要不一致", name)
+		}
+	}
+	return nil
+}
+
+// describeDocker 按 docker save 的 manifest.json 列出镜像，每个标签一条记录
+func (c *bundleContents) describeDocker(index *tarIndex, data []byte) error {
+	var entries []savedManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("解析 manifest.json 失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		image, err := describeImage(index, entry.Config, entry.Layers)
+		if err != nil {
+			return err
+		}
+		if len(entry.RepoTags) == 0 {
+			c.images = append(c.images, image)
+		}
+		for _, tag := range entry.RepoTags {
+			named := image
+			named.Name = tag
+			c.images = append(c.images, named)
+		}
+	}
+	return nil
+}
+
+// describeOCI 按 index.json 列出镜像，并生成 docker load 使用的 manifest.json
+func (c *bundleContents) describeOCI(ctx context.Context, path string, index *tarIndex, data []byte) error {
+	var idx ociIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return fmt.Errorf("解析 index.json 失败: %v", err)
+	}
+
+	// 第二次读取离线包，取出索引引用的镜像清单
+	wanted := make(map[string]bool)
+	for _, descriptor := range idx.Manifests {
+		if !importableManifestTypes[descriptor.MediaType] {
+			return fmt.Errorf("不支持的索引条目类型 %s（多平台镜像索引需先选择平台再导出）", descriptor.MediaType)
+		}
+		wanted[blobPath(descriptor.Digest)] = true
+	}
+	manifests, _, err := scanBundle(ctx, path, &tarIndex{files: map[string]savedFile{}, links: map[string]string{}}, func(name string) bool {
+		return wanted[name]
+	})
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]int) // 镜像清单 -> loadManifest下标
+	for _, descriptor := range idx.Manifests {
+		data, ok := manifests[blobPath(descriptor.Digest)]
+		if !ok {
+			return fmt.Errorf("缺少镜像清单 %s", descriptor.Digest)
+		}
+		var m ociManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("解析镜像清单 %s 失败: %v", descriptor.Digest, err)
+		}
+
+		layers := make([]string, 0, len(m.Layers))
+		for _, layer := range m.Layers {
+			layers = append(layers, blobPath(layer.Digest))
+		}
+		image, err := describeImage(index, blobPath(m.Config.Digest), layers)
+		if err != nil {
+			return err
+		}
+		image.Name = ociImageName(descriptor.Annotations)
+		c.images = append(c.images, image)
+
+		i, ok := loaded[descriptor.Digest]
+		if !ok {
+			i = len(c.loadManifest)
+			loaded[descriptor.Digest] = i
+			c.loadManifest = append(c.loadManifest, savedManifestEntry{Config: blobPath(m.Config.Digest), Layers: layers})
+		}
+		if image.Name != "" && utils.ValidateImageName(image.Name) == nil {
+			c.loadManifest[i].RepoTags = append(c.loadManifest[i].RepoTags, utils.NormalizeImageName(image.Name))
+		}
+	}
+	return nil
+}
+
+// describeImage 确认镜像的配置与层都在包中，并统计大小
+func describeImage(index *tarIndex, config string, layers []string) (models.BundleImageInfo, error) {
+	_, configFile, err := index.resolve(config)
+	if err != nil {
+		return models.BundleImageInfo{}, fmt.Errorf("镜像配置%s", err)
+	}
+	image := models.BundleImageInfo{ID: "sha256:" + configFile.digest, Layers: len(layers), Size: configFile.size}
+	for _, layer := range layers {
+		_, file, err := index.resolve(layer)
+		if err != nil {
+			return models.BundleImageInfo{}, fmt.Errorf("镜像层%s", err)
+		}
+		image.Size += file.size
+	}
+	return image, nil
+}
+
+// ImageCreated 读取已校验离线包中各镜像配置记录的构建时间，返回镜像ID到构建时间的映射
+//
+// 需要重新读取整个离线包，只在源镜像策略设置了构建时间上限时使用。
+func (bs *BundleService) ImageCreated(ctx context.Context, id string) (map[string]time.Time, error) {
+	state, err := bs.loadUpload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// docker save 的配置文件为 <hex>.json 或 blobs/sha256/<hex>，OCI镜像布局为 blobs/sha256/<hex>
+	configs := make(map[string]string) // 包中的文件 -> 镜像ID
+	for _, image := range state.Images {
+		_, hex, _ := strings.Cut(image.ID, ":")
+		configs[blobPath(image.ID)] = image.ID
+		configs[hex+".json"] = image.ID
+	}
+	index := newTarIndex()
+	files, _, err := scanBundle(ctx, bs.uploadPath(id, ".tar"), &index, func(name string) bool {
+		return configs[name] != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created := make(map[string]time.Time)
+	for name, data := range files {
+		var imageConfig struct {
+			Created time.Time `json:"created"`
+		}
+		if err := json.Unmarshal(data, &imageConfig); err != nil {
+			return nil, fmt.Errorf("解析镜像配置 %s 失败: %v", name, err)
+		}
+		created[configs[name]] = imageConfig.Created
+	}
+	return created, nil
+}
+
+// ociImageName 从索引注解中取镜像名，ref.name 只有标签时不作为镜像名
+func ociImageName(annotations map[string]string) string {
+	if name := annotations["io.containerd.image.name"]; name != "" {
+		return name
+	}
+	if ref := annotations["org.opencontainers.image.ref.name"]; strings.ContainsAny(ref, "/:") {
+		return ref
+	}
+	return ""
+}
+
+// blobPath 摘要对应的OCI blob路径
+func blobPath(digest string) string {
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return "blobs/" + algorithm + "/" + hex
+}
+
+// resolveImportMappings 将导入请求中的镜像对应到包中的镜像ID并生成registry下的目标镜像
+//
+// mappings为空时导入全部已命名的镜像。源镜像可以是包中的镜像名（按标准化后的名称比较）或镜像ID。
+// 未指定目标时与转换任务的命名规则相同，按namespace生成；指定时为registry中的路径与标签（如 base/nginx:1.25），
+// 也可以带上registry前缀。
+func resolveImportMappings(images []models.BundleImageInfo, mappings []models.ImportMapping, registry, namespace string) ([]ImportJob, error) {
+	if len(mappings) == 0 {
+		for _, image := range images {
+			if image.Name != "" {
+				mappings = append(mappings, models.ImportMapping{Source: image.Name})
+			}
+		}
+		if len(mappings) == 0 {
+			return nil, fmt.Errorf("离线包中的镜像都没有名称，请在 images 中按镜像ID指定目标镜像")
+		}
+	}
+
+	var jobs []ImportJob
+	targets := make(map[string]bool)
+	for _, mapping := range mappings {
+		image, ok := findBundleImage(images, mapping.Source)
+		if !ok {
+			return nil, fmt.Errorf("离线包中没有镜像 %s", mapping.Source)
+		}
+
+		var target string
+		if path := strings.TrimPrefix(strings.TrimSpace(mapping.Target), registry+"/"); path != "" {
+			if err := utils.ValidateImageName(path); err != nil {
+				return nil, fmt.Errorf("目标镜像 %s 无效: %v", mapping.Target, err)
+			}
+			target = registry + "/" + strings.TrimPrefix(path, "/")
+		} else if image.Name != "" {
+			target = utils.BuildTargetImageName(image.Name, registry, namespace)
+		} else {
+			return nil, fmt.Errorf("镜像 %s 没有名称，需要指定目标镜像", mapping.Source)
+		}
+		if targets[target] {
+			return nil, fmt.Errorf("目标镜像重复: %s", target)
+		}
+		targets[target] = true
+
+		jobs = append(jobs, ImportJob{Source: mapping.Source, ID: image.ID, Target: target})
+	}
+	return jobs, nil
+}
+
+// findBundleImage 按镜像名或镜像ID查找包中的镜像
+func findBundleImage(images []models.BundleImageInfo, source string) (models.BundleImageInfo, bool) {
+	source = strings.TrimSpace(source)
+	for _, image := range images {
+		if image.ID == source || strings.TrimPrefix(image.ID, "sha256:") == source {
+			return image, true
+		}
+	}
+	if utils.ValidateImageName(source) != nil {
+		return models.BundleImageInfo{}, false
+	}
+	normalized := utils.NormalizeImageName(source)
+	for _, image := range images {
+		if image.Name != "" && utils.NormalizeImageName(image.Name) == normalized {
+			return image, true
+		}
+	}
+	return models.BundleImageInfo{}, false
+}
+
+// ImportJob 导入任务中的一个镜像
+type ImportJob struct {
+	Source string // 请求中的源镜像（包中的镜像名或镜像ID）
+	ID     string // 镜像ID
+	Target string // 目标镜像
+}
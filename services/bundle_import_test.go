@@ -0,0 +1,80 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"docker-helper/models"
+)
+
+// dockerSaveBundle 生成只有一个镜像的 docker save 格式离线包，返回内容与镜像ID
+func dockerSaveBundle(t *testing.T, name string, created time.Time) ([]byte, string) {
+	t.Helper()
+	imageConfig, _ := json.Marshal(map[string]interface{}{"created": created, "architecture": "amd64", "os": "linux"})
+	configSum := sha256.Sum256(imageConfig)
+	configHex := hex.EncodeToString(configSum[:])
+	layer := []byte("layer")
+	layerSum := sha256.Sum256(layer)
+	layerPath := hex.EncodeToString(layerSum[:]) + "/layer.tar"
+	manifest, _ := json.Marshal([]savedManifestEntry{{Config: configHex + ".json", RepoTags: []string{name}, Layers: []string{layerPath}}})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{configHex + ".json", imageConfig},
+		{layerPath, layer},
+		{"manifest.json", manifest},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: file.name, Size: int64(len(file.data)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), "sha256:" + configHex
+}
+
+func TestBundleImageCreated(t *testing.T) {
+	bs, err := NewBundleService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, imageID := dockerSaveBundle(t, "nginx:1.25", created)
+
+	upload, err := bs.CreateUpload(&models.BundleUploadRequest{Size: int64(len(data))}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.WriteChunk(upload.ID, 0, int64(len(data)), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	upload, err = bs.CompleteUpload(context.Background(), upload.ID)
+	if err != nil {
+		t.Fatalf("CompleteUpload: %v", err)
+	}
+	if len(upload.Images) != 1 || upload.Images[0].ID != imageID {
+		t.Fatalf("images = %+v, want %s", upload.Images, imageID)
+	}
+
+	got, err := bs.ImageCreated(context.Background(), upload.ID)
+	if err != nil {
+		t.Fatalf("ImageCreated: %v", err)
+	}
+	if !got[imageID].Equal(created) {
+		t.Fatalf("ImageCreated = %v, want %s: %v", got, imageID, created)
+	}
+}
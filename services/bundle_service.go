@@ -0,0 +1,731 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/utils"
+)
+
+// ErrBundleNotFound 离线包文件不存在或已超过保留时间被删除
+var ErrBundleNotFound = errors.New("离线包不存在或已过期删除")
+
+// bundleCleanupInterval 删除过期离线包的间隔
+const bundleCleanupInterval = time.Hour
+
+// 离线包中的附加文件
+const (
+	bundleManifestFile = "bundle.json" // 镜像清单
+	bundleChecksumFile = "SHA256SUMS"  // 包内全部文件的SHA-256，可用 sha256sum -c 校验
+)
+
+// OCI媒体类型
+const (
+	ociLayoutVersion      = "1.0.0"
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIConfig    = "application/vnd.oci.image.config.v1+json"
+	mediaTypeOCILayer     = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeOCILayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// BundleService 离线包服务，将 docker save 导出的镜像写为OCI镜像布局或 docker save 格式的tar包，并定期删除过期文件
+type BundleService struct {
+	dir     string
+	logger  *utils.Logger
+	uploads uploadLocks
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBundleService 创建离线包服务，dir不存在时自动创建
+func NewBundleService(dir string) (*BundleService, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "uploads"), 0755); err != nil {
+		return nil, fmt.Errorf("创建离线包目录失败: %v", err)
+	}
+	return &BundleService{
+		dir:    dir,
+		logger: utils.NewLogger("bundle"),
+	}, nil
+}
+
+// Start 清理上次运行遗留的临时文件并启动过期离线包的后台清理
+func (bs *BundleService) Start() {
+	bs.removeTemporary()
+
+	bs.stop = make(chan struct{})
+	bs.done = make(chan struct{})
+
+	go func() {
+		defer close(bs.done)
+
+		ticker := time.NewTicker(bundleCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			bs.Cleanup()
+
+			select {
+			case <-ticker.C:
+			case <-bs.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理
+func (bs *BundleService) Stop() {
+	if bs.stop == nil {
+		return
+	}
+	close(bs.stop)
+	<-bs.done
+	bs.stop = nil
+}
+
+// Cleanup 删除超过保留时间（bundle_retention）的离线包与上传会话，返回删除的数量
+func (bs *BundleService) Cleanup() int {
+	retention := config.Current().BundleRetention
+	if retention <= 0 {
+		return 0
+	}
+
+	entries, err := os.ReadDir(bs.dir)
+	if err != nil {
+		bs.logger.Errorf("读取离线包目录失败: %v", err)
+		return 0
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".tar") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(bs.dir, name)); err != nil {
+			bs.logger.Errorf("删除过期离线包 %s 失败: %v", name, err)
+			continue
+		}
+		removed++
+	}
+	removed += bs.cleanupUploads(retention)
+	if removed > 0 {
+		bs.logger.Infof("已删除%d个超过%s的离线包与上传会话", removed, retention)
+	}
+	return removed
+}
+
+// removeTemporary 删除未完成的临时文件，只应在没有任务执行时调用
+func (bs *BundleService) removeTemporary() {
+	entries, err := os.ReadDir(bs.dir)
+	if err != nil {
+		bs.logger.Errorf("读取离线包目录失败: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			os.RemoveAll(filepath.Join(bs.dir, entry.Name()))
+		}
+	}
+}
+
+// FileName 下载离线包时使用的文件名
+func (bs *BundleService) FileName(taskID string) string {
+	return "bundle-" + taskID + ".tar"
+}
+
+// path 离线包在目录中的路径
+func (bs *BundleService) path(taskID string) string {
+	return filepath.Join(bs.dir, taskID+".tar")
+}
+
+// Open 打开任务生成的离线包，文件不存在时返回ErrBundleNotFound
+func (bs *BundleService) Open(taskID string) (*os.File, error) {
+	file, err := os.Open(bs.path(taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBundleNotFound
+	}
+	return file, err
+}
+
+// Write 读取 docker save 导出流，按format生成离线包，返回文件大小与SHA-256
+//
+// 导出流先解包到临时目录，多个镜像共用的层按摘要只写入一次。离线包先写入临时文件，完成后再改名，
+// 下载时不会读到未写完的文件。
+func (bs *BundleService) Write(ctx context.Context, taskID, format string, save io.Reader) (size int64, sum string, err error) {
+	staging, err := os.MkdirTemp(bs.dir, ".staging-"+taskID+"-")
+	if err != nil {
+		return 0, "", fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	archive, err := extractSavedImages(ctx, save, staging)
+	if err != nil {
+		return 0, "", err
+	}
+
+	tmp, err := os.CreateTemp(bs.dir, "."+taskID+"-*.tar")
+	if err != nil {
+		return 0, "", fmt.Errorf("创建离线包文件失败: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hasher := sha256.New()
+	buffered := bufio.NewWriterSize(io.MultiWriter(tmp, hasher), 1<<20)
+	w := newBundleWriter(buffered)
+
+	manifest := &bundleManifest{Format: format, CreatedAt: w.modTime}
+	switch format {
+	case models.BundleFormatDocker:
+		err = archive.writeDocker(ctx, w, manifest)
+	default:
+		err = archive.writeOCI(ctx, w, manifest)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if err = w.finish(manifest); err != nil {
+		return 0, "", fmt.Errorf("写入离线包失败: %v", err)
+	}
+	if err = buffered.Flush(); err != nil {
+		return 0, "", fmt.Errorf("写入离线包失败: %v", err)
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, "", fmt.Errorf("写入离线包失败: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, "", fmt.Errorf("写入离线包失败: %v", err)
+	}
+	if err = os.Rename(tmp.Name(), bs.path(taskID)); err != nil {
+		return 0, "", fmt.Errorf("保存离线包失败: %v", err)
+	}
+
+	sum = hex.EncodeToString(hasher.Sum(nil))
+	bs.logger.With("task_id", taskID).Infof("离线包已生成: %s, 格式: %s, 镜像数: %d, 层数: %d, 大小: %d字节",
+		bs.path(taskID), format, len(manifest.Images), manifest.Layers, info.Size())
+	return info.Size(), sum, nil
+}
+
+// bundleManifest 离线包中的 bundle.json
+type bundleManifest struct {
+	Format    string        `json:"format"`
+	CreatedAt time.Time     `json:"created_at"`
+	Layers    int           `json:"layers"` // 去重后的层数
+	Images    []bundleImage `json:"images"`
+}
+
+// bundleImage bundle.json 中的一个镜像
+type bundleImage struct {
+	Tags     []string `json:"tags"`
+	Config   string   `json:"config"`             // 镜像配置摘要，即镜像ID
+	Manifest string   `json:"manifest,omitempty"` // OCI清单摘要，仅oci格式
+	Layers   int      `json:"layers"`
+	Size     int64    `json:"size"` // 配置与各层大小之和，包含与其他镜像共用的层
+}
+
+// savedManifestEntry docker save 导出的 manifest.json 中的一个镜像
+type savedManifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// savedEntry 导出流中的一个条目
+type savedEntry struct {
+	name string
+	kind byte   // tar.TypeDir/TypeReg/TypeSymlink
+	link string // 符号链接的原始目标
+}
+
+// savedFile 解包后的普通文件
+type savedFile struct {
+	digest string // SHA-256，十六进制
+	size   int64
+}
+
+// tarIndex tar包中普通文件的摘要与符号链接
+type tarIndex struct {
+	files map[string]savedFile
+	links map[string]string // 符号链接 -> 指向的路径（相对包根目录）
+}
+
+func newTarIndex() tarIndex {
+	return tarIndex{files: make(map[string]savedFile), links: make(map[string]string)}
+}
+
+// walkTar 逐个读取tar条目，校验路径后调用fn，同时把普通文件的SHA-256与符号链接记入index
+//
+// fn未读完的文件内容由walkTar读完，保证摘要覆盖整个文件。
+func walkTar(ctx context.Context, r io.Reader, index *tarIndex, fn func(header *tar.Header, name string, body io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("包含非法路径: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg:
+			hasher := sha256.New()
+			body := io.TeeReader(tr, hasher)
+			if err := fn(header, name, body); err != nil {
+				return err
+			}
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				return err
+			}
+			index.files[name] = savedFile{digest: hex.EncodeToString(hasher.Sum(nil)), size: header.Size}
+		case tar.TypeSymlink:
+			// 旧版本Docker以符号链接表示重复的层
+			index.links[name] = path.Join(path.Dir(name), header.Linkname)
+			if err := fn(header, name, nil); err != nil {
+				return err
+			}
+		case tar.TypeDir:
+			if err := fn(header, name, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// resolve 解析符号链接，返回实际文件的路径与信息
+func (ix *tarIndex) resolve(name string) (string, savedFile, error) {
+	name = path.Clean(name)
+	for i := 0; i < 16; i++ {
+		if file, ok := ix.files[name]; ok {
+			return name, file, nil
+		}
+		target, ok := ix.links[name]
+		if !ok {
+			break
+		}
+		name = target
+	}
+	return "", savedFile{}, fmt.Errorf("缺少文件: %s", name)
+}
+
+// savedArchive 解包到临时目录的 docker save 导出内容
+type savedArchive struct {
+	tarIndex
+	dir     string
+	entries []savedEntry
+}
+
+// extractSavedImages 将导出流解包到dir，同时计算各文件的SHA-256
+func extractSavedImages(ctx context.Context, save io.Reader, dir string) (*savedArchive, error) {
+	archive := &savedArchive{tarIndex: newTarIndex(), dir: dir}
+
+	err := walkTar(ctx, save, &archive.tarIndex, func(header *tar.Header, name string, body io.Reader) error {
+		entry := savedEntry{name: name, kind: header.Typeflag}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if err := archive.extractFile(name, body); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			entry.link = header.Linkname
+		}
+		archive.entries = append(archive.entries, entry)
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("读取导出的镜像失败: %v", err)
+	}
+
+	if _, ok := archive.files["manifest.json"]; !ok {
+		return nil, fmt.Errorf("导出的镜像缺少 manifest.json")
+	}
+	return archive, nil
+}
+
+// extractFile 写出单个文件
+func (a *savedArchive) extractFile(name string, r io.Reader) error {
+	target := filepath.Join(a.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("解包失败: %v", err)
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("解包失败: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// open 打开解包后的文件
+func (a *savedArchive) open(name string) (*os.File, error) {
+	return os.Open(filepath.Join(a.dir, filepath.FromSlash(name)))
+}
+
+// manifest 读取 manifest.json
+func (a *savedArchive) manifest() ([]savedManifestEntry, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取 manifest.json 失败: %v", err)
+	}
+	var entries []savedManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析 manifest.json 失败: %v", err)
+	}
+	return entries, nil
+}
+
+// describe 生成镜像在 bundle.json 中的记录，并统计去重后的层
+func (a *savedArchive) describe(entry savedManifestEntry, layers map[string]bool) (bundleImage, error) {
+	_, config, err := a.resolve(entry.Config)
+	if err != nil {
+		return bundleImage{}, err
+	}
+	image := bundleImage{
+		Tags:   entry.RepoTags,
+		Config: "sha256:" + config.digest,
+		Layers: len(entry.Layers),
+		Size:   config.size,
+	}
+	if image.Tags == nil {
+		image.Tags = []string{}
+	}
+	for _, layer := range entry.Layers {
+		_, file, err := a.resolve(layer)
+		if err != nil {
+			return bundleImage{}, err
+		}
+		image.Size += file.size
+		layers[file.digest] = true
+	}
+	return image, nil
+}
+
+// writeDocker 按原样写出 docker save 格式，可直接 docker load
+func (a *savedArchive) writeDocker(ctx context.Context, w *bundleWriter, manifest *bundleManifest) error {
+	entries, err := a.manifest()
+	if err != nil {
+		return err
+	}
+	layers := make(map[string]bool)
+	for _, entry := range entries {
+		image, err := a.describe(entry, layers)
+		if err != nil {
+			return err
+		}
+		manifest.Images = append(manifest.Images, image)
+	}
+	manifest.Layers = len(layers)
+
+	for _, entry := range a.entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch entry.kind {
+		case tar.TypeDir:
+			err = w.addDir(entry.name)
+		case tar.TypeSymlink:
+			err = w.addSymlink(entry.name, entry.link)
+		default:
+			err = a.copyFile(w, entry.name, entry.name)
+		}
+		if err != nil {
+			return fmt.Errorf("写入离线包失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeOCI 转换为OCI镜像布局，配置、层与清单按摘要存放在 blobs/sha256 下，相同摘要只写入一次
+func (a *savedArchive) writeOCI(ctx context.Context, w *bundleWriter, manifest *bundleManifest) error {
+	entries, err := a.manifest()
+	if err != nil {
+		return err
+	}
+
+	layout, _ := json.Marshal(map[string]string{"imageLayoutVersion": ociLayoutVersion})
+	if err := w.addBytes("oci-layout", layout); err != nil {
+		return fmt.Errorf("写入离线包失败: %v", err)
+	}
+	for _, dir := range []string{"blobs", "blobs/sha256"} {
+		if err := w.addDir(dir); err != nil {
+			return fmt.Errorf("写入离线包失败: %v", err)
+		}
+	}
+
+	written := make(map[string]bool)
+	writeBlob := func(name string, file savedFile) error {
+		if written[file.digest] {
+			return nil
+		}
+		written[file.digest] = true
+		return a.copyFile(w, name, "blobs/sha256/"+file.digest)
+	}
+
+	index := ociIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex, Manifests: []ociDescriptor{}}
+	layers := make(map[string]bool)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		image, err := a.describe(entry, layers)
+		if err != nil {
+			return err
+		}
+
+		configName, config, err := a.resolve(entry.Config)
+		if err != nil {
+			return err
+		}
+		if err := writeBlob(configName, config); err != nil {
+			return fmt.Errorf("写入镜像配置失败: %v", err)
+		}
+
+		m := ociManifest{
+			SchemaVersion: 2,
+			MediaType:     mediaTypeOCIManifest,
+			Config:        ociDescriptor{MediaType: mediaTypeOCIConfig, Digest: "sha256:" + config.digest, Size: config.size},
+			Layers:        make([]ociDescriptor, 0, len(entry.Layers)),
+		}
+		for _, layer := range entry.Layers {
+			layerName, file, err := a.resolve(layer)
+			if err != nil {
+				return err
+			}
+			mediaType, err := a.layerMediaType(layerName)
+			if err != nil {
+				return err
+			}
+			if err := writeBlob(layerName, file); err != nil {
+				return fmt.Errorf("写入镜像层失败: %v", err)
+			}
+			m.Layers = append(m.Layers, ociDescriptor{MediaType: mediaType, Digest: "sha256:" + file.digest, Size: file.size})
+		}
+
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(data)
+		manifestDigest := hex.EncodeToString(digest[:])
+		if !written[manifestDigest] {
+			written[manifestDigest] = true
+			if err := w.addBytes("blobs/sha256/"+manifestDigest, data); err != nil {
+				return fmt.Errorf("写入镜像清单失败: %v", err)
+			}
+		}
+		image.Manifest = "sha256:" + manifestDigest
+		manifest.Images = append(manifest.Images, image)
+
+		// 每个标签一条索引记录，containerd（ctr import）与skopeo等工具按注解识别镜像名
+		descriptor := ociDescriptor{MediaType: mediaTypeOCIManifest, Digest: image.Manifest, Size: int64(len(data))}
+		if len(entry.RepoTags) == 0 {
+			index.Manifests = append(index.Manifests, descriptor)
+		}
+		for _, tag := range entry.RepoTags {
+			tagged := descriptor
+			_, _, _, version := utils.ParseImageName(tag)
+			tagged.Annotations = map[string]string{
+				"io.containerd.image.name":          fullImageName(tag),
+				"org.opencontainers.image.ref.name": version,
+			}
+			index.Manifests = append(index.Manifests, tagged)
+		}
+	}
+	manifest.Layers = len(layers)
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := w.addBytes("index.json", data); err != nil {
+		return fmt.Errorf("写入离线包失败: %v", err)
+	}
+	return nil
+}
+
+// layerMediaType 根据文件头判断层是否经过gzip压缩
+func (a *savedArchive) layerMediaType(name string) (string, error) {
+	file, err := a.open(name)
+	if err != nil {
+		return "", fmt.Errorf("读取镜像层失败: %v", err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(file, magic); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return mediaTypeOCILayerGzip, nil
+	}
+	return mediaTypeOCILayer, nil
+}
+
+// copyFile 将解包后的文件name以target为名写入离线包
+func (a *savedArchive) copyFile(w *bundleWriter, name, target string) error {
+	file, err := a.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info := a.files[name]
+	return w.addFile(target, info.size, info.digest, file)
+}
+
+// fullImageName 补全镜像的仓库与命名空间，如 nginx:1.25 -> docker.io/library/nginx:1.25
+func fullImageName(image string) string {
+	registry, namespace, repository, tag := utils.ParseImageName(image)
+	name := registry + "/"
+	if namespace != "" {
+		name += namespace + "/"
+	}
+	return name + repository + ":" + tag
+}
+
+// ociDescriptor OCI内容描述符
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest OCI镜像清单
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// ociIndex OCI镜像索引（index.json）
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// bundleWriter 写出离线包tar并记录各文件的SHA-256
+type bundleWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+	sums    strings.Builder
+}
+
+func newBundleWriter(w io.Writer) *bundleWriter {
+	return &bundleWriter{tw: tar.NewWriter(w), modTime: time.Now().UTC().Truncate(time.Second)}
+}
+
+// addDir 写入目录
+func (w *bundleWriter) addDir(name string) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  w.modTime,
+	})
+}
+
+// addSymlink 写入符号链接，不计入 SHA256SUMS
+func (w *bundleWriter) addSymlink(name, link string) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: link,
+		Mode:     0644,
+		ModTime:  w.modTime,
+	})
+}
+
+// addFile 写入文件，digest为空时边写边计算
+func (w *bundleWriter) addFile(name string, size int64, digest string, r io.Reader) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  w.modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	var hasher hash.Hash
+	dst := io.Writer(w.tw)
+	if digest == "" {
+		hasher = sha256.New()
+		dst = io.MultiWriter(w.tw, hasher)
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		return err
+	}
+	if hasher != nil {
+		digest = hex.EncodeToString(hasher.Sum(nil))
+	}
+	fmt.Fprintf(&w.sums, "%s  %s\n", digest, name)
+	return nil
+}
+
+// addBytes 写入内存中的文件
+func (w *bundleWriter) addBytes(name string, data []byte) error {
+	return w.addFile(name, int64(len(data)), "", bytes.NewReader(data))
+}
+
+// finish 写入 bundle.json 与 SHA256SUMS 并结束tar
+func (w *bundleWriter) finish(manifest *bundleManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.addBytes(bundleManifestFile, data); err != nil {
+		return err
+	}
+
+	sums := w.sums.String()
+	if err := w.addFile(bundleChecksumFile, int64(len(sums)), "", strings.NewReader(sums)); err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
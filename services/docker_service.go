@@ -2,15 +2,19 @@ package services
 
 import (
 	"context"
+	"docker-helper/metrics"
+	"docker-helper/tracing"
 	"docker-helper/utils"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type DockerService struct {
@@ -19,7 +23,7 @@ type DockerService struct {
 }
 
 func NewDockerService() (*DockerService, error) {
-	logger := utils.NewLogger("info")
+	logger := utils.NewLogger("docker")
 
 	logger.Info("正在创建Docker客户端...")
 	cli, err := client.NewClientWithOpts(client.FromEnv)
@@ -47,44 +51,57 @@ func NewDockerService() (*DockerService, error) {
 }
 
 // PullImage 拉取镜像
-func (ds *DockerService) PullImage(ctx context.Context, imageName string) error {
-	ds.logger.Infof("Docker: 开始拉取镜像 %s", imageName)
+func (ds *DockerService) PullImage(ctx context.Context, imageName string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.PullImage", attribute.String("image", imageName))
+	defer func() { tracing.End(span, err) }()
+
+	ds.logger.WithContext(ctx).Infof("Docker: 开始拉取镜像 %s", imageName)
 
 	out, err := ds.client.ImagePull(ctx, imageName, types.ImagePullOptions{})
 	if err != nil {
-		ds.logger.Errorf("Docker: 拉取镜像 %s 失败: %v", imageName, err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 拉取镜像 %s 失败: %v", imageName, err)
 		return fmt.Errorf("failed to pull image %s: %v", imageName, err)
 	}
 	defer out.Close()
 
-	// 读取输出但不显示详细信息（实际应用中可以解析JSON获取进度）
-	_, err = io.Copy(io.Discard, out)
+	// 解析输出，统计传输字节数并检查流中的错误
+	transferred, err := readProgressStream(out, "Downloading")
 	if err != nil {
-		ds.logger.Errorf("Docker: 读取拉取输出失败: %v", err)
-		return fmt.Errorf("failed to read pull output: %v", err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 拉取镜像 %s 失败: %v", imageName, err)
+		return fmt.Errorf("failed to pull image %s: %v", imageName, err)
 	}
+	registry, _, _, _ := utils.ParseImageName(imageName)
+	metrics.RegistryBytes.WithLabelValues(registry, "pull").Add(float64(transferred))
+	span.SetAttributes(attribute.Int64("bytes_transferred", transferred))
 
-	ds.logger.Infof("Docker: 成功拉取镜像 %s", imageName)
+	ds.logger.WithContext(ctx).Infof("Docker: 成功拉取镜像 %s", imageName)
 	return nil
 }
 
 // TagImage 给镜像打标签
-func (ds *DockerService) TagImage(ctx context.Context, sourceImage, targetImage string) error {
-	ds.logger.Infof("Docker: 开始标记镜像 %s -> %s", sourceImage, targetImage)
+func (ds *DockerService) TagImage(ctx context.Context, sourceImage, targetImage string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.TagImage",
+		attribute.String("source_image", sourceImage), attribute.String("target_image", targetImage))
+	defer func() { tracing.End(span, err) }()
 
-	err := ds.client.ImageTag(ctx, sourceImage, targetImage)
+	ds.logger.WithContext(ctx).Infof("Docker: 开始标记镜像 %s -> %s", sourceImage, targetImage)
+
+	err = ds.client.ImageTag(ctx, sourceImage, targetImage)
 	if err != nil {
-		ds.logger.Errorf("Docker: 标记镜像失败 %s -> %s: %v", sourceImage, targetImage, err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 标记镜像失败 %s -> %s: %v", sourceImage, targetImage, err)
 		return fmt.Errorf("failed to tag image %s to %s: %v", sourceImage, targetImage, err)
 	}
 
-	ds.logger.Infof("Docker: 成功标记镜像 %s -> %s", sourceImage, targetImage)
+	ds.logger.WithContext(ctx).Infof("Docker: 成功标记镜像 %s -> %s", sourceImage, targetImage)
 	return nil
 }
 
 // PushImage 推送镜像
-func (ds *DockerService) PushImage(ctx context.Context, imageName, username, password string) error {
-	ds.logger.Infof("Docker: 开始推送镜像 %s (用户: %s)", imageName, username)
+func (ds *DockerService) PushImage(ctx context.Context, imageName, username, password string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.PushImage", attribute.String("image", imageName))
+	defer func() { tracing.End(span, err) }()
+
+	ds.logger.WithContext(ctx).Infof("Docker: 开始推送镜像 %s (用户: %s)", imageName, username)
 
 	// 构建认证信息
 	authConfig := types.AuthConfig{
@@ -94,7 +111,7 @@ func (ds *DockerService) PushImage(ctx context.Context, imageName, username, pas
 
 	authJSON, err := json.Marshal(authConfig)
 	if err != nil {
-		ds.logger.Errorf("Docker: 序列化认证配置失败: %v", err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 序列化认证配置失败: %v", err)
 		return fmt.Errorf("failed to marshal auth config: %v", err)
 	}
 
@@ -104,36 +121,153 @@ func (ds *DockerService) PushImage(ctx context.Context, imageName, username, pas
 		RegistryAuth: authStr,
 	})
 	if err != nil {
-		ds.logger.Errorf("Docker: 推送镜像 %s 失败: %v", imageName, err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 推送镜像 %s 失败: %v", imageName, err)
 		return fmt.Errorf("failed to push image %s: %v", imageName, err)
 	}
 	defer out.Close()
 
-	// 读取推送输出
-	_, err = io.Copy(io.Discard, out)
+	// 解析推送输出，统计传输字节数并检查流中的错误
+	transferred, err := readProgressStream(out, "Pushing")
 	if err != nil {
-		ds.logger.Errorf("Docker: 读取推送输出失败: %v", err)
-		return fmt.Errorf("failed to read push output: %v", err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 推送镜像 %s 失败: %v", imageName, err)
+		return fmt.Errorf("failed to push image %s: %v", imageName, err)
 	}
+	registry, _, _, _ := utils.ParseImageName(imageName)
+	metrics.RegistryBytes.WithLabelValues(registry, "push").Add(float64(transferred))
+	span.SetAttributes(attribute.Int64("bytes_transferred", transferred))
 
-	ds.logger.Infof("Docker: 成功推送镜像 %s", imageName)
+	ds.logger.WithContext(ctx).Infof("Docker: 成功推送镜像 %s", imageName)
 	return nil
 }
 
+// progressMessage Docker拉取/推送输出流中的一条JSON消息
+type progressMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readProgressStream 读取输出流直到结束，返回各层传输的字节数之和
+//
+// status为"Downloading"或"Pushing"，每层取进度消息中最大的total，
+// 已存在的层不会产生进度消息，因此不计入。流中出现错误消息时返回该错误。
+func readProgressStream(r io.Reader, status string) (int64, error) {
+	layers := make(map[string]int64)
+	decoder := json.NewDecoder(r)
+
+	for {
+		var msg progressMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, fmt.Errorf("failed to read output: %v", err)
+		}
+
+		if msg.ErrorDetail.Message != "" {
+			return 0, fmt.Errorf("%s", msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return 0, fmt.Errorf("%s", msg.Error)
+		}
+
+		if msg.Status == status && msg.ProgressDetail.Total > layers[msg.ID] {
+			layers[msg.ID] = msg.ProgressDetail.Total
+		}
+	}
+
+	var total int64
+	for _, size := range layers {
+		total += size
+	}
+	return total, nil
+}
+
+// Ping 检查Docker守护进程是否可用，返回守护进程API版本
+func (ds *DockerService) Ping(ctx context.Context) (string, error) {
+	ping, err := ds.client.Ping(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ping.APIVersion, nil
+}
+
+// RootDir 返回Docker守护进程存储镜像的根目录（守护进程所在主机上的路径）
+func (ds *DockerService) RootDir(ctx context.Context) (string, error) {
+	info, err := ds.client.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
+
 // RemoveImage 删除本地镜像
-func (ds *DockerService) RemoveImage(ctx context.Context, imageName string) error {
-	ds.logger.Infof("Docker: 开始删除镜像 %s", imageName)
+func (ds *DockerService) RemoveImage(ctx context.Context, imageName string) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.RemoveImage", attribute.String("image", imageName))
+	defer func() { tracing.End(span, err) }()
+
+	ds.logger.WithContext(ctx).Infof("Docker: 开始删除镜像 %s", imageName)
 
-	_, err := ds.client.ImageRemove(ctx, imageName, types.ImageRemoveOptions{
+	_, err = ds.client.ImageRemove(ctx, imageName, types.ImageRemoveOptions{
 		Force:         true,
 		PruneChildren: true,
 	})
 	if err != nil {
-		ds.logger.Errorf("Docker: 删除镜像 %s 失败: %v", imageName, err)
+		ds.logger.WithContext(ctx).Errorf("Docker: 删除镜像 %s 失败: %v", imageName, err)
 		return fmt.Errorf("failed to remove image %s: %v", imageName, err)
 	}
 
-	ds.logger.Infof("Docker: 成功删除镜像 %s", imageName)
+	ds.logger.WithContext(ctx).Infof("Docker: 成功删除镜像 %s", imageName)
+	return nil
+}
+
+// SaveImages 以 docker save 格式导出本地镜像，调用方负责关闭返回的流
+func (ds *DockerService) SaveImages(ctx context.Context, images []string) (out io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.SaveImages", attribute.StringSlice("images", images))
+	defer func() { tracing.End(span, err) }()
+
+	ds.logger.WithContext(ctx).Infof("Docker: 开始导出镜像 %v", images)
+
+	out, err = ds.client.ImageSave(ctx, images)
+	if err != nil {
+		ds.logger.WithContext(ctx).Errorf("Docker: 导出镜像 %v 失败: %v", images, err)
+		return nil, fmt.Errorf("failed to save images %v: %v", images, err)
+	}
+	return out, nil
+}
+
+// LoadImages 以 docker load 导入镜像，input为 docker save 格式的tar流
+func (ds *DockerService) LoadImages(ctx context.Context, input io.Reader) (err error) {
+	ctx, span := tracing.Start(ctx, "DockerService.LoadImages")
+	defer func() { tracing.End(span, err) }()
+
+	ds.logger.WithContext(ctx).Info("Docker: 开始导入镜像")
+
+	resp, err := ds.client.ImageLoad(ctx, input, true)
+	if err != nil {
+		ds.logger.WithContext(ctx).Errorf("Docker: 导入镜像失败: %v", err)
+		return fmt.Errorf("failed to load images: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查输出流中的错误
+	if resp.JSON {
+		if _, err := readProgressStream(resp.Body, "Loading"); err != nil {
+			ds.logger.WithContext(ctx).Errorf("Docker: 导入镜像失败: %v", err)
+			return fmt.Errorf("failed to load images: %v", err)
+		}
+	} else if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return fmt.Errorf("failed to load images: %v", err)
+	}
+
+	ds.logger.WithContext(ctx).Info("Docker: 成功导入镜像")
 	return nil
 }
 
@@ -149,6 +283,43 @@ func (ds *DockerService) ImageExists(ctx context.Context, imageName string) (boo
 	return true, nil
 }
 
+// ImageInfo 返回本地镜像的ID与大小，镜像不存在时exists为false
+func (ds *DockerService) ImageInfo(ctx context.Context, imageName string) (id string, size int64, exists bool, err error) {
+	inspect, _, err := ds.client.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "", 0, false, nil
+		}
+		return "", 0, false, err
+	}
+	return inspect.ID, inspect.Size, true, nil
+}
+
+// ImagePlatform 返回本地镜像的操作系统、CPU架构与变体
+func (ds *DockerService) ImagePlatform(ctx context.Context, imageName string) (os, architecture, variant string, err error) {
+	inspect, _, err := ds.client.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return "", "", "", err
+	}
+	return inspect.Os, inspect.Architecture, inspect.Variant, nil
+}
+
+// ImageRepoDigest 返回本地镜像从仓库拉取时的清单摘要，repository为不带标签的镜像名（如 nginx、harbor.local/app/web）
+//
+// 多架构镜像为多架构清单的摘要。本地镜像没有该仓库的摘要记录时返回空字符串。
+func (ds *DockerService) ImageRepoDigest(ctx context.Context, imageName, repository string) (string, error) {
+	inspect, _, err := ds.client.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return "", err
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		if name, digest, found := strings.Cut(repoDigest, "@"); found && name == repository {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
 // Close 关闭Docker客户端
 func (ds *DockerService) Close() error {
 	if ds.client != nil {
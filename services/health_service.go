@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// healthCheckTimeout 单项检查的超时时间
+const healthCheckTimeout = 3 * time.Second
+
+// WorkerStatsProvider 提供任务执行状态
+type WorkerStatsProvider interface {
+	WorkerStats() models.WorkerStats
+}
+
+// HealthOptions 就绪检查配置
+type HealthOptions struct {
+	DiskPath     string        // 检查可用空间的路径，为空时使用Docker数据目录
+	MinFreeBytes uint64        // 最低可用空间，低于该值时检查失败
+	CacheTTL     time.Duration // 检查结果缓存时间
+}
+
+// HealthService 存活与就绪检查
+type HealthService struct {
+	store   storage.Store
+	docker  *DockerService
+	workers WorkerStatsProvider
+	opts    HealthOptions
+	started time.Time
+
+	mu         sync.Mutex // 串行化检查，缓存过期时并发请求只执行一次
+	cached     *models.HealthReport
+	dockerRoot string
+}
+
+// NewHealthService 创建健康检查服务
+func NewHealthService(store storage.Store, docker *DockerService, workers WorkerStatsProvider, opts HealthOptions) *HealthService {
+	return &HealthService{
+		store:   store,
+		docker:  docker,
+		workers: workers,
+		opts:    opts,
+		started: time.Now(),
+	}
+}
+
+// Uptime 服务已运行时长
+func (hs *HealthService) Uptime() time.Duration {
+	return time.Since(hs.started)
+}
+
+// Readiness 执行全部就绪检查，缓存未过期且refresh为false时返回缓存结果
+func (hs *HealthService) Readiness(ctx context.Context, refresh bool) *models.HealthReport {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if !refresh && hs.cached != nil && time.Since(hs.cached.CheckedAt) < hs.opts.CacheTTL {
+		report := *hs.cached
+		report.Cached = true
+		return &report
+	}
+
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context, result *models.HealthCheckResult) error
+	}{
+		{"database", hs.checkDatabase},
+		{"docker", hs.checkDocker},
+		{"disk", hs.checkDisk},
+		{"workers", hs.checkWorkers},
+	}
+
+	report := &models.HealthReport{
+		Status:    models.HealthStatusOK,
+		Checks:    make([]models.HealthCheckResult, len(checks)),
+		CheckedAt: time.Now(),
+	}
+
+	// 各项检查并行执行，互不影响
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(result *models.HealthCheckResult, fn func(context.Context, *models.HealthCheckResult) error) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result.Status = models.HealthStatusOK
+			if err := fn(checkCtx, result); err != nil {
+				result.Status = models.HealthStatusFail
+				result.Message = err.Error()
+			}
+			result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+		}(&report.Checks[i], check.fn)
+		report.Checks[i].Name = check.name
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == models.HealthStatusFail {
+			report.Status = models.HealthStatusFail
+			break
+		}
+	}
+
+	hs.cached = report
+	return report
+}
+
+// checkDatabase 连接检查与写入测试
+func (hs *HealthService) checkDatabase(ctx context.Context, result *models.HealthCheckResult) error {
+	start := time.Now()
+	if err := hs.store.Ping(ctx); err != nil {
+		return fmt.Errorf("数据库连接失败: %v", err)
+	}
+	pingLatency := time.Since(start)
+
+	start = time.Now()
+	if err := hs.store.CheckWrite(ctx); err != nil {
+		return fmt.Errorf("数据库写入失败: %v", err)
+	}
+
+	result.Details = map[string]interface{}{
+		"ping_ms":  float64(pingLatency.Microseconds()) / 1000,
+		"write_ms": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	return nil
+}
+
+// checkDocker Docker守护进程连接检查
+func (hs *HealthService) checkDocker(ctx context.Context, result *models.HealthCheckResult) error {
+	version, err := hs.docker.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("Docker守护进程不可用: %v", err)
+	}
+
+	result.Details = map[string]interface{}{"api_version": version}
+	return nil
+}
+
+// checkDisk 镜像存储目录的可用空间检查
+//
+// 未配置路径时使用Docker数据目录。该目录位于守护进程所在主机，
+// 在本机不可访问时（如远程守护进程或容器内运行）跳过检查。
+func (hs *HealthService) checkDisk(ctx context.Context, result *models.HealthCheckResult) error {
+	path := hs.opts.DiskPath
+	configured := path != ""
+
+	if !configured {
+		if hs.dockerRoot == "" {
+			root, err := hs.docker.RootDir(ctx)
+			if err != nil {
+				return fmt.Errorf("获取Docker数据目录失败: %v", err)
+			}
+			hs.dockerRoot = root
+		}
+		path = hs.dockerRoot
+	}
+
+	free, total, err := utils.DiskUsage(path)
+	if err != nil {
+		if configured {
+			return fmt.Errorf("读取磁盘空间失败: %v", err)
+		}
+		result.Status = models.HealthStatusSkipped
+		result.Message = fmt.Sprintf("Docker数据目录 %s 在本机不可访问，可通过 HEALTH_DISK_PATH 指定检查路径", path)
+		return nil
+	}
+
+	result.Details = map[string]interface{}{
+		"path":           path,
+		"free_bytes":     free,
+		"total_bytes":    total,
+		"min_free_bytes": hs.opts.MinFreeBytes,
+	}
+	if free < hs.opts.MinFreeBytes {
+		return fmt.Errorf("磁盘可用空间不足: %d MB（要求至少 %d MB）", free>>20, hs.opts.MinFreeBytes>>20)
+	}
+	return nil
+}
+
+// checkWorkers 任务执行状态检查
+func (hs *HealthService) checkWorkers(ctx context.Context, result *models.HealthCheckResult) error {
+	stats := hs.workers.WorkerStats()
+
+	result.Details = map[string]interface{}{
+		"running":   stats.Running,
+		"queued":    stats.Queued,
+		"accepting": stats.Accepting,
+		"phase":     stats.Phase,
+	}
+	if stats.DrainDeadline != nil {
+		result.Details["drain_deadline"] = stats.DrainDeadline
+	}
+	if !stats.Accepting {
+		return fmt.Errorf("任务服务已停止接收新任务（%s），剩余%d个执行中的任务", stats.Phase, stats.Running)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// historyDeleteBatchSize 归档删除时每批处理的记录数
+const historyDeleteBatchSize = 500
+
+// HistoryService 历史记录服务，负责保留策略的后台执行、归档、按条件删除与导出
+type HistoryService struct {
+	tasks  storage.TaskRepository
+	policy models.RetentionPolicy
+	logger *utils.Logger
+
+	mu        sync.Mutex // 串行化删除操作，避免后台清理与API删除相互干扰
+	lastPrune *models.PruneResult
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHistoryService 创建历史记录服务
+func NewHistoryService(tasks storage.TaskRepository, policy models.RetentionPolicy) *HistoryService {
+	if policy.Interval <= 0 {
+		policy.Interval = time.Hour
+	}
+
+	return &HistoryService{
+		tasks:  tasks,
+		policy: policy,
+		logger: utils.NewLogger("history"),
+	}
+}
+
+// Start 启动后台清理，未配置保留规则时不启动
+func (hs *HistoryService) Start() {
+	if !hs.policy.Enabled() {
+		hs.logger.Info("未配置历史记录保留策略，跳过后台清理")
+		return
+	}
+
+	hs.stop = make(chan struct{})
+	hs.done = make(chan struct{})
+
+	hs.logger.Infof("历史记录后台清理已启动: 保留天数=%d, 每种状态最多保留=%d, 间隔=%s, 归档目录=%s",
+		hs.policy.RetentionDays, hs.policy.MaxRowsPerStatus, hs.policy.Interval, hs.policy.ArchiveDir)
+
+	go func() {
+		defer close(hs.done)
+
+		ticker := time.NewTicker(hs.policy.Interval)
+		defer ticker.Stop()
+
+		for {
+			hs.Prune(context.Background())
+
+			select {
+			case <-ticker.C:
+			case <-hs.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理并等待当前清理结束
+func (hs *HistoryService) Stop() {
+	if hs.stop == nil {
+		return
+	}
+	close(hs.stop)
+	<-hs.done
+	hs.stop = nil
+}
+
+// Status 返回保留策略及最近一次清理结果
+func (hs *HistoryService) Status() *models.RetentionStatus {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	return &models.RetentionStatus{
+		RetentionPolicy: hs.policy,
+		Enabled:         hs.policy.Enabled(),
+		Interval:        hs.policy.Interval.String(),
+		LastPrune:       hs.lastPrune,
+	}
+}
+
+// Prune 按保留策略清理一次历史记录
+func (hs *HistoryService) Prune(ctx context.Context) *models.PruneResult {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	result := &models.PruneResult{StartedAt: time.Now()}
+	archive := hs.newArchive("retention")
+
+	err := func() error {
+		// 1. 删除超过保留天数的记录
+		if hs.policy.RetentionDays > 0 {
+			cutoff := time.Now().AddDate(0, 0, -hs.policy.RetentionDays)
+			deleted, err := hs.purge(ctx, &models.HistoryQuery{FinishedBefore: &cutoff}, archive)
+			result.Deleted += deleted
+			if err != nil {
+				return fmt.Errorf("清理过期记录失败: %v", err)
+			}
+		}
+
+		// 2. 每种状态只保留最新的 MaxRowsPerStatus 条
+		if hs.policy.MaxRowsPerStatus > 0 {
+			for _, status := range []string{models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled, models.TaskStatusInterrupted} {
+				q := &models.HistoryQuery{Statuses: []string{status}, Desc: true, Offset: hs.policy.MaxRowsPerStatus}
+				deleted, err := hs.purge(ctx, q, archive)
+				result.Deleted += deleted
+				if err != nil {
+					return fmt.Errorf("清理%s状态的超额记录失败: %v", status, err)
+				}
+			}
+		}
+
+		return nil
+	}()
+
+	if closeErr := archive.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	result.Archive = archive.Path()
+	result.FinishedAt = time.Now()
+
+	if err != nil {
+		result.Error = err.Error()
+		hs.logger.Errorf("历史记录清理失败（已删除 %d 条）: %v", result.Deleted, err)
+	} else if result.Deleted > 0 {
+		hs.logger.Infof("历史记录清理完成: 删除 %d 条, 归档=%s", result.Deleted, result.Archive)
+	}
+
+	hs.lastPrune = result
+	return result
+}
+
+// Delete 删除满足过滤条件的历史记录，启用归档时先写入归档文件
+func (hs *HistoryService) Delete(ctx context.Context, q *models.HistoryQuery, reason string) (*models.HistoryDeleteResult, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	// 删除不受分页参数影响
+	filter := *q
+	filter.Sort, filter.Desc, filter.After, filter.Limit, filter.Offset = "", false, nil, 0, 0
+
+	if hs.policy.ArchiveDir == "" {
+		deleted, err := hs.tasks.DeleteHistory(ctx, &filter)
+		if err != nil {
+			return nil, fmt.Errorf("删除历史记录失败: %v", err)
+		}
+		return &models.HistoryDeleteResult{Deleted: deleted}, nil
+	}
+
+	archive := hs.newArchive(reason)
+	deleted, err := hs.purge(ctx, &filter, archive)
+	if closeErr := archive.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	result := &models.HistoryDeleteResult{Deleted: deleted, Archive: archive.Path()}
+	if err != nil {
+		return result, fmt.Errorf("删除历史记录失败（已删除 %d 条）: %v", deleted, err)
+	}
+	return result, nil
+}
+
+// historyExportColumns 历史记录导出的列
+var historyExportColumns = []string{
+	"id", "source_image", "target_image", "target_host", "config_id", "created_by", "status",
+	"error_msg", "duration", "created_at", "started_at", "completed_at", "type",
+}
+
+// ExportHistory 逐行写出满足条件的历史记录，返回写出的行数
+func (hs *HistoryService) ExportHistory(ctx context.Context, q *models.HistoryQuery, w utils.TableWriter) (int, error) {
+	if err := w.StartTable("history", historyExportColumns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := hs.tasks.EachHistory(ctx, q, func(task *models.Task) error {
+		err := w.WriteRow(
+			task.ID, task.SourceImage, task.TargetImage, task.TargetHost, task.ConfigID, task.CreatedBy, task.Status,
+			task.ErrorMsg, task.Duration, task.CreatedAt, task.StartedAt, task.CompletedAt, task.Type,
+		)
+		if err != nil {
+			return fmt.Errorf("写出历史记录失败: %v", err)
+		}
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// ExportDetailedStats 写出按日期、仓库和失败原因的统计，每类统计为一张表
+func (hs *HistoryService) ExportDetailedStats(ctx context.Context, q *models.HistoryQuery, w utils.TableWriter) (int, error) {
+	count := 0
+
+	dateStats, err := hs.tasks.DateStats(ctx, q, 0)
+	if err != nil {
+		return count, fmt.Errorf("查询日期统计失败: %v", err)
+	}
+	if err := w.StartTable("date_stats", []string{"date", "total", "success", "failed", "avg_duration"}); err != nil {
+		return count, err
+	}
+	for _, stat := range dateStats {
+		if err := w.WriteRow(stat.Date, stat.Total, stat.Success, stat.Failed, stat.AvgDuration); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	registryStats, err := hs.tasks.RegistryStats(ctx, q, 0)
+	if err != nil {
+		return count, fmt.Errorf("查询仓库统计失败: %v", err)
+	}
+	if err := w.StartTable("registry_stats", []string{"registry", "total", "success", "failed"}); err != nil {
+		return count, err
+	}
+	for _, stat := range registryStats {
+		if err := w.WriteRow(stat.Registry, stat.Total, stat.Success, stat.Failed); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	failureStats, err := hs.tasks.FailureStats(ctx, q, 0)
+	if err != nil {
+		return count, fmt.Errorf("查询失败统计失败: %v", err)
+	}
+	if err := w.StartTable("failure_stats", []string{"error_msg", "count"}); err != nil {
+		return count, err
+	}
+	for _, stat := range failureStats {
+		if err := w.WriteRow(stat.ErrorMsg, stat.Count); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// purge 分批查询、归档并删除满足条件的记录，每批归档写入成功后才会删除
+func (hs *HistoryService) purge(ctx context.Context, q *models.HistoryQuery, archive *historyArchive) (int64, error) {
+	batch := *q
+	batch.Limit = historyDeleteBatchSize
+	batch.After = nil
+
+	var total int64
+	for {
+		tasks, err := hs.tasks.ListHistory(ctx, &batch)
+		if err != nil {
+			return total, err
+		}
+		if len(tasks) == 0 {
+			return total, nil
+		}
+
+		if err := archive.Write(tasks); err != nil {
+			return total, err
+		}
+
+		ids := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+
+		deleted, err := hs.tasks.DeleteTasks(ctx, ids)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted == 0 || len(tasks) < historyDeleteBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (hs *HistoryService) newArchive(reason string) *historyArchive {
+	return &historyArchive{dir: hs.policy.ArchiveDir, reason: reason}
+}
+
+// historyArchive gzip压缩的JSON Lines归档文件，首次写入时才创建
+type historyArchive struct {
+	dir    string
+	reason string
+
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+// Write 追加一批记录并刷新到磁盘，未配置归档目录时不做任何事
+func (a *historyArchive) Write(tasks []*models.Task) error {
+	if a.dir == "" {
+		return nil
+	}
+
+	if a.file == nil {
+		if err := os.MkdirAll(a.dir, 0755); err != nil {
+			return fmt.Errorf("创建归档目录失败: %v", err)
+		}
+
+		pattern := fmt.Sprintf("history-%s-%s-*.jsonl.gz", a.reason, time.Now().UTC().Format("20060102-150405"))
+		file, err := os.CreateTemp(a.dir, pattern)
+		if err != nil {
+			return fmt.Errorf("创建归档文件失败: %v", err)
+		}
+
+		a.file = file
+		a.gz = gzip.NewWriter(file)
+		a.enc = json.NewEncoder(a.gz)
+	}
+
+	for _, task := range tasks {
+		if err := a.enc.Encode(task); err != nil {
+			return fmt.Errorf("写入归档失败: %v", err)
+		}
+	}
+
+	// 确保本批记录已落盘后再删除数据库中的记录
+	if err := a.gz.Flush(); err != nil {
+		return fmt.Errorf("写入归档失败: %v", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return fmt.Errorf("写入归档失败: %v", err)
+	}
+
+	return nil
+}
+
+// Path 归档文件路径，未写入任何记录时为空
+func (a *historyArchive) Path() string {
+	if a.file == nil {
+		return ""
+	}
+	return a.file.Name()
+}
+
+// Close 完成gzip流并关闭文件
+func (a *historyArchive) Close() error {
+	if a.file == nil {
+		return nil
+	}
+
+	if err := a.gz.Close(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("关闭归档文件失败: %v", err)
+	}
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("关闭归档文件失败: %v", err)
+	}
+	return nil
+}
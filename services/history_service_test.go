@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/storage/sqlite"
+)
+
+// newHistoryStore 创建临时SQLite存储，按状态写入已结束的任务
+func newHistoryStore(t *testing.T, tasks map[string]string) storage.Store {
+	t.Helper()
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ctx := context.Background()
+	for id, status := range tasks {
+		if err := store.Tasks().Create(ctx, &models.Task{ID: id, SourceImage: "nginx:" + id, Status: models.TaskStatusPending}); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+		if err := store.Tasks().UpdateProgress(ctx, &models.TaskProgressUpdate{TaskID: id, Status: status}); err != nil {
+			t.Fatalf("UpdateProgress %s: %v", id, err)
+		}
+	}
+	return store
+}
+
+// remainingTasks 返回仍在数据库中的任务ID，按ID排序
+func remainingTasks(t *testing.T, store storage.Store) []string {
+	t.Helper()
+	tasks, err := store.Tasks().ListHistory(context.Background(), &models.HistoryQuery{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// archivedTasks 读取归档文件中已落盘的任务ID，按ID排序；文件可能仍在写入中
+func archivedTasks(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("打开归档文件失败: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("读取归档文件失败: %v", err)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var task models.Task
+		if err := json.Unmarshal(scanner.Bytes(), &task); err != nil {
+			t.Fatalf("解析归档记录失败: %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestHistoryPruneMaxRowsPerStatus(t *testing.T) {
+	store := newHistoryStore(t, map[string]string{
+		"c1": models.TaskStatusCompleted, "c2": models.TaskStatusCompleted,
+		"c3": models.TaskStatusCompleted, "c4": models.TaskStatusCompleted,
+		"f1": models.TaskStatusFailed, "f2": models.TaskStatusFailed,
+	})
+	dir := t.TempDir()
+	hs := NewHistoryService(store.Tasks(), models.RetentionPolicy{MaxRowsPerStatus: 2, ArchiveDir: dir})
+
+	// 创建时间相同时按ID排序，每种状态保留ID最大的2条
+	result := hs.Prune(context.Background())
+	if result.Error != "" || result.Deleted != 2 {
+		t.Fatalf("Prune() = %+v, want 删除2条", result)
+	}
+	if got := remainingTasks(t, store); len(got) != 4 || got[0] != "c3" || got[1] != "c4" || got[2] != "f1" || got[3] != "f2" {
+		t.Fatalf("保留的记录 = %v, want [c3 c4 f1 f2]", got)
+	}
+	if got := archivedTasks(t, result.Archive); len(got) != 2 || got[0] != "c1" || got[1] != "c2" {
+		t.Fatalf("归档的记录 = %v, want [c1 c2]", got)
+	}
+}
+
+// failingDeletes 删除前检查记录已归档，之后返回删除失败
+type failingDeletes struct {
+	storage.TaskRepository
+	t        *testing.T
+	dir      string
+	archived []string
+}
+
+func (f *failingDeletes) DeleteTasks(ctx context.Context, ids []string) (int64, error) {
+	files, _ := filepath.Glob(filepath.Join(f.dir, "*.jsonl.gz"))
+	if len(files) != 1 {
+		f.t.Fatalf("删除前的归档文件 = %v, want 1 个", files)
+	}
+	f.archived = archivedTasks(f.t, files[0])
+	return 0, errors.New("database is locked")
+}
+
+func TestHistoryPruneArchivesBeforeDelete(t *testing.T) {
+	store := newHistoryStore(t, map[string]string{
+		"c1": models.TaskStatusCompleted, "c2": models.TaskStatusCompleted, "c3": models.TaskStatusCompleted,
+	})
+	tasks := &failingDeletes{TaskRepository: store.Tasks(), t: t, dir: t.TempDir()}
+	hs := NewHistoryService(tasks, models.RetentionPolicy{MaxRowsPerStatus: 1, ArchiveDir: tasks.dir})
+
+	result := hs.Prune(context.Background())
+	if result.Error == "" || result.Deleted != 0 {
+		t.Fatalf("Prune() = %+v, want 删除失败", result)
+	}
+	if len(tasks.archived) != 2 || tasks.archived[0] != "c1" || tasks.archived[1] != "c2" {
+		t.Fatalf("删除前已归档 = %v, want [c1 c2]", tasks.archived)
+	}
+	// 删除失败时数据库中的记录不受影响，归档文件仍然保留
+	if got := remainingTasks(t, store); len(got) != 3 {
+		t.Fatalf("删除失败后的记录 = %v, want 全部保留", got)
+	}
+	if result.Archive == "" {
+		t.Fatal("删除失败后归档路径为空")
+	}
+}
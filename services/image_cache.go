@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/metrics"
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// ErrNotCached 镜像不在本地缓存中
+var ErrNotCached = errors.New("镜像不在缓存中")
+
+// imageCacheSweepInterval 后台按缓存策略清理的间隔，任务结束时也会触发一次清理
+const imageCacheSweepInterval = 10 * time.Minute
+
+// ImageCache 本地镜像缓存
+//
+// 启用后，任务拉取的源镜像在任务结束后保留在本地Docker中，后续任务拉取同一镜像时只下载变化的层。
+// 未被使用超过TTL的镜像被清理；缓存镜像大小之和超过磁盘预算时，先清理最久未使用的镜像。
+// 执行中任务正在使用的镜像不会被清理。
+//
+// 多个副本共享数据库时，缓存记录按实例标识区分，各副本只查看与清理自己本地Docker中的镜像。
+type ImageCache struct {
+	entries    storage.ImageCacheRepository
+	docker     *DockerService
+	instanceID string // 本实例标识，与任务记录的所属实例相同
+	logger     *utils.Logger
+
+	mu    sync.Mutex     // 保护inUse；删除镜像时持有，避免任务在删除过程中开始使用该镜像
+	inUse map[string]int // 执行中任务正在使用的镜像及使用数
+
+	kick chan struct{} // 任务结束后触发一次清理
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewImageCache 创建本地镜像缓存
+func NewImageCache(entries storage.ImageCacheRepository, docker *DockerService) *ImageCache {
+	return &ImageCache{
+		entries:    entries,
+		docker:     docker,
+		instanceID: resolveInstanceID(),
+		logger:     utils.NewLogger("image-cache"),
+		inUse:      make(map[string]int),
+		kick:       make(chan struct{}, 1),
+	}
+}
+
+// Start 认领升级前未记录所属实例的缓存记录，启动后台清理，启动时先按当前策略清理一次
+func (c *ImageCache) Start() {
+	if count, err := c.entries.Claim(context.Background(), c.instanceID); err != nil {
+		c.logger.Errorf("认领镜像缓存记录失败: %v", err)
+	} else if count > 0 {
+		c.logger.Infof("已认领%d条未记录所属实例的镜像缓存记录", count)
+	}
+
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(imageCacheSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			c.Evict(context.Background())
+
+			select {
+			case <-ticker.C:
+			case <-c.kick:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理并等待当前清理结束
+func (c *ImageCache) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+}
+
+// enabled 是否启用缓存，c为nil时视为未启用（如不连接数据库的同步命令）
+func (c *ImageCache) enabled() bool {
+	return c != nil && config.Current().ImageCache
+}
+
+// acquire 标记镜像正被使用，返回的函数解除标记并触发一次按策略清理
+func (c *ImageCache) acquire(image string) func() {
+	if c == nil {
+		return func() {}
+	}
+
+	c.mu.Lock()
+	c.inUse[image]++
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		if c.inUse[image]--; c.inUse[image] <= 0 {
+			delete(c.inUse, image)
+		}
+		c.mu.Unlock()
+
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// lookup 镜像是否已缓存且仍在本地，结果计入缓存命中指标
+func (c *ImageCache) lookup(ctx context.Context, image string) bool {
+	hit := false
+	if _, err := c.entries.Get(ctx, c.instanceID, image); err == nil {
+		_, _, exists, err := c.docker.ImageInfo(ctx, image)
+		hit = err == nil && exists
+	}
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	metrics.ImageCacheLookups.WithLabelValues(result).Inc()
+	return hit
+}
+
+// keep 将已拉取的镜像加入缓存或更新最近使用时间，hit表示本次复用了缓存
+func (c *ImageCache) keep(ctx context.Context, image string, hit bool) error {
+	id, size, exists, err := c.docker.ImageInfo(ctx, image)
+	if err != nil {
+		return fmt.Errorf("读取镜像信息失败: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("本地不存在镜像 %s", image)
+	}
+
+	entry := &models.CachedImage{Image: image, ImageID: id, Size: size, LastUsedAt: time.Now()}
+	if err := c.entries.Touch(ctx, c.instanceID, entry, hit); err != nil {
+		return fmt.Errorf("记录镜像缓存失败: %v", err)
+	}
+	return nil
+}
+
+// Status 返回缓存策略与当前缓存的镜像
+func (c *ImageCache) Status(ctx context.Context) (*models.ImageCacheStatus, error) {
+	entries, err := c.entries.List(ctx, c.instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("查询镜像缓存失败: %v", err)
+	}
+
+	cfg := config.Current()
+	status := &models.ImageCacheStatus{
+		Enabled: cfg.ImageCache,
+		TTL:     cfg.ImageCacheTTL.String(),
+		MaxSize: int64(cfg.ImageCacheMaxMB) << 20,
+		Count:   len(entries),
+		Images:  make([]*models.CachedImage, 0, len(entries)),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 按最近使用时间倒序返回
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		entry.InUse = c.inUse[entry.Image] > 0
+		status.TotalSize += entry.Size
+		status.Images = append(status.Images, entry)
+	}
+	return status, nil
+}
+
+// Evict 按缓存策略清理一次：未启用缓存时清理全部，否则清理超过TTL的镜像，再按最久未使用清理到磁盘预算以内
+func (c *ImageCache) Evict(ctx context.Context) *models.ImageCachePurgeResult {
+	result := &models.ImageCachePurgeResult{}
+
+	entries, err := c.entries.List(ctx, c.instanceID)
+	if err != nil {
+		c.logger.Errorf("查询镜像缓存失败: %v", err)
+		return result
+	}
+
+	cfg := config.Current()
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	maxSize := int64(cfg.ImageCacheMaxMB) << 20
+	cutoff := time.Now().Add(-cfg.ImageCacheTTL)
+
+	// entries按最近使用时间正序，最久未使用的在前
+	for _, entry := range entries {
+		expired := !cfg.ImageCache ||
+			(cfg.ImageCacheTTL > 0 && entry.LastUsedAt.Before(cutoff)) ||
+			(maxSize > 0 && total > maxSize)
+		if !expired {
+			continue
+		}
+		if c.remove(ctx, entry, result) {
+			total -= entry.Size
+		}
+	}
+
+	metrics.ImageCacheBytes.Set(float64(total))
+	if len(result.Removed) > 0 {
+		c.logger.Infof("镜像缓存清理完成: 删除 %d 个镜像，释放 %d 字节，剩余 %d 字节", len(result.Removed), result.Freed, total)
+	}
+	return result
+}
+
+// Purge 清理指定的缓存镜像，images为空时清理全部；正在使用的镜像跳过
+func (c *ImageCache) Purge(ctx context.Context, images []string) (*models.ImageCachePurgeResult, error) {
+	var entries []*models.CachedImage
+	if len(images) == 0 {
+		all, err := c.entries.List(ctx, c.instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("查询镜像缓存失败: %v", err)
+		}
+		entries = all
+	} else {
+		for _, image := range images {
+			entry, err := c.entries.Get(ctx, c.instanceID, utils.NormalizeImageName(image))
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, fmt.Errorf("%w: %s", ErrNotCached, image)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("查询镜像缓存失败: %v", err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	result := &models.ImageCachePurgeResult{Removed: []string{}}
+	for _, entry := range entries {
+		c.remove(ctx, entry, result)
+	}
+	c.logger.Infof("手动清理镜像缓存: 删除 %d 个镜像，跳过 %d 个，释放 %d 字节", len(result.Removed), len(result.Skipped), result.Freed)
+
+	// 更新缓存大小指标
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+	return result, nil
+}
+
+// remove 删除一个缓存镜像及其记录，镜像正被使用或删除失败时跳过，返回是否已删除
+func (c *ImageCache) remove(ctx context.Context, entry *models.CachedImage, result *models.ImageCachePurgeResult) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inUse[entry.Image] > 0 {
+		result.Skipped = append(result.Skipped, entry.Image)
+		return false
+	}
+
+	if err := c.docker.RemoveImage(ctx, entry.Image); err != nil {
+		// 镜像已在外部被删除时只删除记录
+		if _, _, exists, inspectErr := c.docker.ImageInfo(ctx, entry.Image); inspectErr != nil || exists {
+			c.logger.Errorf("删除缓存镜像失败: %v", err)
+			result.Skipped = append(result.Skipped, entry.Image)
+			return false
+		}
+	}
+	if err := c.entries.Delete(ctx, c.instanceID, entry.Image); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		c.logger.Errorf("删除镜像缓存记录失败: %v", err)
+	}
+
+	result.Removed = append(result.Removed, entry.Image)
+	result.Freed += entry.Size
+	return true
+}
@@ -2,14 +2,25 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"docker-helper/metrics"
+	"docker-helper/models"
 	"docker-helper/utils"
 )
 
 type ImageService struct {
 	dockerService *DockerService
+	cache         *ImageCache        // 本地镜像缓存，为nil时任务结束后总是删除拉取的镜像
+	mounter       *BlobMounter       // 推送前跨仓库挂载镜像层，为nil时不挂载
+	signatures    *SignatureVerifier // 推送前验证源镜像签名，为nil时不验证
+	referrers     *ReferrerCopier    // 推送后复制源镜像的附属制品，为nil时不复制
 	logger        *utils.Logger
 }
 
@@ -20,7 +31,7 @@ func NewImageService() (*ImageService, error) {
 	}
 
 	// 创建logger实例
-	logger := utils.NewLogger("info")
+	logger := utils.NewLogger("image")
 
 	return &ImageService{
 		dockerService: dockerService,
@@ -30,98 +41,480 @@ func NewImageService() (*ImageService, error) {
 
 // TransformImage 转换镜像：拉取 -> 标记 -> 推送 -> 清理
 func (is *ImageService) TransformImage(ctx context.Context, sourceImage, targetImage, username, password string) (string, int, error) {
-	return is.TransformImageWithProgress(ctx, sourceImage, targetImage, username, password, nil)
+	return is.TransformImageWithProgress(ctx, sourceImage, PushTarget{Image: targetImage, Username: username, Password: password}, nil, nil)
 }
 
-// TransformImageWithProgress 转换镜像并支持进度回调
-func (is *ImageService) TransformImageWithProgress(ctx context.Context, sourceImage, targetImage, username, password string, progressCallback func(step int, stepName string, progress int)) (string, int, error) {
+// TransformImageWithProgress 转换镜像并支持进度回调，mountCallback接收推送前跨仓库挂载节省的上传字节数
+func (is *ImageService) TransformImageWithProgress(ctx context.Context, sourceImage string, target PushTarget,
+	progressCallback func(step int, stepName string, progress int), mountCallback func(size int64)) (string, int, error) {
+	targetImage, username, password := target.Image, target.Username, target.Password
 	startTime := time.Now()
-	is.logger.Infof("开始镜像转换操作: %s -> %s", sourceImage, targetImage)
+	is.logger.WithContext(ctx).Infof("开始镜像转换操作: %s -> %s", sourceImage, targetImage)
 
 	// 1. 验证源镜像名称
-	is.logger.Infof("步骤1: 验证源镜像名称: %s", sourceImage)
+	is.logger.WithContext(ctx).Infof("步骤1: 验证源镜像名称: %s", sourceImage)
 	if err := utils.ValidateImageName(sourceImage); err != nil {
-		is.logger.Errorf("源镜像名称验证失败: %v", err)
+		is.logger.WithContext(ctx).Errorf("源镜像名称验证失败: %v", err)
 		return "", 0, fmt.Errorf("源镜像名称无效: %v", err)
 	}
 
 	// 2. 标准化源镜像名称
 	normalizedSource := utils.NormalizeImageName(sourceImage)
-	is.logger.Infof("步骤2: 标准化源镜像名称: %s -> %s", sourceImage, normalizedSource)
+	is.logger.WithContext(ctx).Infof("步骤2: 标准化源镜像名称: %s -> %s", sourceImage, normalizedSource)
 
 	// 3. 使用用户指定的目标镜像名称（不进行自动构建）
-	is.logger.Infof("步骤3: 使用目标镜像名称: %s", targetImage)
+	is.logger.WithContext(ctx).Infof("步骤3: 使用目标镜像名称: %s", targetImage)
 
-	// 4. 拉取源镜像
+	// 任务执行期间缓存清理不会删除源镜像
+	release := is.cache.acquire(normalizedSource)
+	defer release()
+	useCache := is.cache.enabled()
+
+	// 4. 拉取源镜像，命中缓存时仍向源仓库确认标签，只下载变化的层
 	if progressCallback != nil {
 		progressCallback(4, "拉取源镜像", 60)
 	}
-	is.logger.Infof("步骤4: 开始拉取源镜像: %s", normalizedSource)
+	is.logger.WithContext(ctx).Infof("步骤4: 开始拉取源镜像: %s", normalizedSource)
 	pullStartTime := time.Now()
+	cacheHit := useCache && is.cache.lookup(ctx, normalizedSource)
+	if cacheHit {
+		is.logger.WithContext(ctx).Infof("步骤4: 源镜像已在本地缓存中: %s", normalizedSource)
+	}
 	if err := is.dockerService.PullImage(ctx, normalizedSource); err != nil {
-		is.logger.Errorf("拉取镜像失败: %v", err)
+		is.logger.WithContext(ctx).Errorf("拉取镜像失败: %v", err)
 		return "", 0, fmt.Errorf("拉取镜像失败: %v", err)
 	}
 	pullDuration := time.Since(pullStartTime)
-	is.logger.Infof("步骤4: 拉取镜像完成，耗时: %v", pullDuration)
+	metrics.TaskStepDuration.WithLabelValues("pull").Observe(pullDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("步骤4: 拉取镜像完成，耗时: %v", pullDuration)
+	if useCache {
+		// 未能记录到缓存的镜像按原方式在结束后删除
+		if err := is.cache.keep(ctx, normalizedSource, cacheHit); err != nil {
+			is.logger.WithContext(ctx).Errorf("缓存源镜像失败: %v", err)
+			useCache = false
+		}
+	}
+
+	// 验证源镜像签名，目标仓库配置没有公钥时跳过
+	signature, err := is.signatures.verify(ctx, normalizedSource, target)
+	if err != nil {
+		is.logger.WithContext(ctx).Errorf("签名验证失败: %v", err)
+		if !useCache {
+			is.dockerService.RemoveImage(context.WithoutCancel(ctx), normalizedSource)
+		}
+		return "", 0, err
+	}
 
 	// 5. 标记镜像
 	if progressCallback != nil {
 		progressCallback(5, "标记镜像", 70)
 	}
-	is.logger.Infof("步骤5: 开始标记镜像: %s -> %s", normalizedSource, targetImage)
+	is.logger.WithContext(ctx).Infof("步骤5: 开始标记镜像: %s -> %s", normalizedSource, targetImage)
 	tagStartTime := time.Now()
 	if err := is.dockerService.TagImage(ctx, normalizedSource, targetImage); err != nil {
-		is.logger.Errorf("标记镜像失败: %v", err)
+		is.logger.WithContext(ctx).Errorf("标记镜像失败: %v", err)
 		return "", 0, fmt.Errorf("标记镜像失败: %v", err)
 	}
 	tagDuration := time.Since(tagStartTime)
-	is.logger.Infof("步骤5: 标记镜像完成，耗时: %v", tagDuration)
+	metrics.TaskStepDuration.WithLabelValues("tag").Observe(tagDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("步骤5: 标记镜像完成，耗时: %v", tagDuration)
 
 	// 6. 推送镜像
 	if progressCallback != nil {
 		progressCallback(6, "推送镜像", 95)
 	}
-	is.logger.Infof("步骤6: 开始推送镜像到目标仓库: %s (用户: %s)", targetImage, username)
+	blobs := is.mountBlobs(ctx, normalizedSource, targetImage, username, password, mountCallback)
+	is.logger.WithContext(ctx).Infof("步骤6: 开始推送镜像到目标仓库: %s (用户: %s)", targetImage, username)
 	pushStartTime := time.Now()
 	if err := is.dockerService.PushImage(ctx, targetImage, username, password); err != nil {
-		is.logger.Errorf("推送镜像失败: %v", err)
+		is.logger.WithContext(ctx).Errorf("推送镜像失败: %v", err)
 		// 清理已标记的镜像
-		is.logger.Infof("清理已标记的镜像: %s", targetImage)
+		is.logger.WithContext(ctx).Infof("清理已标记的镜像: %s", targetImage)
 		is.dockerService.RemoveImage(ctx, targetImage)
 		return "", 0, fmt.Errorf("推送镜像失败: %v", err)
 	}
 	pushDuration := time.Since(pushStartTime)
-	is.logger.Infof("步骤6: 推送镜像完成，耗时: %v", pushDuration)
+	metrics.TaskStepDuration.WithLabelValues("push").Observe(pushDuration.Seconds())
+	is.mounter.record(ctx, blobs)
+	is.logger.WithContext(ctx).Infof("步骤6: 推送镜像完成，耗时: %v", pushDuration)
+	if err := is.copyAttachments(ctx, normalizedSource, target, signature); err != nil {
+		is.dockerService.RemoveImage(ctx, targetImage)
+		return "", 0, err
+	}
 
 	// 7. 清理本地镜像（可选）
 	if progressCallback != nil {
 		progressCallback(7, "清理资源", 100)
 	}
-	is.logger.Infof("步骤7: 开始清理本地镜像")
+	is.logger.WithContext(ctx).Infof("步骤7: 开始清理本地镜像")
 	cleanupStartTime := time.Now()
-	if err := is.dockerService.RemoveImage(ctx, normalizedSource); err != nil {
-		is.logger.Errorf("清理源镜像失败: %v", err)
+	if useCache {
+		is.logger.WithContext(ctx).Infof("源镜像保留在本地缓存中: %s", normalizedSource)
+	} else if err := is.dockerService.RemoveImage(ctx, normalizedSource); err != nil {
+		is.logger.WithContext(ctx).Errorf("清理源镜像失败: %v", err)
 	} else {
-		is.logger.Infof("已清理源镜像: %s", normalizedSource)
+		is.logger.WithContext(ctx).Infof("已清理源镜像: %s", normalizedSource)
 	}
 
 	if err := is.dockerService.RemoveImage(ctx, targetImage); err != nil {
-		is.logger.Errorf("清理目标镜像失败: %v", err)
+		is.logger.WithContext(ctx).Errorf("清理目标镜像失败: %v", err)
 	} else {
-		is.logger.Infof("已清理目标镜像: %s", targetImage)
+		is.logger.WithContext(ctx).Infof("已清理目标镜像: %s", targetImage)
 	}
 	cleanupDuration := time.Since(cleanupStartTime)
-	is.logger.Infof("步骤7: 清理完成，耗时: %v", cleanupDuration)
+	is.logger.WithContext(ctx).Infof("步骤7: 清理完成，耗时: %v", cleanupDuration)
 
 	duration := int(time.Since(startTime).Seconds())
-	is.logger.Infof("镜像转换操作完成! 总耗时: %d秒，目标镜像: %s", duration, targetImage)
-	is.logger.Infof("性能统计 - 拉取: %v, 标记: %v, 推送: %v, 清理: %v",
+	is.logger.WithContext(ctx).Infof("镜像转换操作完成! 总耗时: %d秒，目标镜像: %s", duration, targetImage)
+	is.logger.WithContext(ctx).Infof("性能统计 - 拉取: %v, 标记: %v, 推送: %v, 清理: %v",
 		pullDuration, tagDuration, pushDuration, cleanupDuration)
 
 	return targetImage, duration, nil
 }
 
+// PushTarget 转换的目标镜像及其仓库凭据
+type PushTarget struct {
+	Image    string
+	Username string
+	Password string
+	ConfigID string // 目标仓库配置，配置了签名验证公钥时推送前验证源镜像签名；手动输入的仓库为空
+
+	ReferrerTypes []string // 推送后复制到目标的附属制品类型，为空时不复制
+}
+
+// TransformImageToTargets 拉取一次源镜像，标记并推送到多个目标镜像：拉取 -> 逐个目标标记、推送 -> 清理
+//
+// 最多concurrency个目标同时推送，单个目标失败不影响其他目标。每个目标开始与结束时调用report，
+// status为running/completed/failed/cancelled，duration为该目标的耗时（秒）。有目标失败时返回汇总错误。
+// mountCallback接收各目标推送前跨仓库挂载节省的上传字节数，可能被并发调用。
+func (is *ImageService) TransformImageToTargets(ctx context.Context, sourceImage string, targets []PushTarget, concurrency int,
+	progressCallback func(step int, stepName string, progress int), report func(i int, status string, duration int, err error),
+	mountCallback func(size int64)) error {
+	startTime := time.Now()
+	is.logger.WithContext(ctx).Infof("开始多目标镜像转换: %s -> %d 个目标", sourceImage, len(targets))
+
+	if err := utils.ValidateImageName(sourceImage); err != nil {
+		is.logger.WithContext(ctx).Errorf("源镜像名称验证失败: %v", err)
+		return fmt.Errorf("源镜像名称无效: %v", err)
+	}
+	normalizedSource := utils.NormalizeImageName(sourceImage)
+
+	// 任务执行期间缓存清理不会删除源镜像
+	release := is.cache.acquire(normalizedSource)
+	defer release()
+	useCache := is.cache.enabled()
+
+	// 1. 拉取源镜像，只拉取一次
+	if progressCallback != nil {
+		progressCallback(4, "拉取源镜像", 60)
+	}
+	is.logger.WithContext(ctx).Infof("开始拉取源镜像: %s", normalizedSource)
+	pullStartTime := time.Now()
+	cacheHit := useCache && is.cache.lookup(ctx, normalizedSource)
+	if err := is.dockerService.PullImage(ctx, normalizedSource); err != nil {
+		is.logger.WithContext(ctx).Errorf("拉取镜像失败: %v", err)
+		return fmt.Errorf("拉取镜像失败: %v", err)
+	}
+	metrics.TaskStepDuration.WithLabelValues("pull").Observe(time.Since(pullStartTime).Seconds())
+	is.logger.WithContext(ctx).Infof("拉取镜像完成，耗时: %v", time.Since(pullStartTime))
+	if useCache {
+		if err := is.cache.keep(ctx, normalizedSource, cacheHit); err != nil {
+			is.logger.WithContext(ctx).Errorf("缓存源镜像失败: %v", err)
+			useCache = false
+		}
+	}
+
+	// 2. 标记并推送到各目标，进度从60%到95%
+	var (
+		mu       sync.Mutex
+		done     int
+		failures []string
+		wg       sync.WaitGroup
+	)
+	slots := make(chan struct{}, max(concurrency, 1))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target PushTarget) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			targetStartTime := time.Now()
+			err := ctx.Err()
+			if err == nil {
+				report(i, models.TaskStatusRunning, 0, nil)
+				err = is.pushTarget(ctx, normalizedSource, target, mountCallback)
+			}
+
+			status := models.TaskStatusCompleted
+			switch {
+			case err != nil && errors.Is(ctx.Err(), context.Canceled):
+				// 任务被取消或中断，未完成的目标不计为推送失败
+				status = models.TaskStatusCancelled
+			case err != nil:
+				status = models.TaskStatusFailed
+			}
+			report(i, status, int(time.Since(targetStartTime).Seconds()), err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", target.Image, err))
+			}
+			if progressCallback != nil {
+				progressCallback(6, fmt.Sprintf("推送镜像 (%d/%d)", done, len(targets)), 60+35*done/len(targets))
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	// 3. 清理源镜像
+	if progressCallback != nil {
+		progressCallback(7, "清理资源", 100)
+	}
+	if useCache {
+		is.logger.WithContext(ctx).Infof("源镜像保留在本地缓存中: %s", normalizedSource)
+	} else if err := is.dockerService.RemoveImage(context.WithoutCancel(ctx), normalizedSource); err != nil {
+		is.logger.WithContext(ctx).Errorf("清理源镜像失败: %v", err)
+	}
+
+	is.logger.WithContext(ctx).Infof("多目标镜像转换结束! 总耗时: %v，成功 %d 个，失败 %d 个",
+		time.Since(startTime), len(targets)-len(failures), len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("%d/%d 个目标推送失败: %s", len(failures), len(targets), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// pushTarget 标记并推送到一个目标镜像，结束后删除本地的目标标签
+func (is *ImageService) pushTarget(ctx context.Context, source string, target PushTarget, mountCallback func(size int64)) error {
+	signature, err := is.signatures.verify(ctx, source, target)
+	if err != nil {
+		is.logger.WithContext(ctx).Errorf("签名验证失败: %v", err)
+		return err
+	}
+
+	tagStartTime := time.Now()
+	if err := is.dockerService.TagImage(ctx, source, target.Image); err != nil {
+		is.logger.WithContext(ctx).Errorf("标记镜像失败: %v", err)
+		return fmt.Errorf("标记镜像失败: %v", err)
+	}
+	tagDuration := time.Since(tagStartTime)
+	metrics.TaskStepDuration.WithLabelValues("tag").Observe(tagDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("标记镜像完成: %s，耗时: %v", target.Image, tagDuration)
+	defer func() {
+		if err := is.dockerService.RemoveImage(context.WithoutCancel(ctx), target.Image); err != nil {
+			is.logger.WithContext(ctx).Errorf("清理目标镜像失败: %v", err)
+		}
+	}()
+
+	blobs := is.mountBlobs(ctx, source, target.Image, target.Username, target.Password, mountCallback)
+	is.logger.WithContext(ctx).Infof("开始推送镜像到目标仓库: %s (用户: %s)", target.Image, target.Username)
+	pushStartTime := time.Now()
+	if err := is.dockerService.PushImage(ctx, target.Image, target.Username, target.Password); err != nil {
+		is.logger.WithContext(ctx).Errorf("推送镜像失败: %v", err)
+		return fmt.Errorf("推送镜像失败: %v", err)
+	}
+	pushDuration := time.Since(pushStartTime)
+	metrics.TaskStepDuration.WithLabelValues("push").Observe(pushDuration.Seconds())
+	is.mounter.record(ctx, blobs)
+	is.logger.WithContext(ctx).Infof("推送镜像完成: %s，耗时: %v", target.Image, pushDuration)
+	return is.copyAttachments(ctx, source, target, signature)
+}
+
+// copyAttachments 推送完成后复制已验证的签名与附属制品，分别记录 signature、referrers 步骤耗时
+func (is *ImageService) copyAttachments(ctx context.Context, source string, target PushTarget, signature *verifiedSignature) error {
+	if signature != nil {
+		startTime := time.Now()
+		err := is.signatures.copy(ctx, signature, target)
+		metrics.TaskStepDuration.WithLabelValues("signature").Observe(time.Since(startTime).Seconds())
+		if err != nil {
+			is.logger.WithContext(ctx).Errorf("复制签名失败: %v", err)
+			return err
+		}
+	}
+	if is.referrers != nil && len(target.ReferrerTypes) > 0 && !slices.Contains(target.ReferrerTypes, "none") {
+		startTime := time.Now()
+		is.referrers.copy(ctx, source, target)
+		metrics.TaskStepDuration.WithLabelValues("referrers").Observe(time.Since(startTime).Seconds())
+	}
+	return nil
+}
+
+// mountBlobs 推送前将目标仓库中已有的镜像层挂载到目标路径，返回推送成功后需记录的镜像层
+func (is *ImageService) mountBlobs(ctx context.Context, source, target, username, password string, mountCallback func(size int64)) []*models.BlobLocation {
+	blobs, saved := is.mounter.mount(ctx, source, target, username, password)
+	if saved > 0 && mountCallback != nil {
+		mountCallback(saved)
+	}
+	return blobs
+}
+
+// ExportImagesWithProgress 拉取一组镜像并以 docker save 格式交给write处理，结束后删除本次新拉取的镜像
+//
+// write读取导出流的同时生成离线包，返回错误时导出中止。启用本地镜像缓存时，拉取的镜像加入缓存而不删除。
+func (is *ImageService) ExportImagesWithProgress(ctx context.Context, images []string, write func(save io.Reader) error, progressCallback func(step int, stepName string, progress int)) error {
+	startTime := time.Now()
+	is.logger.WithContext(ctx).Infof("开始导出镜像: %v", images)
+
+	normalized := make([]string, 0, len(images))
+	for _, image := range images {
+		if err := utils.ValidateImageName(image); err != nil {
+			return fmt.Errorf("镜像名称 %s 无效: %v", image, err)
+		}
+		normalized = append(normalized, utils.NormalizeImageName(image))
+	}
+
+	// 只删除本次拉取的镜像，本地原有的镜像保留
+	var pulled []string
+	defer func() {
+		for _, image := range pulled {
+			if err := is.dockerService.RemoveImage(context.WithoutCancel(ctx), image); err != nil {
+				is.logger.WithContext(ctx).Errorf("清理镜像失败: %v", err)
+			}
+		}
+	}()
+
+	// 1. 拉取镜像，进度从5%到60%
+	useCache := is.cache.enabled()
+	pullStartTime := time.Now()
+	for i, image := range normalized {
+		if progressCallback != nil {
+			progressCallback(4, fmt.Sprintf("拉取镜像 (%d/%d)", i+1, len(normalized)), 5+55*i/len(normalized))
+		}
+		release := is.cache.acquire(image)
+		defer release()
+
+		cacheHit := useCache && is.cache.lookup(ctx, image)
+		exists, err := is.dockerService.ImageExists(ctx, image)
+		if err != nil {
+			return fmt.Errorf("检查本地镜像 %s 失败: %v", image, err)
+		}
+		if err := is.dockerService.PullImage(ctx, image); err != nil {
+			is.logger.WithContext(ctx).Errorf("拉取镜像失败: %v", err)
+			return fmt.Errorf("拉取镜像 %s 失败: %v", image, err)
+		}
+
+		// 加入缓存的镜像不在结束后删除，本地原有且未缓存的镜像保持不变
+		if useCache && (cacheHit || !exists) {
+			err := is.cache.keep(ctx, image, cacheHit)
+			if err == nil {
+				continue
+			}
+			is.logger.WithContext(ctx).Errorf("缓存镜像失败: %v", err)
+		}
+		if !exists {
+			pulled = append(pulled, image)
+		}
+	}
+	pullDuration := time.Since(pullStartTime)
+	metrics.TaskStepDuration.WithLabelValues("pull").Observe(pullDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("拉取镜像完成，耗时: %v", pullDuration)
+
+	// 2. 导出并写入离线包
+	if progressCallback != nil {
+		progressCallback(5, "导出镜像", 60)
+	}
+	saveStartTime := time.Now()
+	out, err := is.dockerService.SaveImages(ctx, normalized)
+	if err != nil {
+		return fmt.Errorf("导出镜像失败: %v", err)
+	}
+	defer out.Close()
+	if err := write(out); err != nil {
+		is.logger.WithContext(ctx).Errorf("生成离线包失败: %v", err)
+		return fmt.Errorf("生成离线包失败: %v", err)
+	}
+	saveDuration := time.Since(saveStartTime)
+	metrics.TaskStepDuration.WithLabelValues("save").Observe(saveDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("生成离线包完成，耗时: %v", saveDuration)
+
+	// 3. 清理本地镜像
+	if progressCallback != nil {
+		progressCallback(7, "清理资源", 95)
+	}
+
+	is.logger.WithContext(ctx).Infof("镜像导出完成! 总耗时: %v", time.Since(startTime))
+	return nil
+}
+
+// ImportImagesWithProgress 以 docker load 导入离线包，按jobs标记并推送到目标仓库，结束后删除本次导入的镜像
+func (is *ImageService) ImportImagesWithProgress(ctx context.Context, archive io.Reader, jobs []ImportJob, username, password string, progressCallback func(step int, stepName string, progress int)) error {
+	startTime := time.Now()
+	is.logger.WithContext(ctx).Infof("开始导入离线包，推送%d个镜像", len(jobs))
+
+	// 只删除本次导入的镜像，本地原有的镜像保留
+	var loaded []string
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		if seen[job.ID] {
+			continue
+		}
+		seen[job.ID] = true
+		exists, err := is.dockerService.ImageExists(ctx, job.ID)
+		if err != nil {
+			return fmt.Errorf("检查本地镜像 %s 失败: %v", job.ID, err)
+		}
+		if !exists {
+			loaded = append(loaded, job.ID)
+		}
+	}
+	// 同一镜像可能推送到多个目标，全部推送结束后再删除。删除导入的镜像会一并删除其标签，
+	// 本地原有的镜像只删除目标标签
+	var tagged []string
+	defer func() {
+		for _, image := range append(loaded, tagged...) {
+			if err := is.dockerService.RemoveImage(context.WithoutCancel(ctx), image); err != nil {
+				is.logger.WithContext(ctx).Errorf("清理镜像失败: %v", err)
+			}
+		}
+	}()
+
+	// 1. 导入镜像
+	if progressCallback != nil {
+		progressCallback(4, "导入离线包", 10)
+	}
+	loadStartTime := time.Now()
+	if err := is.dockerService.LoadImages(ctx, archive); err != nil {
+		is.logger.WithContext(ctx).Errorf("导入离线包失败: %v", err)
+		return fmt.Errorf("导入离线包失败: %v", err)
+	}
+	loadDuration := time.Since(loadStartTime)
+	metrics.TaskStepDuration.WithLabelValues("load").Observe(loadDuration.Seconds())
+	is.logger.WithContext(ctx).Infof("导入离线包完成，耗时: %v", loadDuration)
+
+	// 2. 逐个标记并推送，进度从40%到95%
+	for i, job := range jobs {
+		if progressCallback != nil {
+			progressCallback(6, fmt.Sprintf("推送镜像 (%d/%d)", i+1, len(jobs)), 40+55*i/len(jobs))
+		}
+
+		if err := is.dockerService.TagImage(ctx, job.ID, job.Target); err != nil {
+			return fmt.Errorf("标记镜像 %s 失败: %v", job.Source, err)
+		}
+		if !slices.Contains(loaded, job.ID) {
+			tagged = append(tagged, job.Target)
+		}
+
+		pushStartTime := time.Now()
+		if err := is.dockerService.PushImage(ctx, job.Target, username, password); err != nil {
+			is.logger.WithContext(ctx).Errorf("推送镜像失败: %v", err)
+			return fmt.Errorf("推送镜像 %s 失败: %v", job.Target, err)
+		}
+		metrics.TaskStepDuration.WithLabelValues("push").Observe(time.Since(pushStartTime).Seconds())
+		is.logger.WithContext(ctx).Infof("已推送: %s -> %s", job.Source, job.Target)
+	}
+
+	// 3. 清理本地镜像
+	if progressCallback != nil {
+		progressCallback(7, "清理资源", 95)
+	}
+
+	is.logger.WithContext(ctx).Infof("离线包导入完成! 总耗时: %v", time.Since(startTime))
+	return nil
+}
+
 // ParseImage 解析镜像信息
 func (is *ImageService) ParseImage(image string) (map[string]string, error) {
 	if err := utils.ValidateImageName(image); err != nil {
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/metrics"
+	"docker-helper/models"
+	"docker-helper/utils"
+)
+
+// ErrPolicyRejected 源镜像不符合源镜像策略，任务未创建
+var ErrPolicyRejected = errors.New("源镜像不符合策略")
+
+// maxImageConfigSize 检查构建时间时读取的镜像配置的最大大小
+const maxImageConfigSize = 4 << 20
+
+// policyLogger 源镜像策略日志，服务端任务与无服务端同步共用
+var policyLogger = utils.NewLogger("policy")
+
+// imageInspector 读取源镜像压缩后的大小，withCreated为true时同时读取构建时间
+type imageInspector func(ctx context.Context, withCreated bool) (int64, time.Time, error)
+
+// CheckSourcePolicy 按源镜像策略检查从仓库拉取的源镜像与推送目标，不符合时返回包含原因的ErrPolicyRejected
+//
+// 设置了大小或构建时间上限时读取源镜像清单，源仓库与某个目标在同一仓库时使用该目标的凭据。
+func CheckSourcePolicy(ctx context.Context, source string, targets []PushTarget) error {
+	return checkPolicy(ctx, source, targets, func(ctx context.Context, withCreated bool) (int64, time.Time, error) {
+		return inspectSourceImage(ctx, source, targets, withCreated)
+	})
+}
+
+// checkPolicy 按源镜像策略检查源镜像与推送目标，大小与构建时间由inspect读取
+//
+// source为空表示来源未知（如离线包中未命名的镜像），此时设置了允许列表即拒绝，不检查标签与摘要。
+func checkPolicy(ctx context.Context, source string, targets []PushTarget, inspect imageInspector) error {
+	cfg := config.Current()
+	label := source
+	if label == "" {
+		label = "未命名镜像"
+	}
+	reject := func(rule, format string, args ...interface{}) error {
+		metrics.PolicyRejections.WithLabelValues(rule).Inc()
+		reason := fmt.Sprintf(format, args...)
+		policyLogger.WithContext(ctx).Warnf("源镜像被策略拒绝: %s, 原因: %s", label, reason)
+		return fmt.Errorf("%w: %s", ErrPolicyRejected, reason)
+	}
+
+	if source == "" {
+		if len(cfg.PolicyAllowedSources) > 0 {
+			return reject("source_not_allowed", "镜像未记录名称，无法确认来源是否允许")
+		}
+	} else if err := checkSourceName(source, targets, cfg, reject); err != nil {
+		return err
+	}
+
+	if cfg.PolicyMaxImageSizeMB == 0 && cfg.PolicyMaxImageAge == 0 {
+		return nil
+	}
+
+	size, created, err := inspect(ctx, cfg.PolicyMaxImageAge > 0)
+	if err != nil {
+		return reject("inspect_failed", "无法读取源镜像以检查大小与构建时间: %v", err)
+	}
+	if limit := int64(cfg.PolicyMaxImageSizeMB) << 20; limit > 0 && size > limit {
+		return reject("image_size", "源镜像大小 %.1fMB 超过上限 %dMB", float64(size)/(1<<20), cfg.PolicyMaxImageSizeMB)
+	}
+	if cfg.PolicyMaxImageAge > 0 {
+		if created.IsZero() {
+			return reject("image_age", "源镜像未记录构建时间，无法检查是否超过 %v", cfg.PolicyMaxImageAge)
+		}
+		if age := time.Since(created); age > cfg.PolicyMaxImageAge {
+			return reject("image_age", "源镜像构建于 %s，已超过 %v", created.Format(time.RFC3339), cfg.PolicyMaxImageAge)
+		}
+	}
+	return nil
+}
+
+// checkImportPolicy 按源镜像策略检查离线包导入的镜像，target为目标仓库的凭据，镜像由各job的Target指定
+//
+// 来源与标签按包中记录的镜像名检查，大小按包中的配置与层计算，构建时间从包中的镜像配置读取。
+func (ts *TaskService) checkImportPolicy(ctx context.Context, upload *models.BundleUpload, jobs []ImportJob, target PushTarget) error {
+	var created map[string]time.Time
+	for _, job := range jobs {
+		image, _ := findBundleImage(upload.Images, job.Source)
+		inspect := func(ctx context.Context, withCreated bool) (int64, time.Time, error) {
+			if withCreated && created == nil {
+				var err error
+				if created, err = ts.bundles.ImageCreated(ctx, upload.ID); err != nil {
+					return 0, time.Time{}, err
+				}
+			}
+			return image.Size, created[image.ID], nil
+		}
+
+		target.Image = job.Target
+		if err := checkPolicy(ctx, image.Name, []PushTarget{target}, inspect); err != nil {
+			return fmt.Errorf("离线包中的镜像 %s: %w", job.Source, err)
+		}
+	}
+	return nil
+}
+
+// checkSourceName 按来源、标签与摘要规则检查源镜像名
+func checkSourceName(source string, targets []PushTarget, cfg *config.Config, reject func(rule, format string, args ...interface{}) error) error {
+	host, repository, tag := splitImageReference(source)
+	sourcePath := host + "/" + repository
+	name, digest := utils.SplitImageDigest(source)
+
+	for _, pattern := range cfg.PolicyDeniedSources {
+		if matchRepositoryPattern(pattern, sourcePath) {
+			return reject("denied_source", "源镜像 %s 属于禁止的来源 %s", sourcePath, pattern)
+		}
+	}
+	if len(cfg.PolicyAllowedSources) > 0 && !slices.ContainsFunc(cfg.PolicyAllowedSources, func(pattern string) bool {
+		return matchRepositoryPattern(pattern, sourcePath)
+	}) {
+		return reject("source_not_allowed", "源镜像 %s 不在允许的来源中", sourcePath)
+	}
+
+	if digest == "" {
+		if slices.Contains(cfg.PolicyBannedTags, tag) {
+			return reject("banned_tag", "源镜像使用了禁止的标签 %s，请使用其他标签或摘要", tag)
+		}
+		for _, target := range targets {
+			targetHost, targetRepository, _ := splitImageReference(target.Image)
+			for _, pattern := range cfg.PolicyDigestTargets {
+				if matchRepositoryPattern(pattern, targetHost+"/"+targetRepository) {
+					return reject("digest_required", "推送到 %s 时源镜像必须带摘要（如 %s@sha256:...）", target.Image, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// inspectSourceImage 读取源镜像压缩后的大小，withCreated为true时同时读取镜像配置中的构建时间
+//
+// 多架构镜像按 linux/amd64 子清单计算，没有该平台时使用第一个子清单。
+func inspectSourceImage(ctx context.Context, source string, targets []PushTarget, withCreated bool) (int64, time.Time, error) {
+	host, repository, reference := splitImageReference(source)
+	client := NewRegistryClient(host, "", "")
+	for _, target := range targets {
+		targetHost, _, _ := splitImageReference(target.Image)
+		if targetClient := NewRegistryClient(targetHost, target.Username, target.Password); targetClient.Host() == client.Host() {
+			client = targetClient
+			break
+		}
+	}
+
+	manifest, err := client.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	content, err := manifest.Content()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if manifest.IsIndex() {
+		if len(content.Manifests) == 0 {
+			return 0, time.Time{}, errors.New("多架构清单中没有子清单")
+		}
+		child := content.Manifests[0]
+		for _, candidate := range content.Manifests {
+			if candidate.Platform != nil && candidate.Platform.OS == "linux" && candidate.Platform.Architecture == "amd64" {
+				child = candidate
+				break
+			}
+		}
+		if manifest, err = client.GetManifest(ctx, repository, child.Digest); err != nil {
+			return 0, time.Time{}, err
+		}
+		if content, err = manifest.Content(); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+
+	size := content.Config.Size
+	for _, layer := range content.Layers {
+		size += layer.Size
+	}
+	if !withCreated || content.Config.Digest == "" {
+		return size, time.Time{}, nil
+	}
+
+	data, err := client.GetBlob(ctx, repository, content.Config.Digest, maxImageConfigSize)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var imageConfig struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.Unmarshal(data, &imageConfig); err != nil {
+		return 0, time.Time{}, fmt.Errorf("解析镜像配置失败: %v", err)
+	}
+	return size, imageConfig.Created, nil
+}
+
+// matchRepositoryPattern 仓库路径（仓库地址/仓库内路径）是否匹配模式
+//
+// 模式按 / 分段，与仓库路径的前若干段比较，每段可使用 path.Match 通配符：
+// docker.io/library 匹配 docker.io/library/nginx，*.example.com 匹配该域名下所有仓库的镜像。
+func matchRepositoryPattern(pattern, repositoryPath string) bool {
+	segments := strings.Split(repositoryPath, "/")
+	n := strings.Count(pattern, "/") + 1
+	if len(segments) < n {
+		return false
+	}
+	matched, _ := path.Match(pattern, strings.Join(segments[:n], "/"))
+	return matched
+}
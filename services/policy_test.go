@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+)
+
+// setConfig 通过环境变量设置配置，测试结束后恢复默认配置
+func setConfig(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Cleanup(func() {
+		if _, err := config.Init(nil); err != nil {
+			t.Errorf("恢复配置失败: %v", err)
+		}
+	})
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	if _, err := config.Init(nil); err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+}
+
+// fixedImage 返回固定大小与构建时间的inspect
+func fixedImage(size int64, created time.Time) imageInspector {
+	return func(ctx context.Context, withCreated bool) (int64, time.Time, error) {
+		return size, created, nil
+	}
+}
+
+func TestMatchRepositoryPattern(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"docker.io/library", "docker.io/library/nginx", true},
+		{"docker.io/library", "docker.io/bitnami/nginx", false},
+		{"*.example.com", "harbor.example.com/base/nginx", true},
+		{"*.example.com", "example.com/base/nginx", false},
+		{"ghcr.io/myorg/app", "ghcr.io/myorg", false},
+	}
+	for _, tt := range tests {
+		if got := matchRepositoryPattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchRepositoryPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	setConfig(t, map[string]string{
+		"POLICY_ALLOWED_SOURCES":   "docker.io/library,ghcr.io/myorg,localhost:5000/myorg",
+		"POLICY_DENIED_SOURCES":    "docker.io/library/busybox",
+		"POLICY_BANNED_TAGS":       "latest",
+		"POLICY_DIGEST_TARGETS":    "harbor.example.com/prod",
+		"POLICY_MAX_IMAGE_SIZE_MB": "100",
+		"POLICY_MAX_IMAGE_AGE":     "720h",
+	})
+	recent := fixedImage(10<<20, time.Now().Add(-time.Hour))
+	staging := []PushTarget{{Image: "harbor.example.com/staging/nginx:1.25"}}
+	prod := []PushTarget{{Image: "harbor.example.com/prod/nginx:1.25"}}
+
+	tests := []struct {
+		name    string
+		source  string
+		targets []PushTarget
+		inspect imageInspector
+		reason  string // 为空表示通过
+	}{
+		{"allowed", "nginx:1.25", staging, recent, ""},
+		{"denied", "busybox:1.36", staging, recent, "禁止的来源"},
+		{"not allowed", "quay.io/coreos/etcd:v3.5", staging, recent, "不在允许的来源中"},
+		{"banned tag", "nginx", staging, recent, "禁止的标签 latest"},
+		{"banned tag with port", "localhost:5000/myorg/app:latest", nil, recent, "禁止的标签 latest"},
+		{"digest required", "nginx:1.25", prod, recent, "必须带摘要"},
+		{"digest", "nginx@sha256:" + strings.Repeat("a", 64), prod, recent, ""},
+		{"too large", "nginx:1.25", staging, fixedImage(200<<20, time.Now()), "超过上限 100MB"},
+		{"too old", "nginx:1.25", staging, fixedImage(1<<20, time.Now().Add(-1000*time.Hour)), "已超过"},
+		{"no created", "nginx:1.25", staging, fixedImage(1<<20, time.Time{}), "未记录构建时间"},
+		{"unnamed", "", staging, recent, "无法确认来源"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPolicy(context.Background(), tt.source, tt.targets, tt.inspect)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("checkPolicy() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPolicyRejected) || !strings.Contains(err.Error(), tt.reason) {
+				t.Fatalf("checkPolicy() = %v, want ErrPolicyRejected containing %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCheckImportPolicy(t *testing.T) {
+	setConfig(t, map[string]string{
+		"POLICY_DENIED_SOURCES":    "docker.io/library/busybox",
+		"POLICY_BANNED_TAGS":       "latest",
+		"POLICY_MAX_IMAGE_SIZE_MB": "100",
+	})
+	upload := &models.BundleUpload{
+		ID: "upload",
+		Images: []models.BundleImageInfo{
+			{Name: "nginx:1.25", ID: "sha256:" + strings.Repeat("1", 64), Size: 10 << 20},
+			{Name: "busybox:1.36", ID: "sha256:" + strings.Repeat("2", 64), Size: 1 << 20},
+			{Name: "redis:latest", ID: "sha256:" + strings.Repeat("3", 64), Size: 1 << 20},
+			{Name: "postgres:16", ID: "sha256:" + strings.Repeat("4", 64), Size: 300 << 20},
+		},
+	}
+	ts := &TaskService{}
+	target := PushTarget{Username: "admin", Password: "secret"}
+
+	tests := []struct {
+		source string
+		reason string
+	}{
+		{"nginx:1.25", ""},
+		{"sha256:" + strings.Repeat("1", 64), ""},
+		{"busybox:1.36", "禁止的来源"},
+		{"redis:latest", "禁止的标签"},
+		{"postgres:16", "超过上限"},
+	}
+	for _, tt := range tests {
+		image, _ := findBundleImage(upload.Images, tt.source)
+		jobs := []ImportJob{{Source: tt.source, ID: image.ID, Target: "harbor.example.com/base/app:1"}}
+		err := ts.checkImportPolicy(context.Background(), upload, jobs, target)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("checkImportPolicy(%s) = %v, want nil", tt.source, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrPolicyRejected) || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("checkImportPolicy(%s) = %v, want ErrPolicyRejected containing %q", tt.source, err, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tagPattern 镜像标签：字母数字或下划线开头，最长128个字符
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// CreatePromoteTask 创建镜像晋级任务：读取仓库中已有镜像的清单，以新标签写入同一仓库，不传输镜像层
+func (ts *TaskService) CreatePromoteTask(ctx context.Context, req *models.PromoteRequest, createdBy string) (resp *models.TaskCreateResponse, err error) {
+	if !ts.WorkerStats().Accepting {
+		return nil, ErrShuttingDown
+	}
+
+	registry, err := ts.defaultRegistryConfig(req.ConfigID)
+	if err != nil {
+		return nil, err
+	}
+	password, err := ts.crypto.DecryptPassword(registry.PasswordEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("解密密码失败: %v", err)
+	}
+
+	client := NewRegistryClient(registry.RegistryURL, registry.Username, password)
+	repository, reference, err := promoteSource(client.Host(), req.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]models.TaskTarget, 0, len(req.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range req.Tags {
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("标签 %q 无效", tag)
+		}
+		if tag == reference {
+			return nil, fmt.Errorf("标签 %q 与源镜像的标签相同", tag)
+		}
+		if seen[tag] {
+			return nil, fmt.Errorf("标签 %q 重复", tag)
+		}
+		seen[tag] = true
+		targets = append(targets, models.TaskTarget{
+			ConfigID:    registry.ID,
+			Name:        registry.Name,
+			TargetHost:  registry.RegistryURL,
+			TargetImage: registry.RegistryURL + "/" + repository + ":" + tag,
+			Status:      models.TaskStatusPending,
+		})
+	}
+
+	taskID := uuid.New().String()
+
+	ctx, span := tracing.Start(ctx, "TaskService.CreatePromoteTask",
+		attribute.String("task_id", taskID), attribute.String("repository", repository))
+	defer func() { tracing.End(span, err) }()
+
+	images := make([]string, 0, len(targets))
+	for _, target := range targets {
+		images = append(images, target.TargetImage)
+	}
+
+	source := registry.RegistryURL + "/" + repository + ":" + reference
+	if strings.HasPrefix(reference, "sha256:") {
+		source = registry.RegistryURL + "/" + repository + "@" + reference
+	}
+
+	pushTargets := make([]PushTarget, 0, len(images))
+	for _, image := range images {
+		pushTargets = append(pushTargets, PushTarget{Image: image, Username: registry.Username, Password: password, ConfigID: registry.ID})
+	}
+	if err := CheckSourcePolicy(ctx, source, pushTargets); err != nil {
+		return nil, err
+	}
+
+	stepMessage := models.TaskStepMessages[models.TaskStepInit]
+	err = ts.store.Tasks().Create(context.Background(), ts.leased(&models.Task{
+		ID:             taskID,
+		Type:           models.TaskTypePromote,
+		SourceImage:    source,
+		TargetImage:    strings.Join(images, ", "),
+		TargetHost:     registry.RegistryURL,
+		TargetUsername: registry.Username,
+		ConfigID:       &registry.ID,
+		Targets:        targets,
+		CreatedBy:      &createdBy,
+		Status:         models.TaskStatusPending,
+		CurrentStep:    models.TaskStepInit,
+		StepMessage:    &stepMessage,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+
+	err = ts.submit(ctx, taskID, func(ctx context.Context) {
+		ts.executePromote(ctx, taskID, client, repository, reference, targets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts.logger.WithContext(ctx).With("task_id", taskID).Infof("晋级任务创建成功: %s, 镜像: %s, 标签: %v", taskID, source, req.Tags)
+
+	return &models.TaskCreateResponse{
+		TaskID:  taskID,
+		Status:  models.TaskStatusPending,
+		Message: "晋级任务已创建，正在后台执行",
+	}, nil
+}
+
+// promoteSource 解析仓库中的镜像为仓库内路径与标签或摘要，镜像可带仓库地址，但必须与仓库配置一致
+func promoteSource(host, image string) (repository, reference string, err error) {
+	path := strings.TrimPrefix(image, host+"/")
+	if first, _, found := strings.Cut(path, "/"); found && path == image && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return "", "", fmt.Errorf("镜像 %s 不在仓库配置的仓库 %s 中", image, host)
+	}
+
+	_, repository, reference = splitImageReference(host + "/" + path)
+	if repository == "" || strings.HasPrefix(repository, "/") || strings.HasSuffix(repository, "/") {
+		return "", "", fmt.Errorf("镜像 %s 无效", image)
+	}
+	if !strings.HasPrefix(reference, "sha256:") && !tagPattern.MatchString(reference) {
+		return "", "", fmt.Errorf("镜像 %s 的标签无效", image)
+	}
+	return repository, reference, nil
+}
+
+// executePromote 执行镜像晋级任务：读取清单 -> 逐个写入新标签
+func (ts *TaskService) executePromote(ctx context.Context, taskID string, client *RegistryClient, repository, reference string, targets []models.TaskTarget) {
+	ctx, cancelTimeout := context.WithTimeout(ctx, config.Current().TaskTimeout)
+	defer cancelTimeout()
+
+	ctx, span := tracing.Start(ctx, "task.execute_promote",
+		attribute.String("task_id", taskID),
+		attribute.String("repository", repository),
+		attribute.Int("tags", len(targets)),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	logger := ts.logger.WithContext(ctx).With("task_id", taskID)
+
+	ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+		TaskID:      taskID,
+		Status:      models.TaskStatusRunning,
+		Progress:    10,
+		CurrentStep: 1,
+		StepMessage: "读取清单",
+	})
+	ts.updateStartTime(taskID)
+
+	startTime := time.Now()
+
+	var manifest *Manifest
+	manifest, err = client.GetManifest(ctx, repository, reference)
+	if err == nil {
+		logger.Infof("已读取清单: %s/%s:%s, 摘要: %s, 类型: %s", client.Host(), repository, reference, manifest.Digest, manifest.MediaType)
+		// 新标签与源镜像摘要相同，已有的签名随摘要保留，只需验证
+		if err = ts.imageService.signatures.verifyInRegistry(ctx, client, repository, manifest.Digest, targets[0].ConfigID); err != nil {
+			logger.Errorf("签名验证失败: %v", err)
+		}
+	}
+	if err == nil {
+		var failures []string
+		for i := range targets {
+			if ctx.Err() != nil {
+				targets[i].Status = models.TaskStatusCancelled
+				continue
+			}
+
+			tag := targets[i].TargetImage[strings.LastIndex(targets[i].TargetImage, ":")+1:]
+			ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+				TaskID:      taskID,
+				Status:      models.TaskStatusRunning,
+				Progress:    10 + 85*i/len(targets),
+				CurrentStep: 6,
+				StepMessage: fmt.Sprintf("写入标签 (%d/%d)", i+1, len(targets)),
+			})
+
+			tagStartTime := time.Now()
+			digest, putErr := client.PutManifest(ctx, repository, tag, manifest)
+			if putErr == nil && digest != manifest.Digest {
+				// 仓库转换了清单格式时新标签不再指向同一镜像
+				putErr = fmt.Errorf("写入后的摘要 %s 与源镜像 %s 不一致", digest, manifest.Digest)
+			}
+			targets[i].Duration = int(time.Since(tagStartTime).Seconds())
+			switch {
+			case putErr != nil && ctx.Err() != nil:
+				targets[i].Status = models.TaskStatusCancelled
+			case putErr != nil:
+				targets[i].Status = models.TaskStatusFailed
+				targets[i].ErrorMsg = putErr.Error()
+				failures = append(failures, fmt.Sprintf("%s: %v", tag, putErr))
+				logger.Errorf("写入标签失败: %s, 错误: %v", targets[i].TargetImage, putErr)
+			default:
+				targets[i].Status = models.TaskStatusCompleted
+				logger.Infof("已写入标签: %s -> %s", targets[i].TargetImage, digest)
+			}
+			if err := ts.store.Tasks().SetTargets(context.Background(), taskID, targets); err != nil {
+				logger.Errorf("更新任务目标状态失败: %s, 错误: %v", taskID, err)
+			}
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case len(failures) > 0:
+			err = fmt.Errorf("%d/%d 个标签写入失败: %s", len(failures), len(targets), strings.Join(failures, "; "))
+		}
+	} else {
+		for i := range targets {
+			targets[i].Status = models.TaskStatusFailed
+			if ctx.Err() != nil {
+				targets[i].Status = models.TaskStatusCancelled
+			}
+		}
+	}
+	if err := ts.store.Tasks().SetTargets(context.Background(), taskID, targets); err != nil {
+		logger.Errorf("更新任务目标状态失败: %s, 错误: %v", taskID, err)
+	}
+
+	ts.finishTask(ctx, taskID, err, startTime, "晋级失败", "晋级完成")
+}
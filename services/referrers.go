@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"docker-helper/config"
+	"docker-helper/metrics"
+	"docker-helper/utils"
+)
+
+// 附属制品的类型，与 referrer_types 配置中的别名一致
+const (
+	referrerKindSignature   = "signature"
+	referrerKindSBOM        = "sbom"
+	referrerKindAttestation = "attestation"
+	referrerKindOther       = "other"
+)
+
+// referrerKinds 常见附属制品的artifactType所属的类型
+var referrerKinds = map[string]string{
+	"application/vnd.dev.cosign.artifact.sig.v1+json":  referrerKindSignature,
+	"application/vnd.dev.cosign.simplesigning.v1+json": referrerKindSignature,
+	"application/vnd.cncf.notary.signature":            referrerKindSignature,
+	"application/vnd.dev.cosign.artifact.sbom.v1+json": referrerKindSBOM,
+	"application/spdx+json":                            referrerKindSBOM,
+	"text/spdx":                                        referrerKindSBOM,
+	"application/vnd.cyclonedx+json":                   referrerKindSBOM,
+	"application/vnd.cyclonedx+xml":                    referrerKindSBOM,
+	"application/vnd.syft+json":                        referrerKindSBOM,
+	"application/vnd.dev.cosign.artifact.att.v1+json":  referrerKindAttestation,
+	"application/vnd.in-toto+json":                     referrerKindAttestation,
+	"application/vnd.dsse.envelope.v1+json":            referrerKindAttestation,
+}
+
+// cosignTagSuffixes cosign以 sha256-<摘要><后缀> 标签保存附属制品时的后缀与对应的artifactType
+var cosignTagSuffixes = []struct {
+	suffix       string
+	artifactType string
+}{
+	{".sig", "application/vnd.dev.cosign.artifact.sig.v1+json"},
+	{".att", "application/vnd.dev.cosign.artifact.att.v1+json"},
+	{".sbom", "application/vnd.dev.cosign.artifact.sbom.v1+json"},
+}
+
+// referrerKind 附属制品的类型，sigstore bundle 视为签名，无法识别的为 other
+func referrerKind(artifactType string) string {
+	if kind, ok := referrerKinds[artifactType]; ok {
+		return kind
+	}
+	if strings.HasPrefix(artifactType, "application/vnd.dev.sigstore.bundle") {
+		return referrerKindSignature
+	}
+	return referrerKindOther
+}
+
+// referrerSelected 附属制品是否属于选择的类型，types中每项为类型别名或完整的artifactType
+func referrerSelected(types []string, artifactType string) bool {
+	kind := referrerKind(artifactType)
+	return slices.ContainsFunc(types, func(t string) bool {
+		return t == kind || t == artifactType
+	})
+}
+
+// referrersTag 附属制品以标签方式保存时使用的标签，sha256:<摘要> 对应 sha256-<摘要>
+func referrersTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// resolveReferrerTypes 检查请求中的附属制品类型，未指定时使用 referrer_types 配置
+func resolveReferrerTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return config.Current().ReferrerTypes, nil
+	}
+	if err := config.ValidateReferrerTypes(types); err != nil {
+		return nil, fmt.Errorf("附属制品类型无效: %v", err)
+	}
+	return types, nil
+}
+
+// ReferrerCopier 推送后将源镜像的附属制品（签名、SBOM、证明等）复制到目标镜像旁
+//
+// 按源镜像拉取时的摘要在源仓库中查找指向它的附属制品：优先使用Referrers API，仓库不支持时读取
+// sha256-<摘要> 标签下的referrers索引；cosign以 sha256-<摘要>.sig/.att/.sbom 标签保存的附属制品总会检查。
+// 推送后目标镜像的摘要与源镜像不同时（如多架构源镜像只推送了一个平台），附属制品的subject改为指向目标摘要，
+// cosign标签改用目标摘要，同时复制源仓库中附加在目标摘要（对应平台的子清单）上的附属制品。
+// 目标仓库不支持Referrers API时同时更新目标的 sha256-<摘要> 索引。复制失败只记录日志，不影响任务。
+type ReferrerCopier struct {
+	docker *DockerService
+	logger *utils.Logger
+}
+
+// NewReferrerCopier 创建附属制品复制服务，docker用于读取源镜像拉取时的摘要，为nil时向源仓库查询
+func NewReferrerCopier(docker *DockerService) *ReferrerCopier {
+	return &ReferrerCopier{
+		docker: docker,
+		logger: utils.NewLogger("referrers"),
+	}
+}
+
+// referrerArtifact 源仓库中的一个附属制品，以摘要引用（referrers）或以标签保存（cosign）
+type referrerArtifact struct {
+	descriptor   Descriptor
+	subject      string    // 附属制品在源仓库中指向的镜像摘要
+	suffix       string    // 以cosign标签保存时的标签后缀（.sig/.att/.sbom），以摘要引用时为空
+	manifest     *Manifest // 查找时已读取的清单，为nil时复制前读取
+	artifactType string
+}
+
+// reference 附属制品在源仓库中的标签或摘要
+func (a *referrerArtifact) reference() string {
+	if a.suffix != "" {
+		return referrersTag(a.subject) + a.suffix
+	}
+	return a.descriptor.Digest
+}
+
+// copy 将源镜像的附属制品中属于target.ReferrerTypes的部分复制到目标镜像所在的仓库路径
+func (r *ReferrerCopier) copy(ctx context.Context, source string, target PushTarget) {
+	types := target.ReferrerTypes
+	if r == nil || len(types) == 0 || slices.Contains(types, "none") {
+		return
+	}
+	logger := r.logger.WithContext(ctx)
+
+	targetHost, targetRepository, targetReference := splitImageReference(target.Image)
+	dst := NewRegistryClient(targetHost, target.Username, target.Password)
+	manifest, err := dst.GetManifest(ctx, targetRepository, targetReference)
+	if err != nil {
+		logger.Errorf("读取目标镜像清单失败，附属制品未复制: %s, 错误: %v", target.Image, err)
+		return
+	}
+	subject := Descriptor{MediaType: manifest.MediaType, Digest: manifest.Digest, Size: int64(len(manifest.Body))}
+
+	// 同一仓库时源镜像可能需要目标仓库的凭据才能读取
+	sourceHost, sourceRepository, sourceReference := splitImageReference(source)
+	src := NewRegistryClient(sourceHost, "", "")
+	if src.Host() == dst.Host() {
+		src = dst
+	}
+
+	sourceDigest, err := r.sourceDigest(ctx, src, source, sourceRepository, sourceReference)
+	if err != nil {
+		logger.Warnf("无法确定源镜像 %s 的摘要，附属制品未复制: %v", source, err)
+		return
+	}
+
+	// 先查找附加在目标摘要上的附属制品，与源摘要上同名的cosign标签以前者为准
+	digests := []string{subject.Digest}
+	if sourceDigest != subject.Digest {
+		digests = append(digests, sourceDigest)
+	}
+	var artifacts []*referrerArtifact
+	for _, digest := range digests {
+		found, err := r.discover(ctx, src, sourceRepository, digest, types)
+		if err != nil {
+			logger.Warnf("查找附属制品失败，未复制: %s/%s@%s, 错误: %v", src.Host(), sourceRepository, digest, err)
+			return
+		}
+		for _, artifact := range found {
+			if !slices.ContainsFunc(artifacts, func(a *referrerArtifact) bool {
+				return (artifact.suffix == "" && a.descriptor.Digest == artifact.descriptor.Digest) ||
+					(artifact.suffix != "" && a.suffix == artifact.suffix)
+			}) {
+				artifacts = append(artifacts, artifact)
+			}
+		}
+	}
+
+	var copied int
+	var referrers []Descriptor
+	for _, artifact := range artifacts {
+		kind := referrerKind(artifact.artifactType)
+		descriptor, written, err := r.copyArtifact(ctx, src, sourceRepository, artifact, dst, targetRepository, subject)
+		switch {
+		case err != nil:
+			metrics.ReferrersCopied.WithLabelValues(kind, "failed").Inc()
+			logger.Errorf("复制附属制品失败: %s/%s@%s, 类型: %s, 错误: %v", src.Host(), sourceRepository, artifact.reference(), artifact.artifactType, err)
+			continue
+		case written:
+			metrics.ReferrersCopied.WithLabelValues(kind, "copied").Inc()
+			copied++
+		default:
+			metrics.ReferrersCopied.WithLabelValues(kind, "exists").Inc()
+		}
+		if artifact.suffix == "" {
+			referrers = append(referrers, descriptor)
+		}
+	}
+	if len(referrers) > 0 {
+		r.updateReferrersIndex(ctx, dst, targetRepository, subject.Digest, referrers)
+	}
+	if copied > 0 {
+		logger.Infof("已复制 %d 个附属制品到 %s@%s", copied, target.Image, subject.Digest)
+	}
+}
+
+// sourceDigest 源镜像在源仓库中的清单摘要：源镜像带摘要时直接使用，其次使用本地拉取时记录的摘要，都没有时向源仓库查询
+func (r *ReferrerCopier) sourceDigest(ctx context.Context, client *RegistryClient, source, repository, reference string) (string, error) {
+	if strings.HasPrefix(reference, "sha256:") {
+		return reference, nil
+	}
+	if r.docker != nil {
+		if digest, err := r.docker.ImageRepoDigest(ctx, source, imageRepositoryName(source)); err == nil && digest != "" {
+			return digest, nil
+		}
+	}
+	manifest, err := client.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest, nil
+}
+
+// imageRepositoryName 去掉镜像名中的标签与摘要，如 harbor.local/app/web:1.0 对应 harbor.local/app/web
+func imageRepositoryName(image string) string {
+	name, _ := utils.SplitImageDigest(image)
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
+
+// discover 在源仓库中查找指向digest且属于选择类型的附属制品
+func (r *ReferrerCopier) discover(ctx context.Context, client *RegistryClient, repository, digest string, types []string) ([]*referrerArtifact, error) {
+	descriptors, err := client.Referrers(ctx, repository, digest)
+	if errors.Is(err, ErrReferrersUnsupported) {
+		descriptors, err = r.fallbackReferrers(ctx, client, repository, digest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []*referrerArtifact
+	for _, descriptor := range descriptors {
+		artifact := &referrerArtifact{descriptor: descriptor, subject: digest, artifactType: descriptor.ArtifactType}
+		if artifact.artifactType == "" {
+			// 描述中没有artifactType时按清单中的artifactType或配置的媒体类型判断
+			if artifact.manifest, err = client.GetManifest(ctx, repository, descriptor.Digest); err != nil {
+				return nil, err
+			}
+			content, err := artifact.manifest.Content()
+			if err != nil {
+				return nil, err
+			}
+			artifact.artifactType = content.ArtifactType
+			if artifact.artifactType == "" {
+				artifact.artifactType = content.Config.MediaType
+			}
+		}
+		if referrerSelected(types, artifact.artifactType) {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+
+	for _, cosign := range cosignTagSuffixes {
+		if !referrerSelected(types, cosign.artifactType) {
+			continue
+		}
+		tag := referrersTag(digest) + cosign.suffix
+		manifest, err := client.GetManifest(ctx, repository, tag)
+		if errors.Is(err, ErrManifestNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, &referrerArtifact{subject: digest, suffix: cosign.suffix, manifest: manifest, artifactType: cosign.artifactType})
+	}
+	return artifacts, nil
+}
+
+// fallbackReferrers 读取仓库不支持Referrers API时客户端维护的 sha256-<摘要> 索引，不存在时返回空列表
+func (r *ReferrerCopier) fallbackReferrers(ctx context.Context, client *RegistryClient, repository, digest string) ([]Descriptor, error) {
+	index, err := client.GetManifest(ctx, repository, referrersTag(digest))
+	if errors.Is(err, ErrManifestNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !index.IsIndex() {
+		return nil, nil
+	}
+	content, err := index.Content()
+	if err != nil {
+		return nil, err
+	}
+	return content.Manifests, nil
+}
+
+// copyArtifact 复制一个附属制品，使其指向目标镜像subject，目标中已有相同清单时跳过
+//
+// 返回写入目标的清单描述与是否写入了目标。以摘要引用的附属制品原本指向其他摘要时改写清单的subject，
+// 清单摘要随之变化；cosign标签改用目标摘要，内容不变。
+func (r *ReferrerCopier) copyArtifact(ctx context.Context, src *RegistryClient, srcRepository string, artifact *referrerArtifact, dst *RegistryClient, dstRepository string, subject Descriptor) (Descriptor, bool, error) {
+	manifest := artifact.manifest
+	if manifest == nil {
+		var err error
+		if manifest, err = src.GetManifest(ctx, srcRepository, artifact.descriptor.Digest); err != nil {
+			return Descriptor{}, false, err
+		}
+	}
+
+	reference := referrersTag(subject.Digest) + artifact.suffix
+	if artifact.suffix == "" {
+		if artifact.subject != subject.Digest {
+			var err error
+			if manifest, err = withSubject(manifest, subject); err != nil {
+				return Descriptor{}, false, err
+			}
+		}
+		reference = manifest.Digest
+	}
+	descriptor := artifact.descriptor
+	descriptor.Digest = manifest.Digest
+	descriptor.Size = int64(len(manifest.Body))
+
+	if existing, err := dst.GetManifest(ctx, dstRepository, reference); err == nil && existing.Digest == manifest.Digest {
+		return descriptor, false, nil
+	}
+	if err := CopyManifest(ctx, src, srcRepository, manifest, dst, dstRepository, reference); err != nil {
+		return Descriptor{}, false, err
+	}
+	return descriptor, true, nil
+}
+
+// withSubject 返回subject改为指定镜像的清单副本，其余字段不变
+func withSubject(manifest *Manifest, subject Descriptor) (*Manifest, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(manifest.Body, &fields); err != nil {
+		return nil, fmt.Errorf("解析附属制品清单失败: %v", err)
+	}
+	encoded, err := json.Marshal(subject)
+	if err != nil {
+		return nil, err
+	}
+	fields["subject"] = encoded
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{MediaType: manifest.MediaType, Digest: digestOf(body), Body: body}, nil
+}
+
+// updateReferrersIndex 目标仓库不支持Referrers API时，将复制的附属制品加入目标的 sha256-<摘要> 索引，
+// 供按OCI规范回退到标签方式查找的客户端使用
+func (r *ReferrerCopier) updateReferrersIndex(ctx context.Context, client *RegistryClient, repository, digest string, referrers []Descriptor) {
+	logger := r.logger.WithContext(ctx)
+
+	if _, err := client.Referrers(ctx, repository, digest); !errors.Is(err, ErrReferrersUnsupported) {
+		if err != nil {
+			logger.Warnf("查询目标仓库的附属制品失败，未更新referrers索引: %v", err)
+		}
+		return
+	}
+
+	tag := referrersTag(digest)
+	var index struct {
+		SchemaVersion int          `json:"schemaVersion"`
+		MediaType     string       `json:"mediaType"`
+		Manifests     []Descriptor `json:"manifests"`
+	}
+	existing, err := client.GetManifest(ctx, repository, tag)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(existing.Body, &index); err != nil {
+			logger.Errorf("解析目标仓库的referrers索引失败: %s:%s, 错误: %v", repository, tag, err)
+			return
+		}
+	case !errors.Is(err, ErrManifestNotFound):
+		logger.Errorf("读取目标仓库的referrers索引失败: %s:%s, 错误: %v", repository, tag, err)
+		return
+	}
+
+	added := false
+	for _, referrer := range referrers {
+		if slices.ContainsFunc(index.Manifests, func(d Descriptor) bool { return d.Digest == referrer.Digest }) {
+			continue
+		}
+		index.Manifests = append(index.Manifests, referrer)
+		added = true
+	}
+	if !added {
+		return
+	}
+
+	index.SchemaVersion = 2
+	index.MediaType = MediaTypeOCIIndex
+	body, err := json.Marshal(index)
+	if err != nil {
+		logger.Errorf("生成referrers索引失败: %v", err)
+		return
+	}
+	if _, err := client.PutManifest(ctx, repository, tag, &Manifest{MediaType: MediaTypeOCIIndex, Body: body}); err != nil {
+		logger.Errorf("写入目标仓库的referrers索引失败: %s:%s, 错误: %v", repository, tag, err)
+		return
+	}
+	logger.Infof("已更新目标仓库的referrers索引: %s/%s:%s", client.Host(), repository, tag)
+}
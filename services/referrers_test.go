@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// referrersOf 目标仓库中指向digest的附属制品，不支持Referrers API时读取 sha256-<摘要> 索引
+func referrersOf(t *testing.T, registry *testRegistry, repository, digest string) []Descriptor {
+	t.Helper()
+	client := NewRegistryClient(registry.host(), "", "")
+	referrers, err := client.Referrers(context.Background(), repository, digest)
+	if err == ErrReferrersUnsupported {
+		body := registry.manifest(repository, referrersTag(digest))
+		if body == nil {
+			return nil
+		}
+		var index ManifestContent
+		if err := json.Unmarshal(body, &index); err != nil {
+			t.Fatal(err)
+		}
+		return index.Manifests
+	}
+	if err != nil {
+		t.Fatalf("Referrers: %v", err)
+	}
+	return referrers
+}
+
+// subjectOf 附属制品清单的subject摘要
+func subjectOf(t *testing.T, registry *testRegistry, repository, digest string) string {
+	t.Helper()
+	var content ManifestContent
+	if err := json.Unmarshal(registry.manifest(repository, digest), &content); err != nil {
+		t.Fatalf("附属制品 %s 不存在: %v", digest, err)
+	}
+	if content.Subject == nil {
+		return ""
+	}
+	return content.Subject.Digest
+}
+
+// seedMultiArch 在源仓库写入多架构镜像：索引引用一个平台子清单，目标仓库中写入该子清单（模拟从daemon推送后的结果）
+func seedMultiArch(src, dst *testRegistry) (index, child, pushed Descriptor) {
+	child = src.seedImage("library/app", "", "amd64")
+	child.Platform = &Platform{OS: "linux", Architecture: "amd64"}
+	index = src.putManifest("library/app", "1.0", map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     MediaTypeOCIIndex,
+		"manifests":     []Descriptor{child},
+	})
+	pushed = dst.seedImage("base/app", "1.0", "amd64")
+	return index, child, pushed
+}
+
+func TestReferrerCopierMultiArch(t *testing.T) {
+	for _, noReferrers := range []bool{false, true} {
+		name := "referrers api"
+		if noReferrers {
+			name = "tag fallback"
+		}
+		t.Run(name, func(t *testing.T) {
+			src, dst := newTestRegistry(t), newTestRegistry(t)
+			dst.noReferrers = noReferrers
+			index, child, pushed := seedMultiArch(src, dst)
+			// 目标中推送的子清单与源子清单内容相同
+			if pushed.Digest != child.Digest {
+				t.Fatalf("pushed = %s, want %s", pushed.Digest, child.Digest)
+			}
+
+			sbom := src.attach("library/app", index, "application/spdx+json", "sbom")
+			provenance := src.attach("library/app", child, "application/vnd.in-toto+json", "provenance")
+			signature := src.putManifest("library/app", referrersTag(index.Digest)+".sig", map[string]interface{}{
+				"schemaVersion": 2,
+				"mediaType":     MediaTypeOCIManifest,
+				"config":        src.putBlob("library/app", []byte("{}")),
+				"layers":        []Descriptor{src.putBlob("library/app", []byte("signature"))},
+			})
+
+			copier := NewReferrerCopier(nil)
+			source := src.host() + "/library/app@" + index.Digest
+			target := PushTarget{Image: dst.host() + "/base/app:1.0", ReferrerTypes: []string{"signature", "sbom", "attestation"}}
+			copier.copy(context.Background(), source, target)
+
+			referrers := referrersOf(t, dst, "base/app", pushed.Digest)
+			if len(referrers) != 2 {
+				t.Fatalf("目标中的附属制品 = %+v, want 2", referrers)
+			}
+			var sawSBOM, sawProvenance bool
+			for _, referrer := range referrers {
+				if subject := subjectOf(t, dst, "base/app", referrer.Digest); subject != pushed.Digest {
+					t.Errorf("附属制品 %s 的subject = %s, want %s", referrer.Digest, subject, pushed.Digest)
+				}
+				switch referrer.Digest {
+				case provenance.Digest:
+					sawProvenance = true
+				case sbom.Digest:
+					t.Errorf("SBOM 未改写subject: %s", referrer.Digest)
+				default:
+					sawSBOM = referrer.ArtifactType == "application/spdx+json"
+				}
+			}
+			if !sawSBOM || !sawProvenance {
+				t.Errorf("目标中的附属制品 = %+v, want 改写后的SBOM与原样复制的provenance", referrers)
+			}
+
+			copied := dst.manifest("base/app", referrersTag(pushed.Digest)+".sig")
+			if copied == nil || digestOf(copied) != signature.Digest {
+				t.Errorf("cosign签名未复制到 %s.sig", referrersTag(pushed.Digest))
+			}
+		})
+	}
+}
+
+func TestReferrerCopierSourceDigestFromRegistry(t *testing.T) {
+	src, dst := newTestRegistry(t), newTestRegistry(t)
+	index, _, pushed := seedMultiArch(src, dst)
+	src.attach("library/app", index, "application/spdx+json", "sbom")
+
+	// 没有本地摘要时按源镜像标签向源仓库查询索引摘要
+	copier := NewReferrerCopier(nil)
+	target := PushTarget{Image: dst.host() + "/base/app:1.0", ReferrerTypes: []string{"sbom"}}
+	copier.copy(context.Background(), src.host()+"/library/app:1.0", target)
+
+	if referrers := referrersOf(t, dst, "base/app", pushed.Digest); len(referrers) != 1 {
+		t.Fatalf("目标中的附属制品 = %+v, want 1", referrers)
+	}
+}
+
+func TestReferrerCopierTypes(t *testing.T) {
+	src, dst := newTestRegistry(t), newTestRegistry(t)
+	image := src.seedImage("library/app", "1.0", "amd64")
+	dst.seedImage("base/app", "1.0", "amd64")
+	src.attach("library/app", image, "application/spdx+json", "sbom")
+	src.attach("library/app", image, "application/vnd.in-toto+json", "provenance")
+
+	copier := NewReferrerCopier(nil)
+	target := PushTarget{Image: dst.host() + "/base/app:1.0", ReferrerTypes: []string{"sbom"}}
+	copier.copy(context.Background(), src.host()+"/library/app:1.0", target)
+
+	referrers := referrersOf(t, dst, "base/app", image.Digest)
+	if len(referrers) != 1 || referrers[0].ArtifactType != "application/spdx+json" {
+		t.Fatalf("目标中的附属制品 = %+v, want 只有SBOM", referrers)
+	}
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"docker-helper/config"
+	"docker-helper/tracing"
+	"docker-helper/utils"
+)
+
+// 清单媒体类型
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestAccept 读取清单时接受的媒体类型
+var manifestAccept = []string{MediaTypeOCIIndex, MediaTypeOCIManifest, MediaTypeDockerManifestList, MediaTypeDockerManifest}
+
+// ErrManifestNotFound 仓库中不存在指定的清单
+var ErrManifestNotFound = errors.New("清单不存在")
+
+// ErrReferrersUnsupported 仓库不支持Referrers API，需改用标签方式查找附属制品
+var ErrReferrersUnsupported = errors.New("仓库不支持Referrers API")
+
+// RegistryClient 镜像仓库HTTP API（OCI distribution）客户端
+//
+// 按仓库返回的认证要求使用Basic认证或向认证服务换取Bearer令牌，令牌按权限范围缓存。
+// 使用HTTPS，仓库在 insecure_registries 中时连接失败后改用HTTP，HTTP请求不发送认证信息。
+type RegistryClient struct {
+	host     string
+	username string
+	password string
+	client   *http.Client
+	logger   *utils.Logger
+
+	mu     sync.Mutex
+	scheme string            // 已探测到可用的协议
+	tokens map[string]string // 权限范围 -> 令牌
+	basic  bool              // 仓库要求Basic认证
+}
+
+// NewRegistryClient 创建仓库客户端，host为仓库地址（如 harbor.example.com、reg.local:5000）
+func NewRegistryClient(host, username, password string) *RegistryClient {
+	return &RegistryClient{
+		host:     registryHost(host),
+		username: username,
+		password: password,
+		client: &http.Client{
+			Transport: tracing.Transport(nil),
+			Timeout:   config.Current().RegistryTimeout,
+		},
+		logger: utils.NewLogger("registry-client"),
+		tokens: make(map[string]string),
+	}
+}
+
+// registryHost 标准化仓库地址：去掉协议与末尾斜杠，Docker Hub统一为 registry-1.docker.io
+func registryHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"), "/")
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	return host
+}
+
+// Host 仓库地址
+func (c *RegistryClient) Host() string {
+	return c.host
+}
+
+// Manifest 仓库中的一个清单
+type Manifest struct {
+	MediaType string
+	Digest    string
+	Body      []byte
+}
+
+// Descriptor 清单中对其他内容的引用
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+}
+
+// Platform 多架构清单中子清单的平台
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// ManifestContent 清单中与复制相关的字段，镜像清单使用Config与Layers，多架构清单使用Manifests
+type ManifestContent struct {
+	MediaType    string       `json:"mediaType"`
+	ArtifactType string       `json:"artifactType,omitempty"`
+	Config       Descriptor   `json:"config"`
+	Layers       []Descriptor `json:"layers"`
+	Manifests    []Descriptor `json:"manifests"`
+	Subject      *Descriptor  `json:"subject,omitempty"`
+}
+
+// IsIndex 是否为多架构清单
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeOCIIndex || m.MediaType == MediaTypeDockerManifestList
+}
+
+// Content 解析清单内容
+func (m *Manifest) Content() (*ManifestContent, error) {
+	var content ManifestContent
+	if err := json.Unmarshal(m.Body, &content); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %v", err)
+	}
+	return &content, nil
+}
+
+// GetManifest 读取清单原文，reference为标签或摘要
+func (c *RegistryClient) GetManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	header := http.Header{"Accept": {strings.Join(manifestAccept, ", ")}}
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/manifests/"+reference, header, nil, pullScope(repository))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s/%s:%s", ErrManifestNotFound, c.host, repository, reference)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("读取清单", resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %v", err)
+	}
+	manifest := &Manifest{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    resp.Header.Get("Docker-Content-Digest"),
+		Body:      body,
+	}
+	if manifest.Digest == "" {
+		manifest.Digest = digestOf(body)
+	}
+	if manifest.MediaType == "" {
+		// 未返回媒体类型时取清单中的mediaType字段
+		var probe struct {
+			MediaType string `json:"mediaType"`
+		}
+		json.Unmarshal(body, &probe)
+		manifest.MediaType = probe.MediaType
+	}
+	return manifest, nil
+}
+
+// PutManifest 以reference（标签或摘要）写入清单，返回仓库计算的摘要
+func (c *RegistryClient) PutManifest(ctx context.Context, repository, reference string, manifest *Manifest) (string, error) {
+	header := http.Header{"Content-Type": {manifest.MediaType}}
+	resp, err := c.do(ctx, http.MethodPut, "/v2/"+repository+"/manifests/"+reference, header, manifest.Body, pushScope(repository))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", responseError("写入清单", resp)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = digestOf(manifest.Body)
+	}
+	return digest, nil
+}
+
+// maxReferrerPages Referrers 跟随分页链接读取的最大页数
+const maxReferrerPages = 20
+
+// Referrers 通过Referrers API列出subject指向digest的附属制品清单，仓库不支持该API时返回ErrReferrersUnsupported
+func (c *RegistryClient) Referrers(ctx context.Context, repository, digest string) ([]Descriptor, error) {
+	header := http.Header{"Accept": {MediaTypeOCIIndex}}
+	var referrers []Descriptor
+	path := "/v2/" + repository + "/referrers/" + digest
+	for page := 0; path != "" && page < maxReferrerPages; page++ {
+		resp, err := c.do(ctx, http.MethodGet, path, header, nil, pullScope(repository))
+		if err != nil {
+			return nil, err
+		}
+		// 支持该API的仓库不会返回404，返回的也必须是OCI多架构清单
+		if resp.StatusCode == http.StatusNotFound || (resp.StatusCode == http.StatusOK &&
+			!strings.HasPrefix(resp.Header.Get("Content-Type"), MediaTypeOCIIndex)) {
+			resp.Body.Close()
+			return nil, ErrReferrersUnsupported
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := responseError("查询附属制品", resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var index ManifestContent
+		err = json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&index)
+		path = nextLink(resp.Header.Get("Link"))
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析附属制品列表失败: %v", err)
+		}
+		referrers = append(referrers, index.Manifests...)
+	}
+	return referrers, nil
+}
+
+// GetBlob 读取镜像层内容并校验摘要，用于读取镜像配置等小文件，超过maxSize字节时返回错误
+func (c *RegistryClient) GetBlob(ctx context.Context, repository, digest string, maxSize int64) ([]byte, error) {
+	body, _, err := c.OpenBlob(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取镜像层失败: %v", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("镜像层 %s 超过 %d 字节", digest, maxSize)
+	}
+	if digestOf(data) != digest {
+		return nil, fmt.Errorf("镜像层 %s 的内容与摘要不一致", digest)
+	}
+	return data, nil
+}
+
+// OpenBlob 打开镜像层内容流，返回内容与仓库声明的大小（未声明时为-1），调用方负责关闭；内容未校验摘要
+func (c *RegistryClient) OpenBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repository+"/blobs/"+digest, nil, nil, pullScope(repository))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, responseError("读取镜像层", resp)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// UploadBlob 以单次请求上传size字节的镜像层，内容以流的方式发送，由仓库按digest校验
+func (c *RegistryClient) UploadBlob(ctx context.Context, repository, digest string, size int64, data io.Reader) error {
+	resp, err := c.do(ctx, http.MethodPost, "/v2/"+repository+"/blobs/uploads/", nil, nil, pushScope(repository))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError("上传镜像层", resp)
+	}
+
+	location, err := uploadLocation(resp)
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	// 请求体只能发送一次，上传会话创建时已完成认证，不再按401重试
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.send(ctx, http.MethodPut, location.String(), header, io.LimitReader(data, size), size, pushScope(repository))
+	if err != nil {
+		c.cancelUpload(ctx, repository, location.String())
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError("上传镜像层", resp)
+	}
+	return nil
+}
+
+// uploadLocation 上传会话地址，相对地址按请求地址解析，仓库可能返回其他主机的绝对地址
+func uploadLocation(resp *http.Response) (*url.URL, error) {
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || (location.Host == "" && location.Path == "") {
+		return nil, fmt.Errorf("上传镜像层失败: 仓库未返回上传地址")
+	}
+	return resp.Request.URL.ResolveReference(location), nil
+}
+
+// BlobExists 仓库路径中是否已存在镜像层
+func (c *RegistryClient) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repository+"/blobs/"+digest, nil, nil, pullScope(repository))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, responseError("查询镜像层", resp)
+}
+
+// MountBlob 将同一仓库中from路径下的镜像层跨仓库挂载到repository，仓库不支持或from中不存在该层时返回false
+func (c *RegistryClient) MountBlob(ctx context.Context, repository, digest, from string) (bool, error) {
+	query := url.Values{"mount": {digest}, "from": {from}}
+	resp, err := c.do(ctx, http.MethodPost, "/v2/"+repository+"/blobs/uploads/?"+query.Encode(), nil, nil,
+		pushScope(repository), pullScope(from))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// 未能挂载时仓库开始了一次普通上传，取消该上传
+		if location, err := uploadLocation(resp); err == nil {
+			c.cancelUpload(ctx, repository, location.String())
+		}
+		return false, nil
+	}
+	return false, responseError("挂载镜像层", resp)
+}
+
+// cancelUpload 取消未完成的上传，location为上传会话的绝对地址，失败时仓库会在上传过期后自行清理
+func (c *RegistryClient) cancelUpload(ctx context.Context, repository, location string) {
+	resp, err := c.do(context.WithoutCancel(ctx), http.MethodDelete, location, nil, nil, pushScope(repository))
+	if err != nil {
+		c.logger.WithContext(ctx).Warnf("取消上传失败: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// CopyManifest 将清单及其配置与镜像层从src的srcRepository复制到dst的dstRepository，以reference（标签或摘要）写入
+//
+// 用于复制签名等附属制品，镜像层以流的方式从src读取并上传到dst，不在内存中缓存；
+// 目标中已存在的镜像层不再上传；两端为同一仓库时先尝试跨仓库挂载。
+func CopyManifest(ctx context.Context, src *RegistryClient, srcRepository string, manifest *Manifest, dst *RegistryClient, dstRepository, reference string) error {
+	content, err := manifest.Content()
+	if err != nil {
+		return err
+	}
+	if manifest.IsIndex() {
+		return fmt.Errorf("不支持复制多架构清单 %s", manifest.Digest)
+	}
+
+	blobs := content.Layers
+	if content.Config.Digest != "" {
+		blobs = append([]Descriptor{content.Config}, blobs...)
+	}
+	for _, blob := range blobs {
+		exists, err := dst.BlobExists(ctx, dstRepository, blob.Digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if src.Host() == dst.Host() {
+			if mounted, err := dst.MountBlob(ctx, dstRepository, blob.Digest, srcRepository); err == nil && mounted {
+				continue
+			}
+		}
+		if err := copyBlob(ctx, src, srcRepository, blob, dst, dstRepository); err != nil {
+			return err
+		}
+	}
+
+	digest, err := dst.PutManifest(ctx, dstRepository, reference, manifest)
+	if err != nil {
+		return err
+	}
+	if digest != manifest.Digest {
+		return fmt.Errorf("写入后的摘要 %s 与源清单 %s 不一致", digest, manifest.Digest)
+	}
+	return nil
+}
+
+// copyBlob 将一个镜像层从src以流的方式复制到dst，内容摘要由目标仓库校验
+func copyBlob(ctx context.Context, src *RegistryClient, srcRepository string, blob Descriptor, dst *RegistryClient, dstRepository string) error {
+	body, size, err := src.OpenBlob(ctx, srcRepository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if size >= 0 && size != blob.Size {
+		return fmt.Errorf("镜像层 %s 的大小 %d 与清单中的 %d 不一致", blob.Digest, size, blob.Size)
+	}
+	return dst.UploadBlob(ctx, dstRepository, blob.Digest, blob.Size, body)
+}
+
+// do 发送请求，按仓库的认证要求认证后重试一次；target为仓库内路径或上传会话等绝对地址
+func (c *RegistryClient) do(ctx context.Context, method, target string, header http.Header, body []byte, scopes ...string) (*http.Response, error) {
+	scope := strings.Join(scopes, " ")
+
+	resp, err := c.send(ctx, method, target, header, bytes.NewReader(body), int64(len(body)), scope)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if resp.Request.URL.Scheme != "https" {
+		return nil, fmt.Errorf("仓库 %s 通过HTTP访问时要求认证，认证信息不会以明文发送，请为仓库启用HTTPS", c.host)
+	}
+	if err := c.authenticate(ctx, challenge, scopes); err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, target, header, bytes.NewReader(body), int64(len(body)), scope)
+}
+
+// send 按已取得的认证信息发送一次请求，body为size字节的请求体
+//
+// target为绝对地址时直接使用，只有与仓库同一主机的HTTPS地址才携带认证信息。
+func (c *RegistryClient) send(ctx context.Context, method, target string, header http.Header, body io.Reader, size int64, scope string) (*http.Response, error) {
+	c.mu.Lock()
+	schemes := []string{"https"}
+	if c.insecure() {
+		schemes = append(schemes, "http")
+	}
+	if c.scheme != "" {
+		schemes = []string{c.scheme}
+	}
+	token, basic := c.tokens[scope], c.basic
+	c.mu.Unlock()
+
+	absolute := !strings.HasPrefix(target, "/")
+	if absolute {
+		schemes = schemes[:1]
+	}
+
+	var lastErr error
+	for _, scheme := range schemes {
+		address := scheme + "://" + c.host + target
+		if absolute {
+			address = target
+		}
+		if seeker, ok := body.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, address, body)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			req.Body, req.GetBody = http.NoBody, nil
+		}
+		req.ContentLength = size
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		switch {
+		case req.URL.Scheme != "https" || req.URL.Host != c.host:
+			// HTTP请求与其他主机的地址不发送认证信息
+		case token != "":
+			req.Header.Set("Authorization", "Bearer "+token)
+		case basic:
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+
+		if !absolute {
+			c.mu.Lock()
+			c.scheme = scheme
+			c.mu.Unlock()
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("连接仓库 %s 失败: %v", c.host, lastErr)
+}
+
+// insecure 仓库是否在 insecure_registries 中，不带端口的配置项匹配该地址的任意端口
+func (c *RegistryClient) insecure() bool {
+	hostname := c.host
+	if host, _, err := net.SplitHostPort(c.host); err == nil {
+		hostname = host
+	}
+	for _, registry := range config.Current().InsecureRegistries {
+		if registry == c.host || registry == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate 按 WWW-Authenticate 取得认证信息
+func (c *RegistryClient) authenticate(ctx context.Context, challenge string, scopes []string) error {
+	kind, params := parseChallenge(challenge)
+	switch kind {
+	case "basic":
+		if c.username == "" {
+			return fmt.Errorf("仓库 %s 需要认证", c.host)
+		}
+		c.mu.Lock()
+		c.basic = true
+		c.mu.Unlock()
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("仓库 %s 返回了不支持的认证方式: %q", c.host, challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	for _, scope := range scopes {
+		query.Add("scope", scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("认证服务地址无效: %v", err)
+	}
+	// 认证服务为HTTP地址时匿名获取令牌
+	if c.username != "" && req.URL.Scheme == "https" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求认证服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("获取仓库令牌", resp)
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析仓库令牌失败: %v", err)
+	}
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+	if result.Token == "" {
+		return fmt.Errorf("认证服务未返回令牌")
+	}
+
+	c.mu.Lock()
+	c.tokens[strings.Join(scopes, " ")] = result.Token
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallenge 解析 WWW-Authenticate，如 Bearer realm="https://auth/token",service="registry"
+func parseChallenge(challenge string) (string, map[string]string) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := make(map[string]string)
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			params[strings.ToLower(key)] = value
+		}
+	}
+	return strings.ToLower(kind), params
+}
+
+// pullScope 读取仓库的权限范围
+func pullScope(repository string) string {
+	return "repository:" + repository + ":pull"
+}
+
+// pushScope 写入仓库的权限范围
+func pushScope(repository string) string {
+	return "repository:" + repository + ":pull,push"
+}
+
+// responseError 将仓库的错误响应转换为错误，包含仓库返回的错误信息
+func responseError(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var result struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, strings.TrimSpace(e.Code+" "+e.Message))
+		}
+		return fmt.Errorf("%s失败，状态码: %d，%s", action, resp.StatusCode, strings.Join(messages, "; "))
+	}
+	return fmt.Errorf("%s失败，状态码: %d", action, resp.StatusCode)
+}
+
+// nextLink 解析分页响应的 Link 头，返回 rel="next" 的请求路径，没有下一页时返回空字符串
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, found := strings.Cut(strings.TrimSpace(link), ";")
+		if !found || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		parsed, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		return parsed.RequestURI()
+	}
+	return ""
+}
+
+// digestOf 计算内容的sha256摘要
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// splitImageReference 将镜像名称拆分为仓库地址、仓库内路径与标签或摘要（同时带标签与摘要时为摘要），Docker Hub的镜像补全为 library/ 路径
+func splitImageReference(image string) (host, repository, reference string) {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, reference = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if reference == "" {
+			reference = name[i+1:]
+		}
+		name = name[:i]
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+
+	host = "docker.io"
+	if first, rest, found := strings.Cut(name, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, name = first, rest
+	}
+	if host == "docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return host, name, reference
+}
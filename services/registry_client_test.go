@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// authRegistry 要求Basic认证的HTTP仓库，记录收到的Authorization头
+type authRegistry struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	requests       int
+	authorizations []string
+}
+
+func newAuthRegistry(t *testing.T) *authRegistry {
+	t.Helper()
+	r := &authRegistry{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			r.authorizations = append(r.authorizations, auth)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *authRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func TestRegistryClientHTTPFallback(t *testing.T) {
+	registry := newTestRegistry(t)
+	image := registry.seedImage("base/app", "1.0", "amd64")
+
+	// 不在 insecure_registries 中的仓库只使用HTTPS
+	setConfig(t, map[string]string{"INSECURE_REGISTRIES": "reg.local:5000"})
+	if _, err := NewRegistryClient(registry.host(), "", "").GetManifest(context.Background(), "base/app", "1.0"); err == nil {
+		t.Fatal("GetManifest() = nil, want HTTPS连接失败")
+	}
+	if requests := len(registry.requests); requests != 0 {
+		t.Fatalf("仓库收到 %d 个HTTP请求, want 0", requests)
+	}
+
+	// 配置项带端口时只匹配该端口，不带端口时匹配任意端口
+	for _, insecure := range []string{registry.host(), "127.0.0.1"} {
+		setConfig(t, map[string]string{"INSECURE_REGISTRIES": insecure})
+		manifest, err := NewRegistryClient(registry.host(), "", "").GetManifest(context.Background(), "base/app", "1.0")
+		if err != nil {
+			t.Fatalf("INSECURE_REGISTRIES=%s: GetManifest: %v", insecure, err)
+		}
+		if manifest.Digest != image.Digest {
+			t.Fatalf("digest = %s, want %s", manifest.Digest, image.Digest)
+		}
+	}
+}
+
+func TestRegistryClientNoCredentialsOverHTTP(t *testing.T) {
+	registry := newAuthRegistry(t)
+	setConfig(t, map[string]string{"INSECURE_REGISTRIES": registry.host()})
+
+	client := NewRegistryClient(registry.host(), "admin", "secret")
+	_, err := client.GetManifest(context.Background(), "base/app", "1.0")
+	if err == nil || !strings.Contains(err.Error(), "HTTPS") {
+		t.Fatalf("GetManifest() = %v, want 要求启用HTTPS的错误", err)
+	}
+	if len(registry.authorizations) != 0 {
+		t.Fatalf("HTTP请求发送了认证信息: %v", registry.authorizations)
+	}
+	if registry.requests != 1 {
+		t.Fatalf("仓库收到 %d 个请求, want 1", registry.requests)
+	}
+}
+
+func TestUploadBlobAbsoluteLocation(t *testing.T) {
+	registry, uploads := newTestRegistry(t), newTestRegistry(t)
+	registry.uploadBase = uploads.URL
+
+	data := []byte("signature payload")
+	client := NewRegistryClient(registry.host(), "", "")
+	if err := client.UploadBlob(context.Background(), "base/app", digestOf(data), int64(len(data)), bytes.NewReader(data)); err != nil {
+		t.Fatalf("UploadBlob: %v", err)
+	}
+	// 上传内容发送到Location中的主机，而不是仓库本身
+	if !uploads.hasBlob("base/app", digestOf(data)) {
+		t.Fatalf("上传服务未收到镜像层, 请求: %v", uploads.requests)
+	}
+	for _, request := range registry.requests {
+		if strings.HasPrefix(request, http.MethodPut) {
+			t.Errorf("仓库收到了上传内容: %s", request)
+		}
+	}
+}
+
+// zeroReader 无限的零字节流
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// streamRegistry 不保存内容的仓库：读取镜像层时生成size字节的零，上传时边接收边计算摘要
+func streamRegistry(t *testing.T, size int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/blobs/"):
+			w.Header().Set("Content-Length", fmt.Sprint(size))
+			io.CopyN(w, zeroReader{}, size)
+		case req.Method == http.MethodPost:
+			w.Header().Set("Location", "/upload/1")
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPut && req.URL.Path == "/upload/1":
+			hash := sha256.New()
+			n, _ := io.Copy(hash, req.Body)
+			if req.ContentLength != size || n != size || "sha256:"+hex.EncodeToString(hash.Sum(nil)) != req.URL.Query().Get("digest") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case req.Method == http.MethodPut:
+			body, _ := io.ReadAll(req.Body)
+			w.Header().Set("Docker-Content-Digest", digestOf(body))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCopyManifestStreamsLargeBlobs(t *testing.T) {
+	setConfig(t, map[string]string{"INSECURE_REGISTRIES": "127.0.0.1"})
+	const size = 80 << 20
+	hash := sha256.New()
+	io.CopyN(hash, zeroReader{}, size)
+	layer := Descriptor{MediaType: "application/octet-stream", Digest: "sha256:" + hex.EncodeToString(hash.Sum(nil)), Size: size}
+
+	body := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"layers":[{"mediaType":"application/octet-stream","digest":%q,"size":%d}]}`,
+		MediaTypeOCIManifest, layer.Digest, layer.Size))
+	manifest := &Manifest{MediaType: MediaTypeOCIManifest, Digest: digestOf(body), Body: body}
+
+	src := NewRegistryClient(strings.TrimPrefix(streamRegistry(t, size).URL, "http://"), "", "")
+	dst := NewRegistryClient(strings.TrimPrefix(streamRegistry(t, size).URL, "http://"), "", "")
+	if err := CopyManifest(context.Background(), src, "library/app", manifest, dst, "base/app", "sha256-abc.sbom"); err != nil {
+		t.Fatalf("CopyManifest: %v", err)
+	}
+}
@@ -9,7 +9,13 @@ import (
 	"strings"
 	"time"
 
+	"docker-helper/config"
+	"docker-helper/metrics"
+	"docker-helper/tracing"
 	"docker-helper/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // RegistryService 仓库服务
@@ -20,7 +26,7 @@ type RegistryService struct {
 
 // NewRegistryService 创建仓库服务实例
 func NewRegistryService() *RegistryService {
-	logger := utils.NewLogger("info")
+	logger := utils.NewLogger("registry")
 	crypto := utils.NewCryptoService()
 
 	return &RegistryService{
@@ -42,14 +48,19 @@ type TestResult struct {
 func (r *RegistryService) TestConnection(ctx context.Context, registryURL, username, password string) (*TestResult, error) {
 	startTime := time.Now()
 
-	r.logger.Infof("开始测试仓库连接: %s, 用户: %s", registryURL, username)
+	ctx, span := tracing.Start(ctx, "RegistryService.TestConnection", attribute.String("registry", registryURL))
+	defer span.End()
+
+	r.logger.WithContext(ctx).Infof("开始测试仓库连接: %s, 用户: %s", registryURL, username)
 
 	// 1. 标准化仓库URL
 	normalizedURL := r.normalizeRegistryURL(registryURL)
 
 	// 2. 测试基础连接
 	if err := r.testBasicConnection(ctx, normalizedURL); err != nil {
-		r.logger.Errorf("基础连接测试失败: %v", err)
+		r.logger.WithContext(ctx).Errorf("基础连接测试失败: %v", err)
+		metrics.RegistryTests.WithLabelValues(normalizedURL, "connection_failed").Inc()
+		span.SetStatus(codes.Error, "connection_failed")
 		return &TestResult{
 			Success:      false,
 			ResponseTime: time.Since(startTime).Milliseconds(),
@@ -59,7 +70,9 @@ func (r *RegistryService) TestConnection(ctx context.Context, registryURL, usern
 
 	// 3. 测试认证
 	if err := r.testAuthentication(ctx, normalizedURL, username, password); err != nil {
-		r.logger.Errorf("认证测试失败: %v", err)
+		r.logger.WithContext(ctx).Errorf("认证测试失败: %v", err)
+		metrics.RegistryTests.WithLabelValues(normalizedURL, "auth_failed").Inc()
+		span.SetStatus(codes.Error, "auth_failed")
 		return &TestResult{
 			Success:      false,
 			ResponseTime: time.Since(startTime).Milliseconds(),
@@ -75,7 +88,8 @@ func (r *RegistryService) TestConnection(ctx context.Context, registryURL, usern
 
 	responseTime := time.Since(startTime).Milliseconds()
 
-	r.logger.Infof("仓库连接测试完成: 成功=%t, 可推送=%t, 耗时=%dms", true, canPush, responseTime)
+	metrics.RegistryTests.WithLabelValues(normalizedURL, "success").Inc()
+	r.logger.WithContext(ctx).Infof("仓库连接测试完成: 成功=%t, 可推送=%t, 耗时=%dms", true, canPush, responseTime)
 
 	return &TestResult{
 		Success:      true,
@@ -106,7 +120,8 @@ func (r *RegistryService) testBasicConnection(ctx context.Context, registryURL s
 	}
 
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: tracing.Transport(nil),
+		Timeout:   config.Current().RegistryTimeout,
 	}
 
 	var lastErr error
@@ -144,7 +159,8 @@ func (r *RegistryService) testAuthentication(ctx context.Context, registryURL, u
 	}
 
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: tracing.Transport(nil),
+		Timeout:   config.Current().RegistryTimeout,
 	}
 
 	// 创建Basic Auth
@@ -193,7 +209,8 @@ func (r *RegistryService) testPushPermission(ctx context.Context, registryURL, u
 	}
 
 	client := &http.Client{
-		Timeout: 15 * time.Second,
+		Transport: tracing.Transport(nil),
+		Timeout:   config.Current().RegistryProbeTimeout,
 	}
 
 	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
@@ -255,7 +272,8 @@ func (r *RegistryService) isHarborRegistry(ctx context.Context, registryURL, use
 	}
 
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Transport: tracing.Transport(nil),
+		Timeout:   config.Current().RegistryProbeTimeout,
 	}
 
 	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
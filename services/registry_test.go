@@ -0,0 +1,263 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRegistry 测试用的内存镜像仓库，实现清单、镜像层上传与Referrers API
+type testRegistry struct {
+	*httptest.Server
+	noReferrers bool   // 不支持Referrers API，查询时返回404
+	uploadBase  string // 上传会话所在的其他服务地址（如 http://127.0.0.1:40001），为空时返回相对地址
+	failMounts  string // 从该仓库路径挂载时返回500
+
+	mu        sync.Mutex
+	manifests map[string]testManifest // 仓库路径@摘要 -> 清单
+	tags      map[string]string       // 仓库路径:标签 -> 摘要
+	blobs     map[string][]byte       // 仓库路径@摘要 -> 内容
+	uploads   map[string][]byte       // 上传ID -> 已接收的内容
+	uploadSeq int
+	requests  []string // 收到的请求，如 "PUT /v2/app/manifests/1.0"
+}
+
+// testManifest 测试仓库中的清单
+type testManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// newTestRegistry 启动测试仓库（HTTP，地址加入 insecure_registries），测试结束时关闭
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	setConfig(t, map[string]string{"INSECURE_REGISTRIES": "127.0.0.1"})
+	r := &testRegistry{
+		manifests: make(map[string]testManifest),
+		tags:      make(map[string]string),
+		blobs:     make(map[string][]byte),
+		uploads:   make(map[string][]byte),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// host 仓库地址，如 127.0.0.1:40000
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// putBlob 写入镜像层，返回摘要
+func (r *testRegistry) putBlob(repository string, data []byte) Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := digestOf(data)
+	r.blobs[repository+"@"+digest] = data
+	return Descriptor{MediaType: "application/octet-stream", Digest: digest, Size: int64(len(data))}
+}
+
+// putManifest 写入清单，tag为空时只能按摘要读取，返回清单的描述
+func (r *testRegistry) putManifest(repository, tag string, manifest interface{}) Descriptor {
+	body, _ := json.Marshal(manifest)
+	var probe struct {
+		MediaType string `json:"mediaType"`
+	}
+	json.Unmarshal(body, &probe)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := digestOf(body)
+	r.manifests[repository+"@"+digest] = testManifest{mediaType: probe.MediaType, body: body}
+	if tag != "" {
+		r.tags[repository+":"+tag] = digest
+	}
+	return Descriptor{MediaType: probe.MediaType, Digest: digest, Size: int64(len(body))}
+}
+
+// manifest 按标签或摘要读取清单，不存在时返回nil
+func (r *testRegistry) manifest(repository, reference string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if digest, ok := r.tags[repository+":"+reference]; ok {
+		reference = digest
+	}
+	return r.manifests[repository+"@"+reference].body
+}
+
+// hasBlob 仓库路径中是否有该镜像层
+func (r *testRegistry) hasBlob(repository, digest string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.blobs[repository+"@"+digest]
+	return ok
+}
+
+// seedImage 写入一个单层镜像，返回清单的描述
+func (r *testRegistry) seedImage(repository, tag, salt string) Descriptor {
+	config := r.putBlob(repository, []byte(`{"architecture":"amd64","os":"linux","salt":"`+salt+`"}`))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	layer := r.putBlob(repository, []byte("layer-"+salt))
+	layer.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	return r.putManifest(repository, tag, map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     MediaTypeOCIManifest,
+		"config":        config,
+		"layers":        []Descriptor{layer},
+	})
+}
+
+// attach 写入一个指向subject的附属制品，返回其清单的描述
+func (r *testRegistry) attach(repository string, subject Descriptor, artifactType, content string) Descriptor {
+	layer := r.putBlob(repository, []byte(content))
+	empty := r.putBlob(repository, []byte("{}"))
+	empty.MediaType = "application/vnd.oci.empty.v1+json"
+	return r.putManifest(repository, "", map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     MediaTypeOCIManifest,
+		"artifactType":  artifactType,
+		"config":        empty,
+		"layers":        []Descriptor{layer},
+		"subject":       subject,
+	})
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		return
+	}
+	for _, kind := range []string{"/manifests/", "/blobs/uploads/", "/blobs/", "/referrers/"} {
+		i := strings.LastIndex(path, kind)
+		if i < 0 {
+			continue
+		}
+		repository, reference := path[:i], path[i+len(kind):]
+		switch kind {
+		case "/manifests/":
+			r.serveManifest(w, req, repository, reference)
+		case "/blobs/uploads/":
+			r.serveUpload(w, req, repository, reference)
+		case "/blobs/":
+			data, ok := r.blobs[repository+"@"+reference]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			if req.Method == http.MethodGet {
+				w.Write(data)
+			}
+		case "/referrers/":
+			r.serveReferrers(w, repository, reference)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repository, reference string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		digest := reference
+		if tagged, ok := r.tags[repository+":"+reference]; ok {
+			digest = tagged
+		}
+		manifest, ok := r.manifests[repository+"@"+digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		if req.Method == http.MethodGet {
+			w.Write(manifest.body)
+		}
+	case http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		var content ManifestContent
+		json.Unmarshal(body, &content)
+		for _, blob := range append(content.Layers, content.Config) {
+			if _, ok := r.blobs[repository+"@"+blob.Digest]; blob.Digest != "" && !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		digest := digestOf(body)
+		r.manifests[repository+"@"+digest] = testManifest{mediaType: req.Header.Get("Content-Type"), body: body}
+		if !strings.HasPrefix(reference, "sha256:") {
+			r.tags[repository+":"+reference] = digest
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (r *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repository, id string) {
+	switch req.Method {
+	case http.MethodPost:
+		if mount := req.URL.Query().Get("mount"); mount != "" {
+			if from := req.URL.Query().Get("from"); from != "" && from == r.failMounts {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if data, ok := r.blobs[req.URL.Query().Get("from")+"@"+mount]; ok {
+				r.blobs[repository+"@"+mount] = data
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.uploadSeq++
+		id := fmt.Sprint(r.uploadSeq)
+		r.uploads[id] = nil
+		w.Header().Set("Location", r.uploadBase+"/v2/"+repository+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		data := append(r.uploads[id], body...)
+		digest := req.URL.Query().Get("digest")
+		if digestOf(data) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[repository+"@"+digest] = data
+		delete(r.uploads, id)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(r.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (r *testRegistry) serveReferrers(w http.ResponseWriter, repository, digest string) {
+	if r.noReferrers {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	referrers := []Descriptor{}
+	for key, manifest := range r.manifests {
+		name, manifestDigest, _ := strings.Cut(key, "@")
+		var content ManifestContent
+		json.Unmarshal(manifest.body, &content)
+		if name != repository || content.Subject == nil || content.Subject.Digest != digest {
+			continue
+		}
+		referrers = append(referrers, Descriptor{
+			MediaType:    manifest.mediaType,
+			Digest:       manifestDigest,
+			Size:         int64(len(manifest.body)),
+			ArtifactType: content.ArtifactType,
+		})
+	}
+	w.Header().Set("Content-Type", MediaTypeOCIIndex)
+	json.NewEncoder(w).Encode(map[string]interface{}{"schemaVersion": 2, "mediaType": MediaTypeOCIIndex, "manifests": referrers})
+}
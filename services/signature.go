@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"docker-helper/metrics"
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/utils"
+)
+
+// cosign签名清单中的注解与媒体类型
+const (
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignSimpleSigningType   = "application/vnd.dev.cosign.simplesigning.v1+json"
+)
+
+// maxSignaturePayloadSize 签名内容（simple signing payload）的最大大小
+const maxSignaturePayloadSize = 1 << 20
+
+// ParsePublicKey 解析PEM格式的签名验证公钥，返回算法名称与公钥DER编码的SHA-256指纹
+func ParsePublicKey(data string) (crypto.PublicKey, string, string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil {
+		return nil, "", "", errors.New("公钥不是PEM格式")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("解析公钥失败: %v", err)
+	}
+
+	var algorithm string
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			algorithm = "ecdsa-p256"
+		case elliptic.P384():
+			algorithm = "ecdsa-p384"
+		default:
+			return nil, "", "", errors.New("仅支持 P-256、P-384 曲线的ECDSA公钥")
+		}
+	case *rsa.PublicKey:
+		algorithm = "rsa"
+	case ed25519.PublicKey:
+		algorithm = "ed25519"
+	default:
+		return nil, "", "", fmt.Errorf("不支持的公钥类型 %T", key)
+	}
+
+	sum := sha256.Sum256(block.Bytes)
+	return key, algorithm, hex.EncodeToString(sum[:]), nil
+}
+
+// verifySignatureWithKey 使用公钥验证签名，ECDSA与RSA对内容的摘要签名，Ed25519对内容本身签名
+func verifySignatureWithKey(key crypto.PublicKey, payload, signature []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		// P-384公钥的签名通常对SHA-384签名，也接受SHA-256
+		if k.Curve == elliptic.P384() {
+			if sum := sha512.Sum384(payload); ecdsa.VerifyASN1(k, sum[:], signature) {
+				return true
+			}
+		}
+		sum := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(k, sum[:], signature)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(payload)
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature) == nil {
+			return true
+		}
+		return rsa.VerifyPSS(k, crypto.SHA256, sum[:], signature, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, signature)
+	}
+	return false
+}
+
+// SignatureVerifier 推送前验证源镜像的cosign签名，推送后将签名复制到目标镜像旁
+//
+// 签名以 sha256-<摘要>.sig 标签保存在源镜像所在的仓库路径中，每个签名层的内容为
+// simple signing payload，注解中为签名。目标仓库配置了公钥时，至少一个签名的payload
+// 指向拉取到的镜像摘要，且可被其中一个公钥验证，才允许推送。目标的公钥按仓库配置ID与
+// 仓库地址查找，手动输入同一仓库地址与凭据的目标同样需要验证。
+type SignatureVerifier struct {
+	configs storage.RegistryConfigRepository
+	keys    storage.SigningKeyRepository
+	docker  *DockerService
+	logger  *utils.Logger
+}
+
+// NewSignatureVerifier 创建签名验证服务，docker用于读取拉取到的镜像摘要
+func NewSignatureVerifier(configs storage.RegistryConfigRepository, keys storage.SigningKeyRepository, docker *DockerService) *SignatureVerifier {
+	return &SignatureVerifier{
+		configs: configs,
+		keys:    keys,
+		docker:  docker,
+		logger:  utils.NewLogger("signature"),
+	}
+}
+
+// verifiedSignature 已验证的源镜像签名，推送后复制到目标镜像旁
+type verifiedSignature struct {
+	client     *RegistryClient
+	repository string
+	digest     string // 已签名的镜像摘要
+	manifest   *Manifest
+}
+
+// ErrSignatureRequired 目标仓库配置了签名验证公钥，但源镜像的签名无法验证，任务未创建
+var ErrSignatureRequired = errors.New("目标仓库要求验证源镜像签名")
+
+// signingKeys 目标仓库的签名验证公钥：configID对应的配置以及仓库地址为host的所有配置的公钥，为空时不验证
+func (v *SignatureVerifier) signingKeys(ctx context.Context, configID, host string) ([]*models.SigningKey, error) {
+	if v == nil {
+		return nil, nil
+	}
+	configIDs := make([]string, 0, 1)
+	if configID != "" {
+		configIDs = append(configIDs, configID)
+	}
+	if host != "" && v.configs != nil {
+		configs, err := v.configs.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("查询仓库配置失败: %v", err)
+		}
+		for _, config := range configs {
+			if config.ID != configID && registryHost(config.RegistryURL) == registryHost(host) {
+				configIDs = append(configIDs, config.ID)
+			}
+		}
+	}
+
+	var keys []*models.SigningKey
+	for _, id := range configIDs {
+		configKeys, err := v.keys.List(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("查询签名验证公钥失败: %v", err)
+		}
+		keys = append(keys, configKeys...)
+	}
+	return keys, nil
+}
+
+// requireUnsigned 推送来源无法携带签名（如离线包）时检查目标仓库，配置了公钥的仓库拒绝推送
+func (v *SignatureVerifier) requireUnsigned(ctx context.Context, configID, host string) error {
+	keys, err := v.signingKeys(ctx, configID, host)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("%w: 离线包中的镜像没有可验证的签名", ErrSignatureRequired)
+	}
+	return nil
+}
+
+// verify 验证本地已拉取的源镜像的签名，目标仓库配置没有公钥时返回nil
+func (v *SignatureVerifier) verify(ctx context.Context, source string, target PushTarget) (*verifiedSignature, error) {
+	targetHost, _, _ := splitImageReference(target.Image)
+	keys, err := v.signingKeys(ctx, target.ConfigID, targetHost)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	host, repository, _ := splitImageReference(source)
+	name := imageRepositoryName(source)
+	digest, err := v.docker.ImageRepoDigest(ctx, source, name)
+	if err != nil || digest == "" {
+		metrics.SignatureVerifications.WithLabelValues("invalid").Inc()
+		return nil, fmt.Errorf("无法确定源镜像 %s 拉取到的摘要，不能验证签名: %v", source, err)
+	}
+
+	client := NewRegistryClient(host, "", "")
+	if targetClient := NewRegistryClient(targetHost, target.Username, target.Password); targetClient.Host() == client.Host() {
+		client = targetClient
+	}
+	return v.verifyDigest(ctx, client, repository, name, digest, keys)
+}
+
+// verifyInRegistry 验证仓库中已有镜像的签名（晋级时源与目标在同一仓库路径，签名随摘要保留，无需复制），
+// 目标仓库配置没有公钥时返回nil
+func (v *SignatureVerifier) verifyInRegistry(ctx context.Context, client *RegistryClient, repository, digest, configID string) error {
+	keys, err := v.signingKeys(ctx, configID, client.Host())
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	_, err = v.verifyDigest(ctx, client, repository, client.Host()+"/"+repository, digest, keys)
+	return err
+}
+
+// verifyDigest 读取 sha256-<摘要>.sig 签名清单并验证，记录验证结果指标
+func (v *SignatureVerifier) verifyDigest(ctx context.Context, client *RegistryClient, repository, name, digest string, keys []*models.SigningKey) (signature *verifiedSignature, err error) {
+	defer func() {
+		switch {
+		case err == nil:
+			metrics.SignatureVerifications.WithLabelValues("verified").Inc()
+		case errors.Is(err, ErrManifestNotFound):
+			metrics.SignatureVerifications.WithLabelValues("unsigned").Inc()
+		default:
+			metrics.SignatureVerifications.WithLabelValues("invalid").Inc()
+		}
+	}()
+
+	tag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	manifest, err := client.GetManifest(ctx, repository, tag)
+	if errors.Is(err, ErrManifestNotFound) {
+		return nil, fmt.Errorf("源镜像 %s@%s 没有签名（未找到 %s 标签）: %w", name, digest, tag, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取源镜像签名失败: %v", err)
+	}
+
+	reason, err := v.verifyManifest(ctx, client, repository, manifest, digest, keys)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, fmt.Errorf("源镜像 %s@%s 的签名验证失败: %s", name, digest, reason)
+	}
+
+	v.logger.WithContext(ctx).Infof("源镜像签名验证通过: %s@%s", name, digest)
+	return &verifiedSignature{client: client, repository: repository, digest: digest, manifest: manifest}, nil
+}
+
+// verifyManifest 逐个检查签名清单中的签名，有一个签名通过验证时返回空原因，否则返回最后一个签名未通过的原因
+func (v *SignatureVerifier) verifyManifest(ctx context.Context, client *RegistryClient, repository string, manifest *Manifest, digest string, keys []*models.SigningKey) (string, error) {
+	content, err := manifest.Content()
+	if err != nil {
+		return "", err
+	}
+
+	publicKeys := make([]crypto.PublicKey, 0, len(keys))
+	for _, key := range keys {
+		publicKey, _, _, err := ParsePublicKey(key.PublicKey)
+		if err != nil {
+			v.logger.WithContext(ctx).Errorf("签名验证公钥 %s 无效: %v", key.Name, err)
+			continue
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	reason := "签名清单中没有签名"
+	for _, layer := range content.Layers {
+		encoded := layer.Annotations[cosignSignatureAnnotation]
+		if layer.MediaType != cosignSimpleSigningType || encoded == "" {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			reason = "签名不是有效的base64编码"
+			continue
+		}
+		payload, err := client.GetBlob(ctx, repository, layer.Digest, maxSignaturePayloadSize)
+		if err != nil {
+			return "", fmt.Errorf("读取签名内容失败: %v", err)
+		}
+
+		var simpleSigning struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+			reason = "签名内容无法解析"
+			continue
+		}
+		if simpleSigning.Critical.Image.DockerManifestDigest != digest {
+			reason = fmt.Sprintf("签名针对的摘要 %s 与镜像摘要不一致", simpleSigning.Critical.Image.DockerManifestDigest)
+			continue
+		}
+
+		for _, publicKey := range publicKeys {
+			if verifySignatureWithKey(publicKey, payload, signature) {
+				return "", nil
+			}
+		}
+		reason = "签名无法用目标仓库配置的公钥验证"
+	}
+	return reason, nil
+}
+
+// copy 将已验证的签名以已签名的摘要复制到目标镜像所在的仓库路径。
+// 多架构镜像推送后目标摘要为平台子清单的摘要，与已签名的源摘要不同，签名仍按源摘要保存，
+// 可用 cosign verify <目标仓库>@<源摘要> 验证；复制失败时返回错误，由调用方使任务失败
+func (v *SignatureVerifier) copy(ctx context.Context, signature *verifiedSignature, target PushTarget) error {
+	if signature == nil {
+		return nil
+	}
+	logger := v.logger.WithContext(ctx)
+
+	host, repository, reference := splitImageReference(target.Image)
+	client := NewRegistryClient(host, target.Username, target.Password)
+	if manifest, err := client.GetManifest(ctx, repository, reference); err == nil && manifest.Digest != signature.digest {
+		logger.Infof("目标镜像 %s 的摘要 %s 与已签名的源镜像摘要 %s 不同，签名按源摘要复制", target.Image, manifest.Digest, signature.digest)
+	}
+
+	tag := strings.Replace(signature.digest, ":", "-", 1) + ".sig"
+	if err := CopyManifest(ctx, signature.client, signature.repository, signature.manifest, client, repository, tag); err != nil {
+		return fmt.Errorf("复制签名到 %s/%s:%s 失败: %v", client.Host(), repository, tag, err)
+	}
+	logger.Infof("已复制签名: %s/%s:%s", client.Host(), repository, tag)
+	return nil
+}
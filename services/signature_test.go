@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+)
+
+// testSigningKeys 内存中的签名验证公钥，按仓库配置ID保存
+type testSigningKeys map[string][]*models.SigningKey
+
+func (k testSigningKeys) List(ctx context.Context, configID string) ([]*models.SigningKey, error) {
+	return k[configID], nil
+}
+
+func (k testSigningKeys) Create(ctx context.Context, key *models.SigningKey) error {
+	k[key.ConfigID] = append(k[key.ConfigID], key)
+	return nil
+}
+
+func (k testSigningKeys) Delete(ctx context.Context, configID, id string) error {
+	return nil
+}
+
+// testRegistryConfigs 内存中的仓库配置，只实现List
+type testRegistryConfigs struct {
+	storage.RegistryConfigRepository
+	configs []*models.RegistryConfig
+}
+
+func (c testRegistryConfigs) List(ctx context.Context) ([]*models.RegistryConfig, error) {
+	return c.configs, nil
+}
+
+// newSigningKey 生成P-256签名私钥，返回私钥与PEM格式的公钥
+func newSigningKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// signImage 按cosign的方式为digest签名，签名清单写入 sha256-<摘要>.sig 标签
+func signImage(t *testing.T, registry *testRegistry, repository, digest string, key *ecdsa.PrivateKey) Descriptor {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"critical": map[string]interface{}{
+			"identity": map[string]string{"docker-reference": registry.host() + "/" + repository},
+			"image":    map[string]string{"docker-manifest-digest": digest},
+			"type":     "cosign container image signature",
+		},
+	})
+	sum := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := registry.putBlob(repository, payload)
+	layer.MediaType = cosignSimpleSigningType
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature)}
+	config := registry.putBlob(repository, []byte("{}"))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	return registry.putManifest(repository, referrersTag(digest)+".sig", map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     MediaTypeOCIManifest,
+		"config":        config,
+		"layers":        []Descriptor{layer},
+	})
+}
+
+func TestSignatureCopyBySourceDigest(t *testing.T) {
+	src, dst := newTestRegistry(t), newTestRegistry(t)
+	index, _, pushed := seedMultiArch(src, dst)
+	key, publicKey := newSigningKey(t)
+	signature := signImage(t, src, "library/app", index.Digest, key)
+
+	verifier := NewSignatureVerifier(nil, testSigningKeys{"registry": {{ConfigID: "registry", Name: "release", PublicKey: publicKey}}}, nil)
+	keys, _ := verifier.signingKeys(context.Background(), "registry", "")
+	client := NewRegistryClient(src.host(), "", "")
+	verified, err := verifier.verifyDigest(context.Background(), client, "library/app", src.host()+"/library/app", index.Digest, keys)
+	if err != nil {
+		t.Fatalf("verifyDigest: %v", err)
+	}
+
+	// 多架构源镜像推送后目标摘要为平台子清单的摘要，签名按源摘要复制
+	target := PushTarget{Image: dst.host() + "/base/app:1.0", ConfigID: "registry"}
+	if err := verifier.copy(context.Background(), verified, target); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	copied := dst.manifest("base/app", referrersTag(index.Digest)+".sig")
+	if copied == nil || digestOf(copied) != signature.Digest {
+		t.Fatalf("签名未按源摘要复制到 %s.sig", referrersTag(index.Digest))
+	}
+	if dst.manifest("base/app", referrersTag(pushed.Digest)+".sig") != nil {
+		t.Errorf("签名不应写入目标摘要的标签")
+	}
+
+	// 目标仓库不可用时返回错误，由调用方使任务失败
+	dst.Close()
+	if err := verifier.copy(context.Background(), verified, target); err == nil {
+		t.Fatal("copy() = nil, want error")
+	}
+}
+
+func TestSignatureVerifyInRegistry(t *testing.T) {
+	registry := newTestRegistry(t)
+	signed := registry.seedImage("base/app", "1.0", "signed")
+	unsigned := registry.seedImage("base/app", "1.1", "unsigned")
+	foreign := registry.seedImage("base/app", "1.2", "foreign")
+	key, publicKey := newSigningKey(t)
+	otherKey, _ := newSigningKey(t)
+	signImage(t, registry, "base/app", signed.Digest, key)
+	signImage(t, registry, "base/app", foreign.Digest, otherKey)
+
+	verifier := NewSignatureVerifier(nil, testSigningKeys{"registry": {{ConfigID: "registry", Name: "release", PublicKey: publicKey}}}, nil)
+	client := NewRegistryClient(registry.host(), "", "")
+	ctx := context.Background()
+
+	if err := verifier.verifyInRegistry(ctx, client, "base/app", signed.Digest, "registry"); err != nil {
+		t.Errorf("已签名: %v", err)
+	}
+	if err := verifier.verifyInRegistry(ctx, client, "base/app", unsigned.Digest, "registry"); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("未签名 = %v, want ErrManifestNotFound", err)
+	}
+	if err := verifier.verifyInRegistry(ctx, client, "base/app", foreign.Digest, "registry"); err == nil {
+		t.Error("其他公钥的签名验证通过, want error")
+	}
+	if err := verifier.verifyInRegistry(ctx, client, "base/app", unsigned.Digest, "other"); err != nil {
+		t.Errorf("未配置公钥的仓库 = %v, want nil", err)
+	}
+}
+
+func TestSignatureRequireUnsigned(t *testing.T) {
+	verifier := NewSignatureVerifier(nil, testSigningKeys{"registry": {{ConfigID: "registry", Name: "release"}}}, nil)
+	if err := verifier.requireUnsigned(context.Background(), "registry", ""); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("requireUnsigned(配置了公钥) = %v, want ErrSignatureRequired", err)
+	}
+	if err := verifier.requireUnsigned(context.Background(), "other", ""); err != nil {
+		t.Errorf("requireUnsigned(未配置公钥) = %v, want nil", err)
+	}
+}
+
+func TestSignatureKeysByTargetHost(t *testing.T) {
+	registry := newTestRegistry(t)
+	unsigned := registry.seedImage("prod/app", "1.0", "unsigned")
+	configs := testRegistryConfigs{configs: []*models.RegistryConfig{
+		{ID: "prod", RegistryURL: "https://" + registry.host() + "/"},
+		{ID: "staging", RegistryURL: "staging.example.com"},
+	}}
+	verifier := NewSignatureVerifier(configs, testSigningKeys{"prod": {{ConfigID: "prod", Name: "release"}}}, nil)
+	ctx := context.Background()
+
+	// 手动输入与配置相同的仓库地址时，同样使用该配置的公钥
+	keys, err := verifier.signingKeys(ctx, "", registry.host())
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("signingKeys(手动目标) = %v, %v, want 1 个公钥", keys, err)
+	}
+	if keys, _ := verifier.signingKeys(ctx, "", "staging.example.com"); len(keys) != 0 {
+		t.Errorf("signingKeys(未配置公钥的仓库) = %v, want 空", keys)
+	}
+
+	client := NewRegistryClient(registry.host(), "", "")
+	if err := verifier.verifyInRegistry(ctx, client, "prod/app", unsigned.Digest, ""); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("verifyInRegistry(手动目标, 未签名) = %v, want ErrManifestNotFound", err)
+	}
+	if err := verifier.requireUnsigned(ctx, "", registry.host()); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("requireUnsigned(手动目标) = %v, want ErrSignatureRequired", err)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// taskLimiter 限制同时执行的任务数，上限在每次检查时通过limit读取，支持运行时调整
+type taskLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  func() int // 返回0或负数表示不限
+	active int
+}
+
+func newTaskLimiter(limit func() int) *taskLimiter {
+	l := &taskLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire 等待执行名额，ctx取消时返回ctx.Err()
+func (l *taskLimiter) Acquire(ctx context.Context) error {
+	// ctx结束时唤醒等待者，使其检查ctx状态
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.cond.Broadcast()
+	})
+	defer stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if limit := l.limit(); limit <= 0 || l.active < limit {
+			l.active++
+			return nil
+		}
+		l.cond.Wait()
+	}
+}
+
+// Release 归还执行名额
+func (l *taskLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.cond.Broadcast()
+}
+
+// Active 返回正在执行的任务数
+func (l *taskLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Wake 上限变化后唤醒等待者重新检查
+func (l *taskLimiter) Wake() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cond.Broadcast()
+}
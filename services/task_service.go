@@ -2,51 +2,182 @@ package services
 
 import (
 	"context"
-	"database/sql"
-	"docker-helper/utils"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"docker-helper/database"
+	"docker-helper/config"
 	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/tracing"
+	"docker-helper/utils"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrShuttingDown 服务关闭过程中拒绝创建新任务
+var ErrShuttingDown = errors.New("服务正在关闭，暂不接收新任务")
+
+// errInterrupted 服务关闭时中断任务使用的取消原因
+var errInterrupted = errors.New("服务关闭，任务被中断")
+
+// errCancelled 用户取消任务使用的取消原因，任务状态已由CancelTask更新
+var errCancelled = errors.New("任务已取消")
+
+// ErrBundleNotReady 任务不是离线包任务或尚未成功完成
+var ErrBundleNotReady = errors.New("任务不是已完成的离线包任务")
+
+// drainLogInterval 关闭过程中输出等待进度的间隔
+const drainLogInterval = 5 * time.Second
+
+// interruptWait 中断任务后等待其退出并记录状态的最长时间
+const interruptWait = 10 * time.Second
+
+// taskLeaseDuration 任务租约时长，实例停止续约超过该时间后其未完成任务可被其他实例回收
+const taskLeaseDuration = 2 * time.Minute
+
+// taskLeaseRenewInterval 续约执行中任务租约的间隔
+const taskLeaseRenewInterval = 30 * time.Second
+
+// taskHandle 已提交执行的任务
+type taskHandle struct {
+	cancel  context.CancelCauseFunc
+	started bool // 已取得执行名额
+}
+
 // TaskService 任务管理服务
 type TaskService struct {
-	imageService *ImageService
-	logger       *utils.Logger
-	crypto       *utils.CryptoService
-	runningTasks map[string]context.CancelFunc // 正在运行的任务取消函数
-	mu           sync.RWMutex                  // 保护runningTasks的互斥锁
+	store         storage.Store
+	imageService  *ImageService
+	logger        *utils.Logger
+	crypto        *utils.CryptoService
+	runningTasks  map[string]*taskHandle // 排队中与执行中的任务
+	mu            sync.RWMutex           // 保护runningTasks与生命周期状态
+	phase         string                 // 生命周期阶段
+	drainDeadline time.Time              // 关闭过程中执行中任务的最晚完成时间
+	wg            sync.WaitGroup         // 所有已提交任务的协程
+	limiter       *taskLimiter           // 并发执行限制
+	bundles       *BundleService         // 离线包文件
+	instanceID    string                 // 本实例标识，记录在所创建的任务上
+	leaseStop     chan struct{}          // 停止租约续约
+	leaseDone     chan struct{}          // 租约续约协程已退出
 }
 
-// NewTaskService 创建任务服务
-func NewTaskService() (*TaskService, error) {
+// NewTaskService 创建任务服务，cache为任务拉取的源镜像使用的本地缓存
+func NewTaskService(store storage.Store, bundles *BundleService, cache *ImageCache) (*TaskService, error) {
 	imageService, err := NewImageService()
 	if err != nil {
 		return nil, err
 	}
+	imageService.cache = cache
+	imageService.mounter = NewBlobMounter(store.BlobLocations(), imageService.dockerService)
+	imageService.signatures = NewSignatureVerifier(store.RegistryConfigs(), store.SigningKeys(), imageService.dockerService)
+	imageService.referrers = NewReferrerCopier(imageService.dockerService)
 
-	logger := utils.NewLogger("info")
+	logger := utils.NewLogger("task")
 	crypto := utils.NewCryptoService()
 
-	return &TaskService{
+	ts := &TaskService{
+		store:        store,
 		imageService: imageService,
 		logger:       logger,
 		crypto:       crypto,
-		runningTasks: make(map[string]context.CancelFunc),
-	}, nil
+		runningTasks: make(map[string]*taskHandle),
+		phase:        models.WorkerPhaseAccepting,
+		limiter:      newTaskLimiter(func() int { return config.Current().TaskConcurrency }),
+		bundles:      bundles,
+		instanceID:   resolveInstanceID(),
+		leaseStop:    make(chan struct{}),
+		leaseDone:    make(chan struct{}),
+	}
+	go ts.renewLeases()
+
+	// 并发上限热加载后唤醒排队中的任务
+	config.OnReload(func(old, new *config.Config) {
+		if old.TaskConcurrency != new.TaskConcurrency {
+			ts.limiter.Wake()
+		}
+	})
+
+	return ts, nil
 }
 
-// CreateTask 创建新任务
-func (ts *TaskService) CreateTask(req *models.TransformRequest) (*models.TaskCreateResponse, error) {
+// instanceID 本进程的实例标识，由resolveInstanceID首次调用时确定
+var (
+	instanceID     string
+	instanceIDOnce sync.Once
+)
+
+// resolveInstanceID 返回本实例标识：优先使用配置，其次主机名，都没有时随机生成；
+// 同一进程内只解析一次，保证任务与镜像缓存记录使用相同的标识
+func resolveInstanceID() string {
+	instanceIDOnce.Do(func() {
+		instanceID = config.Current().InstanceID
+		if instanceID != "" {
+			return
+		}
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			instanceID = hostname
+			return
+		}
+		instanceID = uuid.New().String()
+	})
+	return instanceID
+}
+
+// InstanceID 返回本实例标识
+func (ts *TaskService) InstanceID() string {
+	return ts.instanceID
+}
+
+// leased 为新建任务记录所属实例与租约到期时间
+func (ts *TaskService) leased(task *models.Task) *models.Task {
+	expires := time.Now().Add(taskLeaseDuration)
+	task.InstanceID = &ts.instanceID
+	task.LeaseExpiresAt = &expires
+	return task
+}
+
+// renewLeases 定期续约本实例未完成任务的租约，直到Close
+func (ts *TaskService) renewLeases() {
+	defer close(ts.leaseDone)
+
+	ticker := time.NewTicker(taskLeaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := ts.store.Tasks().RenewLeases(context.Background(), ts.instanceID, time.Now().Add(taskLeaseDuration)); err != nil {
+				ts.logger.Warnf("续约任务租约失败: %v", err)
+			}
+		case <-ts.leaseStop:
+			return
+		}
+	}
+}
+
+// CreateTask 创建新任务，createdBy为发起请求的用户，ctx中的请求ID会传递给任务日志
+func (ts *TaskService) CreateTask(ctx context.Context, req *models.TransformRequest, createdBy string) (resp *models.TaskCreateResponse, err error) {
+	if !ts.WorkerStats().Accepting {
+		return nil, ErrShuttingDown
+	}
+	if len(req.Targets) > 0 {
+		return ts.createTargetsTask(ctx, req, createdBy)
+	}
+
 	// 生成任务ID
 	taskID := uuid.New().String()
 
+	ctx, span := tracing.Start(ctx, "TaskService.CreateTask", attribute.String("task_id", taskID))
+	defer func() { tracing.End(span, err) }()
+
 	// 解析仓库配置信息
 	var targetHost, targetUsername, targetPassword string
 	var configID *string
@@ -78,26 +209,49 @@ func (ts *TaskService) CreateTask(req *models.TransformRequest) (*models.TaskCre
 		targetPassword = req.TargetPassword
 	}
 
-	// 创建任务记录
-	query := `
-		INSERT INTO tasks (
-			id, source_image, target_image, target_host, target_username, config_id,
-			status, progress, current_step, step_message
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	referrerTypes, err := resolveReferrerTypes(req.ReferrerTypes)
+	if err != nil {
+		return nil, err
+	}
+	target := PushTarget{
+		Image:         req.TargetImage,
+		Username:      targetUsername,
+		Password:      targetPassword,
+		ConfigID:      req.ConfigID,
+		ReferrerTypes: referrerTypes,
+	}
+	err = CheckSourcePolicy(ctx, req.SourceImage, []PushTarget{target})
+	if err != nil {
+		return nil, err
+	}
 
+	// 创建任务记录
 	stepMessage := models.TaskStepMessages[models.TaskStepInit]
-	_, err := database.DB.Exec(query,
-		taskID, req.SourceImage, req.TargetImage, targetHost, targetUsername, configID,
-		models.TaskStatusPending, 0, models.TaskStepInit, stepMessage)
+	err = ts.store.Tasks().Create(context.Background(), ts.leased(&models.Task{
+		ID:             taskID,
+		SourceImage:    req.SourceImage,
+		TargetImage:    req.TargetImage,
+		TargetHost:     targetHost,
+		TargetUsername: targetUsername,
+		ConfigID:       configID,
+		CreatedBy:      &createdBy,
+		Status:         models.TaskStatusPending,
+		Progress:       0,
+		CurrentStep:    models.TaskStepInit,
+		StepMessage:    &stepMessage,
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("创建任务记录失败: %v", err)
 	}
 
-	// 启动后台任务执行
-	go ts.executeTaskAsync(taskID, req.SourceImage, req.TargetImage, targetUsername, targetPassword)
+	err = ts.submit(ctx, taskID, func(ctx context.Context) {
+		ts.executeTransform(ctx, taskID, req.SourceImage, target)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	ts.logger.Infof("任务创建成功: %s, 源镜像: %s, 目标镜像: %s", taskID, req.SourceImage, req.TargetImage)
+	ts.logger.WithContext(ctx).With("task_id", taskID).Infof("任务创建成功: %s, 源镜像: %s, 目标镜像: %s", taskID, req.SourceImage, req.TargetImage)
 
 	return &models.TaskCreateResponse{
 		TaskID:  taskID,
@@ -108,27 +262,15 @@ func (ts *TaskService) CreateTask(req *models.TransformRequest) (*models.TaskCre
 
 // GetTask 获取单个任务信息
 func (ts *TaskService) GetTask(taskID string) (*models.TaskStatusResponse, error) {
-	var task models.Task
-	query := `
-		SELECT id, source_image, target_image, target_host, target_username, config_id,
-		       status, progress, current_step, step_message, error_msg, duration,
-		       created_at, started_at, completed_at
-		FROM tasks WHERE id = ?
-	`
-
-	err := database.DB.QueryRow(query, taskID).Scan(
-		&task.ID, &task.SourceImage, &task.TargetImage, &task.TargetHost, &task.TargetUsername, &task.ConfigID,
-		&task.Status, &task.Progress, &task.CurrentStep, &task.StepMessage, &task.ErrorMsg, &task.Duration,
-		&task.CreatedAt, &task.StartedAt, &task.CompletedAt,
-	)
+	task, err := ts.store.Tasks().Get(context.Background(), taskID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("任务不存在")
 		}
 		return nil, fmt.Errorf("查询任务失败: %v", err)
 	}
 
-	response := &models.TaskStatusResponse{Task: task}
+	response := &models.TaskStatusResponse{Task: *task}
 
 	// 计算预计剩余时间（简单估算）
 	if task.Status == models.TaskStatusRunning && task.StartedAt != nil {
@@ -174,32 +316,12 @@ func (ts *TaskService) GetTaskList() (*models.TaskListResponse, error) {
 
 // GetTaskStats 获取任务统计
 func (ts *TaskService) GetTaskStats() (*models.TaskStatsResponse, error) {
-	query := `
-		SELECT 
-			COUNT(*) as total,
-			COALESCE(SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END), 0) as running,
-			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0) as queued,
-			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success,
-			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed,
-			AVG(CASE WHEN status IN ('completed', 'failed') AND duration > 0 THEN duration END) as avg_duration
-		FROM tasks
-	`
-
-	var stats models.TaskStatsResponse
-	var avgDuration sql.NullFloat64
-
-	err := database.DB.QueryRow(query).Scan(
-		&stats.Total, &stats.Running, &stats.Queued, &stats.Success, &stats.Failed, &avgDuration,
-	)
+	stats, err := ts.store.Tasks().Stats(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("查询任务统计失败: %v", err)
 	}
 
-	if avgDuration.Valid {
-		stats.AvgDuration = &avgDuration.Float64
-	}
-
-	return &stats, nil
+	return stats, nil
 }
 
 // CancelTask 取消任务
@@ -208,52 +330,197 @@ func (ts *TaskService) CancelTask(taskID string) error {
 	defer ts.mu.Unlock()
 
 	// 如果任务正在运行，取消执行
-	if cancelFunc, exists := ts.runningTasks[taskID]; exists {
-		cancelFunc()
+	if handle, exists := ts.runningTasks[taskID]; exists {
+		handle.cancel(errCancelled)
 		delete(ts.runningTasks, taskID)
 	}
 
 	// 更新任务状态
-	query := `
-		UPDATE tasks SET 
-			status = ?, 
-			step_message = ?,
-			completed_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND status IN ('pending', 'running')
-	`
-
-	result, err := database.DB.Exec(query, models.TaskStatusCancelled, "任务已取消", taskID)
+	err := ts.store.Tasks().Cancel(context.Background(), taskID, "任务已取消")
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("任务不存在或已完成")
+		}
 		return fmt.Errorf("取消任务失败: %v", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("任务不存在或已完成")
+	ts.logger.With("task_id", taskID).Infof("任务已取消: %s", taskID)
+	return nil
+}
+
+// CreateBundleTask 创建离线包导出任务，拉取images并打包为一个tar文件，完成后可通过DownloadBundle下载
+func (ts *TaskService) CreateBundleTask(ctx context.Context, req *models.BundleRequest, createdBy string) (resp *models.TaskCreateResponse, err error) {
+	if !ts.WorkerStats().Accepting {
+		return nil, ErrShuttingDown
 	}
 
-	ts.logger.Infof("任务已取消: %s", taskID)
-	return nil
+	format := req.Format
+	if format == "" {
+		format = models.BundleFormatOCI
+	}
+	if format != models.BundleFormatOCI && format != models.BundleFormatDocker {
+		return nil, fmt.Errorf("离线包格式必须是 oci 或 docker，当前为 %q", req.Format)
+	}
+
+	// 校验并去除重复的镜像
+	var images []string
+	seen := make(map[string]bool)
+	for _, image := range req.Images {
+		image = strings.TrimSpace(image)
+		if err := utils.ValidateImageName(image); err != nil {
+			return nil, fmt.Errorf("镜像名称 %s 无效: %v", image, err)
+		}
+		normalized := utils.NormalizeImageName(image)
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		images = append(images, image)
+	}
+	for _, image := range images {
+		if err := CheckSourcePolicy(ctx, image, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	taskID := uuid.New().String()
+
+	ctx, span := tracing.Start(ctx, "TaskService.CreateBundleTask",
+		attribute.String("task_id", taskID), attribute.StringSlice("images", images))
+	defer func() { tracing.End(span, err) }()
+
+	stepMessage := models.TaskStepMessages[models.TaskStepInit]
+	err = ts.store.Tasks().Create(context.Background(), ts.leased(&models.Task{
+		ID:           taskID,
+		Type:         models.TaskTypeBundle,
+		SourceImage:  strings.Join(images, ", "),
+		TargetImage:  ts.bundles.FileName(taskID),
+		Images:       images,
+		BundleFormat: &format,
+		CreatedBy:    &createdBy,
+		Status:       models.TaskStatusPending,
+		CurrentStep:  models.TaskStepInit,
+		StepMessage:  &stepMessage,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+
+	err = ts.submit(ctx, taskID, func(ctx context.Context) {
+		ts.executeBundle(ctx, taskID, images, format)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts.logger.WithContext(ctx).With("task_id", taskID).Infof("离线包任务创建成功: %s, 镜像: %v, 格式: %s", taskID, images, format)
+
+	return &models.TaskCreateResponse{
+		TaskID:  taskID,
+		Status:  models.TaskStatusPending,
+		Message: "离线包任务已创建，正在后台执行",
+	}, nil
 }
 
-// executeTaskAsync 异步执行任务
-func (ts *TaskService) executeTaskAsync(taskID, sourceImage, targetImage, username, password string) {
-	// 创建带取消功能的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+// OpenBundle 打开已完成的离线包任务生成的文件，调用方负责关闭
+func (ts *TaskService) OpenBundle(taskID string) (*models.Task, *os.File, error) {
+	task, err := ts.store.Tasks().Get(context.Background(), taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task.Type != models.TaskTypeBundle || task.Status != models.TaskStatusCompleted {
+		return nil, nil, ErrBundleNotReady
+	}
+	file, err := ts.bundles.Open(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, file, nil
+}
+
+// BundleFileName 下载离线包时使用的文件名
+func (ts *TaskService) BundleFileName(taskID string) string {
+	return ts.bundles.FileName(taskID)
+}
+
+// submit 注册任务并在后台执行run，任务不随请求结束而取消，继承请求ID与trace上下文用于关联
+//
+// 关闭过程中提交的任务直接标记为已中断并返回ErrShuttingDown。
+func (ts *TaskService) submit(ctx context.Context, taskID string, run func(ctx context.Context)) error {
+	taskCtx, cancel := context.WithCancelCause(utils.ContextWithTaskID(tracing.Detach(ctx), taskID))
 
-	// 注册取消函数
 	ts.mu.Lock()
-	ts.runningTasks[taskID] = cancel
+	if ts.phase != models.WorkerPhaseAccepting {
+		ts.mu.Unlock()
+		cancel(errInterrupted)
+		ts.interruptTask(taskID, "服务关闭，任务未开始执行")
+		return ErrShuttingDown
+	}
+	ts.runningTasks[taskID] = &taskHandle{cancel: cancel}
+	ts.wg.Add(1)
 	ts.mu.Unlock()
 
+	_, queueSpan := tracing.Start(taskCtx, "task.queue_wait")
+	go ts.runQueued(taskCtx, queueSpan, taskID, run)
+	return nil
+}
+
+// runQueued 等待执行名额后执行任务，取得名额时结束排队等待的Span
+//
+// ctx由submit创建并注册到runningTasks，取消原因为errInterrupted时任务记为已中断。
+func (ts *TaskService) runQueued(ctx context.Context, queueSpan trace.Span, taskID string, run func(ctx context.Context)) {
+	defer ts.wg.Done()
+
 	// 执行完成后清理
 	defer func() {
 		ts.mu.Lock()
-		delete(ts.runningTasks, taskID)
+		if handle, exists := ts.runningTasks[taskID]; exists {
+			handle.cancel(nil)
+			delete(ts.runningTasks, taskID)
+		}
 		ts.mu.Unlock()
 	}()
 
+	// 等待执行名额（task_concurrency）
+	if err := ts.limiter.Acquire(ctx); err != nil {
+		tracing.End(queueSpan, err)
+		if errors.Is(context.Cause(ctx), errInterrupted) {
+			ts.interruptTask(taskID, "服务关闭，任务未开始执行")
+			return
+		}
+		ts.logger.WithContext(ctx).Infof("任务在排队期间被取消: %s", taskID)
+		return
+	}
+	defer ts.limiter.Release()
+	queueSpan.End()
+
+	ts.mu.Lock()
+	if handle, exists := ts.runningTasks[taskID]; exists {
+		handle.started = true
+	}
+	ts.mu.Unlock()
+
+	run(ctx)
+}
+
+// executeTransform 执行镜像转换任务
+func (ts *TaskService) executeTransform(ctx context.Context, taskID, sourceImage string, target PushTarget) {
+	targetImage := target.Image
+
+	// 超时从开始执行时计算，不含排队时间
+	ctx, cancelTimeout := context.WithTimeout(ctx, config.Current().TaskTimeout)
+	defer cancelTimeout()
+
+	ctx, span := tracing.Start(ctx, "task.execute",
+		attribute.String("task_id", taskID),
+		attribute.String("source_image", sourceImage),
+		attribute.String("target_image", targetImage),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	logger := ts.logger.WithContext(ctx)
+
 	// 更新任务状态为运行中 - 步骤1: 验证镜像
 	ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
 		TaskID:      taskID,
@@ -298,14 +565,22 @@ func (ts *TaskService) executeTaskAsync(taskID, sourceImage, targetImage, userna
 	}
 
 	// 执行镜像转换（带进度回调）
-	resultImage, _, err := ts.imageService.TransformImageWithProgress(
-		ctx, sourceImage, targetImage, username, password, progressCallback,
+	var resultImage string
+	resultImage, _, err = ts.imageService.TransformImageWithProgress(
+		ctx, sourceImage, target, progressCallback, ts.mountCallback(taskID),
 	)
 
 	// 计算实际执行时间
 	actualDuration := int(time.Since(startTime).Seconds())
 
-	if err != nil {
+	if err != nil && errors.Is(context.Cause(ctx), errInterrupted) {
+		// 服务关闭时被中断
+		ts.interruptTask(taskID, "服务关闭，任务被中断")
+		logger.Warnf("任务因服务关闭被中断: %s", taskID)
+	} else if err != nil && errors.Is(context.Cause(ctx), errCancelled) {
+		// 已由CancelTask标记为取消，不再记为失败
+		logger.Infof("任务在执行期间被取消: %s", taskID)
+	} else if err != nil {
 		// 任务失败
 		errorMsg := err.Error()
 		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
@@ -319,8 +594,8 @@ func (ts *TaskService) executeTaskAsync(taskID, sourceImage, targetImage, userna
 		})
 
 		ts.updateCompletedTime(taskID)
-		ts.recordHistory(sourceImage, targetImage, extractHostFromImage(targetImage), "failed", &errorMsg, actualDuration)
-		ts.logger.Errorf("任务执行失败: %s, 错误: %v", taskID, err)
+		ts.recordHistory(taskID, sourceImage, targetImage, extractHostFromImage(targetImage), "failed", &errorMsg, actualDuration)
+		logger.Errorf("任务执行失败: %s, 错误: %v", taskID, err)
 	} else {
 		// 任务成功
 		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
@@ -333,171 +608,312 @@ func (ts *TaskService) executeTaskAsync(taskID, sourceImage, targetImage, userna
 		})
 
 		ts.updateCompletedTime(taskID)
-		ts.recordHistory(sourceImage, resultImage, extractHostFromImage(resultImage), "success", nil, actualDuration)
-		ts.logger.Infof("任务执行成功: %s, 目标镜像: %s", taskID, resultImage)
+		ts.recordHistory(taskID, sourceImage, resultImage, extractHostFromImage(resultImage), "success", nil, actualDuration)
+		logger.Infof("任务执行成功: %s, 目标镜像: %s", taskID, resultImage)
 	}
 }
 
-// updateTaskProgress 更新任务进度
-func (ts *TaskService) updateTaskProgress(taskID string, update *models.TaskProgressUpdate) {
-	query := `
-		UPDATE tasks SET 
-			status = ?, 
-			progress = ?, 
-			current_step = ?, 
-			step_message = ?,
-			error_msg = ?,
-			duration = ?
-		WHERE id = ?
-	`
-
-	_, err := database.DB.Exec(query,
-		update.Status, update.Progress, update.CurrentStep,
-		update.StepMessage, update.ErrorMsg, update.Duration, taskID)
-	if err != nil {
-		ts.logger.Errorf("更新任务进度失败: %s, 错误: %v", taskID, err)
+// executeBundle 执行离线包导出任务：拉取镜像 -> 导出并打包 -> 清理
+func (ts *TaskService) executeBundle(ctx context.Context, taskID string, images []string, format string) {
+	// 每个镜像按一个任务的时长计算超时
+	ctx, cancelTimeout := context.WithTimeout(ctx, config.Current().TaskTimeout*time.Duration(len(images)))
+	defer cancelTimeout()
+
+	ctx, span := tracing.Start(ctx, "task.execute_bundle",
+		attribute.String("task_id", taskID),
+		attribute.StringSlice("images", images),
+		attribute.String("format", format),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	logger := ts.logger.WithContext(ctx)
+
+	ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+		TaskID:      taskID,
+		Status:      models.TaskStatusRunning,
+		Progress:    5,
+		CurrentStep: 1,
+		StepMessage: "验证镜像",
+	})
+	ts.updateStartTime(taskID)
+
+	startTime := time.Now()
+
+	progressCallback := func(step int, stepName string, progress int) {
+		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+			TaskID:      taskID,
+			Status:      models.TaskStatusRunning,
+			Progress:    progress,
+			CurrentStep: step,
+			StepMessage: stepName,
+		})
+	}
+
+	var size int64
+	var sum string
+	err = ts.imageService.ExportImagesWithProgress(ctx, images, func(save io.Reader) error {
+		var writeErr error
+		size, sum, writeErr = ts.bundles.Write(ctx, taskID, format, save)
+		return writeErr
+	}, progressCallback)
+	if err == nil {
+		if err = ts.store.Tasks().SetArtifact(context.Background(), taskID, size, sum); err != nil {
+			err = fmt.Errorf("记录离线包信息失败: %v", err)
+		}
+	}
+
+	ts.finishTask(ctx, taskID, err, startTime, "导出失败", "导出完成")
+	if err == nil {
+		logger.Infof("离线包大小: %d字节, SHA-256: %s", size, sum)
 	}
 }
 
-// updateStartTime 更新任务开始时间
-func (ts *TaskService) updateStartTime(taskID string) {
-	query := `UPDATE tasks SET started_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := database.DB.Exec(query, taskID)
+// CreateImportTask 创建离线包导入任务，将已上传并校验的离线包中的镜像推送到仓库配置对应的仓库
+func (ts *TaskService) CreateImportTask(ctx context.Context, req *models.ImportRequest, createdBy string) (resp *models.TaskCreateResponse, err error) {
+	if !ts.WorkerStats().Accepting {
+		return nil, ErrShuttingDown
+	}
+
+	upload, err := ts.bundles.GetUpload(req.UploadID)
 	if err != nil {
-		ts.logger.Errorf("更新任务开始时间失败: %s, 错误: %v", taskID, err)
+		return nil, err
+	}
+	if upload.Status != models.BundleUploadReady {
+		return nil, ErrUploadNotReady
 	}
-}
 
-// updateCompletedTime 更新任务完成时间
-func (ts *TaskService) updateCompletedTime(taskID string) {
-	query := `UPDATE tasks SET completed_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := database.DB.Exec(query, taskID)
+	registry, err := ts.defaultRegistryConfig(req.ConfigID)
 	if err != nil {
-		ts.logger.Errorf("更新任务完成时间失败: %s, 错误: %v", taskID, err)
+		return nil, err
+	}
+	password, err := ts.crypto.DecryptPassword(registry.PasswordEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("解密密码失败: %v", err)
 	}
-}
 
-// getCurrentTask 获取当前运行的任务
-func (ts *TaskService) getCurrentTask() (*models.Task, error) {
-	var task models.Task
-	query := `
-		SELECT id, source_image, target_image, target_host, target_username, config_id,
-		       status, progress, current_step, step_message, error_msg, duration,
-		       created_at, started_at, completed_at
-		FROM tasks 
-		WHERE status = ?
-		ORDER BY started_at DESC
-		LIMIT 1
-	`
-
-	err := database.DB.QueryRow(query, models.TaskStatusRunning).Scan(
-		&task.ID, &task.SourceImage, &task.TargetImage, &task.TargetHost, &task.TargetUsername, &task.ConfigID,
-		&task.Status, &task.Progress, &task.CurrentStep, &task.StepMessage, &task.ErrorMsg, &task.Duration,
-		&task.CreatedAt, &task.StartedAt, &task.CompletedAt,
-	)
+	jobs, err := resolveImportMappings(upload.Images, req.Images, registry.RegistryURL, config.Current().TargetNamespace)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // 没有运行中的任务
-		}
+		return nil, err
+	}
+	if err := ts.checkImportPolicy(ctx, upload, jobs, PushTarget{Username: registry.Username, Password: password, ConfigID: registry.ID}); err != nil {
+		return nil, err
+	}
+	if err := ts.imageService.signatures.requireUnsigned(ctx, registry.ID, registry.RegistryURL); err != nil {
 		return nil, err
 	}
 
-	return &task, nil
-}
+	taskID := uuid.New().String()
 
-// getQueuedTasks 获取队列中的任务
-func (ts *TaskService) getQueuedTasks() ([]*models.Task, error) {
-	query := `
-		SELECT id, source_image, target_image, target_host, target_username, config_id,
-		       status, progress, current_step, step_message, error_msg, duration,
-		       created_at, started_at, completed_at
-		FROM tasks 
-		WHERE status = ?
-		ORDER BY created_at ASC
-	`
-
-	rows, err := database.DB.Query(query, models.TaskStatusPending)
+	ctx, span := tracing.Start(ctx, "TaskService.CreateImportTask",
+		attribute.String("task_id", taskID), attribute.String("upload_id", upload.ID))
+	defer func() { tracing.End(span, err) }()
+
+	sources := make([]string, 0, len(jobs))
+	targets := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		sources = append(sources, job.Source)
+		targets = append(targets, job.Target)
+	}
+
+	stepMessage := models.TaskStepMessages[models.TaskStepInit]
+	err = ts.store.Tasks().Create(context.Background(), ts.leased(&models.Task{
+		ID:             taskID,
+		Type:           models.TaskTypeImport,
+		SourceImage:    strings.Join(sources, ", "),
+		TargetImage:    strings.Join(targets, ", "),
+		TargetHost:     registry.RegistryURL,
+		TargetUsername: registry.Username,
+		ConfigID:       &registry.ID,
+		Images:         sources,
+		BundleFormat:   &upload.Format,
+		CreatedBy:      &createdBy,
+		Status:         models.TaskStatusPending,
+		CurrentStep:    models.TaskStepInit,
+		StepMessage:    &stepMessage,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+
+	uploadID := upload.ID
+	err = ts.submit(ctx, taskID, func(ctx context.Context) {
+		ts.executeImport(ctx, taskID, uploadID, jobs, registry.Username, password)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var tasks []*models.Task
-	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(
-			&task.ID, &task.SourceImage, &task.TargetImage, &task.TargetHost, &task.TargetUsername, &task.ConfigID,
-			&task.Status, &task.Progress, &task.CurrentStep, &task.StepMessage, &task.ErrorMsg, &task.Duration,
-			&task.CreatedAt, &task.StartedAt, &task.CompletedAt,
-		)
+	ts.logger.WithContext(ctx).With("task_id", taskID).Infof("离线包导入任务创建成功: %s, 上传: %s, 镜像: %v", taskID, uploadID, targets)
+
+	return &models.TaskCreateResponse{
+		TaskID:  taskID,
+		Status:  models.TaskStatusPending,
+		Message: "导入任务已创建，正在后台执行",
+	}, nil
+}
+
+// defaultRegistryConfig 取得configID对应的仓库配置，configID为空时使用默认配置
+func (ts *TaskService) defaultRegistryConfig(configID string) (*models.RegistryConfig, error) {
+	if configID != "" {
+		registry, err := ts.getRegistryConfig(configID)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("获取仓库配置失败: %v", err)
 		}
-		tasks = append(tasks, &task)
+		return registry, nil
 	}
 
-	return tasks, nil
+	configs, err := ts.store.RegistryConfigs().List(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取仓库配置失败: %v", err)
+	}
+	for _, registry := range configs {
+		if registry.IsDefault {
+			return registry, nil
+		}
+	}
+	return nil, fmt.Errorf("未指定仓库配置且没有默认配置")
 }
 
-// getRecentTasks 获取最近完成的任务
-func (ts *TaskService) getRecentTasks(limit int) ([]*models.Task, error) {
-	query := `
-		SELECT id, source_image, target_image, target_host, target_username, config_id,
-		       status, progress, current_step, step_message, error_msg, duration,
-		       created_at, started_at, completed_at
-		FROM tasks 
-		WHERE status IN (?, ?)
-		ORDER BY completed_at DESC
-		LIMIT ?
-	`
-
-	rows, err := database.DB.Query(query, models.TaskStatusCompleted, models.TaskStatusFailed, limit)
-	if err != nil {
-		return nil, err
+// executeImport 执行离线包导入任务：导入 -> 标记并推送 -> 清理
+func (ts *TaskService) executeImport(ctx context.Context, taskID, uploadID string, jobs []ImportJob, username, password string) {
+	// 每个镜像按一个任务的时长计算超时
+	ctx, cancelTimeout := context.WithTimeout(ctx, config.Current().TaskTimeout*time.Duration(len(jobs)))
+	defer cancelTimeout()
+
+	ctx, span := tracing.Start(ctx, "task.execute_import",
+		attribute.String("task_id", taskID),
+		attribute.String("upload_id", uploadID),
+		attribute.Int("images", len(jobs)),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+		TaskID:      taskID,
+		Status:      models.TaskStatusRunning,
+		Progress:    5,
+		CurrentStep: 1,
+		StepMessage: "读取离线包",
+	})
+	ts.updateStartTime(taskID)
+
+	startTime := time.Now()
+
+	progressCallback := func(step int, stepName string, progress int) {
+		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+			TaskID:      taskID,
+			Status:      models.TaskStatusRunning,
+			Progress:    progress,
+			CurrentStep: step,
+			StepMessage: stepName,
+		})
 	}
-	defer rows.Close()
 
-	var tasks []*models.Task
-	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(
-			&task.ID, &task.SourceImage, &task.TargetImage, &task.TargetHost, &task.TargetUsername, &task.ConfigID,
-			&task.Status, &task.Progress, &task.CurrentStep, &task.StepMessage, &task.ErrorMsg, &task.Duration,
-			&task.CreatedAt, &task.StartedAt, &task.CompletedAt,
-		)
-		if err != nil {
-			return nil, err
+	var archive io.ReadCloser
+	if _, archive, err = ts.bundles.OpenImport(uploadID); err == nil {
+		err = ts.imageService.ImportImagesWithProgress(ctx, archive, jobs, username, password, progressCallback)
+		archive.Close()
+	}
+
+	ts.finishTask(ctx, taskID, err, startTime, "导入失败", "导入完成")
+}
+
+// finishTask 按执行结果记录离线包与多目标任务的结束状态，被取消或中断的任务不记为失败
+func (ts *TaskService) finishTask(ctx context.Context, taskID string, err error, startTime time.Time, failedMessage, completedMessage string) {
+	logger := ts.logger.WithContext(ctx)
+	actualDuration := int(time.Since(startTime).Seconds())
+
+	if err != nil && errors.Is(context.Cause(ctx), errInterrupted) {
+		// 服务关闭时被中断
+		ts.interruptTask(taskID, "服务关闭，任务被中断")
+		logger.Warnf("任务因服务关闭被中断: %s", taskID)
+	} else if err != nil && errors.Is(context.Cause(ctx), errCancelled) {
+		// 已由CancelTask标记为取消，不再记为失败
+		logger.Infof("任务在执行期间被取消: %s", taskID)
+	} else if err != nil {
+		errorMsg := err.Error()
+		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+			TaskID:      taskID,
+			Status:      models.TaskStatusFailed,
+			Progress:    0,
+			CurrentStep: 1,
+			StepMessage: failedMessage,
+			ErrorMsg:    &errorMsg,
+			Duration:    actualDuration,
+		})
+		ts.updateCompletedTime(taskID)
+		logger.Errorf("任务执行失败: %s, 错误: %v", taskID, err)
+	} else {
+		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+			TaskID:      taskID,
+			Status:      models.TaskStatusCompleted,
+			Progress:    100,
+			CurrentStep: 7,
+			StepMessage: completedMessage,
+			Duration:    actualDuration,
+		})
+		ts.updateCompletedTime(taskID)
+		logger.Infof("任务执行成功: %s", taskID)
+	}
+}
+
+// updateTaskProgress 更新任务进度
+func (ts *TaskService) updateTaskProgress(taskID string, update *models.TaskProgressUpdate) {
+	update.TaskID = taskID
+	if err := ts.store.Tasks().UpdateProgress(context.Background(), update); err != nil {
+		ts.logger.With("task_id", taskID).Errorf("更新任务进度失败: %s, 错误: %v", taskID, err)
+	}
+}
+
+// updateStartTime 更新任务开始时间
+func (ts *TaskService) updateStartTime(taskID string) {
+	if err := ts.store.Tasks().MarkStarted(context.Background(), taskID); err != nil {
+		ts.logger.With("task_id", taskID).Errorf("更新任务开始时间失败: %s, 错误: %v", taskID, err)
+	}
+}
+
+// mountCallback 返回累加任务跨仓库挂载节省字节数的回调
+func (ts *TaskService) mountCallback(taskID string) func(size int64) {
+	return func(size int64) {
+		if err := ts.store.Tasks().AddMountedBytes(context.Background(), taskID, size); err != nil {
+			ts.logger.With("task_id", taskID).Errorf("更新任务挂载字节数失败: %s, 错误: %v", taskID, err)
 		}
-		tasks = append(tasks, &task)
 	}
+}
+
+// updateCompletedTime 更新任务完成时间
+func (ts *TaskService) updateCompletedTime(taskID string) {
+	if err := ts.store.Tasks().MarkCompleted(context.Background(), taskID); err != nil {
+		ts.logger.With("task_id", taskID).Errorf("更新任务完成时间失败: %s, 错误: %v", taskID, err)
+	}
+}
+
+// getCurrentTask 获取当前运行的任务
+func (ts *TaskService) getCurrentTask() (*models.Task, error) {
+	return ts.store.Tasks().Current(context.Background())
+}
 
-	return tasks, nil
+// getQueuedTasks 获取队列中的任务
+func (ts *TaskService) getQueuedTasks() ([]*models.Task, error) {
+	return ts.store.Tasks().Queued(context.Background())
+}
+
+// getRecentTasks 获取最近完成的任务
+func (ts *TaskService) getRecentTasks(limit int) ([]*models.Task, error) {
+	return ts.store.Tasks().Recent(context.Background(), limit)
 }
 
 // recordHistory 记录转换历史（任务数据已在tasks表中，无需额外记录）
-func (ts *TaskService) recordHistory(sourceImage, targetImage, targetHost, status string, errorMsg *string, duration int) {
+func (ts *TaskService) recordHistory(taskID, sourceImage, targetImage, targetHost, status string, errorMsg *string, duration int) {
 	// 注释：由于采用单表设计，任务的历史记录已经存储在tasks表中
 	// 这个方法保留为兼容性，实际不执行任何操作
-	ts.logger.Infof("任务历史已在tasks表中记录: %s -> %s, 状态: %s", sourceImage, targetImage, status)
+	ts.logger.With("task_id", taskID).Infof("任务历史已在tasks表中记录: %s -> %s, 状态: %s", sourceImage, targetImage, status)
 }
 
 // getRegistryConfig 获取仓库配置
 func (ts *TaskService) getRegistryConfig(configID string) (*models.RegistryConfig, error) {
-	var config models.RegistryConfig
-	query := `
-		SELECT id, name, registry_url, username, password_encrypted, status
-		FROM registry_configs WHERE id = ?
-	`
-
-	err := database.DB.QueryRow(query, configID).Scan(
-		&config.ID, &config.Name, &config.RegistryURL,
-		&config.Username, &config.PasswordEncrypted, &config.Status,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &config, nil
+	return ts.store.RegistryConfigs().Get(context.Background(), configID)
 }
 
 // extractHostFromImage 从完整的镜像名称中提取主机地址
@@ -516,15 +932,147 @@ func extractHostFromImage(imageName string) string {
 	return "docker.io"
 }
 
-// Close 关闭服务
+// WorkerStats 返回任务执行状态
+func (ts *TaskService) WorkerStats() models.WorkerStats {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	running := ts.limiter.Active()
+	stats := models.WorkerStats{
+		Running:   running,
+		Queued:    max(len(ts.runningTasks)-running, 0),
+		Accepting: ts.phase == models.WorkerPhaseAccepting,
+		Phase:     ts.phase,
+	}
+	if !ts.drainDeadline.IsZero() {
+		deadline := ts.drainDeadline
+		stats.DrainDeadline = &deadline
+	}
+	return stats
+}
+
+// RecoverUnfinished 将上次运行遗留的等待中与运行中任务标记为已中断，应在启动时、创建任务前调用
+//
+// 多个副本共享数据库时只处理本实例创建的任务、租约已过期的任务，以及未记录实例的旧任务，
+// 其他副本仍在续约的任务不受影响。
+func (ts *TaskService) RecoverUnfinished(ctx context.Context) (int64, error) {
+	count, err := ts.store.Tasks().InterruptUnfinished(ctx, ts.instanceID, time.Now(), "服务重启，任务被中断")
+	if err != nil {
+		return 0, fmt.Errorf("标记遗留任务失败: %v", err)
+	}
+	return count, nil
+}
+
+// Drain 分阶段停止任务服务
+//
+//  1. 停止接收新任务，排队中尚未开始的任务直接标记为已中断
+//  2. 等待执行中的任务在grace内完成，期间定期输出进度
+//  3. 超时或ctx取消后中断剩余任务，并将其标记为已中断
+//
+// 各阶段可通过WorkerStats（就绪检查）查看。
+func (ts *TaskService) Drain(ctx context.Context, grace time.Duration) {
+	ts.mu.Lock()
+	if ts.phase != models.WorkerPhaseAccepting {
+		ts.mu.Unlock()
+		return
+	}
+	ts.phase = models.WorkerPhaseDraining
+	ts.drainDeadline = time.Now().Add(grace)
+	queued := 0
+	for _, handle := range ts.runningTasks {
+		if !handle.started {
+			handle.cancel(errInterrupted)
+			queued++
+		}
+	}
+	running := len(ts.runningTasks) - queued
+	ts.mu.Unlock()
+
+	ts.logger.Infof("任务服务停止接收新任务，中断%d个排队中的任务，等待%d个执行中的任务完成（最长%s）", queued, running, grace)
+
+	done := make(chan struct{})
+	go func() {
+		ts.wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+wait:
+	for {
+		select {
+		case <-done:
+			ts.logger.Info("所有任务已结束")
+			ts.setPhase(models.WorkerPhaseStopped)
+			return
+		case <-ticker.C:
+			stats := ts.WorkerStats()
+			ts.logger.Infof("等待%d个执行中的任务完成，剩余%s", stats.Running, time.Until(ts.drainDeadline).Round(time.Second))
+		case <-timer.C:
+			break wait
+		case <-ctx.Done():
+			ts.logger.Warn("收到强制关闭信号，停止等待")
+			break wait
+		}
+	}
+
+	// 中断剩余任务
+	ts.mu.Lock()
+	ts.phase = models.WorkerPhaseInterrupting
+	remaining := make([]string, 0, len(ts.runningTasks))
+	for taskID, handle := range ts.runningTasks {
+		ts.logger.With("task_id", taskID).Warnf("正在中断任务: %s", taskID)
+		handle.cancel(errInterrupted)
+		remaining = append(remaining, taskID)
+	}
+	ts.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(interruptWait):
+		ts.logger.Warnf("部分任务未能在%s内退出", interruptWait)
+	}
+
+	// 兜底：未能及时退出的任务同样标记为已中断（已记录结束状态的任务不受影响）
+	for _, taskID := range remaining {
+		ts.interruptTask(taskID, "服务关闭，任务被中断")
+	}
+	ts.logger.Infof("已中断%d个任务", len(remaining))
+	ts.setPhase(models.WorkerPhaseStopped)
+}
+
+func (ts *TaskService) setPhase(phase string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.phase = phase
+}
+
+// interruptTask 将任务标记为已中断，任务已结束时忽略
+func (ts *TaskService) interruptTask(taskID, message string) {
+	err := ts.store.Tasks().Interrupt(context.Background(), taskID, message)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		ts.logger.With("task_id", taskID).Errorf("标记任务中断失败: %s, 错误: %v", taskID, err)
+	}
+}
+
+// Close 关闭服务，仍在执行的任务会被立即中断，需要等待任务完成时先调用Drain
 func (ts *TaskService) Close() error {
-	// 取消所有正在运行的任务
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	for taskID, cancelFunc := range ts.runningTasks {
-		ts.logger.Infof("正在取消任务: %s", taskID)
-		cancelFunc()
+	ts.phase = models.WorkerPhaseStopped
+	if ts.leaseStop != nil {
+		close(ts.leaseStop)
+		<-ts.leaseDone
+		ts.leaseStop = nil
+	}
+
+	for taskID, handle := range ts.runningTasks {
+		ts.logger.With("task_id", taskID).Infof("正在中断任务: %s", taskID)
+		handle.cancel(errInterrupted)
 	}
 
 	if ts.imageService != nil {
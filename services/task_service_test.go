@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+	"docker-helper/storage/sqlite"
+	"docker-helper/utils"
+)
+
+// newDrainService 创建只执行测试任务的任务服务，同时最多执行limit个任务
+func newDrainService(t *testing.T, limit int) (*TaskService, storage.Store) {
+	t.Helper()
+	store, err := sqlite.New(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ts := &TaskService{
+		store:        store,
+		logger:       utils.NewLogger("task"),
+		runningTasks: make(map[string]*taskHandle),
+		phase:        models.WorkerPhaseAccepting,
+		limiter:      newTaskLimiter(func() int { return limit }),
+	}
+	return ts, store
+}
+
+// submitTask 创建等待中的任务并提交run执行
+func submitTask(t *testing.T, ts *TaskService, id string, run func(ctx context.Context)) error {
+	t.Helper()
+	if err := ts.store.Tasks().Create(context.Background(), &models.Task{ID: id, SourceImage: "nginx:" + id, Status: models.TaskStatusPending}); err != nil {
+		t.Fatalf("Create %s: %v", id, err)
+	}
+	return ts.submit(context.Background(), id, run)
+}
+
+// taskStatus 查询任务状态
+func taskStatus(t *testing.T, store storage.Store, id string) string {
+	t.Helper()
+	task, err := store.Tasks().Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get %s: %v", id, err)
+	}
+	return task.Status
+}
+
+// waitFor 等待cond成立，超时则失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDrainWaitsForRunningTasks(t *testing.T) {
+	ts, store := newDrainService(t, 1)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	err := submitTask(t, ts, "running", func(ctx context.Context) {
+		close(started)
+		<-release
+		store.Tasks().UpdateProgress(context.Background(), &models.TaskProgressUpdate{TaskID: "running", Status: models.TaskStatusCompleted})
+	})
+	if err != nil {
+		t.Fatalf("submit running: %v", err)
+	}
+	<-started
+	if err := submitTask(t, ts, "queued", func(ctx context.Context) { t.Error("排队中的任务不应开始执行") }); err != nil {
+		t.Fatalf("submit queued: %v", err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		ts.Drain(context.Background(), time.Minute)
+		close(drained)
+	}()
+
+	// 阶段1：停止接收新任务，排队中的任务标记为已中断，执行中的任务继续
+	waitFor(t, "排队任务被中断", func() bool { return taskStatus(t, store, "queued") == models.TaskStatusInterrupted })
+	if stats := ts.WorkerStats(); stats.Phase != models.WorkerPhaseDraining || stats.Accepting || stats.Running != 1 || stats.DrainDeadline == nil {
+		t.Fatalf("排空中 WorkerStats = %+v, want draining、不接收新任务、1个执行中", stats)
+	}
+	if err := submitTask(t, ts, "late", func(ctx context.Context) {}); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("排空中提交 = %v, want ErrShuttingDown", err)
+	}
+	if status := taskStatus(t, store, "late"); status != models.TaskStatusInterrupted {
+		t.Errorf("排空中提交的任务状态 = %s, want interrupted", status)
+	}
+
+	// 阶段2：执行中的任务在宽限期内完成后结束
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务完成后Drain未返回")
+	}
+	if status := taskStatus(t, store, "running"); status != models.TaskStatusCompleted {
+		t.Errorf("执行中任务状态 = %s, want completed", status)
+	}
+	if phase := ts.WorkerStats().Phase; phase != models.WorkerPhaseStopped {
+		t.Errorf("Drain结束后 Phase = %s, want stopped", phase)
+	}
+}
+
+func TestDrainInterruptsAfterGrace(t *testing.T) {
+	ts, store := newDrainService(t, 0)
+
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	err := submitTask(t, ts, "slow", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	<-started
+
+	// 阶段3：宽限期结束后中断剩余任务并标记为已中断
+	ts.Drain(context.Background(), 20*time.Millisecond)
+
+	if err := <-cause; !errors.Is(err, errInterrupted) {
+		t.Errorf("任务取消原因 = %v, want errInterrupted", err)
+	}
+	if status := taskStatus(t, store, "slow"); status != models.TaskStatusInterrupted {
+		t.Errorf("宽限期后任务状态 = %s, want interrupted", status)
+	}
+	if phase := ts.WorkerStats().Phase; phase != models.WorkerPhaseStopped {
+		t.Errorf("Drain结束后 Phase = %s, want stopped", phase)
+	}
+
+	// 再次调用不重复执行
+	ts.Drain(context.Background(), time.Minute)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-helper/config"
+	"docker-helper/models"
+	"docker-helper/tracing"
+	"docker-helper/utils"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// createTargetsTask 创建多目标转换任务：拉取一次源镜像，推送到多个已保存的仓库配置
+func (ts *TaskService) createTargetsTask(ctx context.Context, req *models.TransformRequest, createdBy string) (resp *models.TaskCreateResponse, err error) {
+	targets, pushTargets, err := ts.resolveTransformTargets(req.SourceImage, req.Targets)
+	if err != nil {
+		return nil, err
+	}
+	referrerTypes, err := resolveReferrerTypes(req.ReferrerTypes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pushTargets {
+		pushTargets[i].ReferrerTypes = referrerTypes
+	}
+	if err := CheckSourcePolicy(ctx, req.SourceImage, pushTargets); err != nil {
+		return nil, err
+	}
+
+	taskID := uuid.New().String()
+
+	ctx, span := tracing.Start(ctx, "TaskService.CreateTask",
+		attribute.String("task_id", taskID), attribute.Int("targets", len(targets)))
+	defer func() { tracing.End(span, err) }()
+
+	images := make([]string, 0, len(targets))
+	hosts := make([]string, 0, len(targets))
+	usernames := make([]string, 0, len(targets))
+	for i, target := range targets {
+		images = append(images, target.TargetImage)
+		hosts = append(hosts, target.TargetHost)
+		usernames = append(usernames, pushTargets[i].Username)
+	}
+
+	stepMessage := models.TaskStepMessages[models.TaskStepInit]
+	err = ts.store.Tasks().Create(context.Background(), ts.leased(&models.Task{
+		ID:             taskID,
+		Type:           models.TaskTypeTransform,
+		SourceImage:    req.SourceImage,
+		TargetImage:    strings.Join(images, ", "),
+		TargetHost:     strings.Join(hosts, ", "),
+		TargetUsername: strings.Join(usernames, ", "),
+		Targets:        targets,
+		CreatedBy:      &createdBy,
+		Status:         models.TaskStatusPending,
+		CurrentStep:    models.TaskStepInit,
+		StepMessage:    &stepMessage,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("创建任务记录失败: %v", err)
+	}
+
+	err = ts.submit(ctx, taskID, func(ctx context.Context) {
+		ts.executeTransformTargets(ctx, taskID, req.SourceImage, targets, pushTargets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ts.logger.WithContext(ctx).With("task_id", taskID).Infof("多目标任务创建成功: %s, 源镜像: %s, 目标镜像: %v", taskID, req.SourceImage, images)
+
+	return &models.TaskCreateResponse{
+		TaskID:  taskID,
+		Status:  models.TaskStatusPending,
+		Message: fmt.Sprintf("任务已创建，将推送到 %d 个目标，正在后台执行", len(targets)),
+	}, nil
+}
+
+// resolveTransformTargets 按仓库配置与命名规则生成各目标的镜像名称并取得凭据
+func (ts *TaskService) resolveTransformTargets(sourceImage string, requested []models.TransformTarget) ([]models.TaskTarget, []PushTarget, error) {
+	if err := utils.ValidateImageName(sourceImage); err != nil {
+		return nil, nil, fmt.Errorf("源镜像名称无效: %v", err)
+	}
+
+	targets := make([]models.TaskTarget, 0, len(requested))
+	pushTargets := make([]PushTarget, 0, len(requested))
+	seen := make(map[string]bool)
+	for i, target := range requested {
+		naming := target.Naming
+		if naming == "" {
+			naming = utils.NamingKeep
+		}
+		if naming != utils.NamingKeep && naming != utils.NamingFlatten {
+			return nil, nil, fmt.Errorf("targets[%d] 的 naming 必须是 keep/flatten，当前为 %q", i, target.Naming)
+		}
+		if target.Path != "" {
+			if err := utils.ValidateImageName(strings.TrimPrefix(target.Path, "/")); err != nil {
+				return nil, nil, fmt.Errorf("targets[%d] 的路径 %q 无效: %v", i, target.Path, err)
+			}
+		}
+
+		registry, err := ts.getRegistryConfig(target.ConfigID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取仓库配置 %s 失败: %v", target.ConfigID, err)
+		}
+		password, err := ts.crypto.DecryptPassword(registry.PasswordEncrypted)
+		if err != nil {
+			return nil, nil, fmt.Errorf("解密仓库配置 %s 的密码失败: %v", registry.Name, err)
+		}
+
+		namespace := config.Current().TargetNamespace
+		if target.Namespace != nil {
+			namespace = *target.Namespace
+		}
+		image := utils.TargetImageName(sourceImage, registry.RegistryURL, namespace, naming, target.Path)
+		if seen[image] {
+			return nil, nil, fmt.Errorf("目标镜像重复: %s", image)
+		}
+		seen[image] = true
+
+		targets = append(targets, models.TaskTarget{
+			ConfigID:    registry.ID,
+			Name:        registry.Name,
+			TargetHost:  registry.RegistryURL,
+			TargetImage: image,
+			Status:      models.TaskStatusPending,
+		})
+		pushTargets = append(pushTargets, PushTarget{Image: image, Username: registry.Username, Password: password, ConfigID: registry.ID})
+	}
+	return targets, pushTargets, nil
+}
+
+// executeTransformTargets 执行多目标转换任务，每个目标的结果随任务记录更新
+func (ts *TaskService) executeTransformTargets(ctx context.Context, taskID, sourceImage string, targets []models.TaskTarget, pushTargets []PushTarget) {
+	// 每个目标按一个任务的时长计算超时
+	ctx, cancelTimeout := context.WithTimeout(ctx, config.Current().TaskTimeout*time.Duration(len(targets)))
+	defer cancelTimeout()
+
+	ctx, span := tracing.Start(ctx, "task.execute_targets",
+		attribute.String("task_id", taskID),
+		attribute.String("source_image", sourceImage),
+		attribute.Int("targets", len(targets)),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+		TaskID:      taskID,
+		Status:      models.TaskStatusRunning,
+		Progress:    5,
+		CurrentStep: 1,
+		StepMessage: "验证镜像",
+	})
+	ts.updateStartTime(taskID)
+
+	startTime := time.Now()
+
+	progressCallback := func(step int, stepName string, progress int) {
+		ts.updateTaskProgress(taskID, &models.TaskProgressUpdate{
+			TaskID:      taskID,
+			Status:      models.TaskStatusRunning,
+			Progress:    progress,
+			CurrentStep: step,
+			StepMessage: stepName,
+		})
+	}
+
+	// 目标在各自的协程中结束，更新后整体写回任务记录
+	var mu sync.Mutex
+	saveTargets := func() {
+		if err := ts.store.Tasks().SetTargets(context.Background(), taskID, targets); err != nil {
+			ts.logger.With("task_id", taskID).Errorf("更新任务目标状态失败: %s, 错误: %v", taskID, err)
+		}
+	}
+	report := func(i int, status string, duration int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		targets[i].Status = status
+		targets[i].Duration = duration
+		if err != nil {
+			targets[i].ErrorMsg = err.Error()
+		}
+		saveTargets()
+	}
+
+	err = ts.imageService.TransformImageToTargets(ctx, sourceImage, pushTargets, config.Current().PushConcurrency,
+		progressCallback, report, ts.mountCallback(taskID))
+
+	// 拉取失败或被取消时，尚未开始推送的目标随任务结束
+	mu.Lock()
+	changed := false
+	for i := range targets {
+		if targets[i].Status != models.TaskStatusPending && targets[i].Status != models.TaskStatusRunning {
+			continue
+		}
+		changed = true
+		if errors.Is(ctx.Err(), context.Canceled) {
+			targets[i].Status = models.TaskStatusCancelled
+		} else {
+			targets[i].Status = models.TaskStatusFailed
+			if err != nil {
+				targets[i].ErrorMsg = err.Error()
+			}
+		}
+	}
+	if changed {
+		saveTargets()
+	}
+	mu.Unlock()
+
+	ts.finishTask(ctx, taskID, err, startTime, "转换失败", "转换完成")
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"docker-helper/storage"
+	"docker-helper/storage/storagetest"
+)
+
+// schemaSeq 为每个用例生成不重复的schema名
+var schemaSeq atomic.Int64
+
+// TestContract 需要通过 TEST_POSTGRES_DSN 指定一个可创建schema的PostgreSQL实例，未设置时跳过
+func TestContract(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("未设置 TEST_POSTGRES_DSN")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		// 每个用例在独立的schema中迁移，互不影响
+		schema := fmt.Sprintf("dh_test_%d_%d", time.Now().UnixNano(), schemaSeq.Add(1))
+		if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+				t.Errorf("删除schema失败: %v", err)
+			}
+		})
+
+		store, err := New(withSearchPath(dsn, schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+// withSearchPath 在连接串中设置search_path，支持URL与 key=value 两种格式
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		parsed, err := url.Parse(dsn)
+		if err == nil {
+			query := parsed.Query()
+			query.Set("search_path", schema)
+			parsed.RawQuery = query.Encode()
+			return parsed.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
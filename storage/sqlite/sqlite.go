@@ -0,0 +1,26 @@
+// Package sqlite 基于SQLite文件的存储实现，适用于单实例部署
+package sqlite
+
+import (
+	"strings"
+
+	"docker-helper/database"
+	"docker-helper/storage/sqlstore"
+)
+
+// New 打开（必要时创建）SQLite数据库文件并应用迁移
+func New(path string) (*sqlstore.Store, error) {
+	// 并发写入时等待锁释放，而不是立即返回 database is locked；
+	// WAL模式下流式导出等长时间读取不会阻塞任务写入
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := database.Open(database.DriverSQLite, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlstore.New(db, sqlstore.SQLite{}), nil
+}
@@ -0,0 +1,146 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+
+	"docker-helper/models"
+)
+
+// auditColumns 审计事件查询的列顺序，与scanAuditEvent保持一致
+const auditColumns = `id, actor, source_ip, action, resource, before_summary, after_summary,
+	result, status_code, message, created_at`
+
+type auditRepository struct {
+	*base
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var sourceIP, resource, message *string
+
+	err := row.Scan(
+		&event.ID, &event.Actor, &sourceIP, &event.Action, &resource, &event.Before, &event.After,
+		&event.Result, &event.StatusCode, &message, &event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceIP != nil {
+		event.SourceIP = *sourceIP
+	}
+	if resource != nil {
+		event.Resource = *resource
+	}
+	if message != nil {
+		event.Message = *message
+	}
+
+	return &event, nil
+}
+
+func (r *auditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (
+			actor, source_ip, action, resource, before_summary, after_summary,
+			result, status_code, message
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.exec(ctx, query,
+		event.Actor, event.SourceIP, event.Action, event.Resource, event.Before, event.After,
+		event.Result, event.StatusCode, event.Message)
+	return err
+}
+
+func (r *auditRepository) List(ctx context.Context, q *models.AuditQuery) ([]*models.AuditEvent, int, error) {
+	where, args := r.buildWhere(q)
+
+	var total int
+	if err := r.queryRow(ctx, "SELECT COUNT(*) FROM audit_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_events" + where + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	rows, err := r.query(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := make([]*models.AuditEvent, 0)
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, event)
+	}
+
+	return items, total, rows.Err()
+}
+
+func (r *auditRepository) Each(ctx context.Context, q *models.AuditQuery, fn func(*models.AuditEvent) error) error {
+	where, args := r.buildWhere(q)
+
+	rows, err := r.query(ctx, "SELECT "+auditColumns+" FROM audit_events"+where+" ORDER BY id ASC", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// buildWhere 根据查询条件构建WHERE子句
+func (r *auditRepository) buildWhere(q *models.AuditQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, q.Actor)
+	}
+	if q.Action != "" {
+		// 支持前缀匹配，如 registry 匹配 registry.create/registry.update
+		conditions = append(conditions, "(action = ? OR action LIKE ?)")
+		args = append(args, q.Action, q.Action+".%")
+	}
+	if q.Resource != "" {
+		conditions = append(conditions, "resource LIKE ?")
+		args = append(args, "%"+q.Resource+"%")
+	}
+	if q.Result != "" {
+		conditions = append(conditions, "result = ?")
+		args = append(args, q.Result)
+	}
+	if q.SourceIP != "" {
+		conditions = append(conditions, "source_ip = ?")
+		args = append(args, q.SourceIP)
+	}
+	if q.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, r.dialect.TimeArg(*q.From))
+	}
+	if q.To != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, r.dialect.TimeArg(*q.To))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
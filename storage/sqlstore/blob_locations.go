@@ -0,0 +1,66 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"docker-helper/models"
+)
+
+type blobLocationRepository struct {
+	*base
+}
+
+func (r *blobLocationRepository) Record(ctx context.Context, locations []*models.BlobLocation) error {
+	if len(locations) == 0 {
+		return nil
+	}
+
+	query := r.dialect.Rebind(`
+		INSERT INTO blob_locations (registry, repository, digest, size, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (registry, digest, repository) DO UPDATE SET
+			size = excluded.size,
+			updated_at = excluded.updated_at
+	`)
+	now := r.dialect.TimeArg(time.Now())
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		for _, location := range locations {
+			if _, err := tx.ExecContext(ctx, query, location.Registry, location.Repository, location.Digest, location.Size, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *blobLocationRepository) Locate(ctx context.Context, registry, digest string) ([]*models.BlobLocation, error) {
+	rows, err := r.query(ctx, `
+		SELECT registry, repository, digest, size, updated_at FROM blob_locations
+		WHERE registry = ? AND digest = ?
+		ORDER BY updated_at DESC, repository ASC
+	`, registry, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []*models.BlobLocation
+	for rows.Next() {
+		var location models.BlobLocation
+		if err := rows.Scan(&location.Registry, &location.Repository, &location.Digest, &location.Size, &location.UpdatedAt); err != nil {
+			return nil, err
+		}
+		locations = append(locations, &location)
+	}
+	return locations, rows.Err()
+}
+
+func (r *blobLocationRepository) Forget(ctx context.Context, registry, repository, digest string) error {
+	result, err := r.exec(ctx, "DELETE FROM blob_locations WHERE registry = ? AND repository = ? AND digest = ?", registry, repository, digest)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
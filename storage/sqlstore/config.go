@@ -0,0 +1,60 @@
+package sqlstore
+
+import (
+	"context"
+
+	"docker-helper/models"
+)
+
+type configRepository struct {
+	*base
+}
+
+func (r *configRepository) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	if err := r.queryRow(ctx, "SELECT value FROM config WHERE key = ?", key).Scan(&value); err != nil {
+		return "", notFound(err)
+	}
+	return value, nil
+}
+
+func (r *configRepository) Set(ctx context.Context, key, value string) error {
+	// INSERT ... ON CONFLICT 在SQLite(3.24+)与PostgreSQL中语法一致
+	query := `
+		INSERT INTO config (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.exec(ctx, query, key, value)
+	return err
+}
+
+type userRepository struct {
+	*base
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	if err := row.Scan(&user.Username, &user.Token, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Get(ctx context.Context, username string) (*models.User, error) {
+	return scanUser(r.queryRow(ctx,
+		"SELECT username, token, created_at, updated_at FROM users WHERE username = ?", username))
+}
+
+func (r *userRepository) GetByToken(ctx context.Context, token string) (*models.User, error) {
+	return scanUser(r.queryRow(ctx,
+		"SELECT username, token, created_at, updated_at FROM users WHERE token = ?", token))
+}
+
+func (r *userRepository) UpdateToken(ctx context.Context, username, token string) error {
+	query := "UPDATE users SET token = ?, updated_at = CURRENT_TIMESTAMP WHERE username = ?"
+	result, err := r.exec(ctx, query, token, username)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,73 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqliteTimeLayout 与SQLite CURRENT_TIMESTAMP一致的时间格式（UTC）
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// Dialect 屏蔽不同数据库在SQL语法上的差异
+type Dialect interface {
+	// Name 驱动名称
+	Name() string
+	// Rebind 将 ? 占位符转换为数据库支持的形式
+	Rebind(query string) string
+	// TimeArg 转换时间参数，保证与 CURRENT_TIMESTAMP 写入的值可比较
+	TimeArg(t time.Time) interface{}
+	// CaseInsensitiveLike 不区分大小写的模糊匹配操作符
+	CaseInsensitiveLike() string
+	// DateExpr 将时间列格式化为 YYYY-MM-DD 文本
+	DateExpr(column string) string
+}
+
+// SQLite SQLite方言
+type SQLite struct{}
+
+func (SQLite) Name() string { return "sqlite" }
+
+func (SQLite) Rebind(query string) string { return query }
+
+func (SQLite) TimeArg(t time.Time) interface{} { return t.UTC().Format(sqliteTimeLayout) }
+
+func (SQLite) CaseInsensitiveLike() string { return "LIKE" }
+
+func (SQLite) DateExpr(column string) string { return "DATE(" + column + ")" }
+
+// Postgres PostgreSQL方言
+type Postgres struct{}
+
+func (Postgres) Name() string { return "postgres" }
+
+// Rebind 将 ? 依次替换为 $1, $2 ...（跳过字符串字面量中的问号）
+func (Postgres) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			inString = !inString
+			b.WriteByte(ch)
+		case ch == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
+
+func (Postgres) TimeArg(t time.Time) interface{} { return t.UTC() }
+
+func (Postgres) CaseInsensitiveLike() string { return "ILIKE" }
+
+func (Postgres) DateExpr(column string) string { return "TO_CHAR(" + column + ", 'YYYY-MM-DD')" }
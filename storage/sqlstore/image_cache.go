@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"docker-helper/models"
+)
+
+// imageCacheColumns 镜像缓存查询的列顺序，与scanCachedImage保持一致
+const imageCacheColumns = "image, image_id, size, hits, created_at, last_used_at"
+
+type imageCacheRepository struct {
+	*base
+}
+
+func scanCachedImage(row rowScanner) (*models.CachedImage, error) {
+	var entry models.CachedImage
+	if err := row.Scan(&entry.Image, &entry.ImageID, &entry.Size, &entry.Hits, &entry.CreatedAt, &entry.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *imageCacheRepository) List(ctx context.Context, instanceID string) ([]*models.CachedImage, error) {
+	rows, err := r.query(ctx, "SELECT "+imageCacheColumns+" FROM image_cache WHERE instance_id = ? ORDER BY last_used_at ASC, image ASC", instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.CachedImage
+	for rows.Next() {
+		entry, err := scanCachedImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *imageCacheRepository) Get(ctx context.Context, instanceID, image string) (*models.CachedImage, error) {
+	entry, err := scanCachedImage(r.queryRow(ctx, "SELECT "+imageCacheColumns+" FROM image_cache WHERE instance_id = ? AND image = ?", instanceID, image))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return entry, nil
+}
+
+func (r *imageCacheRepository) Touch(ctx context.Context, instanceID string, entry *models.CachedImage, hit bool) error {
+	lastUsed := entry.LastUsedAt
+	if lastUsed.IsZero() {
+		lastUsed = time.Now()
+	}
+	hits := 0
+	if hit {
+		hits = 1
+	}
+
+	query := `
+		INSERT INTO image_cache (instance_id, image, image_id, size, hits, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)
+		ON CONFLICT (instance_id, image) DO UPDATE SET
+			image_id = excluded.image_id,
+			size = excluded.size,
+			hits = image_cache.hits + ?,
+			last_used_at = excluded.last_used_at
+	`
+	_, err := r.exec(ctx, query, instanceID, entry.Image, entry.ImageID, entry.Size,
+		r.dialect.TimeArg(lastUsed), r.dialect.TimeArg(lastUsed), hits)
+	return err
+}
+
+func (r *imageCacheRepository) Delete(ctx context.Context, instanceID, image string) error {
+	result, err := r.exec(ctx, "DELETE FROM image_cache WHERE instance_id = ? AND image = ?", instanceID, image)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *imageCacheRepository) Claim(ctx context.Context, instanceID string) (int64, error) {
+	query := `
+		UPDATE image_cache SET instance_id = ?
+		WHERE instance_id = '' AND image NOT IN (SELECT image FROM image_cache WHERE instance_id = ?)
+	`
+	result, err := r.exec(ctx, query, instanceID, instanceID)
+	if err != nil {
+		return 0, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// 剩余的是本实例已有同名记录的旧记录
+	if _, err := r.exec(ctx, "DELETE FROM image_cache WHERE instance_id = '' AND image IN (SELECT image FROM image_cache WHERE instance_id = ?)", instanceID); err != nil {
+		return claimed, err
+	}
+	return claimed, nil
+}
@@ -0,0 +1,141 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"docker-helper/models"
+)
+
+// registryColumns 仓库配置查询的列顺序，与scanRegistryConfig保持一致
+const registryColumns = `id, name, registry_url, username, password_encrypted, status,
+	last_test_time, is_default, created_at`
+
+type registryConfigRepository struct {
+	*base
+}
+
+func scanRegistryConfig(row rowScanner) (*models.RegistryConfig, error) {
+	var config models.RegistryConfig
+	var status sql.NullString
+	err := row.Scan(
+		&config.ID, &config.Name, &config.RegistryURL, &config.Username, &config.PasswordEncrypted,
+		&status, &config.LastTestTime, &config.IsDefault, &config.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	config.Status = status.String
+	return &config, nil
+}
+
+func (r *registryConfigRepository) List(ctx context.Context) ([]*models.RegistryConfig, error) {
+	query := "SELECT " + registryColumns + " FROM registry_configs ORDER BY is_default DESC, created_at DESC"
+	rows, err := r.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []*models.RegistryConfig
+	for rows.Next() {
+		config, err := scanRegistryConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, rows.Err()
+}
+
+func (r *registryConfigRepository) Get(ctx context.Context, id string) (*models.RegistryConfig, error) {
+	config, err := scanRegistryConfig(r.queryRow(ctx, "SELECT "+registryColumns+" FROM registry_configs WHERE id = ?", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return config, nil
+}
+
+func (r *registryConfigRepository) Create(ctx context.Context, config *models.RegistryConfig) error {
+	if config.Status == "" {
+		config.Status = "pending"
+	}
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		// 如果设置为默认配置，先清除其他默认配置
+		if config.IsDefault {
+			if _, err := tx.ExecContext(ctx, "UPDATE registry_configs SET is_default = FALSE"); err != nil {
+				return err
+			}
+		}
+
+		query := r.dialect.Rebind(`
+			INSERT INTO registry_configs (id, name, registry_url, username, password_encrypted, status, is_default)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`)
+		_, err := tx.ExecContext(ctx, query,
+			config.ID, config.Name, config.RegistryURL, config.Username, config.PasswordEncrypted,
+			config.Status, config.IsDefault)
+		return err
+	})
+}
+
+func (r *registryConfigRepository) Update(ctx context.Context, config *models.RegistryConfig) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		// 如果设置为默认配置，先清除其他默认配置
+		if config.IsDefault {
+			clear := r.dialect.Rebind("UPDATE registry_configs SET is_default = FALSE WHERE id != ?")
+			if _, err := tx.ExecContext(ctx, clear, config.ID); err != nil {
+				return err
+			}
+		}
+
+		var query string
+		var args []interface{}
+		if config.PasswordEncrypted != "" {
+			// 更新密码后需要重新验证连接
+			query = `
+				UPDATE registry_configs
+				SET name = ?, registry_url = ?, username = ?, password_encrypted = ?, is_default = ?, status = 'pending'
+				WHERE id = ?
+			`
+			args = []interface{}{config.Name, config.RegistryURL, config.Username, config.PasswordEncrypted, config.IsDefault, config.ID}
+		} else {
+			query = `
+				UPDATE registry_configs
+				SET name = ?, registry_url = ?, username = ?, is_default = ?
+				WHERE id = ?
+			`
+			args = []interface{}{config.Name, config.RegistryURL, config.Username, config.IsDefault, config.ID}
+		}
+
+		result, err := tx.ExecContext(ctx, r.dialect.Rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result)
+	})
+}
+
+func (r *registryConfigRepository) Delete(ctx context.Context, id string) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM signing_keys WHERE config_id = ?"), id); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM registry_configs WHERE id = ?"), id)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result)
+	})
+}
+
+func (r *registryConfigRepository) UpdateStatus(ctx context.Context, id, status string) error {
+	query := "UPDATE registry_configs SET status = ?, last_test_time = CURRENT_TIMESTAMP WHERE id = ?"
+	result, err := r.exec(ctx, query, status, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,49 @@
+package sqlstore
+
+import (
+	"context"
+
+	"docker-helper/models"
+)
+
+type signingKeyRepository struct {
+	*base
+}
+
+func (r *signingKeyRepository) List(ctx context.Context, configID string) ([]*models.SigningKey, error) {
+	rows, err := r.query(ctx, `
+		SELECT id, config_id, name, public_key, algorithm, fingerprint, created_at FROM signing_keys
+		WHERE config_id = ?
+		ORDER BY created_at, id
+	`, configID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.SigningKey
+	for rows.Next() {
+		var key models.SigningKey
+		if err := rows.Scan(&key.ID, &key.ConfigID, &key.Name, &key.PublicKey, &key.Algorithm, &key.Fingerprint, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.exec(ctx, `
+		INSERT INTO signing_keys (id, config_id, name, public_key, algorithm, fingerprint)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, key.ID, key.ConfigID, key.Name, key.PublicKey, key.Algorithm, key.Fingerprint)
+	return err
+}
+
+func (r *signingKeyRepository) Delete(ctx context.Context, configID, id string) error {
+	result, err := r.exec(ctx, "DELETE FROM signing_keys WHERE config_id = ? AND id = ?", configID, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
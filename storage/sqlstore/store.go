@@ -0,0 +1,160 @@
+// Package sqlstore 基于database/sql的仓储实现，通过Dialect同时支持SQLite与PostgreSQL
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"docker-helper/metrics"
+	"docker-helper/storage"
+)
+
+// Store storage.Store 的SQL实现
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	base    *base
+
+	tasks    *taskRepository
+	registry *registryConfigRepository
+	config   *configRepository
+	users    *userRepository
+	audit    *auditRepository
+	cache    *imageCacheRepository
+	blobs    *blobLocationRepository
+	keys     *signingKeyRepository
+}
+
+// New 使用已完成迁移的数据库连接创建Store
+func New(db *sql.DB, dialect Dialect) *Store {
+	base := &base{db: db, dialect: dialect}
+	return &Store{
+		db:       db,
+		dialect:  dialect,
+		base:     base,
+		tasks:    &taskRepository{base},
+		registry: &registryConfigRepository{base},
+		config:   &configRepository{base},
+		users:    &userRepository{base},
+		audit:    &auditRepository{base},
+		cache:    &imageCacheRepository{base},
+		blobs:    &blobLocationRepository{base},
+		keys:     &signingKeyRepository{base},
+	}
+}
+
+func (s *Store) Tasks() storage.TaskRepository                     { return s.tasks }
+func (s *Store) RegistryConfigs() storage.RegistryConfigRepository { return s.registry }
+func (s *Store) Config() storage.ConfigRepository                  { return s.config }
+func (s *Store) Users() storage.UserRepository                     { return s.users }
+func (s *Store) Audit() storage.AuditRepository                    { return s.audit }
+func (s *Store) ImageCache() storage.ImageCacheRepository          { return s.cache }
+func (s *Store) BlobLocations() storage.BlobLocationRepository     { return s.blobs }
+func (s *Store) SigningKeys() storage.SigningKeyRepository         { return s.keys }
+
+// Ping 检查数据库连接
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckWrite 更新health_check表的唯一一行
+func (s *Store) CheckWrite(ctx context.Context) error {
+	result, err := s.base.exec(ctx, "UPDATE health_check SET checked_at = ? WHERE id = 1", s.dialect.TimeArg(time.Now()))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// Close 关闭数据库连接
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB 返回底层连接，供健康检查等需要直接访问数据库的场景使用
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// base 各仓储共享的连接与方言
+type base struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func (b *base) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := b.db.ExecContext(ctx, b.dialect.Rebind(query), args...)
+	recordError("exec", err)
+	return result, err
+}
+
+func (b *base) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := b.db.QueryContext(ctx, b.dialect.Rebind(query), args...)
+	recordError("query", err)
+	return rows, err
+}
+
+func (b *base) queryRow(ctx context.Context, query string, args ...interface{}) rowScanner {
+	return &row{b.db.QueryRowContext(ctx, b.dialect.Rebind(query), args...)}
+}
+
+// withTx 在事务中执行fn，fn返回错误时回滚
+func (b *base) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rowScanner 兼容sql.Row与sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// row 包装sql.Row，在Scan出错时记录数据库错误指标
+type row struct {
+	*sql.Row
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	recordError("query", err)
+	return err
+}
+
+// recordError 记录数据库错误指标，记录不存在与请求取消不计入
+func recordError(operation string, err error) {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) {
+		return
+	}
+	metrics.DBErrors.WithLabelValues(operation).Inc()
+}
+
+// notFound 将sql.ErrNoRows转换为storage.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// requireAffected 未影响任何行时返回storage.ErrNotFound
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,587 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"docker-helper/models"
+)
+
+// taskColumns 任务查询的列顺序，与scanTask保持一致
+const taskColumns = `id, source_image, target_image, target_host, target_username, config_id, created_by,
+	status, progress, current_step, step_message, error_msg, duration,
+	created_at, started_at, completed_at,
+	type, images, bundle_format, artifact_size, artifact_sha256, targets, mounted_bytes,
+	instance_id, lease_expires_at`
+
+// historyStatuses 视为历史记录的任务状态
+const historyStatuses = "('completed', 'failed', 'cancelled', 'interrupted')"
+
+type taskRepository struct {
+	*base
+}
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var task models.Task
+	var images, targets sql.NullString
+	err := row.Scan(
+		&task.ID, &task.SourceImage, &task.TargetImage, &task.TargetHost, &task.TargetUsername, &task.ConfigID, &task.CreatedBy,
+		&task.Status, &task.Progress, &task.CurrentStep, &task.StepMessage, &task.ErrorMsg, &task.Duration,
+		&task.CreatedAt, &task.StartedAt, &task.CompletedAt,
+		&task.Type, &images, &task.BundleFormat, &task.ArtifactSize, &task.ArtifactSHA256, &targets, &task.MountedBytes,
+		&task.InstanceID, &task.LeaseExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if images.Valid && images.String != "" {
+		if err := json.Unmarshal([]byte(images.String), &task.Images); err != nil {
+			return nil, fmt.Errorf("解析任务镜像列表失败: %v", err)
+		}
+	}
+	if targets.Valid && targets.String != "" {
+		if err := json.Unmarshal([]byte(targets.String), &task.Targets); err != nil {
+			return nil, fmt.Errorf("解析任务目标列表失败: %v", err)
+		}
+	}
+	return &task, nil
+}
+
+func (r *taskRepository) queryTasks(ctx context.Context, query string, args ...interface{}) ([]*models.Task, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (r *taskRepository) Create(ctx context.Context, task *models.Task) error {
+	query := `
+		INSERT INTO tasks (
+			id, source_image, target_image, target_host, target_username, config_id, created_by,
+			status, progress, current_step, step_message,
+			type, images, bundle_format, targets,
+			instance_id, lease_expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	taskType := task.Type
+	if taskType == "" {
+		taskType = models.TaskTypeTransform
+	}
+	images, err := jsonColumn(task.Images)
+	if err != nil {
+		return err
+	}
+	targets, err := jsonColumn(task.Targets)
+	if err != nil {
+		return err
+	}
+	var leaseExpiresAt interface{}
+	if task.LeaseExpiresAt != nil {
+		leaseExpiresAt = r.dialect.TimeArg(*task.LeaseExpiresAt)
+	}
+
+	_, err = r.exec(ctx, query,
+		task.ID, task.SourceImage, task.TargetImage, task.TargetHost, task.TargetUsername, task.ConfigID, task.CreatedBy,
+		task.Status, task.Progress, task.CurrentStep, task.StepMessage,
+		taskType, images, task.BundleFormat, targets,
+		task.InstanceID, leaseExpiresAt)
+	return err
+}
+
+// jsonColumn 将列表编码为JSON文本列，列表为空时写入NULL
+func jsonColumn[T any](values []T) (*string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+func (r *taskRepository) Get(ctx context.Context, id string) (*models.Task, error) {
+	task, err := scanTask(r.queryRow(ctx, "SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return task, nil
+}
+
+func (r *taskRepository) UpdateProgress(ctx context.Context, update *models.TaskProgressUpdate) error {
+	query := `
+		UPDATE tasks SET
+			status = ?,
+			progress = ?,
+			current_step = ?,
+			step_message = ?,
+			error_msg = ?,
+			duration = ?
+		WHERE id = ?
+	`
+
+	_, err := r.exec(ctx, query,
+		update.Status, update.Progress, update.CurrentStep,
+		update.StepMessage, update.ErrorMsg, update.Duration, update.TaskID)
+	return err
+}
+
+func (r *taskRepository) MarkStarted(ctx context.Context, id string) error {
+	_, err := r.exec(ctx, "UPDATE tasks SET started_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+	return err
+}
+
+func (r *taskRepository) SetArtifact(ctx context.Context, id string, size int64, sha256 string) error {
+	result, err := r.exec(ctx, "UPDATE tasks SET artifact_size = ?, artifact_sha256 = ? WHERE id = ?", size, sha256, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *taskRepository) SetTargets(ctx context.Context, id string, targets []models.TaskTarget) error {
+	encoded, err := jsonColumn(targets)
+	if err != nil {
+		return err
+	}
+	result, err := r.exec(ctx, "UPDATE tasks SET targets = ? WHERE id = ?", encoded, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *taskRepository) AddMountedBytes(ctx context.Context, id string, size int64) error {
+	result, err := r.exec(ctx, "UPDATE tasks SET mounted_bytes = mounted_bytes + ? WHERE id = ?", size, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *taskRepository) MarkCompleted(ctx context.Context, id string) error {
+	_, err := r.exec(ctx, "UPDATE tasks SET completed_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+	return err
+}
+
+// finishUnfinishedQuery 将未结束的任务置为指定的结束状态
+const finishUnfinishedQuery = `
+	UPDATE tasks SET
+		status = ?,
+		step_message = ?,
+		completed_at = CURRENT_TIMESTAMP
+	WHERE status IN ('pending', 'running')
+`
+
+func (r *taskRepository) Cancel(ctx context.Context, id, message string) error {
+	result, err := r.exec(ctx, finishUnfinishedQuery+" AND id = ?", models.TaskStatusCancelled, message, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *taskRepository) Interrupt(ctx context.Context, id, message string) error {
+	result, err := r.exec(ctx, finishUnfinishedQuery+" AND id = ?", models.TaskStatusInterrupted, message, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (r *taskRepository) InterruptUnfinished(ctx context.Context, instanceID string, now time.Time, message string) (int64, error) {
+	query := finishUnfinishedQuery + `
+		AND (instance_id = ? OR instance_id IS NULL OR lease_expires_at IS NULL OR lease_expires_at < ?)
+	`
+	result, err := r.exec(ctx, query, models.TaskStatusInterrupted, message, instanceID, r.dialect.TimeArg(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *taskRepository) RenewLeases(ctx context.Context, instanceID string, until time.Time) (int64, error) {
+	result, err := r.exec(ctx, `
+		UPDATE tasks SET lease_expires_at = ?
+		WHERE instance_id = ? AND status IN ('pending', 'running')
+	`, r.dialect.TimeArg(until), instanceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *taskRepository) Current(ctx context.Context) (*models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE status = ? ORDER BY started_at DESC LIMIT 1"
+	task, err := scanTask(r.queryRow(ctx, query, models.TaskStatusRunning))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // 没有运行中的任务
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+func (r *taskRepository) Queued(ctx context.Context) ([]*models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE status = ? ORDER BY created_at ASC"
+	return r.queryTasks(ctx, query, models.TaskStatusPending)
+}
+
+func (r *taskRepository) Recent(ctx context.Context, limit int) ([]*models.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE status IN (?, ?) ORDER BY completed_at DESC LIMIT ?"
+	return r.queryTasks(ctx, query, models.TaskStatusCompleted, models.TaskStatusFailed, limit)
+}
+
+func (r *taskRepository) Stats(ctx context.Context) (*models.TaskStatsResponse, error) {
+	query := `
+		SELECT
+			COUNT(*) as total,
+			COALESCE(SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END), 0) as running,
+			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0) as queued,
+			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success,
+			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed,
+			AVG(CASE WHEN status IN ('completed', 'failed') AND duration > 0 THEN duration END) as avg_duration
+		FROM tasks
+	`
+
+	var stats models.TaskStatsResponse
+	var avgDuration sql.NullFloat64
+
+	err := r.queryRow(ctx, query).Scan(
+		&stats.Total, &stats.Running, &stats.Queued, &stats.Success, &stats.Failed, &avgDuration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if avgDuration.Valid {
+		stats.AvgDuration = &avgDuration.Float64
+	}
+
+	return &stats, nil
+}
+
+func (r *taskRepository) CountByStatus(ctx context.Context) (map[string]int, error) {
+	rows, err := r.query(ctx, "SELECT status, COUNT(*) FROM tasks GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *taskRepository) ListHistory(ctx context.Context, q *models.HistoryQuery) ([]*models.Task, error) {
+	query, args := r.historySelect(q)
+
+	query += " LIMIT ?"
+	args = append(args, q.Limit)
+	if q.After == nil {
+		query += " OFFSET ?"
+		args = append(args, q.Offset)
+	}
+
+	return r.queryTasks(ctx, query, args...)
+}
+
+func (r *taskRepository) EachHistory(ctx context.Context, q *models.HistoryQuery, fn func(*models.Task) error) error {
+	query, args := r.historySelect(q)
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// historySelect 构建带过滤、游标与排序的历史记录查询（不含LIMIT）
+func (r *taskRepository) historySelect(q *models.HistoryQuery) (string, []interface{}) {
+	where, args := r.historyWhere(q)
+
+	sortExpr := historySortExpr(q.Sort)
+	direction, comparison := "ASC", ">"
+	if q.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	// 游标分页：取排序值（相同时按ID）严格位于游标之后的记录，新插入的记录不会影响后续页
+	if q.After != nil {
+		var value interface{}
+		if q.Sort == models.HistorySortDuration {
+			value = q.After.Duration
+		} else {
+			value = r.dialect.TimeArg(*q.After.Time)
+		}
+		where += " AND (" + sortExpr + " " + comparison + " ? OR (" + sortExpr + " = ? AND id " + comparison + " ?))"
+		args = append(args, value, value, q.After.ID)
+	}
+
+	query := "SELECT " + taskColumns + " FROM tasks" + where +
+		" ORDER BY " + sortExpr + " " + direction + ", id " + direction
+	return query, args
+}
+
+func (r *taskRepository) CountHistory(ctx context.Context, q *models.HistoryQuery) (int, error) {
+	where, args := r.historyWhere(q)
+
+	var count int
+	err := r.queryRow(ctx, "SELECT COUNT(*) FROM tasks"+where, args...).Scan(&count)
+	return count, err
+}
+
+// historySortExpr 返回排序字段对应的SQL表达式，未完成时间的记录按创建时间参与排序
+func historySortExpr(sort string) string {
+	switch sort {
+	case models.HistorySortCompletedAt:
+		return "COALESCE(completed_at, created_at)"
+	case models.HistorySortDuration:
+		return "duration"
+	default:
+		return "created_at"
+	}
+}
+
+// historyWhere 根据过滤条件构建WHERE子句，始终只包含已结束的任务
+func (r *taskRepository) historyWhere(q *models.HistoryQuery) (string, []interface{}) {
+	conditions := []string{"status IN " + historyStatuses}
+	var args []interface{}
+
+	if len(q.Statuses) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(q.Statuses)), ", ")
+		conditions = append(conditions, "status IN ("+placeholders+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	like := r.dialect.CaseInsensitiveLike()
+	if q.Search != "" {
+		conditions = append(conditions, "(source_image "+like+" ? OR target_image "+like+" ?)")
+		args = append(args, "%"+q.Search+"%", "%"+q.Search+"%")
+	}
+	if q.Source != "" {
+		conditions = append(conditions, "source_image "+like+" ?")
+		args = append(args, "%"+q.Source+"%")
+	}
+	if q.Target != "" {
+		conditions = append(conditions, "target_image "+like+" ?")
+		args = append(args, "%"+q.Target+"%")
+	}
+	if q.TargetHost != "" {
+		conditions = append(conditions, "target_host = ?")
+		args = append(args, q.TargetHost)
+	}
+	if q.ConfigID != "" {
+		conditions = append(conditions, "config_id = ?")
+		args = append(args, q.ConfigID)
+	}
+	if q.CreatedBy != "" {
+		conditions = append(conditions, "created_by = ?")
+		args = append(args, q.CreatedBy)
+	}
+	if q.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, r.dialect.TimeArg(*q.From))
+	}
+	if q.To != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, r.dialect.TimeArg(*q.To))
+	}
+	if q.FinishedBefore != nil {
+		conditions = append(conditions, "COALESCE(completed_at, created_at) < ?")
+		args = append(args, r.dialect.TimeArg(*q.FinishedBefore))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *taskRepository) HistoryStats(ctx context.Context) (*models.HistoryStats, error) {
+	query := `
+		SELECT
+			COALESCE(COUNT(*), 0) as total,
+			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as success_count,
+			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed_count,
+			COALESCE(AVG(CASE WHEN status = 'completed' THEN duration ELSE NULL END), 0) as avg_duration
+		FROM tasks
+		WHERE status IN ` + historyStatuses
+
+	var stats models.HistoryStats
+	err := r.queryRow(ctx, query).Scan(&stats.Total, &stats.SuccessCount, &stats.FailedCount, &stats.AvgDuration)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *taskRepository) DeleteHistory(ctx context.Context, q *models.HistoryQuery) (int64, error) {
+	where, args := r.historyWhere(q)
+
+	result, err := r.exec(ctx, "DELETE FROM tasks"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *taskRepository) DeleteTasks(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := "DELETE FROM tasks WHERE id IN (" + placeholders + ") AND status IN " + historyStatuses
+	result, err := r.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *taskRepository) DateStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.DateStat, error) {
+	where, args := r.historyWhere(q)
+	dateExpr := r.dialect.DateExpr("created_at")
+	query := `
+		SELECT
+			` + dateExpr + ` as date,
+			COUNT(*) as total,
+			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success,
+			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed,
+			AVG(CASE WHEN status = 'completed' THEN duration ELSE NULL END) as avg_duration
+		FROM tasks` + where + `
+		GROUP BY ` + dateExpr + `
+		ORDER BY date DESC`
+	query, args = withLimit(query, args, limit)
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.DateStat
+	for rows.Next() {
+		var stat models.DateStat
+		var avgDuration sql.NullFloat64
+		if err := rows.Scan(&stat.Date, &stat.Total, &stat.Success, &stat.Failed, &avgDuration); err != nil {
+			return nil, err
+		}
+		stat.AvgDuration = avgDuration.Float64
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+func (r *taskRepository) RegistryStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.RegistryStat, error) {
+	where, args := r.historyWhere(q)
+	query := `
+		SELECT
+			target_host,
+			COUNT(*) as total,
+			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as success,
+			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) as failed
+		FROM tasks` + where + `
+		GROUP BY target_host
+		ORDER BY total DESC`
+	query, args = withLimit(query, args, limit)
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.RegistryStat
+	for rows.Next() {
+		var stat models.RegistryStat
+		if err := rows.Scan(&stat.Registry, &stat.Total, &stat.Success, &stat.Failed); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+func (r *taskRepository) FailureStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.FailureStat, error) {
+	where, args := r.historyWhere(q)
+	query := `
+		SELECT
+			error_msg,
+			COUNT(*) as count
+		FROM tasks` + where + ` AND status = 'failed' AND error_msg IS NOT NULL
+		GROUP BY error_msg
+		ORDER BY count DESC`
+	query, args = withLimit(query, args, limit)
+
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.FailureStat
+	for rows.Next() {
+		var stat models.FailureStat
+		if err := rows.Scan(&stat.ErrorMsg, &stat.Count); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// withLimit 在limit大于0时追加LIMIT子句
+func withLimit(query string, args []interface{}, limit int) (string, []interface{}) {
+	if limit <= 0 {
+		return query, args
+	}
+	return query + " LIMIT ?", append(args, limit)
+}
@@ -0,0 +1,146 @@
+// Package storage 定义持久化层的仓储接口，具体实现见 storage/sqlite 与 storage/postgres
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"docker-helper/models"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("record not found")
+
+// Store 聚合所有仓储，持有底层数据库连接
+type Store interface {
+	Tasks() TaskRepository
+	RegistryConfigs() RegistryConfigRepository
+	Config() ConfigRepository
+	Users() UserRepository
+	Audit() AuditRepository
+	ImageCache() ImageCacheRepository
+	BlobLocations() BlobLocationRepository
+	SigningKeys() SigningKeyRepository
+
+	// Ping 检查数据库连接
+	Ping(ctx context.Context) error
+	// CheckWrite 执行一次写入测试，用于发现只读或被锁定的数据库
+	CheckWrite(ctx context.Context) error
+	io.Closer
+}
+
+// TaskRepository 任务与历史记录
+type TaskRepository interface {
+	Create(ctx context.Context, task *models.Task) error
+	Get(ctx context.Context, id string) (*models.Task, error)
+	UpdateProgress(ctx context.Context, update *models.TaskProgressUpdate) error
+	MarkStarted(ctx context.Context, id string) error
+	MarkCompleted(ctx context.Context, id string) error
+	// SetArtifact 记录离线包任务生成的文件大小与SHA-256，任务不存在时返回ErrNotFound
+	SetArtifact(ctx context.Context, id string, size int64, sha256 string) error
+	// SetTargets 记录多目标转换任务各目标的推送结果，任务不存在时返回ErrNotFound
+	SetTargets(ctx context.Context, id string, targets []models.TaskTarget) error
+	// AddMountedBytes 累加任务通过跨仓库挂载节省的上传字节数，任务不存在时返回ErrNotFound
+	AddMountedBytes(ctx context.Context, id string, size int64) error
+	// Cancel 将等待中或运行中的任务标记为已取消，任务不存在或已结束时返回ErrNotFound
+	Cancel(ctx context.Context, id, message string) error
+	// Interrupt 将等待中或运行中的任务标记为已中断，任务不存在或已结束时返回ErrNotFound
+	Interrupt(ctx context.Context, id, message string) error
+	// InterruptUnfinished 将属于instanceID、未记录所属实例或租约在now之前已过期的等待中与运行中任务标记为已中断，
+	// 返回更新的数量；其他实例租约有效的任务不受影响
+	InterruptUnfinished(ctx context.Context, instanceID string, now time.Time, message string) (int64, error)
+	// RenewLeases 将instanceID的等待中与运行中任务的租约延长到until，返回更新的数量
+	RenewLeases(ctx context.Context, instanceID string, until time.Time) (int64, error)
+
+	Current(ctx context.Context) (*models.Task, error)
+	Queued(ctx context.Context) ([]*models.Task, error)
+	Recent(ctx context.Context, limit int) ([]*models.Task, error)
+	Stats(ctx context.Context) (*models.TaskStatsResponse, error)
+	// CountByStatus 按状态统计全部任务数量
+	CountByStatus(ctx context.Context) (map[string]int, error)
+
+	// 历史记录（已结束的任务）
+	// ListHistory 按条件查询历史记录，q.After不为空时使用游标分页并忽略Offset
+	ListHistory(ctx context.Context, q *models.HistoryQuery) ([]*models.Task, error)
+	// EachHistory 按条件和排序逐条遍历历史记录（忽略分页），用于流式导出
+	EachHistory(ctx context.Context, q *models.HistoryQuery, fn func(*models.Task) error) error
+	// CountHistory 统计满足过滤条件的历史记录数量（不考虑分页）
+	CountHistory(ctx context.Context, q *models.HistoryQuery) (int, error)
+	HistoryStats(ctx context.Context) (*models.HistoryStats, error)
+	// DeleteHistory 删除满足过滤条件的全部历史记录（忽略排序与分页）
+	DeleteHistory(ctx context.Context, q *models.HistoryQuery) (int64, error)
+	// DeleteTasks 按ID删除历史记录，未结束的任务不会被删除
+	DeleteTasks(ctx context.Context, ids []string) (int64, error)
+	// 统计按过滤条件聚合，limit小于等于0表示不限制条数
+	DateStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.DateStat, error)
+	RegistryStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.RegistryStat, error)
+	FailureStats(ctx context.Context, q *models.HistoryQuery, limit int) ([]models.FailureStat, error)
+}
+
+// RegistryConfigRepository 仓库配置
+type RegistryConfigRepository interface {
+	List(ctx context.Context) ([]*models.RegistryConfig, error)
+	Get(ctx context.Context, id string) (*models.RegistryConfig, error)
+	// Create 创建配置，IsDefault为true时在同一事务中清除其他默认配置
+	Create(ctx context.Context, config *models.RegistryConfig) error
+	// Update 更新配置，PasswordEncrypted为空时保留原密码，不存在时返回ErrNotFound
+	Update(ctx context.Context, config *models.RegistryConfig) error
+	// Delete 删除配置及其签名验证公钥，不存在时返回ErrNotFound
+	Delete(ctx context.Context, id string) error
+	UpdateStatus(ctx context.Context, id, status string) error
+}
+
+// ConfigRepository 系统键值配置
+type ConfigRepository interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+}
+
+// UserRepository 访问账户
+type UserRepository interface {
+	Get(ctx context.Context, username string) (*models.User, error)
+	GetByToken(ctx context.Context, token string) (*models.User, error)
+	UpdateToken(ctx context.Context, username, token string) error
+}
+
+// AuditRepository 只追加的审计事件
+type AuditRepository interface {
+	Append(ctx context.Context, event *models.AuditEvent) error
+	List(ctx context.Context, q *models.AuditQuery) ([]*models.AuditEvent, int, error)
+	// Each 按时间正序遍历匹配的事件（忽略分页），用于流式导出
+	Each(ctx context.Context, q *models.AuditQuery, fn func(*models.AuditEvent) error) error
+}
+
+// ImageCacheRepository 本地保留的源镜像，记录按所属服务实例区分，各实例只读写自己的记录
+type ImageCacheRepository interface {
+	// List 按最近使用时间正序（最久未使用在前）返回instanceID的全部记录
+	List(ctx context.Context, instanceID string) ([]*models.CachedImage, error)
+	Get(ctx context.Context, instanceID, image string) (*models.CachedImage, error)
+	// Touch 记录instanceID使用了镜像：不存在时创建，存在时更新镜像ID、大小与最近使用时间（LastUsedAt），hit为true时复用次数加一
+	Touch(ctx context.Context, instanceID string, entry *models.CachedImage, hit bool) error
+	// Delete 删除instanceID的记录，不存在时返回ErrNotFound
+	Delete(ctx context.Context, instanceID, image string) error
+	// Claim 将未记录所属实例的记录（升级前创建）归属到instanceID，已有同名记录时保留instanceID自己的记录，返回认领的数量
+	Claim(ctx context.Context, instanceID string) (int64, error)
+}
+
+// BlobLocationRepository 目标仓库中已知存在的镜像层
+type BlobLocationRepository interface {
+	// Record 记录镜像层存在于仓库路径中，已存在时更新大小与时间
+	Record(ctx context.Context, locations []*models.BlobLocation) error
+	// Locate 返回registry中已知包含该镜像层的记录，最近记录的在前
+	Locate(ctx context.Context, registry, digest string) ([]*models.BlobLocation, error)
+	// Forget 删除一条记录，不存在时返回ErrNotFound
+	Forget(ctx context.Context, registry, repository, digest string) error
+}
+
+// SigningKeyRepository 仓库配置的签名验证公钥
+type SigningKeyRepository interface {
+	// List 返回仓库配置的公钥，按添加时间排序
+	List(ctx context.Context, configID string) ([]*models.SigningKey, error)
+	Create(ctx context.Context, key *models.SigningKey) error
+	// Delete 删除仓库配置的一个公钥，不存在时返回ErrNotFound
+	Delete(ctx context.Context, configID, id string) error
+}
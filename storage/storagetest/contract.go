@@ -0,0 +1,780 @@
+// Package storagetest 提供storage.Store实现必须满足的契约测试
+//
+// 每个存储实现在自己的测试中调用 Run 即可复用同一套用例，例如：
+//
+//	func TestSQLiteContract(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Store {
+//			store, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return store
+//		})
+//	}
+//
+// PostgreSQL实现可通过 TEST_POSTGRES_DSN 指向本地实例运行，每个用例在独立的schema中迁移。
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"docker-helper/models"
+	"docker-helper/storage"
+)
+
+// NewStoreFunc 为每个用例创建一个空的Store
+type NewStoreFunc func(t *testing.T) storage.Store
+
+// Run 运行全部契约用例
+func Run(t *testing.T, newStore NewStoreFunc) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"TaskLifecycle", testTaskLifecycle},
+		{"TaskLeases", testTaskLeases},
+		{"BundleTask", testBundleTask},
+		{"TaskTargets", testTaskTargets},
+		{"TaskHistory", testTaskHistory},
+		{"HistoryCursor", testHistoryCursor},
+		{"HistoryCursorInserts", testHistoryCursorInserts},
+		{"RegistryConfigs", testRegistryConfigs},
+		{"Config", testConfig},
+		{"Users", testUsers},
+		{"AuditAppendOnly", testAudit},
+		{"ImageCache", testImageCache},
+		{"BlobLocations", testBlobLocations},
+		{"SigningKeys", testSigningKeys},
+		{"Health", testHealth},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newStore(t)
+			t.Cleanup(func() { s.Close() })
+			tc.fn(t, s)
+		})
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func newTask(id, status string) *models.Task {
+	return &models.Task{
+		ID:             id,
+		SourceImage:    "nginx:" + id,
+		TargetImage:    "harbor.example.com/transform/nginx:" + id,
+		TargetHost:     "harbor.example.com",
+		TargetUsername: "robot",
+		Status:         status,
+		StepMessage:    strPtr(models.TaskStepMessages[models.TaskStepInit]),
+	}
+}
+
+func testTaskLifecycle(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	if err := tasks.Create(ctx, newTask("t1", models.TaskStatusPending)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	queued, err := tasks.Queued(ctx)
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("Queued = %d, %v; want 1 task", len(queued), err)
+	}
+
+	if err := tasks.MarkStarted(ctx, "t1"); err != nil {
+		t.Fatalf("MarkStarted: %v", err)
+	}
+	err = tasks.UpdateProgress(ctx, &models.TaskProgressUpdate{
+		TaskID: "t1", Status: models.TaskStatusRunning, Progress: 60, CurrentStep: 4, StepMessage: "拉取源镜像",
+	})
+	if err != nil {
+		t.Fatalf("UpdateProgress: %v", err)
+	}
+
+	current, err := tasks.Current(ctx)
+	if err != nil || current == nil || current.ID != "t1" || current.Progress != 60 || current.StartedAt == nil {
+		t.Fatalf("Current = %+v, %v; want running t1 at 60%%", current, err)
+	}
+
+	if err := tasks.Cancel(ctx, "t1", "任务已取消"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if err := tasks.Cancel(ctx, "t1", "任务已取消"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Cancel finished task = %v; want ErrNotFound", err)
+	}
+
+	got, err := tasks.Get(ctx, "t1")
+	if err != nil || got.Status != models.TaskStatusCancelled || got.CompletedAt == nil {
+		t.Fatalf("Get = %+v, %v; want cancelled with completed_at", got, err)
+	}
+
+	if _, err := tasks.Get(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get missing = %v; want ErrNotFound", err)
+	}
+
+	stats, err := tasks.Stats(ctx)
+	if err != nil || stats.Total != 1 {
+		t.Fatalf("Stats = %+v, %v; want total 1", stats, err)
+	}
+
+	counts, err := tasks.CountByStatus(ctx)
+	if err != nil || counts[models.TaskStatusCancelled] != 1 || counts[models.TaskStatusPending] != 0 {
+		t.Fatalf("CountByStatus = %v, %v; want cancelled 1", counts, err)
+	}
+
+	for _, id := range []string{"t2", "t3", "t4"} {
+		if err := tasks.Create(ctx, newTask(id, models.TaskStatusPending)); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+	}
+	if err := tasks.Interrupt(ctx, "t2", "服务关闭"); err != nil {
+		t.Fatalf("Interrupt: %v", err)
+	}
+	if err := tasks.Interrupt(ctx, "t1", "服务关闭"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Interrupt finished task = %v; want ErrNotFound", err)
+	}
+	if n, err := tasks.InterruptUnfinished(ctx, "node-a", time.Now(), "服务重启"); err != nil || n != 2 {
+		t.Fatalf("InterruptUnfinished = %d, %v; want 2", n, err)
+	}
+	counts, err = tasks.CountByStatus(ctx)
+	if err != nil || counts[models.TaskStatusInterrupted] != 3 || counts[models.TaskStatusCancelled] != 1 {
+		t.Fatalf("CountByStatus = %v, %v; want interrupted 3, cancelled 1", counts, err)
+	}
+}
+
+func testTaskLeases(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+	now := time.Now().Truncate(time.Second)
+
+	owned := func(id, instance string, lease time.Time) *models.Task {
+		task := newTask(id, models.TaskStatusRunning)
+		task.InstanceID = strPtr(instance)
+		task.LeaseExpiresAt = &lease
+		return task
+	}
+	for _, task := range []*models.Task{
+		owned("own", "node-a", now.Add(time.Minute)),
+		owned("live", "node-b", now.Add(time.Minute)),
+		owned("expired", "node-b", now.Add(-time.Minute)),
+		owned("renewed", "node-c", now.Add(-time.Minute)),
+		newTask("legacy", models.TaskStatusPending),
+	} {
+		if err := tasks.Create(ctx, task); err != nil {
+			t.Fatalf("Create %s: %v", task.ID, err)
+		}
+	}
+
+	got, err := tasks.Get(ctx, "own")
+	if err != nil || got.InstanceID == nil || *got.InstanceID != "node-a" ||
+		got.LeaseExpiresAt == nil || !got.LeaseExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Get own = %+v, %v; want instance node-a with lease", got, err)
+	}
+
+	if n, err := tasks.RenewLeases(ctx, "node-c", now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("RenewLeases = %d, %v; want 1", n, err)
+	}
+
+	// node-a 重启：中断自己的、租约过期的与未记录实例的任务，保留其他实例租约有效的任务
+	if n, err := tasks.InterruptUnfinished(ctx, "node-a", now, "服务重启"); err != nil || n != 3 {
+		t.Fatalf("InterruptUnfinished = %d, %v; want 3", n, err)
+	}
+	want := map[string]string{
+		"own":     models.TaskStatusInterrupted,
+		"live":    models.TaskStatusRunning,
+		"expired": models.TaskStatusInterrupted,
+		"renewed": models.TaskStatusRunning,
+		"legacy":  models.TaskStatusInterrupted,
+	}
+	for id, status := range want {
+		task, err := tasks.Get(ctx, id)
+		if err != nil || task.Status != status {
+			t.Fatalf("task %s = %+v, %v; want status %s", id, task, err, status)
+		}
+	}
+}
+
+func testBundleTask(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	task := &models.Task{
+		ID:           "b1",
+		Type:         models.TaskTypeBundle,
+		SourceImage:  "nginx:1.25, redis:7",
+		TargetImage:  "b1.tar",
+		Images:       []string{"nginx:1.25", "redis:7"},
+		BundleFormat: strPtr(models.BundleFormatOCI),
+		Status:       models.TaskStatusPending,
+	}
+	if err := tasks.Create(ctx, task); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := tasks.SetArtifact(ctx, "b1", 1024, "abc123"); err != nil {
+		t.Fatalf("SetArtifact: %v", err)
+	}
+	if err := tasks.SetArtifact(ctx, "missing", 1, "x"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("SetArtifact missing = %v; want ErrNotFound", err)
+	}
+
+	got, err := tasks.Get(ctx, "b1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Type != models.TaskTypeBundle || len(got.Images) != 2 || got.Images[1] != "redis:7" ||
+		got.BundleFormat == nil || *got.BundleFormat != models.BundleFormatOCI ||
+		got.ArtifactSize == nil || *got.ArtifactSize != 1024 || got.ArtifactSHA256 == nil || *got.ArtifactSHA256 != "abc123" {
+		t.Fatalf("Get = %+v; want bundle task with images and artifact", got)
+	}
+
+	if err := tasks.Create(ctx, newTask("t1", models.TaskStatusPending)); err != nil {
+		t.Fatalf("Create t1: %v", err)
+	}
+	got, err = tasks.Get(ctx, "t1")
+	if err != nil || got.Type != models.TaskTypeTransform || got.Images != nil || got.ArtifactSize != nil || got.Targets != nil {
+		t.Fatalf("Get t1 = %+v, %v; want transform task without bundle fields", got, err)
+	}
+}
+
+func testTaskTargets(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	task := newTask("m1", models.TaskStatusPending)
+	task.Targets = []models.TaskTarget{
+		{ConfigID: "c1", Name: "harbor", TargetImage: "harbor.local/transform/nginx:1.25", Status: models.TaskStatusPending},
+		{ConfigID: "c2", Name: "acr", TargetImage: "acr.local/nginx:1.25", Status: models.TaskStatusPending},
+	}
+	if err := tasks.Create(ctx, task); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	task.Targets[1].Status = models.TaskStatusFailed
+	task.Targets[1].ErrorMsg = "push denied"
+	task.Targets[1].Duration = 3
+	if err := tasks.SetTargets(ctx, "m1", task.Targets); err != nil {
+		t.Fatalf("SetTargets: %v", err)
+	}
+	if err := tasks.SetTargets(ctx, "missing", task.Targets); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("SetTargets missing = %v; want ErrNotFound", err)
+	}
+
+	got, err := tasks.Get(ctx, "m1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Targets) != 2 || got.Targets[0].Name != "harbor" || got.Targets[0].Status != models.TaskStatusPending ||
+		got.Targets[1].Status != models.TaskStatusFailed || got.Targets[1].ErrorMsg != "push denied" || got.Targets[1].Duration != 3 {
+		t.Fatalf("Get targets = %+v; want updated per-target results", got.Targets)
+	}
+}
+
+func testTaskHistory(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	for _, tc := range []struct{ id, status string }{
+		{"h1", models.TaskStatusCompleted},
+		{"h2", models.TaskStatusFailed},
+		{"h3", models.TaskStatusPending},
+	} {
+		if err := tasks.Create(ctx, newTask(tc.id, models.TaskStatusPending)); err != nil {
+			t.Fatalf("Create %s: %v", tc.id, err)
+		}
+		update := &models.TaskProgressUpdate{TaskID: tc.id, Status: tc.status, Duration: 10}
+		if tc.status == models.TaskStatusFailed {
+			update.ErrorMsg = strPtr("推送镜像失败")
+		}
+		if err := tasks.UpdateProgress(ctx, update); err != nil {
+			t.Fatalf("UpdateProgress %s: %v", tc.id, err)
+		}
+	}
+
+	history, err := tasks.ListHistory(ctx, &models.HistoryQuery{Limit: 10, Desc: true})
+	if err != nil || len(history) != 2 {
+		t.Fatalf("ListHistory = %d, %v; want 2", len(history), err)
+	}
+
+	failed, err := tasks.ListHistory(ctx, &models.HistoryQuery{Statuses: []string{models.TaskStatusFailed}, Search: "NGINX:H2", Limit: 10})
+	if err != nil || len(failed) != 1 || failed[0].ID != "h2" {
+		t.Fatalf("ListHistory filtered = %+v, %v; want h2", failed, err)
+	}
+	if count, err := tasks.CountHistory(ctx, &models.HistoryQuery{TargetHost: "other.example.com"}); err != nil || count != 0 {
+		t.Fatalf("CountHistory by host = %d, %v; want 0", count, err)
+	}
+
+	stats, err := tasks.HistoryStats(ctx)
+	if err != nil || stats.Total != 2 || stats.SuccessCount != 1 || stats.FailedCount != 1 {
+		t.Fatalf("HistoryStats = %+v, %v", stats, err)
+	}
+
+	since := time.Now().AddDate(0, 0, -30)
+	dates, err := tasks.DateStats(ctx, &models.HistoryQuery{From: &since}, 30)
+	if err != nil || len(dates) != 1 || dates[0].Total != 2 || len(dates[0].Date) != len("2006-01-02") {
+		t.Fatalf("DateStats = %+v, %v", dates, err)
+	}
+
+	registries, err := tasks.RegistryStats(ctx, &models.HistoryQuery{}, 10)
+	if err != nil || len(registries) != 1 || registries[0].Registry != "harbor.example.com" {
+		t.Fatalf("RegistryStats = %+v, %v", registries, err)
+	}
+
+	failures, err := tasks.FailureStats(ctx, &models.HistoryQuery{}, 10)
+	if err != nil || len(failures) != 1 || failures[0].Count != 1 {
+		t.Fatalf("FailureStats = %+v, %v", failures, err)
+	}
+	if failures, _ := tasks.FailureStats(ctx, &models.HistoryQuery{Statuses: []string{models.TaskStatusCompleted}}, 0); len(failures) != 0 {
+		t.Fatalf("FailureStats filtered by completed = %+v; want none", failures)
+	}
+
+	var exported []string
+	err = tasks.EachHistory(ctx, &models.HistoryQuery{Sort: models.HistorySortCreatedAt}, func(task *models.Task) error {
+		exported = append(exported, task.ID)
+		return nil
+	})
+	if err != nil || len(exported) != 2 {
+		t.Fatalf("EachHistory = %v, %v; want 2 tasks", exported, err)
+	}
+
+	// 未结束的任务不会被按ID删除
+	deleted, err := tasks.DeleteTasks(ctx, []string{"h2", "h3"})
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteTasks = %d, %v; want 1", deleted, err)
+	}
+	if _, err := tasks.Get(ctx, "h3"); err != nil {
+		t.Fatalf("pending task deleted: %v", err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	deleted, err = tasks.DeleteHistory(ctx, &models.HistoryQuery{FinishedBefore: &future})
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteHistory = %d, %v; want 1", deleted, err)
+	}
+	if count, _ := tasks.CountHistory(ctx, &models.HistoryQuery{}); count != 0 {
+		t.Fatalf("CountHistory after clear = %d", count)
+	}
+}
+
+func testHistoryCursor(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("c%d", i)
+		if err := tasks.Create(ctx, newTask(id, models.TaskStatusPending)); err != nil {
+			t.Fatalf("Create %s: %v", id, err)
+		}
+		update := &models.TaskProgressUpdate{TaskID: id, Status: models.TaskStatusCompleted, Duration: i % 2}
+		if err := tasks.UpdateProgress(ctx, update); err != nil {
+			t.Fatalf("UpdateProgress %s: %v", id, err)
+		}
+	}
+
+	for _, sort := range []string{models.HistorySortCreatedAt, models.HistorySortCompletedAt, models.HistorySortDuration} {
+		want, err := tasks.CountHistory(ctx, &models.HistoryQuery{})
+		if err != nil {
+			t.Fatalf("CountHistory: %v", err)
+		}
+
+		seen := map[string]bool{}
+		q := &models.HistoryQuery{Sort: sort, Desc: true, Limit: 2}
+		for page := 0; ; page++ {
+			items, err := tasks.ListHistory(ctx, q)
+			if err != nil {
+				t.Fatalf("ListHistory(%s) page %d: %v", sort, page, err)
+			}
+			for _, item := range items {
+				if seen[item.ID] {
+					t.Fatalf("ListHistory(%s) returned %s twice", sort, item.ID)
+				}
+				seen[item.ID] = true
+			}
+			if len(items) < q.Limit {
+				break
+			}
+			q.After = models.NewHistoryCursor(items[len(items)-1], sort, true)
+
+			// 翻页过程中新增的任务不应出现在后续页中
+			if page == 0 {
+				id := "new-" + sort
+				if err := tasks.Create(ctx, newTask(id, models.TaskStatusPending)); err != nil {
+					t.Fatalf("Create %s: %v", id, err)
+				}
+				update := &models.TaskProgressUpdate{TaskID: id, Status: models.TaskStatusCompleted, Duration: 9}
+				if err := tasks.UpdateProgress(ctx, update); err != nil {
+					t.Fatalf("UpdateProgress %s: %v", id, err)
+				}
+				if err := tasks.MarkCompleted(ctx, id); err != nil {
+					t.Fatalf("MarkCompleted %s: %v", id, err)
+				}
+			}
+		}
+		if len(seen) != want {
+			t.Fatalf("ListHistory(%s) paged over %d tasks; want %d", sort, len(seen), want)
+		}
+	}
+}
+
+// createCompleted 创建一条已完成、耗时为duration秒的任务
+func createCompleted(t *testing.T, tasks storage.TaskRepository, id string, duration int) {
+	t.Helper()
+	ctx := context.Background()
+	if err := tasks.Create(ctx, newTask(id, models.TaskStatusPending)); err != nil {
+		t.Fatalf("Create %s: %v", id, err)
+	}
+	update := &models.TaskProgressUpdate{TaskID: id, Status: models.TaskStatusCompleted, Duration: duration}
+	if err := tasks.UpdateProgress(ctx, update); err != nil {
+		t.Fatalf("UpdateProgress %s: %v", id, err)
+	}
+}
+
+func testHistoryCursorInserts(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	tasks := s.Tasks()
+
+	for _, task := range []struct {
+		id       string
+		duration int
+	}{{"d0", 10}, {"d1", 20}, {"d2", 20}, {"d3", 30}, {"d4", 30}, {"d5", 40}} {
+		createCompleted(t, tasks, task.id, task.duration)
+	}
+
+	q := &models.HistoryQuery{Sort: models.HistorySortDuration, Desc: true, Limit: 2}
+	var got []string
+	for page := 0; ; page++ {
+		items, err := tasks.ListHistory(ctx, q)
+		if err != nil {
+			t.Fatalf("ListHistory page %d: %v", page, err)
+		}
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		if len(items) < q.Limit {
+			break
+		}
+		q.After = models.NewHistoryCursor(items[len(items)-1], q.Sort, q.Desc)
+
+		// 第一页（d5, d4）之后插入：排在游标之前的记录不出现，排在游标之后的记录（含排序值相同、按ID在后的）出现在后续页
+		if page == 0 {
+			createCompleted(t, tasks, "d9", 50)  // 排序值大于游标
+			createCompleted(t, tasks, "d45", 30) // 排序值相同，ID排在游标之前
+			createCompleted(t, tasks, "d35", 30) // 排序值相同，ID排在游标之后
+			createCompleted(t, tasks, "d15", 15) // 排序值小于游标
+		}
+	}
+
+	want := []string{"d5", "d4", "d35", "d3", "d2", "d1", "d15", "d0"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("paged = %v; want %v", got, want)
+	}
+}
+
+func testRegistryConfigs(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	configs := s.RegistryConfigs()
+
+	first := &models.RegistryConfig{ID: "r1", Name: "dev", RegistryURL: "dev.example.com", Username: "u", PasswordEncrypted: "enc1", IsDefault: true}
+	second := &models.RegistryConfig{ID: "r2", Name: "prod", RegistryURL: "prod.example.com", Username: "u", PasswordEncrypted: "enc2", IsDefault: true}
+	for _, c := range []*models.RegistryConfig{first, second} {
+		if err := configs.Create(ctx, c); err != nil {
+			t.Fatalf("Create %s: %v", c.ID, err)
+		}
+	}
+
+	list, err := configs.List(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != "r2" || list[1].IsDefault {
+		t.Fatalf("List = %+v, %v; want r2 as the only default", list, err)
+	}
+
+	// 未提供密码时保留原密码
+	first.Name = "dev-renamed"
+	first.PasswordEncrypted = ""
+	first.IsDefault = false
+	if err := configs.Update(ctx, first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := configs.Get(ctx, "r1")
+	if err != nil || got.Name != "dev-renamed" || got.PasswordEncrypted != "enc1" {
+		t.Fatalf("Get after update = %+v, %v", got, err)
+	}
+
+	if err := configs.UpdateStatus(ctx, "r1", "verified"); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if got, _ := configs.Get(ctx, "r1"); got.Status != "verified" || got.LastTestTime == nil {
+		t.Fatalf("status not updated: %+v", got)
+	}
+
+	if err := configs.Delete(ctx, "r1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := configs.Delete(ctx, "r1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Delete missing = %v; want ErrNotFound", err)
+	}
+	if err := configs.Update(ctx, first); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Update missing = %v; want ErrNotFound", err)
+	}
+}
+
+func testConfig(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	config := s.Config()
+
+	if _, err := config.Get(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get missing = %v; want ErrNotFound", err)
+	}
+	if err := config.Set(ctx, "k", "v1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := config.Set(ctx, "k", "v2"); err != nil {
+		t.Fatalf("Set overwrite: %v", err)
+	}
+	if v, err := config.Get(ctx, "k"); err != nil || v != "v2" {
+		t.Fatalf("Get = %q, %v; want v2", v, err)
+	}
+}
+
+func testUsers(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	users := s.Users()
+
+	admin, err := users.Get(ctx, models.DefaultUsername)
+	if err != nil || admin.Token == "" {
+		t.Fatalf("default admin user = %+v, %v", admin, err)
+	}
+
+	if err := users.UpdateToken(ctx, models.DefaultUsername, "rotated"); err != nil {
+		t.Fatalf("UpdateToken: %v", err)
+	}
+	if u, err := users.GetByToken(ctx, "rotated"); err != nil || u.Username != models.DefaultUsername {
+		t.Fatalf("GetByToken = %+v, %v", u, err)
+	}
+	if _, err := users.GetByToken(ctx, admin.Token); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("old token still valid: %v", err)
+	}
+	if err := users.UpdateToken(ctx, "nobody", "x"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("UpdateToken missing = %v; want ErrNotFound", err)
+	}
+}
+
+func testAudit(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	audit := s.Audit()
+
+	for _, action := range []string{"registry.create", "registry.update", "history.clear"} {
+		event := &models.AuditEvent{Actor: "admin", SourceIP: "127.0.0.1", Action: action, Result: models.AuditResultSuccess, StatusCode: 200}
+		if err := audit.Append(ctx, event); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	items, total, err := audit.List(ctx, &models.AuditQuery{Action: "registry", Limit: 1})
+	if err != nil || total != 2 || len(items) != 1 || items[0].Action != "registry.update" {
+		t.Fatalf("List = %+v (total %d), %v", items, total, err)
+	}
+
+	from := time.Now().Add(-time.Hour)
+	var exported []string
+	err = audit.Each(ctx, &models.AuditQuery{From: &from}, func(e *models.AuditEvent) error {
+		exported = append(exported, e.Action)
+		return nil
+	})
+	if err != nil || len(exported) != 3 || exported[0] != "registry.create" {
+		t.Fatalf("Each = %v, %v", exported, err)
+	}
+}
+
+func testImageCache(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	cache := s.ImageCache()
+
+	now := time.Now().Truncate(time.Second)
+	for i, image := range []string{"docker.io/library/nginx:1.25", "docker.io/library/redis:7"} {
+		entry := &models.CachedImage{Image: image, ImageID: fmt.Sprintf("sha256:%d", i), Size: 100, LastUsedAt: now.Add(time.Duration(i) * time.Minute)}
+		if err := cache.Touch(ctx, "replica-a", entry, false); err != nil {
+			t.Fatalf("Touch %s: %v", image, err)
+		}
+	}
+
+	// 再次使用nginx：更新镜像ID与大小，复用次数加一，移到最近使用
+	err := cache.Touch(ctx, "replica-a", &models.CachedImage{Image: "docker.io/library/nginx:1.25", ImageID: "sha256:new", Size: 200, LastUsedAt: now.Add(time.Hour)}, true)
+	if err != nil {
+		t.Fatalf("Touch hit: %v", err)
+	}
+
+	entries, err := cache.List(ctx, "replica-a")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("List = %+v, %v; want 2 entries", entries, err)
+	}
+	if entries[0].Image != "docker.io/library/redis:7" || entries[1].Image != "docker.io/library/nginx:1.25" {
+		t.Fatalf("List order = %s, %s; want least recently used first", entries[0].Image, entries[1].Image)
+	}
+	nginx := entries[1]
+	if nginx.ImageID != "sha256:new" || nginx.Size != 200 || nginx.Hits != 1 || !nginx.LastUsedAt.Equal(now.Add(time.Hour)) || nginx.CreatedAt.IsZero() {
+		t.Fatalf("nginx entry = %+v; want updated id, size, hits and last use", nginx)
+	}
+
+	if err := cache.Delete(ctx, "replica-a", "docker.io/library/redis:7"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := cache.Delete(ctx, "replica-a", "docker.io/library/redis:7"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Delete missing = %v; want ErrNotFound", err)
+	}
+	if _, err := cache.Get(ctx, "replica-a", "docker.io/library/redis:7"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get deleted = %v; want ErrNotFound", err)
+	}
+	if got, err := cache.Get(ctx, "replica-a", "docker.io/library/nginx:1.25"); err != nil || got.Hits != 1 {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	// 共享数据库的其他实例缓存同名镜像时互不影响
+	if err := cache.Touch(ctx, "replica-b", &models.CachedImage{Image: "docker.io/library/nginx:1.25", ImageID: "sha256:b", Size: 300, LastUsedAt: now}, false); err != nil {
+		t.Fatalf("Touch replica-b: %v", err)
+	}
+	if entries, err := cache.List(ctx, "replica-b"); err != nil || len(entries) != 1 || entries[0].ImageID != "sha256:b" {
+		t.Fatalf("List replica-b = %+v, %v; want only its own entry", entries, err)
+	}
+	if err := cache.Delete(ctx, "replica-b", "docker.io/library/nginx:1.25"); err != nil {
+		t.Fatalf("Delete replica-b: %v", err)
+	}
+	if got, err := cache.Get(ctx, "replica-a", "docker.io/library/nginx:1.25"); err != nil || got.ImageID != "sha256:new" {
+		t.Fatalf("Get replica-a after replica-b Delete = %+v, %v; want unchanged", got, err)
+	}
+
+	// 认领未记录所属实例的记录，本实例已有的同名记录保留
+	for _, image := range []string{"docker.io/library/nginx:1.25", "docker.io/library/alpine:3"} {
+		if err := cache.Touch(ctx, "", &models.CachedImage{Image: image, ImageID: "sha256:legacy", Size: 10, LastUsedAt: now}, false); err != nil {
+			t.Fatalf("Touch legacy %s: %v", image, err)
+		}
+	}
+	if claimed, err := cache.Claim(ctx, "replica-a"); err != nil || claimed != 1 {
+		t.Fatalf("Claim = %d, %v; want 1", claimed, err)
+	}
+	if entries, err := cache.List(ctx, ""); err != nil || len(entries) != 0 {
+		t.Fatalf("List legacy after Claim = %+v, %v; want none", entries, err)
+	}
+	if got, err := cache.Get(ctx, "replica-a", "docker.io/library/nginx:1.25"); err != nil || got.ImageID != "sha256:new" {
+		t.Fatalf("Get nginx after Claim = %+v, %v; want own entry kept", got, err)
+	}
+	if got, err := cache.Get(ctx, "replica-a", "docker.io/library/alpine:3"); err != nil || got.ImageID != "sha256:legacy" {
+		t.Fatalf("Get alpine after Claim = %+v, %v; want claimed entry", got, err)
+	}
+}
+
+func testBlobLocations(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	blobs := s.BlobLocations()
+
+	if err := blobs.Record(ctx, []*models.BlobLocation{
+		{Registry: "harbor.local", Repository: "base/nginx", Digest: "sha256:aaa", Size: 100},
+		{Registry: "harbor.local", Repository: "base/nginx", Digest: "sha256:bbb", Size: 200},
+		{Registry: "other.local", Repository: "base/nginx", Digest: "sha256:aaa", Size: 100},
+	}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := blobs.Record(ctx, []*models.BlobLocation{
+		{Registry: "harbor.local", Repository: "app/web", Digest: "sha256:aaa", Size: 100},
+	}); err != nil {
+		t.Fatalf("Record again: %v", err)
+	}
+
+	got, err := blobs.Locate(ctx, "harbor.local", "sha256:aaa")
+	if err != nil {
+		t.Fatalf("Locate: %v", err)
+	}
+	if len(got) != 2 || got[0].Repository != "app/web" || got[1].Repository != "base/nginx" || got[1].Size != 100 {
+		t.Fatalf("Locate = %+v; want app/web then base/nginx", got)
+	}
+
+	if err := blobs.Forget(ctx, "harbor.local", "app/web", "sha256:aaa"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if err := blobs.Forget(ctx, "harbor.local", "app/web", "sha256:aaa"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Forget again = %v; want ErrNotFound", err)
+	}
+	if got, err := blobs.Locate(ctx, "harbor.local", "sha256:aaa"); err != nil || len(got) != 1 {
+		t.Fatalf("Locate after Forget = %+v, %v; want one location", got, err)
+	}
+
+	tasks := s.Tasks()
+	if err := tasks.Create(ctx, newTask("m1", models.TaskStatusRunning)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, size := range []int64{100, 200} {
+		if err := tasks.AddMountedBytes(ctx, "m1", size); err != nil {
+			t.Fatalf("AddMountedBytes: %v", err)
+		}
+	}
+	if err := tasks.AddMountedBytes(ctx, "missing", 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("AddMountedBytes missing = %v; want ErrNotFound", err)
+	}
+	if task, err := tasks.Get(ctx, "m1"); err != nil || task.MountedBytes != 300 {
+		t.Fatalf("Get = %+v, %v; want mounted_bytes 300", task, err)
+	}
+}
+
+func testSigningKeys(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	keys := s.SigningKeys()
+
+	for _, id := range []string{"r1", "r2"} {
+		config := &models.RegistryConfig{ID: id, Name: id, RegistryURL: id + ".example.com", Username: "u", PasswordEncrypted: "enc"}
+		if err := s.RegistryConfigs().Create(ctx, config); err != nil {
+			t.Fatalf("Create config %s: %v", id, err)
+		}
+	}
+	for _, key := range []*models.SigningKey{
+		{ID: "k1", ConfigID: "r1", Name: "release", PublicKey: "pem1", Algorithm: "ecdsa-p256", Fingerprint: "f1"},
+		{ID: "k2", ConfigID: "r1", Name: "backup", PublicKey: "pem2", Algorithm: "ed25519", Fingerprint: "f2"},
+		{ID: "k3", ConfigID: "r2", Name: "release", PublicKey: "pem1", Algorithm: "ecdsa-p256", Fingerprint: "f1"},
+	} {
+		if err := keys.Create(ctx, key); err != nil {
+			t.Fatalf("Create %s: %v", key.ID, err)
+		}
+	}
+	if err := keys.Create(ctx, &models.SigningKey{ID: "k4", ConfigID: "r1", Name: "dup", PublicKey: "pem1", Algorithm: "ecdsa-p256", Fingerprint: "f1"}); err == nil {
+		t.Fatal("Create duplicate fingerprint succeeded; want error")
+	}
+
+	list, err := keys.List(ctx, "r1")
+	if err != nil || len(list) != 2 || list[0].ID != "k1" || list[1].Algorithm != "ed25519" || list[0].CreatedAt.IsZero() {
+		t.Fatalf("List = %+v, %v; want k1, k2", list, err)
+	}
+
+	if err := keys.Delete(ctx, "r2", "k1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Delete from other config = %v; want ErrNotFound", err)
+	}
+	if err := keys.Delete(ctx, "r1", "k1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if err := s.RegistryConfigs().Delete(ctx, "r1"); err != nil {
+		t.Fatalf("Delete config: %v", err)
+	}
+	if list, err := keys.List(ctx, "r1"); err != nil || len(list) != 0 {
+		t.Fatalf("List after config delete = %+v, %v; want none", list, err)
+	}
+	if list, err := keys.List(ctx, "r2"); err != nil || len(list) != 1 {
+		t.Fatalf("List r2 = %+v, %v; want k3", list, err)
+	}
+}
+
+func testHealth(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	if err := s.Ping(ctx); err != nil {
+		t.Fatalf("Ping = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.CheckWrite(ctx); err != nil {
+			t.Fatalf("CheckWrite = %v", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package tracing 配置OpenTelemetry链路追踪，并提供创建Span的辅助函数
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 支持的导出器
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const tracerName = "docker-helper"
+
+// Options 链路追踪配置
+type Options struct {
+	Exporter    string  // none/otlp/stdout/file
+	Endpoint    string  // OTLP HTTP端点（如 http://collector:4318），为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+	File        string  // file导出器写入的文件
+	SampleRatio float64 // 采样比例，0~1
+}
+
+// Setup 初始化全局TracerProvider，返回的函数用于在退出前刷新并关闭导出器
+//
+// Exporter为none时不导出任何Span，但仍会传播上游传入的trace上下文。
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterFile:
+		if opts.File == "" {
+			return nil, fmt.Errorf("file导出器需要指定文件路径")
+		}
+		file, err = os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("打开追踪文件失败: %v", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("不支持的追踪导出器: %s（可选 none/otlp/stdout/file）", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪导出器失败: %v", err)
+	}
+
+	// OTEL_SERVICE_NAME 与 OTEL_RESOURCE_ATTRIBUTES 可覆盖默认的资源属性
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(tracerName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪资源失败: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start 创建子Span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束Span，err不为空时记录错误并将状态设为Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach 返回保留ctx中的trace上下文与其他值、但不随ctx取消的上下文，用于请求结束后继续执行的后台任务
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// Handler 为HTTP服务端请求创建Span，并从请求头中提取上游的trace上下文
+func Handler(h http.Handler, skip ...string) http.Handler {
+	return otelhttp.NewHandler(h, "http.server",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			for _, path := range skip {
+				if r.URL.Path == path {
+					return false
+				}
+			}
+			return true
+		}),
+	)
+}
+
+// Transport 为HTTP客户端请求创建Span并注入trace上下文，base为空时使用http.DefaultTransport
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
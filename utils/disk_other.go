@@ -0,0 +1,10 @@
+//go:build !unix
+
+package utils
+
+import "errors"
+
+// DiskUsage 当前平台不支持查询磁盘空间
+func DiskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("当前平台不支持查询磁盘空间")
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package utils
+
+import "syscall"
+
+// DiskUsage 返回路径所在文件系统的可用空间与总空间（字节）
+func DiskUsage(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	// Bavail为非特权用户可用的块数
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 支持的导出格式
+const (
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+	ExportXLSX  = "xlsx"
+)
+
+// ExportContentType 返回导出格式对应的Content-Type
+func ExportContentType(format string) string {
+	switch format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportJSONL:
+		return "application/x-ndjson; charset=utf-8"
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// TableWriter 按行流式写出表格数据，一个导出文件可包含多张表
+type TableWriter interface {
+	// StartTable 开始一张新表，columns为列名
+	StartTable(name string, columns []string) error
+	// WriteRow 写入一行，值的顺序与列名一致
+	WriteRow(values ...interface{}) error
+	// Close 刷新缓冲并结束文件（不关闭底层Writer）
+	Close() error
+}
+
+// NewTableWriter 创建指定格式的表格写出器
+func NewTableWriter(format string, w io.Writer) (TableWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvTableWriter{w: csv.NewWriter(w)}, nil
+	case ExportJSONL:
+		return &jsonlTableWriter{w: bufio.NewWriter(w)}, nil
+	case ExportXLSX:
+		return &xlsxTableWriter{x: NewXLSXWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s（可选 csv/jsonl/xlsx）", format)
+	}
+}
+
+// exportValue 将导出值规范化：解引用指针，时间格式化为RFC3339
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC().Format(time.RFC3339)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// csvTableWriter 多张表之间以空行分隔，每张表以列名行开头
+type csvTableWriter struct {
+	w      *csv.Writer
+	tables int
+}
+
+func (t *csvTableWriter) StartTable(name string, columns []string) error {
+	if t.tables > 0 {
+		if err := t.w.Write(nil); err != nil {
+			return err
+		}
+	}
+	t.tables++
+	return t.w.Write(columns)
+}
+
+func (t *csvTableWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		if v := exportValue(value); v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// jsonlTableWriter 每行输出一个JSON对象，字段顺序与列名一致，表名写入 table 字段
+type jsonlTableWriter struct {
+	w       *bufio.Writer
+	name    string
+	columns []string
+}
+
+func (t *jsonlTableWriter) StartTable(name string, columns []string) error {
+	t.name = name
+	t.columns = columns
+	return nil
+}
+
+func (t *jsonlTableWriter) WriteRow(values ...interface{}) error {
+	t.w.WriteByte('{')
+	first := true
+	writeField := func(key string, value interface{}) error {
+		if !first {
+			t.w.WriteByte(',')
+		}
+		first = false
+
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		t.w.Write(k)
+		t.w.WriteByte(':')
+		_, err = t.w.Write(v)
+		return err
+	}
+
+	if t.name != "" {
+		if err := writeField("table", t.name); err != nil {
+			return err
+		}
+	}
+	for i, value := range values {
+		key := fmt.Sprintf("column_%d", i+1)
+		if i < len(t.columns) {
+			key = t.columns[i]
+		}
+		if err := writeField(key, exportValue(value)); err != nil {
+			return err
+		}
+	}
+
+	_, err := t.w.WriteString("}\n")
+	return err
+}
+
+func (t *jsonlTableWriter) Close() error {
+	return t.w.Flush()
+}
+
+// xlsxTableWriter 每张表对应一个工作表，首行为列名
+type xlsxTableWriter struct {
+	x *XLSXWriter
+}
+
+func (t *xlsxTableWriter) StartTable(name string, columns []string) error {
+	if err := t.x.NewSheet(name); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	return t.x.WriteRow(header...)
+}
+
+func (t *xlsxTableWriter) WriteRow(values ...interface{}) error {
+	normalized := make([]interface{}, len(values))
+	for i, value := range values {
+		normalized[i] = exportValue(value)
+	}
+	return t.x.WriteRow(normalized...)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	return t.x.Close()
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readZipEntry 读取zip中指定条目的内容
+func readZipEntry(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("解析XLSX失败: %v", err)
+	}
+	for _, file := range zr.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	t.Fatalf("XLSX中缺少 %s", name)
+	return ""
+}
+
+func TestXLSXWriterStreamsRows(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewTableWriter(ExportXLSX, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.StartTable("history", []string{"id", "source_image", "duration"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 行数足够多时，数据在Close之前已经写入底层Writer，而不是在内存中累积
+	const rows = 20000
+	for i := 0; i < rows; i++ {
+		if err := w.WriteRow(strings.Repeat("x", 32)+string(rune('a'+i%26)), "nginx:<1.25> & \"latest\"", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.Len() == 0 {
+		t.Fatal("Close之前未写出任何数据")
+	}
+	if err := w.StartTable("failure_stats", []string{"error_msg", "count"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(nil, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+	if err := xml.Unmarshal([]byte(sheet), new(interface{})); err != nil {
+		t.Fatalf("工作表不是有效的XML: %v", err)
+	}
+	if n := strings.Count(sheet, "<row "); n != rows+1 {
+		t.Errorf("工作表行数 = %d, want %d", n, rows+1)
+	}
+	if !strings.Contains(sheet, `<t xml:space="preserve">nginx:&lt;1.25&gt; &amp; &#34;latest&#34;</t>`) {
+		t.Error("字符串单元格未转义")
+	}
+	if !strings.Contains(sheet, `<c r="C20001"><v>19999</v></c>`) {
+		t.Error("数值未写为数字单元格")
+	}
+
+	second := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet2.xml")
+	if !strings.Contains(second, `<row r="2"><c r="B2"><v>3</v></c></row>`) {
+		t.Errorf("nil值应写为空单元格: %s", second)
+	}
+	workbook := readZipEntry(t, out.Bytes(), "xl/workbook.xml")
+	if !strings.Contains(workbook, `name="history"`) || !strings.Contains(workbook, `name="failure_stats"`) {
+		t.Errorf("工作簿缺少工作表: %s", workbook)
+	}
+}
+
+func TestCSVTableWriterEscaping(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewTableWriter(ExportCSV, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	completed := time.Date(2025, 3, 1, 8, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	var missing *time.Time
+	errorMsg := "pull failed: \"manifest unknown\",\nretry later"
+	if err := w.StartTable("history", []string{"id", "error_msg", "completed_at", "started_at"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("t1", &errorMsg, &completed, missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.StartTable("stats", []string{"registry", "total"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("harbor.local, prod", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id,error_msg,completed_at,started_at\n" +
+		"t1,\"pull failed: \"\"manifest unknown\"\",\nretry later\",2025-03-01T00:30:00Z,\n" +
+		"\n" +
+		"registry,total\n" +
+		"\"harbor.local, prod\",2\n"
+	if out.String() != want {
+		t.Fatalf("CSV输出 =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// 标准CSV解析器能还原原始值
+	r := csv.NewReader(strings.NewReader(out.String()))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("解析CSV失败: %v", err)
+	}
+	if records[1][1] != errorMsg || records[3][0] != "harbor.local, prod" {
+		t.Fatalf("解析结果 = %q", records)
+	}
+}
@@ -1,22 +1,164 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 全局日志配置：所有Logger共享同一个输出与级别，由ConfigureLogging根据配置设置，
+// 因此在配置加载前创建的Logger（如包级变量）也会使用最终的配置。
+var (
+	logLevel   = new(slog.LevelVar)
+	logHandler atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	setHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+}
+
+// ConfigureLogging 设置全局日志级别（debug/info/warn/error）与格式（json/text）
+func ConfigureLogging(level, format string) error {
+	return ConfigureLoggingOutput(os.Stdout, level, format)
+}
+
+// ConfigureLoggingOutput 与ConfigureLogging相同，但写入指定的输出
+func ConfigureLoggingOutput(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("无效的日志级别: %s（可选 debug/info/warn/error）", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	switch strings.ToLower(format) {
+	case "", "json":
+		setHandler(slog.NewJSONHandler(w, opts))
+	case "text":
+		setHandler(slog.NewTextHandler(w, opts))
+	default:
+		return fmt.Errorf("无效的日志格式: %s（可选 json/text）", format)
+	}
+
+	logLevel.Set(l)
+	return nil
+}
+
+func setHandler(h slog.Handler) {
+	logHandler.Store(&h)
+	// 标准库log包与slog默认Logger的输出同样走全局配置
+	slog.SetDefault(slog.New(sharedHandler{}))
+}
+
+// sharedHandler 每次写日志时委托给当前的全局Handler，使配置变更对已创建的Logger生效
+type sharedHandler struct {
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h sharedHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h sharedHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := *logHandler.Load()
+	for _, wrap := range h.wrap {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h sharedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h sharedHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h sharedHandler) with(wrap func(slog.Handler) slog.Handler) sharedHandler {
+	wraps := make([]func(slog.Handler) slog.Handler, len(h.wrap), len(h.wrap)+1)
+	copy(wraps, h.wrap)
+	return sharedHandler{wrap: append(wraps, wrap)}
+}
+
+// 上下文中携带的关联ID
+type contextKey string
+
+const (
+	requestIDKey contextKey = "request_id"
+	taskIDKey    contextKey = "task_id"
 )
 
+// ContextWithRequestID 在上下文中记录请求ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext 读取上下文中的请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithTaskID 在上下文中记录任务ID
+func ContextWithTaskID(ctx context.Context, taskID string) context.Context {
+	return context.WithValue(ctx, taskIDKey, taskID)
+}
+
+// TaskIDFromContext 读取上下文中的任务ID
+func TaskIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(taskIDKey).(string)
+	return id
+}
+
+// Logger 结构化日志，输出与级别由全局配置决定
 type Logger struct {
-	*log.Logger
-	level string
+	slog *slog.Logger
+}
+
+// NewLogger 创建日志记录器，component 作为每行日志的 component 字段
+func NewLogger(component string) *Logger {
+	logger := slog.New(sharedHandler{})
+	if component != "" {
+		logger = logger.With("component", component)
+	}
+	return &Logger{slog: logger}
+}
+
+// With 返回附加了字段的Logger
+func (l *Logger) With(args ...interface{}) *Logger {
+	return &Logger{slog: l.slog.With(args...)}
 }
 
-func NewLogger(level string) *Logger {
-	return &Logger{
-		Logger: log.New(os.Stdout, "", log.LstdFlags),
-		level:  level,
+// WithContext 返回附加了上下文中请求ID、任务ID与trace ID的Logger
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	var args []interface{}
+	if id := RequestIDFromContext(ctx); id != "" {
+		args = append(args, string(requestIDKey), id)
+	}
+	if id := TaskIDFromContext(ctx); id != "" {
+		args = append(args, string(taskIDKey), id)
 	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		args = append(args, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+	if len(args) == 0 {
+		return l
+	}
+	return l.With(args...)
+}
+
+// Slog 返回底层的slog.Logger，用于需要写入自定义字段的场景
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
 }
 
 // isPollingPath 判断是否为轮询相关的路径
@@ -38,30 +180,38 @@ func isPollingPath(path string) bool {
 }
 
 func (l *Logger) Info(args ...interface{}) {
-	l.Printf("[INFO] %s", fmt.Sprint(args...))
+	l.slog.Info(fmt.Sprint(args...))
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	l.slog.Warn(fmt.Sprint(args...))
 }
 
 func (l *Logger) Error(args ...interface{}) {
-	l.Printf("[ERROR] %s", fmt.Sprint(args...))
+	l.slog.Error(fmt.Sprint(args...))
 }
 
 func (l *Logger) Debug(args ...interface{}) {
-	if l.level == "debug" {
-		l.Printf("[DEBUG] %s", fmt.Sprint(args...))
+	if l.slog.Enabled(context.Background(), slog.LevelDebug) {
+		l.slog.Debug(fmt.Sprint(args...))
 	}
 }
 
 func (l *Logger) Infof(format string, args ...interface{}) {
-	l.Printf("[INFO] "+format, args...)
+	l.slog.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.slog.Warn(fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.Printf("[ERROR] "+format, args...)
+	l.slog.Error(fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	if l.level == "debug" {
-		l.Printf("[DEBUG] "+format, args...)
+	if l.slog.Enabled(context.Background(), slog.LevelDebug) {
+		l.slog.Debug(fmt.Sprintf(format, args...))
 	}
 }
 
@@ -85,11 +235,5 @@ func (l *Logger) InfoPollingSimple(path string, args ...interface{}) {
 
 // DebugSQL SQL查询的调试日志，只在debug模式下显示
 func (l *Logger) DebugSQL(query string, args ...interface{}) {
-	if l.level == "debug" {
-		if len(args) > 0 {
-			l.Printf("[DEBUG] SQL查询: %s, 参数: %v", query, args)
-		} else {
-			l.Printf("[DEBUG] SQL查询: %s", query)
-		}
-	}
+	l.slog.Debug("SQL查询", "query", query, "args", args)
 }
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold 键数量超过该值时清理过期窗口
+const sweepThreshold = 10000
+
+// RateLimitResult 单次限流判定结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time // 当前窗口结束时间
+}
+
+// RetryAfter 距离窗口重置的剩余时间（至少1秒）
+func (r RateLimitResult) RetryAfter() time.Duration {
+	wait := time.Until(r.ResetAt)
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// RateLimiter 基于固定窗口的内存限流器，按键独立计数
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+	mu      sync.Mutex
+}
+
+// NewRateLimiter 创建限流器，limit<=0 表示不限流
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Enabled 是否启用限流
+func (rl *RateLimiter) Enabled() bool {
+	return rl.limit > 0 && rl.window > 0
+}
+
+// Allow 为指定键消耗一次配额
+func (rl *RateLimiter) Allow(key string) RateLimitResult {
+	if !rl.Enabled() {
+		return RateLimitResult{Allowed: true}
+	}
+
+	now := time.Now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if len(rl.windows) > sweepThreshold {
+		for k, w := range rl.windows {
+			if now.After(w.resetAt) {
+				delete(rl.windows, k)
+			}
+		}
+	}
+
+	w, exists := rl.windows[key]
+	if !exists || now.After(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(rl.window)}
+		rl.windows[key] = w
+	}
+
+	result := RateLimitResult{Limit: rl.limit, ResetAt: w.resetAt}
+	if w.count >= rl.limit {
+		return result
+	}
+
+	w.count++
+	result.Allowed = true
+	result.Remaining = rl.limit - w.count
+	return result
+}
+
+// loginFailures 连续失败次数，最后一次失败后经过expiresAt仍无失败时计数作废
+type loginFailures struct {
+	count     int
+	expiresAt time.Time
+}
+
+// LoginGuard 登录失败计数与锁定
+type LoginGuard struct {
+	maxFailures int
+	lockout     time.Duration
+	failures    map[string]*loginFailures
+	lockedUntil map[string]time.Time
+	mu          sync.Mutex
+}
+
+// NewLoginGuard 创建登录保护，连续失败maxFailures次后锁定lockout时长，maxFailures<=0 表示不锁定
+// 失败计数在最后一次失败后lockout时长内有效
+func NewLoginGuard(maxFailures int, lockout time.Duration) *LoginGuard {
+	return &LoginGuard{
+		maxFailures: maxFailures,
+		lockout:     lockout,
+		failures:    make(map[string]*loginFailures),
+		lockedUntil: make(map[string]time.Time),
+	}
+}
+
+// LockedFor 返回键剩余的锁定时长，未锁定时返回0
+func (lg *LoginGuard) LockedFor(key string) time.Duration {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	until, locked := lg.lockedUntil[key]
+	if !locked {
+		return 0
+	}
+
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(lg.lockedUntil, key)
+		return 0
+	}
+	return remaining
+}
+
+// RecordFailure 记录一次失败，达到阈值时锁定并返回true
+func (lg *LoginGuard) RecordFailure(key string) bool {
+	if lg.maxFailures <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	// 只清理过期的记录，避免大量来源IP冲掉其他IP的计数
+	if len(lg.failures) > sweepThreshold {
+		for k, f := range lg.failures {
+			if now.After(f.expiresAt) {
+				delete(lg.failures, k)
+			}
+		}
+	}
+	if len(lg.lockedUntil) > sweepThreshold {
+		for k, until := range lg.lockedUntil {
+			if now.After(until) {
+				delete(lg.lockedUntil, k)
+			}
+		}
+	}
+
+	f, exists := lg.failures[key]
+	if !exists || now.After(f.expiresAt) {
+		f = &loginFailures{}
+		lg.failures[key] = f
+	}
+	f.count++
+	f.expiresAt = now.Add(lg.lockout)
+	if f.count < lg.maxFailures {
+		return false
+	}
+
+	delete(lg.failures, key)
+	lg.lockedUntil[key] = now.Add(lg.lockout)
+	return true
+}
+
+// RecordSuccess 登录成功后清除失败计数
+func (lg *LoginGuard) RecordSuccess(key string) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	delete(lg.failures, key)
+}
+
+// LoginThrottle 按连续失败次数逐次加倍延迟登录响应，不锁定
+//
+// 连续失败超过threshold次后，每次尝试先等待 1s、2s、4s…（不超过maxDelay），
+// 减慢分布式猜测的速度，正确的凭据仍可在等待后登录。
+type LoginThrottle struct {
+	threshold int
+	maxDelay  time.Duration
+	failures  map[string]int
+	mu        sync.Mutex
+}
+
+// NewLoginThrottle 创建登录延迟，threshold<=0 或 maxDelay<=0 表示不延迟
+func NewLoginThrottle(threshold int, maxDelay time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		threshold: threshold,
+		maxDelay:  maxDelay,
+		failures:  make(map[string]int),
+	}
+}
+
+// Delay 返回键下一次尝试前需要等待的时长
+func (lt *LoginThrottle) Delay(key string) time.Duration {
+	if lt.threshold <= 0 || lt.maxDelay <= 0 {
+		return 0
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	excess := lt.failures[key] - lt.threshold
+	if excess < 0 {
+		return 0
+	}
+	if excess >= 30 {
+		return lt.maxDelay
+	}
+	return min(time.Second<<excess, lt.maxDelay)
+}
+
+// RecordFailure 记录一次失败
+func (lt *LoginThrottle) RecordFailure(key string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.failures[key]++
+}
+
+// RecordSuccess 登录成功后清除失败计数
+func (lt *LoginThrottle) RecordSuccess(key string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	delete(lt.failures, key)
+}
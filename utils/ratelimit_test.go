@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardSweepsExpiredLocks(t *testing.T) {
+	lg := NewLoginGuard(1, time.Millisecond)
+	for i := 0; i <= sweepThreshold; i++ {
+		lg.RecordFailure(fmt.Sprintf("ip:%d", i))
+	}
+	if len(lg.lockedUntil) <= sweepThreshold {
+		t.Fatalf("lockedUntil = %d, want > %d", len(lg.lockedUntil), sweepThreshold)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !lg.RecordFailure("ip:new") {
+		t.Fatal("RecordFailure() = false, want 锁定")
+	}
+	if _, ok := lg.lockedUntil["ip:new"]; !ok || len(lg.lockedUntil) != 1 {
+		t.Fatalf("清理后 lockedUntil = %d 项, want 只有新锁定", len(lg.lockedUntil))
+	}
+}
+
+func TestLoginGuardSweepKeepsLiveFailures(t *testing.T) {
+	lg := NewLoginGuard(3, time.Minute)
+	lg.RecordFailure("ip:victim")
+	lg.RecordFailure("ip:victim")
+
+	// 大量来源IP不能冲掉仍有效的计数
+	for i := 0; i <= sweepThreshold+1; i++ {
+		lg.RecordFailure(fmt.Sprintf("ip:%d", i))
+	}
+	if !lg.RecordFailure("ip:victim") {
+		t.Fatal("第3次失败未锁定，计数被清除")
+	}
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	lt := NewLoginThrottle(2, 5*time.Second)
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := lt.Delay("admin"); got != delay {
+			t.Errorf("%d次失败后 Delay() = %v, want %v", i, got, delay)
+		}
+		lt.RecordFailure("admin")
+	}
+
+	lt.RecordSuccess("admin")
+	if got := lt.Delay("admin"); got != 0 {
+		t.Errorf("登录成功后 Delay() = %v, want 0", got)
+	}
+}
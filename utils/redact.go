@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RedactedValue 脱敏后的占位值
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeys 需要脱敏的字段名（小写，按包含关系匹配）
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"private_key",
+	"credential",
+}
+
+// IsSensitiveKey 判断字段名是否属于敏感信息
+func IsSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	// has_password 等布尔标识不含敏感内容
+	if strings.HasPrefix(lower, "has_") {
+		return false
+	}
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(lower, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactValue 递归脱敏任意JSON结构中的敏感字段
+func RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if IsSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+			redacted[key] = RedactValue(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = RedactValue(item)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// RedactJSON 脱敏JSON文本，无法解析的内容不予保留
+func RedactJSON(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ""
+	}
+
+	return MarshalRedacted(value)
+}
+
+// MarshalRedacted 将任意对象脱敏后序列化为JSON文本
+func MarshalRedacted(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	// 先序列化再反序列化，统一转换为通用结构后脱敏
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return ""
+	}
+
+	redacted, err := json.Marshal(RedactValue(generic))
+	if err != nil {
+		return ""
+	}
+
+	return string(redacted)
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // IsPollingRequest 判断是否为轮询请求的全局工具函数
 func IsPollingRequest(path, method string) bool {
@@ -48,3 +52,25 @@ func GetRequestPath(path string) string {
 	}
 	return path
 }
+
+// ParseTimeParam 解析查询参数中的时间，支持RFC3339和YYYY-MM-DD格式
+// endOfDay为true时，日期格式会取当天最后一秒，便于作为区间上限
+func ParseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("时间格式无效: %s（支持RFC3339或YYYY-MM-DD）", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+
+	return &t, nil
+}
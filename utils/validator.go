@@ -12,8 +12,8 @@ func ValidateImageName(image string) error {
 		return fmt.Errorf("镜像名称不能为空")
 	}
 
-	// 基本格式检查：允许 nginx, nginx:tag, registry.com/namespace/image:tag
-	pattern := `^([a-zA-Z0-9\-\.]+([:\d+])?/)?([a-zA-Z0-9\-\_\.]+/)?[a-zA-Z0-9\-\_\.]+(:[\w\-\.]+)?$`
+	// 基本格式检查：允许 nginx, nginx:tag, registry.com/namespace/image:tag，可带 @sha256:<摘要>
+	pattern := `^([a-zA-Z0-9\-\.]+([:\d+])?/)?([a-zA-Z0-9\-\_\.]+/)?[a-zA-Z0-9\-\_\.]+(:[\w\-\.]+)?(@sha256:[a-f0-9]{64})?$`
 	matched, err := regexp.MatchString(pattern, image)
 	if err != nil {
 		return fmt.Errorf("镜像名称格式验证失败: %v", err)
@@ -25,7 +25,13 @@ func ValidateImageName(image string) error {
 	return nil
 }
 
-// ParseImageName 解析镜像名称
+// SplitImageDigest 分离镜像名称中的摘要，如 nginx:1.25@sha256:... -> nginx:1.25, sha256:...
+func SplitImageDigest(image string) (name, digest string) {
+	name, digest, _ = strings.Cut(image, "@")
+	return name, digest
+}
+
+// ParseImageName 解析镜像名称，忽略摘要；未写标签时标签为latest
 func ParseImageName(image string) (registry, namespace, repository, tag string) {
 	// 默认值
 	registry = "docker.io"
@@ -33,6 +39,7 @@ func ParseImageName(image string) (registry, namespace, repository, tag string)
 	tag = "latest"
 
 	// 分离tag
+	image, _ = SplitImageDigest(image)
 	parts := strings.Split(image, ":")
 	imagePart := parts[0]
 	if len(parts) > 1 {
@@ -71,32 +78,66 @@ func ParseImageName(image string) (registry, namespace, repository, tag string)
 	return
 }
 
-// NormalizeImageName 标准化镜像名称
+// NormalizeImageName 标准化镜像名称，带摘要的镜像以摘要代替标签
 func NormalizeImageName(image string) string {
 	registry, namespace, repository, tag := ParseImageName(image)
 
+	reference := ":" + tag
+	if _, digest := SplitImageDigest(image); digest != "" {
+		reference = "@" + digest
+	}
+
 	if registry == "docker.io" && namespace == "library" {
-		return fmt.Sprintf("%s:%s", repository, tag)
+		return fmt.Sprintf("%s%s", repository, reference)
 	} else if registry == "docker.io" {
-		return fmt.Sprintf("%s/%s:%s", namespace, repository, tag)
+		return fmt.Sprintf("%s/%s%s", namespace, repository, reference)
 	} else {
-		return fmt.Sprintf("%s/%s/%s:%s", registry, namespace, repository, tag)
+		return fmt.Sprintf("%s/%s/%s%s", registry, namespace, repository, reference)
 	}
 }
 
-// BuildTargetImageName 构建目标镜像名称
-func BuildTargetImageName(sourceImage, targetHost string) string {
+// BuildTargetImageName 构建目标镜像名称：仓库地址 + 命名空间（可为空）+ 源镜像路径
+func BuildTargetImageName(sourceImage, targetHost, targetNamespace string) string {
 	registry, namespace, repository, tag := ParseImageName(sourceImage)
 
+	prefix := targetHost + "/"
+	if targetNamespace != "" {
+		prefix += targetNamespace + "/"
+	}
+
 	// 构建目标路径
 	if registry == "docker.io" && namespace == "library" {
 		// nginx:latest -> harbor.com/transform/nginx:latest
-		return fmt.Sprintf("%s/transform/%s:%s", targetHost, repository, tag)
+		return fmt.Sprintf("%s%s:%s", prefix, repository, tag)
 	} else if registry == "docker.io" {
 		// user/nginx:latest -> harbor.com/transform/user/nginx:latest
-		return fmt.Sprintf("%s/transform/%s/%s:%s", targetHost, namespace, repository, tag)
+		return fmt.Sprintf("%s%s/%s:%s", prefix, namespace, repository, tag)
 	} else {
 		// gcr.io/google/nginx:latest -> harbor.com/transform/gcr.io/google/nginx:latest
-		return fmt.Sprintf("%s/transform/%s/%s/%s:%s", targetHost, registry, namespace, repository, tag)
+		return fmt.Sprintf("%s%s/%s/%s:%s", prefix, registry, namespace, repository, tag)
+	}
+}
+
+// 目标镜像命名规则
+const (
+	NamingKeep    = "keep"    // 保留源镜像的仓库与命名空间路径，与BuildTargetImageName一致
+	NamingFlatten = "flatten" // 只保留镜像名与标签
+)
+
+// TargetImageName 按命名规则生成目标镜像名称，path非空时直接使用 targetHost/path，不使用命名规则
+func TargetImageName(sourceImage, targetHost, targetNamespace, naming, path string) string {
+	if path != "" {
+		return targetHost + "/" + strings.TrimPrefix(path, "/")
+	}
+
+	if naming == NamingFlatten {
+		// gcr.io/google/nginx:latest -> harbor.com/transform/nginx:latest
+		_, _, repository, tag := ParseImageName(sourceImage)
+		prefix := targetHost + "/"
+		if targetNamespace != "" {
+			prefix += targetNamespace + "/"
+		}
+		return prefix + repository + ":" + tag
 	}
+	return BuildTargetImageName(sourceImage, targetHost, targetNamespace)
 }
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// XLSXWriter 流式写出最小化的XLSX工作簿
+//
+// 每个工作表按行直接写入zip条目，字符串使用内联字符串（inlineStr），
+// 不需要共享字符串表，因此内存占用与行数无关。工作表必须依次写完。
+type XLSXWriter struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	sheets []string
+	row    int
+}
+
+// NewXLSXWriter 创建XLSX写出器
+func NewXLSXWriter(w io.Writer) *XLSXWriter {
+	return &XLSXWriter{zw: zip.NewWriter(w)}
+}
+
+// NewSheet 结束当前工作表并开始一个新的工作表
+func (x *XLSXWriter) NewSheet(name string) error {
+	if err := x.finishSheet(); err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", len(x.sheets)+1)
+	}
+	// Excel工作表名最长31个字符
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	x.sheets = append(x.sheets, name)
+
+	entry, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if err != nil {
+		return err
+	}
+
+	x.sheet = bufio.NewWriter(entry)
+	x.row = 0
+	_, err = x.sheet.WriteString(xml.Header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return err
+}
+
+// WriteRow 写入一行，数值写为数字单元格，其余按字符串写入，nil写为空单元格
+func (x *XLSXWriter) WriteRow(values ...interface{}) error {
+	if x.sheet == nil {
+		if err := x.NewSheet(""); err != nil {
+			return err
+		}
+	}
+
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int, int32, int64, float32, float64:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%v</v></c>`, ref, v)
+		default:
+			fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(x.sheet, []byte(fmt.Sprint(v))); err != nil {
+				return err
+			}
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Close 写出工作簿结构并结束zip
+func (x *XLSXWriter) Close() error {
+	if len(x.sheets) == 0 {
+		if err := x.NewSheet(""); err != nil {
+			return err
+		}
+	}
+	if err := x.finishSheet(); err != nil {
+		return err
+	}
+
+	var contentTypes, workbook, workbookRels bytes.Buffer
+	contentTypes.WriteString(xml.Header +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	workbook.WriteString(xml.Header +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, name := range x.sheets {
+		id := i + 1
+		fmt.Fprintf(&contentTypes,
+			`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, id)
+
+		workbook.WriteString(`<sheet name="`)
+		xml.EscapeText(&workbook, []byte(name))
+		fmt.Fprintf(&workbook, `" sheetId="%d" r:id="rId%d"/>`, id, id)
+
+		fmt.Fprintf(&workbookRels,
+			`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, id, id)
+	}
+
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(xml.Header +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+	}
+
+	for _, file := range files {
+		entry, err := x.zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(file.data); err != nil {
+			return err
+		}
+	}
+
+	return x.zw.Close()
+}
+
+// finishSheet 结束当前工作表
+func (x *XLSXWriter) finishSheet() error {
+	if x.sheet == nil {
+		return nil
+	}
+
+	if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	err := x.sheet.Flush()
+	x.sheet = nil
+	return err
+}
+
+// columnName 将从0开始的列序号转换为Excel列名（A, B, ..., Z, AA, ...）
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
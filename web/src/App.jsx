@@ -6,6 +6,7 @@ import Login from './pages/Login';
 import Dashboard from './pages/Dashboard';
 import { AuthProvider } from './contexts/AuthContext';
 import { TaskProvider } from './contexts/TaskContext';
+import { BASE_PATH } from './utils/basePath';
 import './App.css';
 
 function App() {
@@ -13,7 +14,7 @@ function App() {
     <ConfigProvider locale={zhCN}>
       <AuthProvider>
         <TaskProvider>
-          <Router>
+          <Router basename={BASE_PATH || '/'}>
             <div className="App">
               <Routes>
                 <Route path="/login" element={<Login />} />
@@ -20,7 +20,8 @@ const HistoryList = ({ refreshTrigger }) => {
       ]);
       
       if (historyResponse.data.success) {
-        setHistory(historyResponse.data.data || []);
+        const historyData = historyResponse.data.data || {};
+        setHistory(historyData.items || []);
       }
       
       if (statsResponse.data.success) {
@@ -7,6 +7,7 @@ const TransformForm = ({ onStatusChange, onComplete }) => {
   const [form] = Form.useForm();
   const [loading, setLoading] = useState(false);
   const [parsedImage, setParsedImage] = useState('');
+  const [targetNamespace, setTargetNamespace] = useState('transform');
 
   // 解析镜像名称
   const parseImage = async (imageValue) => {
@@ -18,11 +19,13 @@ const TransformForm = ({ onStatusChange, onComplete }) => {
     try {
       const response = await api.post('/image/parse', { image: imageValue.trim() });
       if (response.data.success) {
+        const namespace = response.data.target_namespace ?? targetNamespace;
         setParsedImage(response.data.parsed_image);
+        setTargetNamespace(namespace);
         // 自动生成目标镜像名称
         const targetHost = form.getFieldValue('target_host');
         if (targetHost) {
-          generateTargetImage(response.data.parsed_image, targetHost);
+          generateTargetImage(response.data.parsed_image, targetHost, namespace);
         }
       }
     } catch (error) {
@@ -32,18 +35,21 @@ const TransformForm = ({ onStatusChange, onComplete }) => {
   };
 
   // 生成目标镜像名称
-  const generateTargetImage = (sourceImage, targetHost) => {
+  const generateTargetImage = (sourceImage, targetHost, namespace = targetNamespace) => {
     if (!sourceImage || !targetHost) return;
-    
+
+    // 命名空间由服务端配置（target_namespace），为空时直接放在仓库根路径
+    const prefix = namespace ? `${targetHost}/${namespace}` : targetHost;
+
     // 移除源镜像中的registry部分（如果有）
     let imageName = sourceImage;
     if (sourceImage.includes('/') && !sourceImage.startsWith('library/')) {
       // 如果包含registry，保留完整路径
-      const targetImage = `${targetHost}/transform/${sourceImage}`;
+      const targetImage = `${prefix}/${sourceImage}`;
       form.setFieldsValue({ target_image: targetImage });
     } else {
       // 标准镜像，只保留名称和标签
-      const targetImage = `${targetHost}/transform/${imageName}`;
+      const targetImage = `${prefix}/${imageName}`;
       form.setFieldsValue({ target_image: targetImage });
     }
   };
@@ -1,6 +1,7 @@
 import React, { createContext, useContext, useState, useEffect } from 'react';
 import { message } from 'antd';
 import api from '../services/api';
+import { withBasePath } from '../utils/basePath';
 
 const AuthContext = createContext();
 
@@ -73,7 +74,7 @@ export const AuthProvider = ({ children }) => {
       
       // 确保跳转到登录页
       setTimeout(() => {
-        window.location.href = '/login';
+        window.location.href = withBasePath('/login');
       }, 500);
     }
   };
@@ -59,7 +59,8 @@ export const TaskProvider = ({ children }) => {
 
       let recent = [];
       if (historyResponse.data.success) {
-        recent = historyResponse.data.data || [];
+        const historyData = historyResponse.data.data || {};
+        recent = historyData.items || [];
       }
 
       setTasks(prev => ({
@@ -241,7 +242,7 @@ export const TaskProvider = ({ children }) => {
       const taskData = await fetchTaskStatus(taskId);
       
       // 检查任务是否完成
-      if (taskData && ['completed', 'failed', 'cancelled'].includes(taskData.status)) {
+      if (taskData && ['completed', 'failed', 'cancelled', 'interrupted'].includes(taskData.status)) {
         message.success(
           taskData.status === 'completed' 
             ? `任务执行成功！耗时 ${taskData.duration} 秒` 
@@ -1,8 +1,9 @@
 import axios from 'axios';
+import { BASE_PATH, withBasePath } from '../utils/basePath';
 
 // 创建axios实例
 const api = axios.create({
-  baseURL: '/api',
+  baseURL: withBasePath('/api'),
   timeout: 300000, // 5分钟超时，因为镜像转换可能需要较长时间
   headers: {
     'Content-Type': 'application/json'
@@ -35,11 +36,11 @@ api.interceptors.response.use(
       localStorage.removeItem('token');
       
       // 检查当前是否已经在登录页，避免无限重定向
-      const currentPath = window.location.pathname;
-      if (currentPath !== '/login' && currentPath !== '/') {
+      const currentPath = window.location.pathname.slice(BASE_PATH.length);
+      if (currentPath !== '/login' && currentPath !== '/' && currentPath !== '') {
         // 只有当前不在登录页时才跳转
         setTimeout(() => {
-          window.location.href = '/login';
+          window.location.href = withBasePath('/login');
         }, 100); // 添加小延迟避免状态冲突
       }
       
@@ -0,0 +1,15 @@
+/**
+ * 部署路径（URL前缀）工具函数
+ */
+
+/**
+ * 服务部署的URL前缀（BASE_PATH），由服务端在返回index.html时注入，部署在根路径时为空字符串
+ */
+export const BASE_PATH = (window.__BASE_PATH__ || '').replace(/\/+$/, '');
+
+/**
+ * 为站内绝对路径加上URL前缀
+ * @param {string} path - 以 / 开头的路径，如 /login
+ * @returns {string} 带前缀的路径
+ */
+export const withBasePath = (path) => `${BASE_PATH}${path}`;
@@ -4,6 +4,8 @@ import react from '@vitejs/plugin-react'
 // https://vite.dev/config/
 export default defineConfig({
   plugins: [react()],
+  // 使用相对路径引用资源，服务端通过<base>标签支持任意URL前缀（BASE_PATH）
+  base: './',
   server: {
     port: 3000,
     host: true,